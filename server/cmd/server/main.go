@@ -1,17 +1,39 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
+	"github.com/myan/handx-server/internal/agent"
+	"github.com/myan/handx-server/internal/complete"
+	"github.com/myan/handx-server/internal/files"
+	"github.com/myan/handx-server/internal/history"
+	"github.com/myan/handx-server/internal/monitor"
+	"github.com/myan/handx-server/internal/notify"
+	"github.com/myan/handx-server/internal/paths"
 	"github.com/myan/handx-server/internal/qrcode"
+	"github.com/myan/handx-server/internal/recording"
+	"github.com/myan/handx-server/internal/remote"
+	"github.com/myan/handx-server/internal/scheduler"
+	"github.com/myan/handx-server/internal/screen"
+	"github.com/myan/handx-server/internal/search"
 	"github.com/myan/handx-server/internal/server"
+	"github.com/myan/handx-server/internal/shell"
+	"github.com/myan/handx-server/internal/sizing"
+	"github.com/myan/handx-server/internal/snapshot"
+	"github.com/myan/handx-server/internal/stream"
 	"github.com/myan/handx-server/internal/tmux"
+	"github.com/myan/handx-server/internal/transcript"
+	"github.com/myan/handx-server/internal/watcher"
+	"github.com/myan/handx-server/internal/zellij"
+	"github.com/myan/handx-server/pkg/protocol"
 	"github.com/spf13/viper"
 )
 
@@ -57,18 +79,259 @@ func main() {
 		log.Printf("Failed to generate QR code: %v", err)
 	}
 
-	// Create tmux manager with history lines from config
+	// Create the session backend with history lines from config
 	historyLines := viper.GetInt("tmux.history_lines")
 	if historyLines <= 0 {
 		historyLines = 10000 // Default
 	}
-	tmuxManager, err := tmux.NewManager(historyLines)
+	backend, err := newBackend(viper.GetString("backend"), historyLines)
 	if err != nil {
-		log.Fatalf("Failed to create tmux manager: %v", err)
+		log.Fatalf("Failed to create %s backend: %v", viper.GetString("backend"), err)
 	}
 
 	// Create WebSocket server
-	wsServer := server.NewServer(tmuxManager)
+	wsServer := server.NewServer(backend)
+	wsServer.SetTokenManager(tokenManager)
+
+	// Data directory for persisted server state
+	dataDir := paths.ExpandHome(viper.GetString("storage.data_dir"))
+
+	// Outbound notifications for users without a connected client
+	var notifier *notify.Notifier
+	if viper.GetBool("notifications.enabled") {
+		notifier = notify.NewNotifier(notify.Options{
+			Retries:    viper.GetInt("notifications.retries"),
+			RetryDelay: viper.GetDuration("notifications.retry_delay"),
+		})
+
+		var sinks []notify.SinkConfig
+		if err := viper.UnmarshalKey("notifications.sinks", &sinks); err != nil {
+			log.Fatalf("Invalid notification sinks: %v", err)
+		}
+		for _, cfg := range sinks {
+			sink, err := notify.NewSink(cfg)
+			if err != nil {
+				log.Fatalf("Invalid notification sink: %v", err)
+			}
+			notifier.AddSink(sink, cfg.Filter)
+		}
+
+		if viper.GetBool("notifications.web_push.enabled") {
+			webPush, err := notify.NewWebPush(filepath.Join(dataDir, "web_push.json"), viper.GetString("notifications.web_push.subject"))
+			if err != nil {
+				log.Fatalf("Failed to set up web push: %v", err)
+			}
+			var filter notify.Filter
+			if err := viper.UnmarshalKey("notifications.web_push", &filter); err != nil {
+				log.Fatalf("Invalid web push filter: %v", err)
+			}
+			notifier.AddSink(webPush, filter)
+			wsServer.SetWebPush(webPush)
+		}
+
+		go notifier.Run()
+	}
+
+	// Create command scheduler
+	if viper.GetBool("scheduler.enabled") {
+		schedulesFile := viper.GetString("scheduler.file")
+		if schedulesFile == "" {
+			schedulesFile = filepath.Join(dataDir, "schedules.json")
+		}
+		sched, err := scheduler.NewScheduler(backend, paths.ExpandHome(schedulesFile), viper.GetInt("scheduler.history_limit"))
+		if err != nil {
+			log.Fatalf("Failed to create scheduler: %v", err)
+		}
+		wsServer.SetScheduler(sched)
+		if notifier != nil {
+			sched.Subscribe(func(schedule protocol.Schedule, run protocol.ScheduleRun) {
+				notifier.Notify(notify.FromScheduleRun(schedule, run))
+			})
+		}
+		go sched.Run()
+	}
+
+	// Workspace templates
+	templatesDir := viper.GetString("templates.dir")
+	if templatesDir == "" {
+		templatesDir = filepath.Join(dataDir, "templates")
+	}
+	wsServer.SetTemplatesDir(paths.ExpandHome(templatesDir))
+
+	// Session snapshots
+	snapshotBackend, canSnapshot := backend.(snapshot.Backend)
+	if viper.GetBool("snapshot.enabled") && !canSnapshot {
+		log.Printf("Session snapshots are not supported by the %s backend, skipping", viper.GetString("backend"))
+	}
+	if viper.GetBool("snapshot.enabled") && canSnapshot {
+		snapshotDir := viper.GetString("snapshot.dir")
+		if snapshotDir == "" {
+			snapshotDir = filepath.Join(dataDir, "snapshots")
+		}
+		snapshotter := snapshot.NewSnapshotter(snapshotBackend, paths.ExpandHome(snapshotDir), snapshot.Options{
+			Interval:        viper.GetDuration("snapshot.interval"),
+			Scrollback:      viper.GetBool("snapshot.scrollback"),
+			ScrollbackLines: viper.GetInt("snapshot.scrollback_lines"),
+			RestoreCommands: viper.GetStringSlice("snapshot.restore_commands"),
+		})
+		wsServer.SetSnapshotter(snapshotter)
+
+		if viper.GetBool("snapshot.restore_on_start") {
+			if result, err := snapshotter.Restore(nil); err != nil {
+				log.Printf("Failed to restore sessions: %v", err)
+			} else {
+				log.Printf("Restored %d sessions from snapshot", len(result.Restored))
+			}
+		}
+		go snapshotter.Run()
+	}
+
+	// Pane recording
+	recordingBackend, canRecord := backend.(recording.Backend)
+	if viper.GetBool("recording.enabled") && !canRecord {
+		log.Printf("Pane recording is not supported by the %s backend, skipping", viper.GetString("backend"))
+	}
+	if viper.GetBool("recording.enabled") && canRecord {
+		recordingsDir := viper.GetString("recording.dir")
+		if recordingsDir == "" {
+			recordingsDir = filepath.Join(dataDir, "recordings")
+		}
+		recorder, err := recording.NewRecorder(recordingBackend, paths.ExpandHome(recordingsDir), viper.GetDuration("recording.max_duration"))
+		if err != nil {
+			log.Fatalf("Failed to create recorder: %v", err)
+		}
+		wsServer.SetRecorder(recorder)
+	}
+
+	// Command history and snippets
+	var historyStore *history.Store
+	if viper.GetBool("history.enabled") {
+		historyFile := viper.GetString("history.file")
+		if historyFile == "" {
+			historyFile = filepath.Join(dataDir, "history.json")
+		}
+		historyStore, err = history.NewStore(paths.ExpandHome(historyFile), viper.GetInt("history.max_entries"))
+		if err != nil {
+			log.Fatalf("Failed to load command history: %v", err)
+		}
+
+		snippetsFile := viper.GetString("history.snippets_file")
+		if snippetsFile == "" {
+			snippetsFile = filepath.Join(dataDir, "snippets.json")
+		}
+		snippets, err := history.NewSnippets(paths.ExpandHome(snippetsFile))
+		if err != nil {
+			log.Fatalf("Failed to load snippets: %v", err)
+		}
+		wsServer.SetHistory(historyStore, snippets)
+	}
+
+	// Completion, including recent commands when the history is enabled
+	wsServer.SetCompleter(complete.NewCompleter(historyStore))
+
+	// Transcript export
+	if viper.GetBool("transcript.enabled") {
+		wsServer.SetExporter(transcript.NewExporter(backend, viper.GetDuration("transcript.link_ttl"), historyLines))
+	}
+
+	// File access within the configured roots
+	if viper.GetBool("files.enabled") {
+		roots := viper.GetStringSlice("files.roots")
+		for i, root := range roots {
+			roots[i] = paths.ExpandHome(root)
+		}
+		browser, err := files.NewBrowser(files.Options{
+			Roots:         roots,
+			MaxReadBytes:  viper.GetInt64("files.max_read_bytes"),
+			MaxWriteBytes: viper.GetInt64("files.max_write_bytes"),
+		})
+		if err != nil {
+			log.Fatalf("Failed to set up file access: %v", err)
+		}
+		wsServer.SetFileBrowser(browser)
+	}
+
+	// Sizing sessions to client viewports
+	sizingBackend, canSize := backend.(sizing.Backend)
+	if viper.GetBool("sizing.enabled") && !canSize {
+		log.Printf("Sizing is not supported by the %s backend, skipping", viper.GetString("backend"))
+	}
+	if viper.GetBool("sizing.enabled") && canSize {
+		sizer, err := sizing.NewSizer(sizingBackend, viper.GetString("sizing.policy"))
+		if err != nil {
+			log.Fatalf("Invalid sizing configuration: %v", err)
+		}
+		wsServer.SetSizer(sizer)
+	}
+
+	// Attaching as tmux clients in pseudo terminals
+	_, usingTmux := backend.(*tmux.Manager)
+	wsServer.SetAttachEnabled(viper.GetBool("attach.enabled") && usingTmux)
+
+	// Pane output stream shared by the features that watch pane output
+	streamer := stream.NewStreamer(backend, viper.GetDuration("tmux.capture_interval"))
+
+	// Pane monitoring
+	monitorBackend, canMonitor := backend.(monitor.Backend)
+	if viper.GetBool("monitor.enabled") && !canMonitor {
+		log.Printf("Pane monitoring is not supported by the %s backend, skipping", viper.GetString("backend"))
+	}
+	if viper.GetBool("monitor.enabled") && canMonitor {
+		paneMonitor := monitor.NewMonitor(monitorBackend, streamer, monitor.Options{
+			Bell:          viper.GetBool("monitor.bell"),
+			ActivityAfter: viper.GetDuration("monitor.activity_after"),
+			SilenceAfter:  viper.GetDuration("monitor.silence_after"),
+			AlertLines:    viper.GetInt("monitor.alert_lines"),
+		})
+		wsServer.SetMonitor(paneMonitor)
+		if notifier != nil {
+			paneMonitor.Subscribe(func(alert protocol.PaneAlertPayload) {
+				notifier.Notify(notify.FromPaneAlert(alert))
+			})
+		}
+		go paneMonitor.Run()
+	}
+
+	// Output watchers
+	if viper.GetBool("watchers.enabled") {
+		watchersFile := viper.GetString("watchers.file")
+		if watchersFile == "" {
+			watchersFile = filepath.Join(dataDir, "watchers.json")
+		}
+		registry, err := watcher.NewRegistry(streamer, paths.ExpandHome(watchersFile))
+		if err != nil {
+			log.Fatalf("Failed to create output watchers: %v", err)
+		}
+		wsServer.SetWatchers(registry)
+		if notifier != nil {
+			registry.Subscribe(func(match protocol.WatcherMatchPayload) {
+				notifier.Notify(notify.FromWatcherMatch(match))
+			})
+		}
+	}
+
+	// AI agent prompt detection
+	if viper.GetBool("agent.enabled") {
+		detector := agent.NewDetector(backend, streamer, viper.GetDuration("agent.idle_after"))
+		wsServer.SetAgentDetector(detector)
+		if notifier != nil {
+			detector.Subscribe(func(state protocol.AgentStatePayload) {
+				if n, ok := notify.FromAgentState(state); ok {
+					notifier.Notify(n)
+				}
+			})
+		}
+		go detector.Run()
+	}
+
+	// Output search, optionally served from an index of streamed output
+	var searchIndex *search.Index
+	if viper.GetBool("search.index") {
+		searchIndex = search.NewIndex(backend, streamer, viper.GetInt("search.index_lines"))
+	}
+	wsServer.SetSearcher(search.NewSearcher(backend, searchIndex, historyLines))
+
+	go streamer.Run()
 
 	// Start server hub
 	go wsServer.Run()
@@ -96,6 +359,83 @@ func main() {
 	<-quit
 
 	log.Println("Shutting down server...")
+	if historyStore != nil {
+		historyStore.Flush()
+	}
+}
+
+// newBackend creates the session backend of a kind: tmux, which falls back
+// to plain shells when tmux is not installed, ssh, shell, screen or zellij
+func newBackend(kind string, historyLines int) (server.Backend, error) {
+	shellOptions := shell.Options{
+		Shell:        viper.GetString("shell.path"),
+		HistoryLines: historyLines,
+	}
+
+	switch kind {
+	case "tmux", "":
+		hosts, err := remoteHosts()
+		if err != nil {
+			return nil, err
+		}
+		manager, err := tmux.NewManager(tmux.Options{
+			HistoryLines: historyLines,
+			Sockets:      viper.GetStringSlice("tmux.sockets"),
+			Discover:     viper.GetBool("tmux.discover_sockets"),
+			Hosts:        hosts,
+		})
+		if errors.Is(err, tmux.ErrNotInstalled) {
+			log.Printf("tmux is not installed, running plain shells instead")
+			return shell.NewManager(shellOptions)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return manager, nil
+	case "ssh":
+		hosts, err := remoteHosts()
+		if err != nil {
+			return nil, err
+		}
+		return tmux.NewManager(tmux.Options{
+			HistoryLines: historyLines,
+			Hosts:        hosts,
+			RemoteOnly:   true,
+		})
+	case "shell":
+		return shell.NewManager(shellOptions)
+	case "screen":
+		return screen.NewManager(historyLines)
+	case "zellij":
+		return zellij.NewManager(historyLines)
+	default:
+		return nil, fmt.Errorf("unknown backend '%s'", kind)
+	}
+}
+
+// remoteHosts creates the hosts of the ssh.hosts config list
+func remoteHosts() ([]tmux.Runner, error) {
+	var configs []remote.HostConfig
+	if err := viper.UnmarshalKey("ssh.hosts", &configs); err != nil {
+		return nil, fmt.Errorf("invalid ssh hosts: %w", err)
+	}
+
+	opts := remote.Options{
+		KnownHosts:     paths.ExpandHome(viper.GetString("ssh.known_hosts")),
+		ConnectTimeout: viper.GetDuration("ssh.connect_timeout"),
+		Keepalive:      viper.GetDuration("ssh.keepalive"),
+		MaxSessions:    viper.GetInt("ssh.max_sessions"),
+	}
+	hosts := make([]tmux.Runner, 0, len(configs))
+	for _, cfg := range configs {
+		cfg.KeyFile = paths.ExpandHome(cfg.KeyFile)
+		host, err := remote.NewHost(cfg, opts)
+		if err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts, nil
 }
 
 func loadConfig() {
@@ -109,7 +449,52 @@ func loadConfig() {
 	viper.SetDefault("server.port", 8080)
 	viper.SetDefault("security.token_lifetime", "1h")
 	viper.SetDefault("tmux.history_lines", 10000)
+	viper.SetDefault("tmux.capture_interval", "500ms")
+	viper.SetDefault("tmux.sockets", []string{})
+	viper.SetDefault("tmux.discover_sockets", false)
+	viper.SetDefault("ssh.known_hosts", "~/.ssh/known_hosts")
+	viper.SetDefault("ssh.connect_timeout", "10s")
+	viper.SetDefault("ssh.keepalive", "30s")
+	viper.SetDefault("ssh.max_sessions", 8)
+	viper.SetDefault("backend", "tmux")
 	viper.SetDefault("cors.allowed_origins", []string{"http://localhost:3000"})
+	viper.SetDefault("storage.data_dir", "~/.handx")
+	viper.SetDefault("scheduler.enabled", true)
+	viper.SetDefault("scheduler.history_limit", 50)
+	viper.SetDefault("snapshot.enabled", true)
+	viper.SetDefault("snapshot.interval", "5m")
+	viper.SetDefault("snapshot.scrollback", false)
+	viper.SetDefault("snapshot.scrollback_lines", 2000)
+	viper.SetDefault("snapshot.restore_commands", []string{"vim", "nvim", "less", "more", "tail", "top", "htop", "man"})
+	viper.SetDefault("snapshot.restore_on_start", false)
+	viper.SetDefault("monitor.enabled", true)
+	viper.SetDefault("monitor.bell", true)
+	viper.SetDefault("monitor.activity_after", "0s")
+	viper.SetDefault("monitor.silence_after", "0s")
+	viper.SetDefault("monitor.alert_lines", 5)
+	viper.SetDefault("watchers.enabled", true)
+	viper.SetDefault("agent.enabled", true)
+	viper.SetDefault("agent.idle_after", "10s")
+	viper.SetDefault("search.index", false)
+	viper.SetDefault("search.index_lines", 10000)
+	viper.SetDefault("recording.enabled", true)
+	viper.SetDefault("recording.max_duration", "2h")
+	viper.SetDefault("history.enabled", true)
+	viper.SetDefault("history.max_entries", 5000)
+	viper.SetDefault("transcript.enabled", true)
+	viper.SetDefault("transcript.link_ttl", "5m")
+	viper.SetDefault("files.enabled", false)
+	viper.SetDefault("files.roots", []string{"~"})
+	viper.SetDefault("files.max_read_bytes", 1<<20)
+	viper.SetDefault("files.max_write_bytes", 100<<20)
+	viper.SetDefault("sizing.enabled", false)
+	viper.SetDefault("sizing.policy", "latest")
+	viper.SetDefault("attach.enabled", true)
+	viper.SetDefault("notifications.enabled", true)
+	viper.SetDefault("notifications.retries", 3)
+	viper.SetDefault("notifications.retry_delay", "2s")
+	viper.SetDefault("notifications.web_push.enabled", true)
+	viper.SetDefault("notifications.web_push.subject", "mailto:handx@localhost")
 
 	if err := viper.ReadInConfig(); err != nil {
 		log.Printf("Config file not found, using defaults: %v", err)
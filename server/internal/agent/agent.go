@@ -0,0 +1,283 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/myan/handx-server/internal/stream"
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// Backend is the subset of the tmux manager used to answer prompts
+type Backend interface {
+	SendToPane(paneID, text string, enter bool) error
+}
+
+// Errors returned by Respond for requests that can't be answered
+var (
+	ErrNotWaiting    = errors.New("pane is not waiting for input")
+	ErrInvalidOption = errors.New("invalid option")
+)
+
+// paneState is the last detected state of a pane
+type paneState struct {
+	payload    protocol.AgentStatePayload
+	submit     bool                        // Prompt options are confirmed with Enter
+	answered   *protocol.AgentStatePayload // Prompt answered through Respond
+	reported   bool                        // payload was published
+	tracked    bool                        // The pane looks like it runs an agent
+	lastOutput time.Time                   // When the pane last printed
+}
+
+// Detector recognizes AI agent prompts, activity and errors in pane output
+// and publishes agent state changes
+// Prompts are reported for every pane; running, errored and idle only for
+// panes that showed something agent specific like a thinking indicator, so
+// plain shells stay quiet.
+type Detector struct {
+	backend     Backend
+	streamer    *stream.Streamer
+	idleAfter   time.Duration
+	mu          sync.Mutex
+	panes       map[string]*paneState // Keyed by pane ID
+	subscribers []func(protocol.AgentStatePayload)
+}
+
+// NewDetector creates a detector fed by the output of streamer; running
+// agents count as idle after idleAfter without output
+func NewDetector(backend Backend, streamer *stream.Streamer, idleAfter time.Duration) *Detector {
+	if idleAfter <= 0 {
+		idleAfter = 10 * time.Second
+	}
+
+	d := &Detector{
+		backend:   backend,
+		streamer:  streamer,
+		idleAfter: idleAfter,
+		panes:     make(map[string]*paneState),
+	}
+	streamer.Subscribe(d.handleOutput)
+
+	return d
+}
+
+// Subscribe registers fn to be called for every state change
+// fn runs on a detector goroutine and must not block.
+func (d *Detector) Subscribe(fn func(protocol.AgentStatePayload)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.subscribers = append(d.subscribers, fn)
+}
+
+// Run marks quiet agents idle and forgets closed panes until the process exits
+func (d *Detector) Run() {
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		d.checkIdle(now)
+	}
+}
+
+// States returns the current state of every pane with a known agent state
+func (d *Detector) States() []protocol.AgentStatePayload {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	result := make([]protocol.AgentStatePayload, 0, len(d.panes))
+	for _, state := range d.panes {
+		if state.tracked || state.payload.State == protocol.AgentWaitingForInput {
+			result = append(result, state.payload)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.SessionName != b.SessionName {
+			return a.SessionName < b.SessionName
+		}
+		if a.WindowIndex != b.WindowIndex {
+			return a.WindowIndex < b.WindowIndex
+		}
+		return a.PaneIndex < b.PaneIndex
+	})
+
+	return result
+}
+
+// Respond answers the prompt a pane is waiting on with one of its options
+// or free text and returns the input sent
+func (d *Detector) Respond(location protocol.PaneLocation, option *int, text string) (string, error) {
+	if (option == nil) == (text == "") {
+		return "", fmt.Errorf("%w: either an option or text is required", ErrInvalidOption)
+	}
+
+	d.mu.Lock()
+	state, ok := d.panes[location.PaneID]
+	if !ok || state.payload.State != protocol.AgentWaitingForInput {
+		d.mu.Unlock()
+		return "", fmt.Errorf("%w: %s:%d.%d", ErrNotWaiting, location.SessionName, location.WindowIndex, location.PaneIndex)
+	}
+
+	input, enter := text, true
+	if option != nil {
+		options := state.payload.Options
+		if *option < 0 || *option >= len(options) {
+			d.mu.Unlock()
+			return "", fmt.Errorf("%w: %d, the prompt has %d options", ErrInvalidOption, *option, len(options))
+		}
+		input, enter = options[*option].Key, state.submit
+	}
+	answered := state.payload
+	d.mu.Unlock()
+
+	if err := d.backend.SendToPane(location.PaneID, input, enter); err != nil {
+		return "", err
+	}
+
+	d.update(location, detection{state: protocol.AgentRunning}, time.Now())
+
+	// The prompt may stay on screen after it was answered, e.g. when the
+	// program doesn't redraw, so don't report it again
+	d.mu.Lock()
+	state.answered = &answered
+	d.mu.Unlock()
+	return input, nil
+}
+
+// handleOutput analyzes the screen of a pane that printed something
+func (d *Detector) handleOutput(output stream.Output) {
+	screen := d.streamer.Screen(output.PaneID, choiceLines)
+	d.update(output.PaneLocation, detect(screen), output.Time)
+}
+
+// update records the detected state of a pane and publishes it if it changed
+func (d *Detector) update(location protocol.PaneLocation, detected detection, now time.Time) {
+	d.mu.Lock()
+	state, ok := d.panes[location.PaneID]
+	if !ok {
+		state = &paneState{}
+		d.panes[location.PaneID] = state
+	}
+	state.lastOutput = now
+	state.tracked = state.tracked || detected.agentLike
+
+	if state.answered != nil {
+		if detected.state == protocol.AgentWaitingForInput && samePrompt(*state.answered, detected) {
+			detected = detection{state: protocol.AgentRunning}
+		} else {
+			state.answered = nil
+		}
+	}
+
+	payload := protocol.AgentStatePayload{
+		PaneLocation: location,
+		State:        detected.state,
+		PromptType:   detected.promptType,
+		Prompt:       detected.prompt,
+		Options:      detected.options,
+		Tool:         detected.tool,
+		Error:        detected.err,
+		ChangedAt:    state.payload.ChangedAt,
+	}
+	previous := state.payload.State
+	changed := !samePayload(state.payload, payload)
+	if changed {
+		payload.ChangedAt = now.UnixMilli()
+	}
+	state.payload = payload
+	state.submit = detected.submit
+
+	// Panes that aren't agents only report prompts and their end; the state
+	// of a pane that just turned out to be an agent may not be reported yet
+	eligible := state.tracked || payload.State == protocol.AgentWaitingForInput || previous == protocol.AgentWaitingForInput
+	publish := eligible && (changed || !state.reported)
+	state.reported = publish || (state.reported && !changed)
+	d.mu.Unlock()
+
+	if publish {
+		d.publish(payload)
+	}
+}
+
+// checkIdle marks running agents that stopped printing as idle
+func (d *Detector) checkIdle(now time.Time) {
+	// Forget panes that no longer exist
+	live := make(map[string]bool)
+	for _, location := range d.streamer.Panes() {
+		live[location.PaneID] = true
+	}
+
+	idle := make([]protocol.AgentStatePayload, 0)
+
+	d.mu.Lock()
+	for id, state := range d.panes {
+		if !live[id] {
+			delete(d.panes, id)
+			continue
+		}
+		if !state.tracked || state.payload.State != protocol.AgentRunning {
+			continue
+		}
+		if now.Sub(state.lastOutput) >= d.idleAfter {
+			state.payload = protocol.AgentStatePayload{
+				PaneLocation: state.payload.PaneLocation,
+				State:        protocol.AgentIdle,
+				ChangedAt:    now.UnixMilli(),
+			}
+			state.reported = true
+			idle = append(idle, state.payload)
+		}
+	}
+	d.mu.Unlock()
+
+	for _, payload := range idle {
+		d.publish(payload)
+	}
+}
+
+// publish sends a state change to all subscribers
+func (d *Detector) publish(payload protocol.AgentStatePayload) {
+	log.Printf("Agent in %s:%d.%d is %s", payload.SessionName, payload.WindowIndex, payload.PaneIndex, payload.State)
+
+	d.mu.Lock()
+	subscribers := d.subscribers
+	d.mu.Unlock()
+
+	for _, fn := range subscribers {
+		fn(payload)
+	}
+}
+
+// samePrompt reports whether a detected prompt is the one in state
+func samePrompt(state protocol.AgentStatePayload, detected detection) bool {
+	return samePayload(state, protocol.AgentStatePayload{
+		PaneLocation: state.PaneLocation,
+		State:        detected.state,
+		PromptType:   detected.promptType,
+		Prompt:       detected.prompt,
+		Options:      detected.options,
+		Error:        detected.err,
+	})
+}
+
+// samePayload reports whether two states describe the same situation
+// The tool isn't compared so agents running one tool after another don't
+// flood clients with events.
+func samePayload(a, b protocol.AgentStatePayload) bool {
+	if a.PaneLocation != b.PaneLocation || a.State != b.State || a.PromptType != b.PromptType ||
+		a.Prompt != b.Prompt || a.Error != b.Error || len(a.Options) != len(b.Options) {
+		return false
+	}
+	for i := range a.Options {
+		if a.Options[i] != b.Options[i] {
+			return false
+		}
+	}
+	return true
+}
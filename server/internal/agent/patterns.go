@@ -0,0 +1,260 @@
+package agent
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// How far up the screen each pattern is looked for
+const (
+	yesNoLines    = 5
+	choiceLines   = 15
+	thinkingLines = 5
+	errorLines    = 3
+	toolLines     = 3
+
+	// Lines that may follow a menu besides box borders, e.g. a wrapped
+	// option or a hint on how to answer
+	menuFooterLines = 2
+)
+
+var (
+	// Claude Code style confirmations; like all questions they must end
+	// their line, as anything after them is the answer
+	claudeYesNoRegex = regexp.MustCompile(`(?i)(?:Do you want to|Would you like to|Should I|Apply this|Proceed with).*\?\s*(?:\(y/n\)|\[y/n\]|yes/no)\s*:?$`)
+
+	// Gemini CLI style confirmations
+	geminiConfirmRegex = regexp.MustCompile(`(?i)(?:Press Enter to|Confirm|Accept changes|Apply|Continue)\s*(?:\(yes/no\)|\[Y/n\])\s*:?$`)
+
+	// Plain confirmations ending the screen
+	yesNoSuffixRegex = regexp.MustCompile(`(?i)(?:\(y/n\)|\[y/n\]|\[yes/no\]|\(yes/no\))\s*:?$`)
+
+	// Menus listing [1] options
+	bracketOptionRegex = regexp.MustCompile(`^\[(\d+)\]\s+(.+)$`)
+
+	// Menus listing 1. options with a cursor, e.g. "❯ 1. Yes"
+	cursorOptionRegex = regexp.MustCompile(`^([❯›>]\s*)?(\d+)\.\s+(.+)$`)
+
+	thinkingRegex = regexp.MustCompile(`(?i)(?:Thinking|Analyzing|Processing|Generating)(?:\.{2,}|…)|esc to interrupt`)
+	errorRegex    = regexp.MustCompile(`(?i)(?:Error|Failed|Exception|Traceback):\s*(.+)`)
+	toolRegex     = regexp.MustCompile("(?i)(?:Running|Executing|Using)\\s+(?:tool\\s+)?[`']?(\\w+)[`']?")
+)
+
+// detection is what the screen of a pane shows
+type detection struct {
+	state      string
+	promptType string
+	prompt     string
+	options    []protocol.PromptOption
+	submit     bool // Options are confirmed with Enter
+	tool       string
+	err        string
+	agentLike  bool // Something only an agent prints was seen
+}
+
+// detect analyzes the lines at the bottom of a pane's screen
+// What is lowest on the screen is the most recent, so it wins; on the same
+// line prompts take precedence over thinking, errors and tool runs.
+func detect(screen []string) detection {
+	lines := make([]string, len(screen))
+	for i, line := range screen {
+		lines[i] = cleanLine(line)
+	}
+
+	detectors := []func([]string) (detection, int, bool){
+		detectYesNo,
+		detectChoice,
+		detectThinking,
+		detectError,
+		detectTool,
+	}
+
+	result := detection{state: protocol.AgentRunning}
+	best := -1
+	for _, fn := range detectors {
+		if d, line, ok := fn(lines); ok && line > best {
+			result, best = d, line
+		}
+	}
+	return result
+}
+
+// detectThinking looks for a thinking indicator
+func detectThinking(lines []string) (detection, int, bool) {
+	if _, line := lastMatch(thinkingRegex, lines, thinkingLines); line >= 0 {
+		return detection{state: protocol.AgentRunning, agentLike: true}, line, true
+	}
+	return detection{}, 0, false
+}
+
+// detectError looks for an error message
+func detectError(lines []string) (detection, int, bool) {
+	if m, line := lastMatch(errorRegex, lines, errorLines); line >= 0 {
+		return detection{state: protocol.AgentErrored, err: strings.TrimSpace(m[1])}, line, true
+	}
+	return detection{}, 0, false
+}
+
+// detectTool looks for a tool being run
+func detectTool(lines []string) (detection, int, bool) {
+	if m, line := lastMatch(toolRegex, lines, toolLines); line >= 0 {
+		return detection{state: protocol.AgentRunning, tool: m[1], agentLike: true}, line, true
+	}
+	return detection{}, 0, false
+}
+
+// detectYesNo looks for a yes/no question near the bottom of the screen
+func detectYesNo(lines []string) (detection, int, bool) {
+	question, line := "", -1
+	for _, re := range []*regexp.Regexp{claudeYesNoRegex, geminiConfirmRegex} {
+		if m, i := lastMatch(re, lines, yesNoLines); i >= 0 {
+			question, line = strings.TrimSpace(m[0]), i
+			break
+		}
+	}
+	if line < 0 {
+		// Any line ending with a plain confirmation asks the question
+		if _, i := lastMatch(yesNoSuffixRegex, lines, yesNoLines); i >= 0 {
+			question, line = lines[i], i
+		}
+	}
+	if line < 0 {
+		return detection{}, 0, false
+	}
+
+	yes, no := "y", "n"
+	if strings.Contains(strings.ToLower(question), "yes/no") {
+		yes, no = "yes", "no"
+	}
+
+	return detection{
+		state:      protocol.AgentWaitingForInput,
+		promptType: protocol.PromptYesNo,
+		prompt:     question,
+		options: []protocol.PromptOption{
+			{Key: yes, Label: "Yes"},
+			{Key: no, Label: "No"},
+		},
+		submit: true,
+	}, line, true
+}
+
+// detectChoice looks for a numbered menu near the bottom of the screen,
+// either "[1] option" lines or "1. option" lines with a selection cursor
+// Options must be numbered from 1 without gaps; when a line was redrawn
+// the latest version of an option wins.
+func detectChoice(lines []string) (detection, int, bool) {
+	recent := tail(lines, choiceLines)
+	offset := len(lines) - len(recent)
+
+	type option struct {
+		number int
+		label  string
+		line   int
+	}
+	options := make([]option, 0)
+	cursor := false
+	bracket := false
+
+	for i, line := range recent {
+		number, label := 0, ""
+		if m := bracketOptionRegex.FindStringSubmatch(line); m != nil {
+			number, _ = strconv.Atoi(m[1])
+			label = m[2]
+			bracket = true
+		} else if m := cursorOptionRegex.FindStringSubmatch(line); m != nil {
+			number, _ = strconv.Atoi(m[2])
+			label = m[3]
+			if m[1] != "" {
+				cursor = true
+			}
+		} else {
+			continue
+		}
+
+		// A new menu starting at 1 replaces anything listed above it
+		if number == 1 {
+			options = options[:0]
+		}
+		options = append(options, option{number: number, label: strings.TrimSpace(label), line: i})
+	}
+
+	if len(options) < 2 || (!bracket && !cursor) {
+		return detection{}, 0, false
+	}
+	for i, o := range options {
+		if o.number != i+1 {
+			return detection{}, 0, false
+		}
+	}
+
+	// Anything more below the menu means it was answered or abandoned
+	footer := 0
+	for _, line := range recent[options[len(options)-1].line+1:] {
+		if !isBorder(line) {
+			footer++
+		}
+	}
+	if footer > menuFooterLines {
+		return detection{}, 0, false
+	}
+
+	// The question is the closest text above the menu
+	prompt := ""
+	for i := options[0].line - 1; i >= 0; i-- {
+		if recent[i] != "" {
+			prompt = recent[i]
+			break
+		}
+	}
+
+	result := detection{
+		state:      protocol.AgentWaitingForInput,
+		promptType: protocol.PromptChoice,
+		prompt:     prompt,
+		options:    make([]protocol.PromptOption, 0, len(options)),
+		// Cursor menus act on the number key alone
+		submit: !cursor,
+	}
+	for _, o := range options {
+		result.options = append(result.options, protocol.PromptOption{Key: strconv.Itoa(o.number), Label: o.label})
+	}
+	return result, offset + options[len(options)-1].line, true
+}
+
+// cleanLine trims whitespace and the borders of boxes agents draw around prompts
+func cleanLine(line string) string {
+	return strings.TrimSpace(strings.Trim(strings.TrimSpace(line), "│┃|"))
+}
+
+// isBorder reports whether line is empty or only draws a box
+func isBorder(line string) bool {
+	for _, r := range line {
+		if r != ' ' && (r < 0x2500 || r > 0x257f) {
+			return false
+		}
+	}
+	return true
+}
+
+// tail returns at most n trailing lines
+func tail(lines []string, n int) []string {
+	if len(lines) <= n {
+		return lines
+	}
+	return lines[len(lines)-n:]
+}
+
+// lastMatch returns the submatches and index of the last of the trailing
+// n lines that re matches, or -1 if none does
+func lastMatch(re *regexp.Regexp, lines []string, n int) ([]string, int) {
+	for i := len(lines) - 1; i >= 0 && i >= len(lines)-n; i-- {
+		if m := re.FindStringSubmatch(lines[i]); m != nil {
+			return m, i
+		}
+	}
+	return nil, -1
+}
@@ -0,0 +1,365 @@
+package artifact
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// Smaller blocks are rarely worth a card of their own
+const (
+	minCodeLength = 50
+	minDiffLength = 20
+	minJSONLength = 20
+)
+
+// maxJSONLines bounds how far a JSON document is followed
+const maxJSONLines = 500
+
+var (
+	fenceRegex     = regexp.MustCompile("^\\s*(```+|~~~+)\\s*([\\w+#.-]*)")
+	headingRegex   = regexp.MustCompile(`^#{1,6}\s+\S`)
+	tableRowRegex  = regexp.MustCompile(`^\s*\|.*\|\s*$`)
+	tableRuleRegex = regexp.MustCompile(`^\s*\|?\s*:?-{3,}:?\s*(\|\s*:?-{3,}:?\s*)*\|?\s*$`)
+	hunkRegex      = regexp.MustCompile(`^@@ -\d+(,\d+)? \+\d+(,\d+)? @@`)
+
+	// Shell prompts like user@host:~/src$, where printed markdown ends
+	shellPromptRegex = regexp.MustCompile(`^[\w.-]+@[\w.-]+[: ].*[$#%] `)
+	// Bare root prompts like "# make install", which look like level 1
+	// headings; headings rarely start with a lowercase word
+	rootPromptRegex = regexp.MustCompile(`^# [a-z0-9_./~-]+( |$)`)
+)
+
+// Extract finds code blocks, diffs, JSON documents and markdown in lines of
+// terminal output, ordered by where they start
+// Line numbers are 1-based indexes into lines; for fenced blocks they span
+// the content. Diffs and JSON inside fenced code blocks are reported with the
+// block's language, and markdown sections may contain code blocks.
+func Extract(lines []string) []protocol.Artifact {
+	artifacts := make([]protocol.Artifact, 0)
+
+	// Fenced blocks first, their content isn't looked at again
+	fenced := make([]bool, len(lines))
+	for i := 0; i < len(lines); i++ {
+		m := fenceRegex.FindStringSubmatch(lines[i])
+		if m == nil {
+			continue
+		}
+		end := closingFence(lines, i+1, m[1])
+		if end < 0 {
+			// Still being printed or scrolled out
+			continue
+		}
+		for j := i; j <= end; j++ {
+			fenced[j] = true
+		}
+		if a, ok := codeBlock(lines, i, end, strings.ToLower(m[2])); ok {
+			artifacts = append(artifacts, a)
+		}
+		i = end
+	}
+
+	for i := 0; i < len(lines); i++ {
+		if fenced[i] {
+			continue
+		}
+		if end, ok := diffEnd(lines, fenced, i); ok {
+			if a, ok := diffArtifact(lines, i, end, "diff"); ok {
+				artifacts = append(artifacts, a)
+			}
+			i = end
+			continue
+		}
+		if end, ok := jsonEnd(lines, fenced, i); ok {
+			if a, ok := jsonArtifact(lines, i, end, "json"); ok {
+				artifacts = append(artifacts, a)
+				i = end
+			}
+		}
+	}
+
+	// Markdown sections end where diffs and JSON start
+	stops := make(map[int]bool)
+	for _, a := range artifacts {
+		if a.Type != protocol.ArtifactCode {
+			stops[a.StartLine-1] = true
+		}
+	}
+	artifacts = append(artifacts, markdownSections(lines, stops)...)
+
+	sort.SliceStable(artifacts, func(i, j int) bool {
+		return artifacts[i].StartLine < artifacts[j].StartLine
+	})
+	return artifacts
+}
+
+// closingFence returns the index of the fence closing a block opened with
+// marker, or -1
+func closingFence(lines []string, from int, marker string) int {
+	for i := from; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(trimmed, marker[:3]) && strings.Trim(trimmed, marker[:1]) == "" && len(trimmed) >= len(marker) {
+			return i
+		}
+	}
+	return -1
+}
+
+// codeBlock converts the fenced block from start to end to an artifact
+func codeBlock(lines []string, start, end int, language string) (protocol.Artifact, bool) {
+	switch language {
+	case "diff", "patch":
+		return diffArtifact(lines, start+1, end-1, language)
+	case "json":
+		if a, ok := jsonArtifact(lines, start+1, end-1, language); ok {
+			return a, true
+		}
+	}
+
+	content := strings.Join(lines[start+1:end], "\n")
+	if len(content) <= minCodeLength {
+		return protocol.Artifact{}, false
+	}
+
+	return protocol.Artifact{
+		Type:      protocol.ArtifactCode,
+		Language:  language,
+		StartLine: start + 2,
+		EndLine:   end,
+		Content:   content,
+	}, true
+}
+
+// diffEnd reports whether a unified diff starts at line start and where it ends
+func diffEnd(lines []string, fenced []bool, start int) (int, bool) {
+	line := lines[start]
+	switch {
+	case strings.HasPrefix(line, "diff --git "), hunkRegex.MatchString(line):
+	case strings.HasPrefix(line, "--- ") && start+1 < len(lines) && strings.HasPrefix(lines[start+1], "+++ "):
+	default:
+		return 0, false
+	}
+
+	end := start
+	for i := start + 1; i < len(lines) && !fenced[i] && isDiffLine(lines[i]); i++ {
+		end = i
+	}
+	return end, true
+}
+
+// isDiffLine reports whether line can be part of a unified diff
+func isDiffLine(line string) bool {
+	if line == "" {
+		// Context lines of blank source lines lose their space in captures
+		return true
+	}
+	switch line[0] {
+	case ' ', '+', '-', '\\':
+		return true
+	}
+	for _, prefix := range []string{"@@ ", "diff --git ", "index ", "new file mode", "deleted file mode", "old mode", "new mode", "similarity index", "rename from", "rename to", "Binary files"} {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// diffArtifact converts the diff from start to end to an artifact with stats
+func diffArtifact(lines []string, start, end int, language string) (protocol.Artifact, bool) {
+	// Trailing blank lines belong to whatever follows
+	for end >= start && strings.TrimSpace(lines[end]) == "" {
+		end--
+	}
+	if end < start {
+		return protocol.Artifact{}, false
+	}
+
+	content := strings.Join(lines[start:end+1], "\n")
+	if len(content) <= minDiffLength {
+		return protocol.Artifact{}, false
+	}
+
+	stats := &protocol.DiffStats{}
+	headers := 0
+	for _, line := range lines[start : end+1] {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			stats.Files++
+		case strings.HasPrefix(line, "+++ "):
+			headers++
+		case strings.HasPrefix(line, "---"):
+		case strings.HasPrefix(line, "+"):
+			stats.Additions++
+		case strings.HasPrefix(line, "-"):
+			stats.Deletions++
+		}
+	}
+	// Plain unified diffs only have ---/+++ headers
+	if stats.Files == 0 {
+		stats.Files = headers
+	}
+
+	return protocol.Artifact{
+		Type:      protocol.ArtifactDiff,
+		Language:  language,
+		StartLine: start + 1,
+		EndLine:   end + 1,
+		Content:   content,
+		Diff:      stats,
+	}, true
+}
+
+// jsonEnd reports whether a JSON object or array starts at line start and
+// where it ends, by following brackets outside of strings
+func jsonEnd(lines []string, fenced []bool, start int) (int, bool) {
+	trimmed := strings.TrimSpace(lines[start])
+	if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+		return 0, false
+	}
+
+	depth := 0
+	inString, escaped := false, false
+	for i := start; i < len(lines) && i < start+maxJSONLines && !fenced[i]; i++ {
+		for _, r := range lines[i] {
+			switch {
+			case escaped:
+				escaped = false
+			case inString && r == '\\':
+				escaped = true
+			case r == '"':
+				inString = !inString
+			case inString:
+			case r == '{' || r == '[':
+				depth++
+			case r == '}' || r == ']':
+				depth--
+			}
+		}
+		if depth <= 0 {
+			return i, depth == 0
+		}
+	}
+	return 0, false
+}
+
+// jsonArtifact converts the JSON document from start to end to an artifact
+func jsonArtifact(lines []string, start, end int, language string) (protocol.Artifact, bool) {
+	content := strings.TrimSpace(strings.Join(lines[start:end+1], "\n"))
+	if len(content) < minJSONLength || !json.Valid([]byte(content)) {
+		return protocol.Artifact{}, false
+	}
+
+	return protocol.Artifact{
+		Type:      protocol.ArtifactJSON,
+		Language:  language,
+		StartLine: start + 1,
+		EndLine:   end + 1,
+		Content:   content,
+	}, true
+}
+
+// markdownSections finds headed markdown sections and standalone tables
+// A section runs from a heading to the next heading, two blank lines, a
+// shell prompt or a line in stops.
+func markdownSections(lines []string, stops map[int]bool) []protocol.Artifact {
+	artifacts := make([]protocol.Artifact, 0)
+	fence := ""
+
+	for i := 0; i < len(lines); i++ {
+		if m := fenceRegex.FindStringSubmatch(lines[i]); m != nil && fence == "" {
+			fence = m[1]
+			continue
+		} else if fence != "" {
+			if strings.HasPrefix(strings.TrimSpace(lines[i]), fence) {
+				fence = ""
+			}
+			continue
+		}
+
+		var end int
+		switch {
+		case isHeading(lines[i]):
+			end = sectionEnd(lines, i, stops)
+		case isTableStart(lines, i):
+			end = i + 1
+			for end+1 < len(lines) && tableRowRegex.MatchString(lines[end+1]) {
+				end++
+			}
+		default:
+			continue
+		}
+
+		// Needs more than a lone heading
+		if nonBlank(lines[i:end+1]) >= 2 {
+			artifacts = append(artifacts, protocol.Artifact{
+				Type:      protocol.ArtifactMarkdown,
+				Language:  "markdown",
+				StartLine: i + 1,
+				EndLine:   end + 1,
+				Content:   strings.Join(lines[i:end+1], "\n"),
+			})
+		}
+		i = end
+	}
+
+	return artifacts
+}
+
+// sectionEnd returns the last line of the markdown section headed at start
+func sectionEnd(lines []string, start int, stops map[int]bool) int {
+	end := start
+	blank := 0
+	fence := ""
+	for i := start + 1; i < len(lines) && !stops[i]; i++ {
+		if m := fenceRegex.FindStringSubmatch(lines[i]); m != nil && fence == "" {
+			fence = m[1]
+		} else if fence != "" {
+			if strings.HasPrefix(strings.TrimSpace(lines[i]), fence) {
+				fence = ""
+			}
+		} else if isHeading(lines[i]) || isPrompt(lines[i]) {
+			break
+		}
+
+		if strings.TrimSpace(lines[i]) == "" && fence == "" {
+			blank++
+			if blank >= 2 {
+				break
+			}
+			continue
+		}
+		blank = 0
+		end = i
+	}
+	return end
+}
+
+// isHeading reports whether line is a markdown heading rather than a prompt
+func isHeading(line string) bool {
+	return headingRegex.MatchString(line) && !isPrompt(line)
+}
+
+// isPrompt reports whether line starts with a shell prompt
+func isPrompt(line string) bool {
+	return shellPromptRegex.MatchString(line) || rootPromptRegex.MatchString(line)
+}
+
+// isTableStart reports whether a markdown table with header and rule starts at i
+func isTableStart(lines []string, i int) bool {
+	return i+1 < len(lines) && tableRowRegex.MatchString(lines[i]) && tableRuleRegex.MatchString(lines[i+1])
+}
+
+// nonBlank counts the lines with text
+func nonBlank(lines []string) int {
+	count := 0
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,212 @@
+package attach
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/myan/handx-server/internal/pty"
+)
+
+// Limits of a terminal size
+const (
+	defaultCols = 80
+	defaultRows = 24
+	maxSize     = 1000
+)
+
+// closeTimeout is how long a tmux client gets to detach before it is killed
+const closeTimeout = 2 * time.Second
+
+// Options configures an attachment
+type Options struct {
+	SessionName string
+	Cols        int
+	Rows        int
+	// Grouped attaches to a new session grouped with SessionName, so the
+	// client can switch windows without affecting other clients; the
+	// session is destroyed when the client detaches
+	Grouped bool
+	// Socket is the socket path of the tmux server running the session,
+	// the server plain tmux commands use when empty
+	Socket string
+}
+
+// Session is a tmux client running in a pseudo terminal
+type Session struct {
+	name      string // Session the tmux client is attached to
+	cmd       *exec.Cmd
+	pty       *os.File
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Start runs tmux attach for a session in a new pseudo terminal and passes
+// everything the tmux client prints to output until it exits
+// output may block to slow the client down; it returns false to stop.
+func Start(opts Options, output func([]byte) bool) (*Session, error) {
+	cols, rows := clampSize(opts.Cols, opts.Rows)
+
+	// The client must use the server running the session; plain tmux
+	// commands use the one TMUX selects when handx itself runs in tmux
+	socket := opts.Socket
+	if socket == "" {
+		output, err := exec.Command("tmux", "display-message", "-p", "#{socket_path}").Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to find the tmux server: %w", err)
+		}
+		socket = strings.TrimSpace(string(output))
+	}
+
+	if err := exec.Command("tmux", "-S", socket, "has-session", "-t", "="+opts.SessionName).Run(); err != nil {
+		return nil, fmt.Errorf("session '%s' not found", opts.SessionName)
+	}
+
+	name := opts.SessionName
+	args := []string{"attach-session", "-t", "=" + name}
+	if opts.Grouped {
+		suffix, err := randomSuffix()
+		if err != nil {
+			return nil, err
+		}
+		name = opts.SessionName + "-handx-" + suffix
+		args = []string{"new-session", "-t", "=" + opts.SessionName, "-s", name, ";", "set-option", "destroy-unattached", "on"}
+	}
+
+	args = append([]string{"-S", socket}, args...)
+
+	master, slave, err := pty.Open()
+	if err != nil {
+		return nil, err
+	}
+	if err := pty.SetSize(master, cols, rows); err != nil {
+		master.Close()
+		slave.Close()
+		return nil, fmt.Errorf("failed to size pseudo terminal: %w", err)
+	}
+
+	cmd := exec.Command("tmux", args...)
+	cmd.Env = clientEnv()
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = slave, slave, slave
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+	if err := cmd.Start(); err != nil {
+		master.Close()
+		slave.Close()
+		return nil, fmt.Errorf("failed to start tmux client: %w", err)
+	}
+	// The client holds the slave end now
+	slave.Close()
+
+	s := &Session{
+		name: name,
+		cmd:  cmd,
+		pty:  master,
+		done: make(chan struct{}),
+	}
+	go s.relay(output)
+
+	log.Printf("Attached to session %s in a pseudo terminal (%dx%d)", name, cols, rows)
+	return s, nil
+}
+
+// Name returns the session the tmux client is attached to, which differs
+// from the requested one for grouped attachments
+func (s *Session) Name() string {
+	return s.name
+}
+
+// Done is closed when the tmux client has exited
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+// Write types input into the tmux client
+func (s *Session) Write(input []byte) error {
+	_, err := s.pty.Write(input)
+	return err
+}
+
+// Resize changes the size of the pseudo terminal, and with it of the tmux client
+func (s *Session) Resize(cols, rows int) (int, int, error) {
+	cols, rows = clampSize(cols, rows)
+	return cols, rows, pty.SetSize(s.pty, cols, rows)
+}
+
+// Close detaches the tmux client, killing it if it doesn't exit in time
+func (s *Session) Close() {
+	s.closeOnce.Do(func() {
+		// tmux clients detach on SIGHUP, like when a terminal is closed
+		s.cmd.Process.Signal(syscall.SIGHUP)
+		select {
+		case <-s.done:
+		case <-time.After(closeTimeout):
+			s.cmd.Process.Kill()
+			s.pty.Close()
+			<-s.done
+		}
+	})
+}
+
+// relay copies the client's output until it exits
+func (s *Session) relay(output func([]byte) bool) {
+	defer close(s.done)
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := s.pty.Read(buf)
+		if n > 0 {
+			data := make([]byte, n)
+			copy(data, buf[:n])
+			if !output(data) {
+				s.cmd.Process.Signal(syscall.SIGHUP)
+			}
+		}
+		if err != nil {
+			// EIO once the client exited and closed the slave end
+			break
+		}
+	}
+
+	s.cmd.Wait()
+	s.pty.Close()
+	log.Printf("Detached from session %s", s.name)
+}
+
+// clampSize applies defaults and limits to a terminal size
+func clampSize(cols, rows int) (int, int) {
+	if cols <= 0 {
+		cols = defaultCols
+	}
+	if rows <= 0 {
+		rows = defaultRows
+	}
+	return min(cols, maxSize), min(rows, maxSize)
+}
+
+// clientEnv returns the environment of the tmux client: a capable terminal,
+// and no TMUX variable, which would make tmux refuse to attach
+func clientEnv() []string {
+	env := make([]string, 0, len(os.Environ())+1)
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, "TMUX=") && !strings.HasPrefix(kv, "TERM=") {
+			env = append(env, kv)
+		}
+	}
+	return append(env, "TERM=xterm-256color")
+}
+
+// randomSuffix returns a short random suffix for grouped session names
+func randomSuffix() (string, error) {
+	bytes := make([]byte, 3)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
@@ -0,0 +1,287 @@
+package complete
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/myan/handx-server/internal/history"
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+const (
+	defaultLimit = 50
+	maxLimit     = 500
+
+	// pathCacheTTL is how long the executables on PATH are cached, as
+	// clients ask on every keystroke
+	pathCacheTTL = 30 * time.Second
+)
+
+// Commands after which a git branch is expected
+var branchCommands = map[string]bool{
+	"checkout": true, "switch": true, "merge": true, "rebase": true,
+	"branch": true, "diff": true, "log": true, "reset": true, "cherry-pick": true,
+}
+
+// Completer computes completions for partial command lines without touching
+// the pane they are typed into
+type Completer struct {
+	history *history.Store // nil without command history
+
+	mu          sync.Mutex
+	executables []string
+	cachedAt    time.Time
+}
+
+// NewCompleter creates a completer; store may be nil
+func NewCompleter(store *history.Store) *Completer {
+	return &Completer{history: store}
+}
+
+// Complete returns completions for the word input ends with, resolving
+// paths and git branches in cwd
+// Executables are looked up on the server's PATH, which usually matches the
+// shells tmux starts.
+func (c *Completer) Complete(input, cwd, sessionName string, limit int) []protocol.Completion {
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+
+	start, word, args := currentWord(input)
+	wordStart := utf8.RuneCountInString(input[:start])
+
+	result := make([]protocol.Completion, 0)
+	seen := make(map[string]bool)
+	add := func(completions []protocol.Completion) {
+		for _, completion := range completions {
+			key := completion.Kind + "\x00" + completion.Text
+			if len(result) < limit && !seen[key] {
+				seen[key] = true
+				result = append(result, completion)
+			}
+		}
+	}
+
+	if len(args) == 0 && !strings.Contains(word, "/") {
+		add(c.commands(word, wordStart))
+	} else {
+		if expectsBranch(args) {
+			add(branches(cwd, word, wordStart))
+		}
+		add(paths(cwd, word, wordStart))
+	}
+	add(c.recent(input, sessionName))
+
+	return result
+}
+
+// currentWord splits input into the byte offset and text of the word being
+// typed and the words before it in the current command
+func currentWord(input string) (int, string, []string) {
+	start := len(input)
+	for start > 0 {
+		r, size := utf8.DecodeLastRuneInString(input[:start])
+		// A backslash escapes a space within the word
+		if r == ' ' || r == '\t' {
+			if start-size > 0 && input[start-size-1] == '\\' {
+				start -= size + 1
+				continue
+			}
+			break
+		}
+		start -= size
+	}
+
+	// Only the command after the last separator matters
+	before := input[:start]
+	for _, sep := range []string{"&&", "||", ";", "|"} {
+		if i := strings.LastIndex(before, sep); i >= 0 {
+			before = before[i+len(sep):]
+		}
+	}
+
+	return start, input[start:], strings.Fields(before)
+}
+
+// expectsBranch reports whether a git command's next argument is usually a branch
+func expectsBranch(args []string) bool {
+	return len(args) >= 2 && args[0] == "git" && branchCommands[args[1]]
+}
+
+// commands completes executables on PATH
+func (c *Completer) commands(prefix string, start int) []protocol.Completion {
+	result := make([]protocol.Completion, 0)
+	for _, name := range c.pathExecutables() {
+		if strings.HasPrefix(name, prefix) {
+			result = append(result, protocol.Completion{
+				Text:  name,
+				Kind:  protocol.CompletionCommand,
+				Start: start,
+			})
+		}
+	}
+	return result
+}
+
+// pathExecutables returns the sorted names of the executables on PATH
+func (c *Completer) pathExecutables() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.executables != nil && time.Since(c.cachedAt) < pathCacheTTL {
+		return c.executables
+	}
+
+	seen := make(map[string]bool)
+	names := make([]string, 0)
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if seen[entry.Name()] || entry.IsDir() {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			// Symlinks are common on PATH; follow them for the mode
+			if info.Mode()&os.ModeSymlink != 0 {
+				if info, err = os.Stat(filepath.Join(dir, entry.Name())); err != nil || info.IsDir() {
+					continue
+				}
+			}
+			if info.Mode()&0111 != 0 {
+				seen[entry.Name()] = true
+				names = append(names, entry.Name())
+			}
+		}
+	}
+	sort.Strings(names)
+
+	c.executables = names
+	c.cachedAt = time.Now()
+	return names
+}
+
+// paths completes files and directories, relative to cwd unless word is
+// absolute or starts with ~
+func paths(cwd, word string, start int) []protocol.Completion {
+	raw := strings.ReplaceAll(word, `\ `, " ")
+	dirPart, prefix := "", raw
+	if i := strings.LastIndex(raw, "/"); i >= 0 {
+		dirPart, prefix = raw[:i+1], raw[i+1:]
+	}
+
+	dir := dirPart
+	switch {
+	case dir == "":
+		dir = cwd
+	case strings.HasPrefix(dir, "~/"):
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil
+		}
+		dir = filepath.Join(home, dir[2:])
+	case !filepath.IsAbs(dir):
+		dir = filepath.Join(cwd, dir)
+	}
+	if dir == "" {
+		return nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	result := make([]protocol.Completion, 0)
+	for _, entry := range entries {
+		name := entry.Name()
+		// Hidden files only when asked for
+		if !strings.HasPrefix(name, prefix) || (strings.HasPrefix(name, ".") && !strings.HasPrefix(prefix, ".")) {
+			continue
+		}
+
+		kind, suffix := protocol.CompletionPath, ""
+		isDir := entry.IsDir()
+		if entry.Type()&os.ModeSymlink != 0 {
+			if info, err := os.Stat(filepath.Join(dir, name)); err == nil && info.IsDir() {
+				isDir = true
+			}
+		}
+		if isDir {
+			kind, suffix = protocol.CompletionDirectory, "/"
+		}
+
+		result = append(result, protocol.Completion{
+			Text:    strings.ReplaceAll(dirPart+name, " ", `\ `) + suffix,
+			Display: name + suffix,
+			Kind:    kind,
+			Start:   start,
+		})
+	}
+
+	// Directories first, as they are usually descended into
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Kind == protocol.CompletionDirectory && result[j].Kind != protocol.CompletionDirectory
+	})
+	return result
+}
+
+// branches completes local and remote git branches of the repository at cwd
+func branches(cwd, prefix string, start int) []protocol.Completion {
+	if cwd == "" {
+		return nil
+	}
+
+	output, err := exec.Command("git", "-C", cwd, "for-each-ref", "--format=%(refname:short)", "refs/heads", "refs/remotes").Output()
+	if err != nil {
+		return nil
+	}
+
+	result := make([]protocol.Completion, 0)
+	for _, name := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if name != "" && strings.HasPrefix(name, prefix) && !strings.HasSuffix(name, "/HEAD") {
+			result = append(result, protocol.Completion{
+				Text:  name,
+				Kind:  protocol.CompletionBranch,
+				Start: start,
+			})
+		}
+	}
+	return result
+}
+
+// recent completes the whole input with commands from the history of the
+// session, falling back to the global history
+func (c *Completer) recent(input, sessionName string) []protocol.Completion {
+	if c.history == nil || strings.TrimSpace(input) == "" {
+		return nil
+	}
+
+	result := make([]protocol.Completion, 0)
+	for _, session := range []string{sessionName, ""} {
+		for _, entry := range c.history.Get(session, 0) {
+			if strings.HasPrefix(entry.Command, input) && entry.Command != input {
+				result = append(result, protocol.Completion{
+					Text:  entry.Command,
+					Kind:  protocol.CompletionHistory,
+					Start: 0,
+				})
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,28 @@
+// Package environ validates the extra environment variables sessions and
+// windows are created with
+package environ
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Pairs validates extra environment variables and returns them as KEY=value
+// pairs, sorted by name for deterministic command lines
+func Pairs(extra map[string]string) ([]string, error) {
+	keys := make([]string, 0, len(extra))
+	for k := range extra {
+		if k == "" || strings.Contains(k, "=") {
+			return nil, fmt.Errorf("invalid environment variable name '%s'", k)
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+extra[k])
+	}
+	return pairs, nil
+}
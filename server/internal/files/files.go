@@ -0,0 +1,456 @@
+package files
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"unicode/utf8"
+
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// HTTP paths files are downloaded from and uploaded to
+const (
+	DownloadPath = "/files/download"
+	UploadPath   = "/files/upload"
+)
+
+var (
+	// ErrOutsideRoots is returned for paths outside every configured root
+	ErrOutsideRoots = errors.New("path is outside the allowed roots")
+	// ErrNotFound is returned for missing files and directories
+	ErrNotFound = errors.New("no such file or directory")
+	// ErrExists is returned when writing over a file without overwrite
+	ErrExists = errors.New("file already exists")
+	// ErrTooLarge is returned for writes beyond the size limit
+	ErrTooLarge = errors.New("file is too large")
+)
+
+// Options configures a Browser
+type Options struct {
+	Roots         []string // Directories files may be accessed in
+	MaxReadBytes  int64    // Largest read_file chunk
+	MaxWriteBytes int64    // Largest write_file content or upload per file
+}
+
+// Browser lists, reads and writes files within a set of root directories
+// Paths are resolved through symlinks before they are checked, so links
+// can't lead out of the roots.
+type Browser struct {
+	roots         []string
+	maxReadBytes  int64
+	maxWriteBytes int64
+}
+
+// NewBrowser creates a browser for the roots that exist
+func NewBrowser(opts Options) (*Browser, error) {
+	if opts.MaxReadBytes <= 0 {
+		opts.MaxReadBytes = 1 << 20
+	}
+	if opts.MaxWriteBytes <= 0 {
+		opts.MaxWriteBytes = 100 << 20
+	}
+
+	roots := make([]string, 0, len(opts.Roots))
+	for _, root := range opts.Roots {
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			return nil, fmt.Errorf("invalid root '%s': %w", root, err)
+		}
+		real, err := filepath.EvalSymlinks(abs)
+		if err != nil {
+			return nil, fmt.Errorf("invalid root '%s': %w", root, err)
+		}
+		roots = append(roots, real)
+	}
+	if len(roots) == 0 {
+		return nil, fmt.Errorf("no roots configured")
+	}
+
+	return &Browser{
+		roots:         roots,
+		maxReadBytes:  opts.MaxReadBytes,
+		maxWriteBytes: opts.MaxWriteBytes,
+	}, nil
+}
+
+// Roots returns the resolved root directories
+func (b *Browser) Roots() []string {
+	return append([]string(nil), b.roots...)
+}
+
+// Resolve returns the real absolute path of path, which is relative to
+// base, or to the first root when base is empty, unless it is absolute or
+// starts with ~
+// The path itself doesn't need to exist, but it must lie within a root.
+func (b *Browser) Resolve(path, base string) (string, error) {
+	if base == "" {
+		base = b.roots[0]
+	}
+
+	switch {
+	case path == "":
+		path = base
+	case path == "~" || strings.HasPrefix(path, "~/"):
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(home, path[1:])
+	case !filepath.IsAbs(path):
+		path = filepath.Join(base, path)
+	}
+
+	real, err := realPath(filepath.Clean(path))
+	if err != nil {
+		return "", err
+	}
+	if !b.allowed(real) {
+		return "", fmt.Errorf("%w: %s", ErrOutsideRoots, path)
+	}
+	return real, nil
+}
+
+// List returns the entries of a directory
+func (b *Browser) List(path, base string, showHidden bool) (*protocol.ListDirectoryResponse, error) {
+	dir, err := b.Resolve(path, base)
+	if err != nil {
+		return nil, err
+	}
+
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	entries := make([]protocol.FileEntry, 0, len(dirEntries))
+	for _, dirEntry := range dirEntries {
+		if !showHidden && strings.HasPrefix(dirEntry.Name(), ".") {
+			continue
+		}
+		info, err := dirEntry.Info()
+		if err != nil {
+			// Removed since it was listed
+			continue
+		}
+		entries = append(entries, entryFor(filepath.Join(dir, dirEntry.Name()), info))
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		iDir := entries[i].Type == protocol.FileTypeDirectory
+		jDir := entries[j].Type == protocol.FileTypeDirectory
+		if iDir != jDir {
+			return iDir
+		}
+		return strings.ToLower(entries[i].Name) < strings.ToLower(entries[j].Name)
+	})
+
+	parent := ""
+	if up := filepath.Dir(dir); up != dir && b.allowed(up) {
+		parent = up
+	}
+
+	return &protocol.ListDirectoryResponse{
+		Path:    dir,
+		Parent:  parent,
+		Roots:   b.Roots(),
+		Entries: entries,
+	}, nil
+}
+
+// Read returns up to length bytes of a file from offset; a negative offset
+// counts from the end of the file
+// Text is returned as is and binary content base64 encoded.
+func (b *Browser) Read(path, base string, offset, length int64) (*protocol.ReadFileResponse, error) {
+	file, info, err := b.Open(path, base)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	size := info.Size()
+	if offset < 0 {
+		offset = max(0, size+offset)
+	}
+	offset = min(offset, size)
+	if length <= 0 || length > b.maxReadBytes {
+		length = b.maxReadBytes
+	}
+
+	data := make([]byte, min(length, size-offset))
+	n, err := file.ReadAt(data, offset)
+	if err != nil && err != io.EOF {
+		return nil, wrapError(err)
+	}
+	data = data[:n]
+
+	truncated := offset+int64(n) < size
+	if truncated {
+		// Don't split a character at the end of a chunk of text
+		data = trimPartialRune(data)
+	}
+
+	response := &protocol.ReadFileResponse{
+		Path:       file.Name(),
+		Size:       size,
+		Offset:     offset,
+		Length:     int64(len(data)),
+		Truncated:  offset+int64(len(data)) < size,
+		ModifiedAt: info.ModTime().UnixMilli(),
+	}
+	if isText(data) {
+		response.Encoding = protocol.EncodingUTF8
+		response.Content = string(data)
+	} else {
+		response.Encoding = protocol.EncodingBase64
+		response.Content = base64.StdEncoding.EncodeToString(data)
+	}
+	return response, nil
+}
+
+// Open opens a regular file for reading
+func (b *Browser) Open(path, base string) (*os.File, os.FileInfo, error) {
+	resolved, err := b.Resolve(path, base)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.Open(resolved)
+	if err != nil {
+		return nil, nil, wrapError(err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, wrapError(err)
+	}
+	if !info.Mode().IsRegular() {
+		file.Close()
+		return nil, nil, fmt.Errorf("%s is not a regular file", resolved)
+	}
+	return file, info, nil
+}
+
+// Write writes content, encoded as utf8 or base64, to a file
+func (b *Browser) Write(payload protocol.WriteFilePayload, base string) (*protocol.FileEntry, error) {
+	if payload.Path == "" {
+		return nil, fmt.Errorf("path is required")
+	}
+
+	var data []byte
+	switch payload.Encoding {
+	case protocol.EncodingUTF8, "":
+		data = []byte(payload.Content)
+	case protocol.EncodingBase64:
+		decoded, err := base64.StdEncoding.DecodeString(payload.Content)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 content: %w", err)
+		}
+		data = decoded
+	default:
+		return nil, fmt.Errorf("unknown encoding '%s'", payload.Encoding)
+	}
+
+	resolved, err := b.Resolve(payload.Path, base)
+	if err != nil {
+		return nil, err
+	}
+	return b.writeFile(resolved, bytes.NewReader(data), payload.Append, payload.Overwrite, payload.CreateDirs)
+}
+
+// Upload writes r to a file called name in the directory dir
+func (b *Browser) Upload(dir, base, name string, r io.Reader, overwrite bool) (*protocol.FileEntry, error) {
+	name = filepath.Base(filepath.Clean("/" + name))
+	if name == "/" || name == "." {
+		return nil, fmt.Errorf("invalid file name")
+	}
+
+	resolvedDir, err := b.Resolve(dir, base)
+	if err != nil {
+		return nil, err
+	}
+	resolved, err := b.Resolve(filepath.Join(resolvedDir, name), base)
+	if err != nil {
+		return nil, err
+	}
+	return b.writeFile(resolved, r, false, overwrite, false)
+}
+
+// writeFile writes r to the resolved path, replacing files atomically
+func (b *Browser) writeFile(path string, r io.Reader, appendTo, overwrite, createDirs bool) (*protocol.FileEntry, error) {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		if info.IsDir() {
+			return nil, fmt.Errorf("%s is a directory", path)
+		}
+		if !appendTo && !overwrite {
+			return nil, fmt.Errorf("%w: %s", ErrExists, path)
+		}
+		mode = info.Mode().Perm()
+	}
+
+	dir := filepath.Dir(path)
+	if createDirs {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, wrapError(err)
+		}
+	}
+
+	// Read one byte past the limit to tell a full file from a larger one
+	limited := io.LimitReader(r, b.maxWriteBytes+1)
+
+	if appendTo {
+		data, err := io.ReadAll(limited)
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(data)) > b.maxWriteBytes {
+			return nil, fmt.Errorf("%w: more than %d bytes", ErrTooLarge, b.maxWriteBytes)
+		}
+		// Don't follow a symlink swapped in since the path was resolved
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE|syscall.O_NOFOLLOW, mode)
+		if err != nil {
+			return nil, wrapError(err)
+		}
+		if _, err := file.Write(data); err != nil {
+			file.Close()
+			return nil, err
+		}
+		if err := file.Close(); err != nil {
+			return nil, err
+		}
+	} else {
+		// Write to a temp file first so readers never see a partial file
+		tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+		if err != nil {
+			return nil, wrapError(err)
+		}
+		n, err := io.Copy(tmp, limited)
+		if err == nil && n > b.maxWriteBytes {
+			err = fmt.Errorf("%w: more than %d bytes", ErrTooLarge, b.maxWriteBytes)
+		}
+		if err == nil {
+			err = tmp.Chmod(mode)
+		}
+		if closeErr := tmp.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			// Renaming replaces a symlink at path instead of following it
+			err = os.Rename(tmp.Name(), path)
+		}
+		if err != nil {
+			os.Remove(tmp.Name())
+			return nil, err
+		}
+	}
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	entry := entryFor(path, info)
+	return &entry, nil
+}
+
+// allowed reports whether a real path lies within a root
+func (b *Browser) allowed(path string) bool {
+	for _, root := range b.roots {
+		if path == root || strings.HasPrefix(path, strings.TrimSuffix(root, string(filepath.Separator))+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// realPath resolves the symlinks of the longest existing prefix of a clean
+// absolute path
+// A dangling symlink among the missing components is rejected, since
+// writing through it would create its target wherever it points.
+func realPath(path string) (string, error) {
+	rest := ""
+	for {
+		real, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(real, rest), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", wrapError(err)
+		}
+		if info, lerr := os.Lstat(path); lerr == nil && info.Mode()&os.ModeSymlink != 0 {
+			return "", fmt.Errorf("%w: %s is a symlink to a missing path", ErrOutsideRoots, path)
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return "", wrapError(err)
+		}
+		rest = filepath.Join(filepath.Base(path), rest)
+		path = parent
+	}
+}
+
+// entryFor describes the file at path, following a symlink for its type
+func entryFor(path string, info os.FileInfo) protocol.FileEntry {
+	entry := protocol.FileEntry{
+		Name:       filepath.Base(path),
+		Path:       path,
+		Size:       info.Size(),
+		Mode:       info.Mode().String(),
+		ModifiedAt: info.ModTime().UnixMilli(),
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		entry.Symlink = true
+		if target, err := os.Stat(path); err == nil {
+			info = target
+			entry.Size = target.Size()
+		}
+	}
+
+	switch {
+	case info.IsDir():
+		entry.Type = protocol.FileTypeDirectory
+	case info.Mode().IsRegular():
+		entry.Type = protocol.FileTypeFile
+	default:
+		entry.Type = protocol.FileTypeOther
+	}
+	return entry
+}
+
+// trimPartialRune drops an incomplete UTF-8 sequence at the end of data
+func trimPartialRune(data []byte) []byte {
+	for i := 1; i < utf8.UTFMax && i <= len(data); i++ {
+		if utf8.RuneStart(data[len(data)-i]) {
+			if !utf8.FullRune(data[len(data)-i:]) {
+				return data[:len(data)-i]
+			}
+			break
+		}
+	}
+	return data
+}
+
+// isText reports whether data is UTF-8 text without NUL bytes
+func isText(data []byte) bool {
+	return utf8.Valid(data) && bytes.IndexByte(data, 0) < 0
+}
+
+// wrapError maps not-exist errors to ErrNotFound
+func wrapError(err error) error {
+	if errors.Is(err, os.ErrNotExist) {
+		var pathErr *os.PathError
+		if errors.As(err, &pathErr) {
+			return fmt.Errorf("%w: %s", ErrNotFound, pathErr.Path)
+		}
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,114 @@
+package files
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// newTestBrowser returns a browser rooted in a temp dir, and a directory
+// outside the root
+func newTestBrowser(t *testing.T) (*Browser, string, string) {
+	t.Helper()
+
+	root := t.TempDir()
+	outside := t.TempDir()
+	b, err := NewBrowser(Options{Roots: []string{root}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b, b.Roots()[0], outside
+}
+
+func TestWrite(t *testing.T) {
+	b, root, _ := newTestBrowser(t)
+
+	entry, err := b.Write(protocol.WriteFilePayload{Path: "dir/notes.txt", Content: "hello", CreateDirs: true}, "")
+	if err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if want := filepath.Join(root, "dir", "notes.txt"); entry.Path != want {
+		t.Errorf("path = %q, want %q", entry.Path, want)
+	}
+
+	if _, err := b.Write(protocol.WriteFilePayload{Path: "dir/notes.txt", Content: "!", Append: true}, ""); err != nil {
+		t.Fatalf("append failed: %v", err)
+	}
+	if data, _ := os.ReadFile(entry.Path); string(data) != "hello!" {
+		t.Errorf("content = %q, want hello!", data)
+	}
+
+	_, err = b.Write(protocol.WriteFilePayload{Path: "dir/notes.txt", Content: "again"}, "")
+	if !errors.Is(err, ErrExists) {
+		t.Errorf("write over an existing file = %v, want ErrExists", err)
+	}
+}
+
+func TestWriteOutsideRoots(t *testing.T) {
+	b, root, outside := newTestBrowser(t)
+
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	paths := []string{
+		filepath.Join(outside, "file"),
+		"../" + filepath.Base(outside) + "/file",
+		"link/file",
+	}
+	for _, path := range paths {
+		_, err := b.Write(protocol.WriteFilePayload{Path: path, Content: "x"}, "")
+		if !errors.Is(err, ErrOutsideRoots) {
+			t.Errorf("Write(%q) = %v, want ErrOutsideRoots", path, err)
+		}
+	}
+	assertEmpty(t, outside)
+}
+
+func TestWriteThroughDanglingSymlink(t *testing.T) {
+	b, root, outside := newTestBrowser(t)
+
+	// Links to paths outside the root that don't exist yet
+	if err := os.Symlink(filepath.Join(outside, "file"), filepath.Join(root, "file")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "dir"), filepath.Join(root, "dir")); err != nil {
+		t.Fatal(err)
+	}
+
+	payloads := []protocol.WriteFilePayload{
+		{Path: "file", Content: "x"},
+		{Path: "file", Content: "x", Overwrite: true},
+		{Path: "file", Content: "x", Append: true},
+		{Path: "dir/file", Content: "x", CreateDirs: true},
+		{Path: "dir/file", Content: "x", Append: true, CreateDirs: true},
+	}
+	for _, payload := range payloads {
+		_, err := b.Write(payload, "")
+		if !errors.Is(err, ErrOutsideRoots) {
+			t.Errorf("Write(%+v) = %v, want ErrOutsideRoots", payload, err)
+		}
+	}
+
+	if _, err := b.Upload("", "", "file", strings.NewReader("x"), true); !errors.Is(err, ErrOutsideRoots) {
+		t.Errorf("Upload = %v, want ErrOutsideRoots", err)
+	}
+	assertEmpty(t, outside)
+}
+
+// assertEmpty fails unless dir has no entries
+func assertEmpty(t *testing.T, dir string) {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		t.Errorf("%s was created outside the root", filepath.Join(dir, entry.Name()))
+	}
+}
@@ -0,0 +1,267 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// defaultLimit is how many entries are returned when a request doesn't say
+const defaultLimit = 100
+
+// saveDelay is how long changes are collected before the history is written,
+// so a burst of commands costs one write
+const saveDelay = 5 * time.Second
+
+// entryKey identifies a command in a session
+type entryKey struct {
+	session string
+	command string
+}
+
+// Store records executed commands per session, one entry per distinct
+// command, and persists them
+// The global history merges the entries of all sessions.
+type Store struct {
+	path       string // File the history is persisted to
+	maxEntries int
+	mu         sync.Mutex
+	entries    map[entryKey]*protocol.HistoryEntry
+	dirty      bool        // Changed since the last save
+	saveTimer  *time.Timer // Pending save, nil when there is none
+}
+
+// NewStore creates a store keeping at most maxEntries and loads the
+// persisted history from path
+func NewStore(path string, maxEntries int) (*Store, error) {
+	if maxEntries <= 0 {
+		maxEntries = 5000
+	}
+
+	s := &Store{
+		path:       path,
+		maxEntries: maxEntries,
+		entries:    make(map[entryKey]*protocol.HistoryEntry),
+	}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Record adds a command executed in a session
+// Running a command again moves it to the top instead of adding a duplicate.
+// The history is saved shortly after, off the caller's goroutine.
+func (s *Store) Record(sessionName, command string) {
+	command = strings.TrimSpace(command)
+	if command == "" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UnixMilli()
+	key := entryKey{session: sessionName, command: command}
+	entry, ok := s.entries[key]
+	if !ok {
+		entry = &protocol.HistoryEntry{
+			Command:     command,
+			SessionName: sessionName,
+			FirstUsedAt: now,
+		}
+		s.entries[key] = entry
+	}
+	entry.Count++
+	entry.LastUsedAt = now
+
+	s.trim()
+	s.dirty = true
+	if s.saveTimer == nil {
+		s.saveTimer = time.AfterFunc(saveDelay, s.Flush)
+	}
+}
+
+// Flush writes pending changes to disk; call it before exiting
+func (s *Store) Flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.saveTimer != nil {
+		s.saveTimer.Stop()
+		s.saveTimer = nil
+	}
+	if !s.dirty {
+		return
+	}
+	if err := s.save(); err != nil {
+		log.Printf("Failed to save command history: %v", err)
+		return
+	}
+	s.dirty = false
+}
+
+// Get returns the most recently used commands of a session, or of all
+// sessions without a session name
+func (s *Store) Get(sessionName string, limit int) []protocol.HistoryEntry {
+	return s.Search("", sessionName, limit)
+}
+
+// Search returns the most recently used commands containing every word of
+// query, ignoring case
+func (s *Store) Search(query, sessionName string, limit int) []protocol.HistoryEntry {
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	words := strings.Fields(strings.ToLower(query))
+
+	s.mu.Lock()
+	entries := s.view(sessionName)
+	s.mu.Unlock()
+
+	result := make([]protocol.HistoryEntry, 0, min(limit, len(entries)))
+	for _, entry := range entries {
+		if len(result) >= limit {
+			break
+		}
+		if matchesAll(strings.ToLower(entry.Command), words) {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// view returns the entries of a session, or the entries of all sessions
+// merged by command, most recently used first; callers must hold the lock
+func (s *Store) view(sessionName string) []protocol.HistoryEntry {
+	result := make([]protocol.HistoryEntry, 0)
+
+	if sessionName != "" {
+		for key, entry := range s.entries {
+			if key.session == sessionName {
+				result = append(result, *entry)
+			}
+		}
+	} else {
+		merged := make(map[string]*protocol.HistoryEntry)
+		for _, entry := range s.entries {
+			global, ok := merged[entry.Command]
+			if !ok {
+				global = &protocol.HistoryEntry{
+					Command:     entry.Command,
+					FirstUsedAt: entry.FirstUsedAt,
+				}
+				merged[entry.Command] = global
+			}
+			global.Count += entry.Count
+			global.FirstUsedAt = min(global.FirstUsedAt, entry.FirstUsedAt)
+			global.LastUsedAt = max(global.LastUsedAt, entry.LastUsedAt)
+			global.Sessions = append(global.Sessions, entry.SessionName)
+		}
+		for _, entry := range merged {
+			sort.Strings(entry.Sessions)
+			result = append(result, *entry)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].LastUsedAt != result[j].LastUsedAt {
+			return result[i].LastUsedAt > result[j].LastUsedAt
+		}
+		return result[i].Command < result[j].Command
+	})
+	return result
+}
+
+// trim drops the least recently used entries beyond maxEntries; callers
+// must hold the lock
+func (s *Store) trim() {
+	if len(s.entries) <= s.maxEntries {
+		return
+	}
+
+	keys := make([]entryKey, 0, len(s.entries))
+	for key := range s.entries {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return s.entries[keys[i]].LastUsedAt < s.entries[keys[j]].LastUsedAt
+	})
+	for _, key := range keys[:len(keys)-s.maxEntries] {
+		delete(s.entries, key)
+	}
+}
+
+// load reads the persisted history from disk
+func (s *Store) load() error {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read command history: %w", err)
+	}
+
+	var entries []*protocol.HistoryEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("failed to parse command history: %w", err)
+	}
+
+	for _, entry := range entries {
+		s.entries[entryKey{session: entry.SessionName, command: entry.Command}] = entry
+	}
+
+	log.Printf("Loaded %d history entries from %s", len(s.entries), s.path)
+	return nil
+}
+
+// save writes the history to disk; callers must hold the lock
+func (s *Store) save() error {
+	entries := make([]*protocol.HistoryEntry, 0, len(s.entries))
+	for _, entry := range s.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].LastUsedAt < entries[j].LastUsedAt
+	})
+
+	return writeJSON(s.path, entries)
+}
+
+// matchesAll reports whether text contains every word
+func matchesAll(text string, words []string) bool {
+	for _, word := range words {
+		if !strings.Contains(text, word) {
+			return false
+		}
+	}
+	return true
+}
+
+// writeJSON atomically writes v as indented JSON to path
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", filepath.Base(path), err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", filepath.Base(path), err)
+	}
+
+	// Write to a temp file first so a crash never leaves a truncated file
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+	return os.Rename(tmp, path)
+}
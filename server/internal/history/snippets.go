@@ -0,0 +1,229 @@
+package history
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// Parameters are written {{name}} or {{name|default}} in snippet commands
+var paramRegex = regexp.MustCompile(`\{\{\s*([A-Za-z_][\w-]*)\s*(?:\|([^}]*))?\}\}`)
+
+// ErrSnippetNotFound is returned for unknown snippet IDs and names
+var ErrSnippetNotFound = errors.New("snippet not found")
+
+// Snippets holds named command templates and persists them
+type Snippets struct {
+	path     string // File the snippets are persisted to
+	mu       sync.Mutex
+	snippets map[string]*protocol.Snippet // Keyed by ID
+}
+
+// NewSnippets creates a snippet store and loads the persisted snippets from path
+func NewSnippets(path string) (*Snippets, error) {
+	s := &Snippets{
+		path:     path,
+		snippets: make(map[string]*protocol.Snippet),
+	}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Save creates a snippet, or replaces the one with the same name
+func (s *Snippets) Save(payload protocol.SaveSnippetPayload) (*protocol.Snippet, error) {
+	name := strings.TrimSpace(payload.Name)
+	if name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if strings.TrimSpace(payload.Command) == "" {
+		return nil, fmt.Errorf("command is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UnixMilli()
+	snippet := s.byName(name)
+	var previous *protocol.Snippet
+	if snippet != nil {
+		saved := *snippet
+		previous = &saved
+	} else {
+		id, err := generateSnippetID()
+		if err != nil {
+			return nil, err
+		}
+		snippet = &protocol.Snippet{ID: id, Name: name, CreatedAt: now}
+	}
+
+	snippet.Command = payload.Command
+	snippet.Description = payload.Description
+	snippet.Params = parseParams(payload.Command)
+	snippet.UpdatedAt = now
+
+	s.snippets[snippet.ID] = snippet
+	if err := s.save(); err != nil {
+		if previous != nil {
+			s.snippets[snippet.ID] = previous
+		} else {
+			delete(s.snippets, snippet.ID)
+		}
+		return nil, err
+	}
+
+	result := *snippet
+	return &result, nil
+}
+
+// List returns all snippets ordered by name
+func (s *Snippets) List() []protocol.Snippet {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]protocol.Snippet, 0, len(s.snippets))
+	for _, snippet := range s.snippets {
+		result = append(result, *snippet)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return strings.ToLower(result[i].Name) < strings.ToLower(result[j].Name)
+	})
+	return result
+}
+
+// Render returns the command of the snippet with the given ID, or else
+// name, with its parameters filled in from values or their defaults
+func (s *Snippets) Render(id, name string, values map[string]string) (string, error) {
+	s.mu.Lock()
+	snippet, ok := s.snippets[id]
+	if !ok && id == "" {
+		snippet = s.byName(name)
+		ok = snippet != nil
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		if id == "" {
+			id = name
+		}
+		return "", fmt.Errorf("%w: '%s'", ErrSnippetNotFound, id)
+	}
+
+	missing := make([]string, 0)
+	command := paramRegex.ReplaceAllStringFunc(snippet.Command, func(match string) string {
+		m := paramRegex.FindStringSubmatch(match)
+		if value, ok := values[m[1]]; ok {
+			return value
+		}
+		if strings.Contains(match, "|") {
+			return m[2]
+		}
+		missing = append(missing, m[1])
+		return match
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("missing parameters: %s", strings.Join(missing, ", "))
+	}
+
+	return command, nil
+}
+
+// Delete removes a snippet
+func (s *Snippets) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.snippets[id]; !ok {
+		return fmt.Errorf("%w: '%s'", ErrSnippetNotFound, id)
+	}
+
+	delete(s.snippets, id)
+	return s.save()
+}
+
+// byName returns the snippet with a name, ignoring case; callers must hold the lock
+func (s *Snippets) byName(name string) *protocol.Snippet {
+	for _, snippet := range s.snippets {
+		if strings.EqualFold(snippet.Name, name) {
+			return snippet
+		}
+	}
+	return nil
+}
+
+// parseParams lists the parameters of a command in order of appearance
+func parseParams(command string) []protocol.SnippetParam {
+	params := make([]protocol.SnippetParam, 0)
+	seen := make(map[string]bool)
+	for _, m := range paramRegex.FindAllStringSubmatch(command, -1) {
+		if seen[m[1]] {
+			continue
+		}
+		seen[m[1]] = true
+		params = append(params, protocol.SnippetParam{
+			Name:       m[1],
+			Default:    m[2],
+			HasDefault: strings.Contains(m[0], "|"),
+		})
+	}
+	return params
+}
+
+// load reads persisted snippets from disk
+func (s *Snippets) load() error {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read snippets: %w", err)
+	}
+
+	var snippets []*protocol.Snippet
+	if err := json.Unmarshal(data, &snippets); err != nil {
+		return fmt.Errorf("failed to parse snippets: %w", err)
+	}
+
+	for _, snippet := range snippets {
+		s.snippets[snippet.ID] = snippet
+	}
+
+	log.Printf("Loaded %d snippets from %s", len(s.snippets), s.path)
+	return nil
+}
+
+// save writes all snippets to disk; callers must hold the lock
+func (s *Snippets) save() error {
+	snippets := make([]*protocol.Snippet, 0, len(s.snippets))
+	for _, snippet := range s.snippets {
+		snippets = append(snippets, snippet)
+	}
+	sort.Slice(snippets, func(i, j int) bool {
+		return snippets[i].CreatedAt < snippets[j].CreatedAt
+	})
+
+	return writeJSON(s.path, snippets)
+}
+
+// generateSnippetID generates a random snippet ID
+func generateSnippetID() (string, error) {
+	bytes := make([]byte, 8)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return "snip-" + hex.EncodeToString(bytes), nil
+}
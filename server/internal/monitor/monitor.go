@@ -0,0 +1,300 @@
+package monitor
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/myan/handx-server/internal/stream"
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// Backend is the subset of the tmux manager used to detect bells
+type Backend interface {
+	TakeBells() ([]protocol.PaneLocation, error)
+}
+
+// Options are the monitoring defaults for panes without their own settings
+type Options struct {
+	Bell          bool
+	ActivityAfter time.Duration // Idleness after which new output alerts, 0 disables
+	SilenceAfter  time.Duration // Time without output after which to alert, 0 disables
+	AlertLines    int           // Output lines included in alerts
+}
+
+// settings are the effective monitoring settings of a pane
+type settings struct {
+	bell          bool
+	activityAfter time.Duration
+	silenceAfter  time.Duration
+}
+
+// paneState tracks the output of one pane
+type paneState struct {
+	location     protocol.PaneLocation
+	lastOutput   time.Time
+	hasOutput    bool // Output was seen since monitoring started
+	silenceFired bool // A silence alert was sent for the current quiet period
+	recent       []string
+}
+
+// Monitor watches panes for bells, activity after idleness and silence and
+// publishes pane alerts
+type Monitor struct {
+	backend     Backend
+	streamer    *stream.Streamer
+	defaults    settings
+	alertLines  int
+	started     time.Time
+	mu          sync.Mutex
+	panes       map[string]*paneState // Keyed by pane ID
+	overrides   map[string]settings   // Per-pane settings keyed by pane ID
+	subscribers []func(protocol.PaneAlertPayload)
+}
+
+// NewMonitor creates a monitor fed by the output of streamer
+func NewMonitor(backend Backend, streamer *stream.Streamer, opts Options) *Monitor {
+	if opts.AlertLines <= 0 {
+		opts.AlertLines = 5
+	}
+
+	m := &Monitor{
+		backend:  backend,
+		streamer: streamer,
+		defaults: settings{
+			bell:          opts.Bell,
+			activityAfter: opts.ActivityAfter,
+			silenceAfter:  opts.SilenceAfter,
+		},
+		alertLines: opts.AlertLines,
+		started:    time.Now(),
+		panes:      make(map[string]*paneState),
+		overrides:  make(map[string]settings),
+	}
+	streamer.Subscribe(m.handleOutput)
+
+	return m
+}
+
+// Subscribe registers fn to be called for every alert
+// fn runs on a monitor goroutine and must not block.
+func (m *Monitor) Subscribe(fn func(protocol.PaneAlertPayload)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.subscribers = append(m.subscribers, fn)
+}
+
+// Run checks for bells and silence every second until the process exits
+func (m *Monitor) Run() {
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		m.checkBells()
+		m.checkSilence(now)
+	}
+}
+
+// SetPane changes the monitoring settings of a pane
+// Fields that are nil keep their current value; reset drops the pane's
+// own settings first so it follows the defaults again.
+func (m *Monitor) SetPane(location protocol.PaneLocation, bell *bool, activityAfter, silenceAfter *time.Duration, reset bool) protocol.PaneMonitor {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.overrides[location.PaneID]
+	if !ok || reset {
+		s = m.defaults
+	}
+	if bell != nil {
+		s.bell = *bell
+	}
+	if activityAfter != nil {
+		s.activityAfter = *activityAfter
+	}
+	if silenceAfter != nil {
+		s.silenceAfter = *silenceAfter
+	}
+
+	if s == m.defaults {
+		delete(m.overrides, location.PaneID)
+	} else {
+		m.overrides[location.PaneID] = s
+	}
+
+	state := m.paneLocked(location)
+	state.location = location
+	// A new silence setting applies to the current quiet period
+	state.silenceFired = false
+
+	return toPaneMonitor(location, s)
+}
+
+// Defaults returns the settings of panes without their own
+func (m *Monitor) Defaults() protocol.PaneMonitor {
+	return toPaneMonitor(protocol.PaneLocation{}, m.defaults)
+}
+
+// List returns the panes with their own monitoring settings
+func (m *Monitor) List() []protocol.PaneMonitor {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make([]protocol.PaneMonitor, 0, len(m.overrides))
+	for id, s := range m.overrides {
+		location := protocol.PaneLocation{PaneID: id}
+		if state, ok := m.panes[id]; ok {
+			location = state.location
+		}
+		result = append(result, toPaneMonitor(location, s))
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.SessionName != b.SessionName {
+			return a.SessionName < b.SessionName
+		}
+		if a.WindowIndex != b.WindowIndex {
+			return a.WindowIndex < b.WindowIndex
+		}
+		return a.PaneIndex < b.PaneIndex
+	})
+
+	return result
+}
+
+// handleOutput records new pane output and raises activity alerts
+func (m *Monitor) handleOutput(output stream.Output) {
+	m.mu.Lock()
+	state := m.paneLocked(output.PaneLocation)
+	s := m.settingsLocked(output.PaneID)
+
+	idle := output.Time.Sub(state.lastOutput)
+	state.location = output.PaneLocation
+	state.lastOutput = output.Time
+	state.hasOutput = true
+	state.silenceFired = false
+	state.recent = lastLines(append(state.recent, output.Lines...), m.alertLines)
+	m.mu.Unlock()
+
+	if s.activityAfter > 0 && idle >= s.activityAfter {
+		m.publish(output.PaneLocation, protocol.AlertActivity, lastLines(output.Lines, m.alertLines))
+	}
+}
+
+// checkBells raises alerts for windows that rang the bell
+func (m *Monitor) checkBells() {
+	bells, err := m.backend.TakeBells()
+	if err != nil {
+		log.Printf("Failed to check bells: %v", err)
+	}
+
+	for _, location := range bells {
+		m.mu.Lock()
+		s := m.settingsLocked(location.PaneID)
+		lines := append([]string{}, m.paneLocked(location).recent...)
+		m.mu.Unlock()
+
+		if s.bell {
+			m.publish(location, protocol.AlertBell, lines)
+		}
+	}
+}
+
+// checkSilence raises alerts for panes that stopped printing
+func (m *Monitor) checkSilence(now time.Time) {
+	type alert struct {
+		location protocol.PaneLocation
+		lines    []string
+	}
+	alerts := make([]alert, 0)
+
+	// Forget panes that no longer exist
+	live := make(map[string]bool)
+	for _, location := range m.streamer.Panes() {
+		live[location.PaneID] = true
+	}
+
+	m.mu.Lock()
+	for id, state := range m.panes {
+		if !live[id] {
+			delete(m.panes, id)
+			delete(m.overrides, id)
+			continue
+		}
+
+		s := m.settingsLocked(id)
+		if s.silenceAfter <= 0 || !state.hasOutput || state.silenceFired {
+			continue
+		}
+		if now.Sub(state.lastOutput) >= s.silenceAfter {
+			state.silenceFired = true
+			alerts = append(alerts, alert{location: state.location, lines: append([]string{}, state.recent...)})
+		}
+	}
+	m.mu.Unlock()
+
+	for _, a := range alerts {
+		m.publish(a.location, protocol.AlertSilence, a.lines)
+	}
+}
+
+// publish sends an alert to all subscribers
+func (m *Monitor) publish(location protocol.PaneLocation, alertType string, lines []string) {
+	alert := protocol.PaneAlertPayload{
+		PaneLocation: location,
+		AlertType:    alertType,
+		Lines:        lines,
+	}
+
+	log.Printf("Pane alert: %s in %s:%d.%d", alertType, location.SessionName, location.WindowIndex, location.PaneIndex)
+
+	m.mu.Lock()
+	subscribers := m.subscribers
+	m.mu.Unlock()
+
+	for _, fn := range subscribers {
+		fn(alert)
+	}
+}
+
+// paneLocked returns the state of a pane, creating it if needed
+// Must be called with m.mu held.
+func (m *Monitor) paneLocked(location protocol.PaneLocation) *paneState {
+	state, ok := m.panes[location.PaneID]
+	if !ok {
+		// Panes count as active since monitoring started
+		state = &paneState{location: location, lastOutput: m.started}
+		m.panes[location.PaneID] = state
+	}
+	return state
+}
+
+// settingsLocked returns the effective settings of a pane
+// Must be called with m.mu held.
+func (m *Monitor) settingsLocked(paneID string) settings {
+	if s, ok := m.overrides[paneID]; ok {
+		return s
+	}
+	return m.defaults
+}
+
+// toPaneMonitor converts settings to their protocol form
+func toPaneMonitor(location protocol.PaneLocation, s settings) protocol.PaneMonitor {
+	return protocol.PaneMonitor{
+		PaneLocation:  location,
+		Bell:          s.bell,
+		ActivityAfter: int(s.activityAfter / time.Second),
+		SilenceAfter:  int(s.silenceAfter / time.Second),
+	}
+}
+
+// lastLines returns at most n trailing lines
+func lastLines(lines []string, n int) []string {
+	if len(lines) <= n {
+		return lines
+	}
+	return lines[len(lines)-n:]
+}
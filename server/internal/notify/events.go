@@ -0,0 +1,121 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// FromPaneAlert converts a pane alert to a notification
+func FromPaneAlert(alert protocol.PaneAlertPayload) Notification {
+	title := ""
+	priority := PriorityDefault
+	switch alert.AlertType {
+	case protocol.AlertBell:
+		title = "Bell in " + paneName(alert.PaneLocation)
+		priority = PriorityHigh
+	case protocol.AlertActivity:
+		title = "Activity in " + paneName(alert.PaneLocation)
+	case protocol.AlertSilence:
+		title = "Silence in " + paneName(alert.PaneLocation)
+	default:
+		title = fmt.Sprintf("Alert in %s", paneName(alert.PaneLocation))
+	}
+
+	return Notification{
+		Event:       EventPaneAlert,
+		Title:       title,
+		Message:     linesMessage(alert.Lines),
+		SessionName: alert.SessionName,
+		Priority:    priority,
+		Data:        alert,
+	}
+}
+
+// FromWatcherMatch converts a watcher match to a notification
+func FromWatcherMatch(match protocol.WatcherMatchPayload) Notification {
+	name := match.WatcherName
+	if name == "" {
+		name = match.Pattern
+	}
+
+	return Notification{
+		Event:       EventWatcherMatch,
+		Title:       fmt.Sprintf("%s matched in %s", name, paneName(match.PaneLocation)),
+		Message:     linesMessage(match.Lines),
+		SessionName: match.SessionName,
+		Priority:    PriorityHigh,
+		Data:        match,
+	}
+}
+
+// FromScheduleRun converts a scheduled command run to a notification
+func FromScheduleRun(schedule protocol.Schedule, run protocol.ScheduleRun) Notification {
+	name := schedule.Name
+	if name == "" {
+		name = schedule.Command
+	}
+
+	n := Notification{
+		Event:       EventScheduleRun,
+		Title:       fmt.Sprintf("Scheduled command ran in %s", schedule.SessionName),
+		Message:     name,
+		SessionName: schedule.SessionName,
+		Priority:    PriorityLow,
+		Data: map[string]interface{}{
+			"schedule": schedule,
+			"run":      run,
+		},
+	}
+	if !run.Success {
+		n.Title = fmt.Sprintf("Scheduled command failed in %s", schedule.SessionName)
+		n.Message = fmt.Sprintf("%s: %s", name, run.Error)
+		n.Priority = PriorityHigh
+	}
+	return n
+}
+
+// FromAgentState converts an agent state change to a notification
+// Only prompts and errors are worth notifying about.
+func FromAgentState(state protocol.AgentStatePayload) (Notification, bool) {
+	switch state.State {
+	case protocol.AgentWaitingForInput:
+		lines := []string{state.Prompt}
+		for i, option := range state.Options {
+			lines = append(lines, fmt.Sprintf("%d. %s", i+1, option.Label))
+		}
+		return Notification{
+			Event:       EventApprovalRequest,
+			Title:       "Agent waiting for input in " + paneName(state.PaneLocation),
+			Message:     strings.TrimSpace(strings.Join(lines, "\n")),
+			SessionName: state.SessionName,
+			Priority:    PriorityHigh,
+			Data:        state,
+		}, true
+	case protocol.AgentErrored:
+		return Notification{
+			Event:       EventAgentError,
+			Title:       "Agent error in " + paneName(state.PaneLocation),
+			Message:     state.Error,
+			SessionName: state.SessionName,
+			Priority:    PriorityDefault,
+			Data:        state,
+		}, true
+	default:
+		return Notification{}, false
+	}
+}
+
+// paneName formats a pane location as session:window.pane
+func paneName(location protocol.PaneLocation) string {
+	return fmt.Sprintf("%s:%d.%d", location.SessionName, location.WindowIndex, location.PaneIndex)
+}
+
+// linesMessage joins output lines into a notification body
+func linesMessage(lines []string) string {
+	if len(lines) == 0 {
+		return "(no output)"
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,183 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// Events that produce notifications
+const (
+	EventPaneAlert       = "pane_alert"
+	EventWatcherMatch    = "watcher_match"
+	EventScheduleRun     = "schedule_run"
+	EventApprovalRequest = "approval_request"
+	EventAgentError      = "agent_error"
+)
+
+// Priorities, following ntfy's 1-5 scale
+const (
+	PriorityLow     = 2
+	PriorityDefault = 3
+	PriorityHigh    = 4
+)
+
+// Notification is a message for the user that doesn't need a connected client
+type Notification struct {
+	Event       string      `json:"event"`
+	Title       string      `json:"title"`
+	Message     string      `json:"message"`
+	SessionName string      `json:"session_name,omitempty"`
+	Priority    int         `json:"priority"`
+	Data        interface{} `json:"data,omitempty"` // The event payload
+	Time        int64       `json:"time"`           // Unix ms
+}
+
+// Sink delivers notifications to one destination
+type Sink interface {
+	Name() string
+	Send(ctx context.Context, n Notification) error
+}
+
+// permanentError marks a failure that retrying won't fix, e.g. a rejected request
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+// Filter selects the notifications a sink receives; empty lists match everything
+type Filter struct {
+	Events      []string `mapstructure:"events"`
+	Sessions    []string `mapstructure:"sessions"`
+	MinPriority int      `mapstructure:"min_priority"`
+}
+
+// matches reports whether n passes the filter
+func (f Filter) matches(n Notification) bool {
+	if n.Priority < f.MinPriority {
+		return false
+	}
+	return matchesAny(f.Events, n.Event) && matchesAny(f.Sessions, n.SessionName)
+}
+
+// matchesAny reports whether value is in values, or values is empty
+func matchesAny(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Options configures a Notifier
+type Options struct {
+	Retries    int           // Additional attempts after a failed delivery
+	RetryDelay time.Duration // Delay before the first retry, doubled for each further one
+	Timeout    time.Duration // Per attempt
+}
+
+// route is a sink with its filter
+type route struct {
+	sink   Sink
+	filter Filter
+}
+
+// Notifier fans notifications out to the sinks whose filters match
+type Notifier struct {
+	opts   Options
+	mu     sync.Mutex
+	routes []route
+	queue  chan Notification
+}
+
+// NewNotifier creates a notifier without sinks
+func NewNotifier(opts Options) *Notifier {
+	if opts.Retries < 0 {
+		opts.Retries = 0
+	}
+	if opts.RetryDelay <= 0 {
+		opts.RetryDelay = 2 * time.Second
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+
+	return &Notifier{
+		opts:  opts,
+		queue: make(chan Notification, 256),
+	}
+}
+
+// AddSink registers a sink receiving the notifications that pass filter
+func (n *Notifier) AddSink(sink Sink, filter Filter) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.routes = append(n.routes, route{sink: sink, filter: filter})
+	log.Printf("Notification sink added: %s", sink.Name())
+}
+
+// Notify queues a notification without blocking
+// Notifications are dropped when the queue is full.
+func (n *Notifier) Notify(notification Notification) {
+	if notification.Time == 0 {
+		notification.Time = time.Now().UnixMilli()
+	}
+	if notification.Priority == 0 {
+		notification.Priority = PriorityDefault
+	}
+
+	select {
+	case n.queue <- notification:
+	default:
+		log.Printf("Notification queue full, dropped %s notification", notification.Event)
+	}
+}
+
+// Run delivers queued notifications until the process exits
+// Each delivery runs on its own goroutine so a slow sink doesn't delay the others.
+func (n *Notifier) Run() {
+	for notification := range n.queue {
+		n.mu.Lock()
+		routes := n.routes
+		n.mu.Unlock()
+
+		for _, r := range routes {
+			if r.filter.matches(notification) {
+				go n.deliver(r.sink, notification)
+			}
+		}
+	}
+}
+
+// deliver sends a notification to a sink, retrying with exponential backoff
+func (n *Notifier) deliver(sink Sink, notification Notification) {
+	delay := n.opts.RetryDelay
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), n.opts.Timeout)
+		err := sink.Send(ctx, notification)
+		cancel()
+		if err == nil {
+			return
+		}
+
+		var permanent *permanentError
+		if errors.As(err, &permanent) || attempt >= n.opts.Retries {
+			log.Printf("Failed to send %s notification to %s: %v", notification.Event, sink.Name(), err)
+			return
+		}
+
+		log.Printf("Sending %s notification to %s failed, retrying in %s: %v", notification.Event, sink.Name(), delay, err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
@@ -0,0 +1,136 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newFlakyStandIn starts an HTTP server failing the first failures requests
+// with status and accepting the rest; every request body is sent on the
+// returned channel
+func newFlakyStandIn(t *testing.T, failures, status int) (*httptest.Server, <-chan Notification) {
+	t.Helper()
+
+	var mu sync.Mutex
+	received := make(chan Notification, 16)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n Notification
+		json.NewDecoder(r.Body).Decode(&n)
+		received <- n
+
+		mu.Lock()
+		defer mu.Unlock()
+		if failures > 0 {
+			failures--
+			w.WriteHeader(status)
+			return
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, received
+}
+
+// newTestNotifier starts a notifier retrying quickly
+func newTestNotifier(retries int) *Notifier {
+	n := NewNotifier(Options{Retries: retries, RetryDelay: time.Millisecond, Timeout: time.Second})
+	go n.Run()
+	return n
+}
+
+// collect waits for count notifications, then checks that no more arrive
+func collect(t *testing.T, received <-chan Notification, count int) []Notification {
+	t.Helper()
+
+	result := make([]Notification, 0, count)
+	timeout := time.After(5 * time.Second)
+	for len(result) < count {
+		select {
+		case n := <-received:
+			result = append(result, n)
+		case <-timeout:
+			t.Fatalf("received %d notifications, want %d", len(result), count)
+		}
+	}
+
+	select {
+	case n := <-received:
+		t.Fatalf("unexpected notification %+v", n)
+	case <-time.After(100 * time.Millisecond):
+	}
+	return result
+}
+
+func addWebhook(t *testing.T, n *Notifier, url string, filter Filter) {
+	t.Helper()
+	sink, err := NewSink(SinkConfig{URL: url})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.AddSink(sink, filter)
+}
+
+func TestRetryOnServerError(t *testing.T) {
+	server, received := newFlakyStandIn(t, 2, http.StatusServiceUnavailable)
+	n := newTestNotifier(3)
+	addWebhook(t, n, server.URL, Filter{})
+
+	n.Notify(testNotification)
+
+	// Two failures and the successful attempt
+	for _, got := range collect(t, received, 3) {
+		if got.Title != testNotification.Title {
+			t.Errorf("retried notification %+v, want %+v", got, testNotification)
+		}
+	}
+}
+
+func TestRetriesAreLimited(t *testing.T) {
+	server, received := newFlakyStandIn(t, 10, http.StatusInternalServerError)
+	n := newTestNotifier(2)
+	addWebhook(t, n, server.URL, Filter{})
+
+	n.Notify(testNotification)
+	collect(t, received, 3)
+}
+
+func TestNoRetryOnClientError(t *testing.T) {
+	server, received := newFlakyStandIn(t, 10, http.StatusBadRequest)
+	n := newTestNotifier(3)
+	addWebhook(t, n, server.URL, Filter{})
+
+	n.Notify(testNotification)
+	collect(t, received, 1)
+}
+
+func TestFilters(t *testing.T) {
+	all, allReceived := newFlakyStandIn(t, 0, 0)
+	filtered, filteredReceived := newFlakyStandIn(t, 0, 0)
+
+	n := newTestNotifier(0)
+	addWebhook(t, n, all.URL, Filter{})
+	addWebhook(t, n, filtered.URL, Filter{
+		Events:      []string{EventWatcherMatch, EventAgentError},
+		Sessions:    []string{"dev"},
+		MinPriority: PriorityDefault,
+	})
+
+	n.Notify(Notification{Event: EventWatcherMatch, SessionName: "dev", Priority: PriorityHigh, Title: "passes"})
+	n.Notify(Notification{Event: EventScheduleRun, SessionName: "dev", Priority: PriorityHigh, Title: "other event"})
+	n.Notify(Notification{Event: EventWatcherMatch, SessionName: "ops", Priority: PriorityHigh, Title: "other session"})
+	n.Notify(Notification{Event: EventAgentError, SessionName: "dev", Priority: PriorityLow, Title: "low priority"})
+	// Notify fills in the default priority, which passes
+	n.Notify(Notification{Event: EventAgentError, SessionName: "dev", Title: "default priority"})
+
+	collect(t, allReceived, 5)
+	got := make(map[string]bool)
+	for _, notification := range collect(t, filteredReceived, 2) {
+		got[notification.Title] = true
+	}
+	if !got["passes"] || !got["default priority"] {
+		t.Errorf("filtered sink received %v, want passes and default priority", got)
+	}
+}
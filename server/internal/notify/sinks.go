@@ -0,0 +1,156 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Sink types
+const (
+	SinkWebhook = "webhook"
+	SinkNtfy    = "ntfy"
+	SinkGotify  = "gotify"
+)
+
+// SinkConfig configures an HTTP sink from the notifications.sinks config list
+type SinkConfig struct {
+	Name    string            `mapstructure:"name"`
+	Type    string            `mapstructure:"type"`
+	URL     string            `mapstructure:"url"`     // Webhook URL, ntfy topic URL or Gotify server URL
+	Token   string            `mapstructure:"token"`   // ntfy access token or Gotify application token
+	Headers map[string]string `mapstructure:"headers"` // Extra request headers
+	Filter  `mapstructure:",squash"`
+}
+
+// NewSink creates an HTTP sink from its configuration
+func NewSink(cfg SinkConfig) (Sink, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("sink '%s' has no url", cfg.Name)
+	}
+	if cfg.Name == "" {
+		cfg.Name = cfg.Type
+	}
+
+	switch cfg.Type {
+	case SinkWebhook, "":
+		return &webhookSink{cfg: cfg}, nil
+	case SinkNtfy:
+		return &ntfySink{cfg: cfg}, nil
+	case SinkGotify:
+		if cfg.Token == "" {
+			return nil, fmt.Errorf("gotify sink '%s' needs an application token", cfg.Name)
+		}
+		return &gotifySink{cfg: cfg}, nil
+	default:
+		return nil, fmt.Errorf("unknown sink type '%s'", cfg.Type)
+	}
+}
+
+// webhookSink posts notifications as JSON
+type webhookSink struct {
+	cfg SinkConfig
+}
+
+func (s *webhookSink) Name() string {
+	return s.cfg.Name
+}
+
+func (s *webhookSink) Send(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return &permanentError{err}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return &permanentError{err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return send(req, s.cfg.Headers)
+}
+
+// ntfySink publishes to an ntfy topic URL, see https://docs.ntfy.sh/publish/
+type ntfySink struct {
+	cfg SinkConfig
+}
+
+func (s *ntfySink) Name() string {
+	return s.cfg.Name
+}
+
+func (s *ntfySink) Send(ctx context.Context, n Notification) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.URL, strings.NewReader(n.Message))
+	if err != nil {
+		return &permanentError{err}
+	}
+	req.Header.Set("Title", n.Title)
+	req.Header.Set("Priority", strconv.Itoa(n.Priority))
+	req.Header.Set("Tags", n.Event)
+	if s.cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.cfg.Token)
+	}
+
+	return send(req, s.cfg.Headers)
+}
+
+// gotifySink posts to a Gotify server's message API
+type gotifySink struct {
+	cfg SinkConfig
+}
+
+func (s *gotifySink) Name() string {
+	return s.cfg.Name
+}
+
+func (s *gotifySink) Send(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"title":    n.Title,
+		"message":  n.Message,
+		"priority": n.Priority * 2, // Gotify uses 0-10
+	})
+	if err != nil {
+		return &permanentError{err}
+	}
+
+	url := strings.TrimRight(s.cfg.URL, "/") + "/message"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return &permanentError{err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Gotify-Key", s.cfg.Token)
+
+	return send(req, s.cfg.Headers)
+}
+
+// send performs a sink request and classifies the response
+// Rate limiting and server errors are retried, other client errors are not.
+func send(req *http.Request, headers map[string]string) error {
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return err
+	}
+	return &permanentError{err}
+}
@@ -0,0 +1,167 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// request is what a stand-in server received
+type request struct {
+	method string
+	path   string
+	header http.Header
+	body   []byte
+}
+
+// newStandIn starts an HTTP server answering every request with status and
+// recording it
+func newStandIn(t *testing.T, status int) (*httptest.Server, <-chan request) {
+	t.Helper()
+
+	requests := make(chan request, 16)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{method: r.Method, path: r.URL.Path, header: r.Header, body: body}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+var testNotification = Notification{
+	Event:       EventWatcherMatch,
+	Title:       "error matched in dev:0.0",
+	Message:     "panic: boom",
+	SessionName: "dev",
+	Priority:    PriorityHigh,
+	Time:        1700000000000,
+}
+
+func TestWebhookSink(t *testing.T) {
+	server, requests := newStandIn(t, http.StatusOK)
+	sink, err := NewSink(SinkConfig{Type: SinkWebhook, URL: server.URL + "/hook", Headers: map[string]string{"X-Secret": "s3"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sink.Send(context.Background(), testNotification); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	req := <-requests
+	if req.method != http.MethodPost || req.path != "/hook" {
+		t.Errorf("request = %s %s, want POST /hook", req.method, req.path)
+	}
+	if got := req.header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+	if got := req.header.Get("X-Secret"); got != "s3" {
+		t.Errorf("X-Secret = %q, want s3", got)
+	}
+
+	var body Notification
+	if err := json.Unmarshal(req.body, &body); err != nil {
+		t.Fatalf("body is not a notification: %v", err)
+	}
+	if body != testNotification {
+		t.Errorf("body = %+v, want %+v", body, testNotification)
+	}
+}
+
+func TestNtfySink(t *testing.T) {
+	server, requests := newStandIn(t, http.StatusOK)
+	sink, err := NewSink(SinkConfig{Type: SinkNtfy, URL: server.URL + "/handx", Token: "tk"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sink.Send(context.Background(), testNotification); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	req := <-requests
+	if req.path != "/handx" {
+		t.Errorf("path = %q, want /handx", req.path)
+	}
+	if string(req.body) != testNotification.Message {
+		t.Errorf("body = %q, want %q", req.body, testNotification.Message)
+	}
+	want := map[string]string{
+		"Title":         testNotification.Title,
+		"Priority":      "4",
+		"Tags":          EventWatcherMatch,
+		"Authorization": "Bearer tk",
+	}
+	for name, value := range want {
+		if got := req.header.Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+}
+
+func TestGotifySink(t *testing.T) {
+	server, requests := newStandIn(t, http.StatusOK)
+	if _, err := NewSink(SinkConfig{Type: SinkGotify, URL: server.URL}); err == nil {
+		t.Error("gotify sink without a token was accepted")
+	}
+	sink, err := NewSink(SinkConfig{Type: SinkGotify, URL: server.URL + "/", Token: "app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sink.Send(context.Background(), testNotification); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	req := <-requests
+	if req.path != "/message" {
+		t.Errorf("path = %q, want /message", req.path)
+	}
+	if got := req.header.Get("X-Gotify-Key"); got != "app" {
+		t.Errorf("X-Gotify-Key = %q, want app", got)
+	}
+
+	var body struct {
+		Title    string `json:"title"`
+		Message  string `json:"message"`
+		Priority int    `json:"priority"`
+	}
+	if err := json.Unmarshal(req.body, &body); err != nil {
+		t.Fatalf("body is not JSON: %v", err)
+	}
+	if body.Title != testNotification.Title || body.Message != testNotification.Message || body.Priority != 8 {
+		t.Errorf("body = %+v, want title, message and priority 8", body)
+	}
+}
+
+func TestSendErrors(t *testing.T) {
+	tests := []struct {
+		status    int
+		permanent bool
+	}{
+		{http.StatusInternalServerError, false},
+		{http.StatusBadGateway, false},
+		{http.StatusTooManyRequests, false},
+		{http.StatusBadRequest, true},
+		{http.StatusUnauthorized, true},
+	}
+
+	for _, tt := range tests {
+		server, _ := newStandIn(t, tt.status)
+		sink, err := NewSink(SinkConfig{URL: server.URL})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = sink.Send(context.Background(), testNotification)
+		var permanent *permanentError
+		if err == nil || errors.As(err, &permanent) != tt.permanent {
+			t.Errorf("status %d: error = %v, want permanent %v", tt.status, err, tt.permanent)
+		}
+	}
+}
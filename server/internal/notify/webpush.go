@@ -0,0 +1,207 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/SherClockHolmes/webpush-go"
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// pushState is persisted so browsers stay subscribed across restarts;
+// subscriptions are bound to the VAPID key they were created with
+type pushState struct {
+	PublicKey     string                      `json:"public_key"`
+	PrivateKey    string                      `json:"private_key"`
+	Subscriptions []protocol.PushSubscription `json:"subscriptions"`
+}
+
+// WebPush delivers notifications to subscribed browsers, e.g. the installed PWA
+type WebPush struct {
+	path    string
+	subject string // VAPID contact, a mailto: or https: URL
+	mu      sync.Mutex
+	state   pushState
+}
+
+// NewWebPush loads the VAPID keys and subscriptions from path, generating
+// keys on first use
+func NewWebPush(path, subject string) (*WebPush, error) {
+	w := &WebPush{
+		path:    path,
+		subject: subject,
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &w.state); err != nil {
+			return nil, fmt.Errorf("failed to parse web push state: %w", err)
+		}
+	case os.IsNotExist(err):
+	default:
+		return nil, fmt.Errorf("failed to read web push state: %w", err)
+	}
+
+	if w.state.PublicKey == "" || w.state.PrivateKey == "" {
+		private, public, err := webpush.GenerateVAPIDKeys()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate VAPID keys: %w", err)
+		}
+		w.state = pushState{PublicKey: public, PrivateKey: private}
+		if err := w.save(); err != nil {
+			return nil, err
+		}
+		log.Printf("Generated VAPID keys for web push in %s", path)
+	}
+
+	return w, nil
+}
+
+// PublicKey returns the VAPID public key browsers subscribe with
+func (w *WebPush) PublicKey() string {
+	return w.state.PublicKey
+}
+
+// Subscribe adds or refreshes a browser subscription
+func (w *WebPush) Subscribe(sub protocol.PushSubscription) error {
+	if sub.Endpoint == "" || sub.Keys.P256dh == "" || sub.Keys.Auth == "" {
+		return fmt.Errorf("subscription needs an endpoint and keys")
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.removeLocked(sub.Endpoint)
+	w.state.Subscriptions = append(w.state.Subscriptions, sub)
+	return w.save()
+}
+
+// Unsubscribe removes a browser subscription
+func (w *WebPush) Unsubscribe(endpoint string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.removeLocked(endpoint) {
+		return fmt.Errorf("subscription not found")
+	}
+	return w.save()
+}
+
+// Name implements Sink
+func (w *WebPush) Name() string {
+	return "web_push"
+}
+
+// Send implements Sink, pushing to every subscribed browser
+// Subscriptions the push service reports as gone are dropped. An error is
+// only returned when no browser received the notification.
+func (w *WebPush) Send(ctx context.Context, n Notification) error {
+	w.mu.Lock()
+	subs := append([]protocol.PushSubscription{}, w.state.Subscriptions...)
+	w.mu.Unlock()
+
+	if len(subs) == 0 {
+		return nil
+	}
+
+	// The service worker shows title and body and keeps the rest for clicks
+	message, err := json.Marshal(map[string]interface{}{
+		"title":        n.Title,
+		"body":         n.Message,
+		"event":        n.Event,
+		"session_name": n.SessionName,
+		"time":         n.Time,
+	})
+	if err != nil {
+		return &permanentError{err}
+	}
+
+	urgency := webpush.UrgencyNormal
+	if n.Priority >= PriorityHigh {
+		urgency = webpush.UrgencyHigh
+	} else if n.Priority <= PriorityLow {
+		urgency = webpush.UrgencyLow
+	}
+
+	var lastErr error
+	delivered := 0
+	for _, sub := range subs {
+		resp, err := webpush.SendNotificationWithContext(ctx, message, &webpush.Subscription{
+			Endpoint: sub.Endpoint,
+			Keys:     webpush.Keys{Auth: sub.Keys.Auth, P256dh: sub.Keys.P256dh},
+		}, &webpush.Options{
+			Subscriber:      strings.TrimPrefix(w.subject, "mailto:"), // Added back by the library
+			VAPIDPublicKey:  w.state.PublicKey,
+			VAPIDPrivateKey: w.state.PrivateKey,
+			TTL:             3600,
+			Urgency:         urgency,
+		})
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+
+		switch {
+		case resp.StatusCode >= 200 && resp.StatusCode < 300:
+			delivered++
+		case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+			log.Printf("Dropping expired web push subscription %s", sub.Endpoint)
+			w.mu.Lock()
+			if w.removeLocked(sub.Endpoint) {
+				if err := w.save(); err != nil {
+					log.Printf("Failed to persist web push subscriptions: %v", err)
+				}
+			}
+			w.mu.Unlock()
+		default:
+			lastErr = fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(detail)))
+		}
+	}
+
+	if delivered == 0 && lastErr != nil {
+		return lastErr
+	}
+	return nil
+}
+
+// removeLocked removes the subscription with endpoint; callers must hold the lock
+func (w *WebPush) removeLocked(endpoint string) bool {
+	for i, sub := range w.state.Subscriptions {
+		if sub.Endpoint == endpoint {
+			w.state.Subscriptions = append(w.state.Subscriptions[:i], w.state.Subscriptions[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// save writes the keys and subscriptions to disk; callers must hold the lock
+func (w *WebPush) save() error {
+	data, err := json.MarshalIndent(w.state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode web push state: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(w.path), 0700); err != nil {
+		return fmt.Errorf("failed to create web push directory: %w", err)
+	}
+
+	// Write to a temp file first so a crash never leaves a truncated file
+	tmp := w.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write web push state: %w", err)
+	}
+	return os.Rename(tmp, w.path)
+}
@@ -0,0 +1,119 @@
+package notify
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// newSubscription returns a browser subscription to endpoint with valid keys
+func newSubscription(t *testing.T, endpoint string) protocol.PushSubscription {
+	t.Helper()
+
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := make([]byte, 16)
+	rand.Read(auth)
+
+	sub := protocol.PushSubscription{Endpoint: endpoint}
+	sub.Keys.P256dh = base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes())
+	sub.Keys.Auth = base64.RawURLEncoding.EncodeToString(auth)
+	return sub
+}
+
+func newTestWebPush(t *testing.T) (*WebPush, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "webpush.json")
+	w, err := NewWebPush(path, "mailto:ops@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w, path
+}
+
+func TestWebPushSend(t *testing.T) {
+	server, requests := newStandIn(t, http.StatusCreated)
+	w, _ := newTestWebPush(t)
+	if err := w.Subscribe(newSubscription(t, server.URL+"/push/1")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Send(context.Background(), testNotification); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	req := <-requests
+	if req.method != http.MethodPost || req.path != "/push/1" {
+		t.Errorf("request = %s %s, want POST /push/1", req.method, req.path)
+	}
+	want := map[string]string{
+		"Content-Encoding": "aes128gcm",
+		"TTL":              "3600",
+		"Urgency":          "high",
+	}
+	for name, value := range want {
+		if got := req.header.Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+	if auth := req.header.Get("Authorization"); !strings.HasPrefix(auth, "vapid t=") || !strings.Contains(auth, "k="+w.PublicKey()) {
+		t.Errorf("Authorization = %q, want a VAPID header with the public key", auth)
+	}
+	// The payload is encrypted for the subscription
+	if len(req.body) == 0 || strings.Contains(string(req.body), testNotification.Title) {
+		t.Errorf("body is empty or not encrypted: %q", req.body)
+	}
+}
+
+func TestWebPushDropsExpiredSubscriptions(t *testing.T) {
+	gone, _ := newStandIn(t, http.StatusGone)
+	live, _ := newStandIn(t, http.StatusCreated)
+	w, path := newTestWebPush(t)
+	for _, endpoint := range []string{gone.URL, live.URL} {
+		if err := w.Subscribe(newSubscription(t, endpoint)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := w.Send(context.Background(), testNotification); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	// Dropped in memory and on disk
+	reloaded, err := NewWebPush(path, "mailto:ops@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, state := range []pushState{w.state, reloaded.state} {
+		if len(state.Subscriptions) != 1 || state.Subscriptions[0].Endpoint != live.URL {
+			t.Errorf("subscriptions = %+v, want only %s", state.Subscriptions, live.URL)
+		}
+	}
+}
+
+func TestWebPushServerError(t *testing.T) {
+	server, _ := newStandIn(t, http.StatusInternalServerError)
+	w, _ := newTestWebPush(t)
+	if err := w.Subscribe(newSubscription(t, server.URL)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Not permanent, so the notifier retries
+	err := w.Send(context.Background(), testNotification)
+	if _, permanent := err.(*permanentError); err == nil || permanent {
+		t.Errorf("Send = %v, want a retryable error", err)
+	}
+	if len(w.state.Subscriptions) != 1 {
+		t.Errorf("subscription dropped after a server error")
+	}
+}
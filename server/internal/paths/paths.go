@@ -0,0 +1,46 @@
+// Package paths resolves file paths given in configuration and templates,
+// quotes them for shells and derives file names
+package paths
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ExpandHome expands a leading ~ to the user's home directory
+// Paths without one, and all paths when the home directory is unknown, are
+// returned unchanged.
+func ExpandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
+
+// Quote joins arguments into a command line for a POSIX shell, quoting each
+// as a single word
+func Quote(args ...string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
+
+// SanitizeFileName replaces characters that are unsafe in file names, or in
+// the Content-Disposition header they are downloaded with
+func SanitizeFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' || r == '"' || r < ' ' {
+			return '_'
+		}
+		return r
+	}, name)
+}
@@ -0,0 +1,150 @@
+package procinfo
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// process is a raw process table entry
+type process struct {
+	pid        int
+	ppid       int
+	command    string
+	args       string  // Filled lazily on platforms where it is expensive to read
+	cpuTicks   uint64  // Total user+system CPU time in clock ticks (Linux only)
+	startTicks uint64  // Start time after boot in clock ticks (Linux only)
+	cpuPercent float64 // Set directly on platforms that report it
+	rss        int64   // Resident set size in bytes
+}
+
+// Table is a snapshot of the system process table
+type Table struct {
+	procs    map[int]*process
+	children map[int][]int
+	taken    time.Time
+}
+
+// minSampleInterval is how long after a CPU sample the next one is taken;
+// snapshots in between report the last computed usage, since a few
+// milliseconds hold too few clock ticks to measure it
+const minSampleInterval = time.Second
+
+// cpuSample remembers a process's CPU time at a point in time and the usage
+// computed then
+type cpuSample struct {
+	ticks   uint64
+	at      time.Time
+	percent float64
+}
+
+// Previous CPU samples so repeated snapshots report current rather than lifetime usage
+var (
+	samples   = make(map[int]cpuSample)
+	samplesMu sync.Mutex
+)
+
+// Snapshot reads the current process table
+func Snapshot() (*Table, error) {
+	procs, err := readProcesses()
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Table{
+		procs:    make(map[int]*process, len(procs)),
+		children: make(map[int][]int),
+		taken:    time.Now(),
+	}
+	for _, p := range procs {
+		t.procs[p.pid] = p
+		t.children[p.ppid] = append(t.children[p.ppid], p.pid)
+	}
+	for _, pids := range t.children {
+		sort.Ints(pids)
+	}
+
+	t.updateCPU()
+	return t, nil
+}
+
+// Tree returns pid and all of its descendants, parents before children
+func (t *Table) Tree(pid int) []protocol.Process {
+	result := make([]protocol.Process, 0)
+	if _, ok := t.procs[pid]; !ok {
+		return result
+	}
+
+	var walk func(pid int)
+	walk = func(pid int) {
+		p := t.procs[pid]
+		if p.args == "" {
+			p.args = readArgs(p)
+		}
+		result = append(result, protocol.Process{
+			PID:        p.pid,
+			PPID:       p.ppid,
+			Command:    p.command,
+			Args:       p.args,
+			CPUPercent: math.Round(p.cpuPercent*10) / 10,
+			MemoryRSS:  p.rss,
+		})
+		for _, child := range t.children[pid] {
+			walk(child)
+		}
+	}
+	walk(pid)
+
+	return result
+}
+
+// Foreground returns the most recently started direct child of pid, which
+// for a pane's shell is the program running in front, or nil if there is none
+func (t *Table) Foreground(pid int) *protocol.Process {
+	children := t.children[pid]
+	if len(children) == 0 {
+		return nil
+	}
+
+	tree := t.Tree(children[len(children)-1])
+	return &tree[0]
+}
+
+// updateCPU computes CPU usage since the previous sample for platforms
+// that only report cumulative CPU time. Processes seen for the first time
+// report their lifetime average.
+func (t *Table) updateCPU() {
+	if clockTicks == 0 {
+		// ps already reported percentages
+		return
+	}
+
+	samplesMu.Lock()
+	defer samplesMu.Unlock()
+
+	uptime := systemUptimeTicks()
+	for pid, p := range t.procs {
+		prev, ok := samples[pid]
+		switch {
+		case ok && p.cpuTicks >= prev.ticks && t.taken.Sub(prev.at) < minSampleInterval:
+			p.cpuPercent = prev.percent
+			continue
+		case ok && p.cpuTicks >= prev.ticks:
+			elapsed := t.taken.Sub(prev.at).Seconds() * float64(clockTicks)
+			p.cpuPercent = float64(p.cpuTicks-prev.ticks) / elapsed * 100
+		case uptime > p.startTicks:
+			p.cpuPercent = float64(p.cpuTicks) / float64(uptime-p.startTicks) * 100
+		}
+		samples[pid] = cpuSample{ticks: p.cpuTicks, at: t.taken, percent: p.cpuPercent}
+	}
+
+	// Forget processes that have exited
+	for pid := range samples {
+		if _, ok := t.procs[pid]; !ok {
+			delete(samples, pid)
+		}
+	}
+}
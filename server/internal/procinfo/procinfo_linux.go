@@ -0,0 +1,104 @@
+package procinfo
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// clockTicks is USER_HZ, the unit of CPU times in /proc; 100 on all mainstream architectures
+const clockTicks = 100
+
+// readProcesses reads every process from /proc/<pid>/stat
+func readProcesses() ([]*process, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read /proc: %w", err)
+	}
+
+	pageSize := int64(os.Getpagesize())
+	procs := make([]*process, 0, len(entries))
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		// Processes may exit while we scan
+		data, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+
+		p, ok := parseStat(pid, data, pageSize)
+		if ok {
+			procs = append(procs, p)
+		}
+	}
+
+	return procs, nil
+}
+
+// parseStat parses /proc/<pid>/stat; see proc(5)
+func parseStat(pid int, data []byte, pageSize int64) (*process, bool) {
+	// comm is in parentheses and may itself contain spaces or parentheses
+	start := bytes.IndexByte(data, '(')
+	end := bytes.LastIndexByte(data, ')')
+	if start < 0 || end < start {
+		return nil, false
+	}
+
+	// Fields after comm, starting with field 3 (state)
+	fields := strings.Fields(string(data[end+1:]))
+	if len(fields) < 22 {
+		return nil, false
+	}
+
+	ppid, _ := strconv.Atoi(fields[1])
+	utime, _ := strconv.ParseUint(fields[11], 10, 64)
+	stime, _ := strconv.ParseUint(fields[12], 10, 64)
+	startTicks, _ := strconv.ParseUint(fields[19], 10, 64)
+	rssPages, _ := strconv.ParseInt(fields[21], 10, 64)
+
+	return &process{
+		pid:        pid,
+		ppid:       ppid,
+		command:    string(data[start+1 : end]),
+		cpuTicks:   utime + stime,
+		startTicks: startTicks,
+		rss:        rssPages * pageSize,
+	}, true
+}
+
+// readArgs reads a process's command line from /proc/<pid>/cmdline
+func readArgs(p *process) string {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(p.pid), "cmdline"))
+	if err != nil || len(data) == 0 {
+		// Kernel threads and zombies have no command line
+		return p.command
+	}
+
+	return strings.Join(strings.Split(strings.TrimRight(string(data), "\x00"), "\x00"), " ")
+}
+
+// systemUptimeTicks returns the time since boot in clock ticks
+func systemUptimeTicks() uint64 {
+	data, err := os.ReadFile("/proc/uptime")
+	if err != nil {
+		return 0
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0
+	}
+
+	seconds, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0
+	}
+	return uint64(seconds * clockTicks)
+}
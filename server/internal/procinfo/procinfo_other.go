@@ -0,0 +1,64 @@
+//go:build !linux
+
+package procinfo
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// clockTicks is zero because ps already reports CPU percentages here
+const clockTicks = 0
+
+// readProcesses reads the process table with ps, which on macOS and the BSDs
+// reports a decaying CPU average directly
+func readProcesses() ([]*process, error) {
+	output, err := exec.Command("ps", "-A", "-o", "pid=,ppid=,pcpu=,rss=,args=").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run ps: %w", err)
+	}
+
+	procs := make([]*process, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+
+		pid, err1 := strconv.Atoi(fields[0])
+		ppid, err2 := strconv.Atoi(fields[1])
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		cpu, _ := strconv.ParseFloat(fields[2], 64)
+		rssKB, _ := strconv.ParseInt(fields[3], 10, 64)
+
+		procs = append(procs, &process{
+			pid:        pid,
+			ppid:       ppid,
+			command:    strings.TrimPrefix(filepath.Base(fields[4]), "-"),
+			args:       strings.Join(fields[4:], " "),
+			cpuPercent: cpu,
+			rss:        rssKB * 1024,
+		})
+	}
+
+	return procs, nil
+}
+
+// readArgs returns the command line captured by ps
+func readArgs(p *process) string {
+	return p.args
+}
+
+// systemUptimeTicks is unused without cumulative CPU times
+func systemUptimeTicks() uint64 {
+	return 0
+}
@@ -0,0 +1,63 @@
+// Package pty opens pseudo terminals for processes that expect a terminal
+package pty
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// Open opens a new pseudo terminal and returns its master and slave ends
+func Open() (*os.File, *os.File, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open /dev/ptmx: %w", err)
+	}
+
+	// Going through the raw connection keeps the master non-blocking, so
+	// closing it ends a pending read
+	var number int
+	var ioctlErr error
+	conn, err := master.SyscallConn()
+	if err == nil {
+		err = conn.Control(func(fd uintptr) {
+			if ioctlErr = unix.IoctlSetPointerInt(int(fd), unix.TIOCSPTLCK, 0); ioctlErr == nil {
+				number, ioctlErr = unix.IoctlGetInt(int(fd), unix.TIOCGPTN)
+			}
+		})
+	}
+	if err == nil {
+		err = ioctlErr
+	}
+	if err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("failed to unlock pseudo terminal: %w", err)
+	}
+
+	slave, err := os.OpenFile(fmt.Sprintf("/dev/pts/%d", number), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("failed to open pseudo terminal: %w", err)
+	}
+	return master, slave, nil
+}
+
+// SetSize sets the window size of a pseudo terminal, signalling the process
+// attached to it
+func SetSize(pty *os.File, cols, rows int) error {
+	conn, err := pty.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var ioctlErr error
+	err = conn.Control(func(fd uintptr) {
+		ioctlErr = unix.IoctlSetWinsize(int(fd), unix.TIOCSWINSZ, &unix.Winsize{Row: uint16(rows), Col: uint16(cols)})
+	})
+	if err != nil {
+		return err
+	}
+	return ioctlErr
+}
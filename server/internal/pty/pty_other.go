@@ -0,0 +1,18 @@
+//go:build !linux
+
+package pty
+
+import (
+	"fmt"
+	"os"
+)
+
+// Open is only implemented on Linux
+func Open() (*os.File, *os.File, error) {
+	return nil, nil, fmt.Errorf("pseudo terminals are not supported on this platform")
+}
+
+// SetSize is only implemented on Linux
+func SetSize(pty *os.File, cols, rows int) error {
+	return fmt.Errorf("pseudo terminals are not supported on this platform")
+}
@@ -0,0 +1,102 @@
+package recording
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+)
+
+// castVersion is the asciicast format version written and read
+const castVersion = 2
+
+// Event types of asciicast v2 files
+const (
+	EventOutput = "o"
+	EventResize = "r"
+)
+
+// Header is the first line of an asciicast v2 file
+type Header struct {
+	Version   int    `json:"version"`
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+	Timestamp int64  `json:"timestamp"`
+	Title     string `json:"title,omitempty"`
+}
+
+// Event is a line of an asciicast v2 file after the header, stored as a
+// [time, type, data] array
+type Event struct {
+	Time float64 // Seconds since the recording started
+	Type string
+	Data string
+}
+
+// MarshalJSON encodes an event as an array
+func (e Event) MarshalJSON() ([]byte, error) {
+	// Microseconds are as precise as asciinema itself records
+	return json.Marshal([]interface{}{math.Round(e.Time*1e6) / 1e6, e.Type, e.Data})
+}
+
+// UnmarshalJSON decodes an event from an array
+func (e *Event) UnmarshalJSON(data []byte) error {
+	var fields []json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if len(fields) != 3 {
+		return fmt.Errorf("event has %d fields, expected 3", len(fields))
+	}
+	if err := json.Unmarshal(fields[0], &e.Time); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(fields[1], &e.Type); err != nil {
+		return err
+	}
+	return json.Unmarshal(fields[2], &e.Data)
+}
+
+// Play calls fn for every output event, waiting between events as long as
+// the recording did divided by speed; pauses are capped at maxIdle seconds
+// unless it is 0. It reports false if stop was closed before the end.
+func Play(events []Event, speed, maxIdle float64, stop <-chan struct{}, fn func(Event)) bool {
+	if speed <= 0 {
+		speed = 1
+	}
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	<-timer.C
+
+	last := 0.0
+	for _, event := range events {
+		if event.Type != EventOutput {
+			continue
+		}
+
+		pause := event.Time - last
+		if maxIdle > 0 && pause > maxIdle {
+			pause = maxIdle
+		}
+		last = event.Time
+
+		if pause > 0 {
+			timer.Reset(time.Duration(pause / speed * float64(time.Second)))
+			select {
+			case <-stop:
+				return false
+			case <-timer.C:
+			}
+		} else {
+			select {
+			case <-stop:
+				return false
+			default:
+			}
+		}
+
+		fn(event)
+	}
+	return true
+}
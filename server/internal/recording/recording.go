@@ -0,0 +1,549 @@
+package recording
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/myan/handx-server/internal/paths"
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+const (
+	// pollInterval is how often piped output is turned into events, and so
+	// the timing resolution of recordings
+	pollInterval = 100 * time.Millisecond
+
+	// paneCheckInterval is how often the recorded pane is checked for
+	// resizes and for having closed
+	paneCheckInterval = 2 * time.Second
+
+	// DownloadPath is where the HTTP server serves recordings, followed by the ID
+	DownloadPath = "/recordings/"
+)
+
+// Recording IDs are generated by generateRecordingID; anything else could
+// point outside the recordings directory
+var recordingIDRegex = regexp.MustCompile(`^rec-[0-9a-f]{16}$`)
+
+// Errors returned for requests on recordings
+var (
+	ErrNotFound         = errors.New("recording not found")
+	ErrAlreadyRecording = errors.New("pane is already being recorded")
+)
+
+// Backend is the subset of the tmux manager used to record panes
+type Backend interface {
+	PipePane(paneID, command string) error
+	PaneSize(paneID string) (int, int, error)
+	CapturePane(paneID string, lines int) (string, error)
+}
+
+// activeRecording is a recording in progress
+// The pane's output is piped by tmux into a raw file, which is read every
+// pollInterval and appended to the asciicast file as timed events.
+type activeRecording struct {
+	meta    protocol.Recording
+	start   time.Time
+	raw     *os.File
+	cast    *os.File
+	pending []byte // Incomplete UTF-8 sequence held back for the next event
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// Recorder records pane output to asciicast v2 files
+// Each recording is stored as <id>.cast with its metadata in <id>.json.
+type Recorder struct {
+	backend     Backend
+	dir         string
+	maxDuration time.Duration
+	mu          sync.Mutex
+	active      map[string]*activeRecording // Keyed by recording ID
+}
+
+// NewRecorder creates a recorder storing recordings in dir; recordings are
+// stopped after maxDuration unless it is 0
+// Recordings left active by a previous run are finished.
+func NewRecorder(backend Backend, dir string, maxDuration time.Duration) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create recordings directory: %w", err)
+	}
+
+	r := &Recorder{
+		backend:     backend,
+		dir:         dir,
+		maxDuration: maxDuration,
+		active:      make(map[string]*activeRecording),
+	}
+	r.finishAbandoned()
+
+	return r, nil
+}
+
+// Start begins recording a pane
+func (r *Recorder) Start(location protocol.PaneLocation, title string) (*protocol.Recording, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, a := range r.active {
+		if a.meta.PaneID == location.PaneID {
+			return nil, fmt.Errorf("%w: %s", ErrAlreadyRecording, a.meta.ID)
+		}
+	}
+
+	width, height, err := r.backend.PaneSize(location.PaneID)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := generateRecordingID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate recording ID: %w", err)
+	}
+
+	now := time.Now()
+	a := &activeRecording{
+		meta: protocol.Recording{
+			ID:           id,
+			PaneLocation: location,
+			Title:        title,
+			Width:        width,
+			Height:       height,
+			StartedAt:    now.UnixMilli(),
+			Active:       true,
+			URL:          DownloadPath + id,
+		},
+		start: now,
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+
+	a.cast, err = os.OpenFile(r.castPath(id), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recording: %w", err)
+	}
+	a.raw, err = os.OpenFile(r.rawPath(id), os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0600)
+	if err != nil {
+		a.cast.Close()
+		os.Remove(r.castPath(id))
+		return nil, fmt.Errorf("failed to create recording: %w", err)
+	}
+
+	cleanup := func() {
+		a.cast.Close()
+		a.raw.Close()
+		os.Remove(r.castPath(id))
+		os.Remove(r.rawPath(id))
+	}
+
+	header, _ := json.Marshal(Header{
+		Version:   castVersion,
+		Width:     width,
+		Height:    height,
+		Timestamp: now.Unix(),
+		Title:     title,
+	})
+	if _, err := a.cast.Write(append(header, '\n')); err != nil {
+		cleanup()
+		return nil, fmt.Errorf("failed to write recording: %w", err)
+	}
+
+	// Start from what the pane shows, output only draws on top of it
+	if screen, err := r.backend.CapturePane(location.PaneID, 0); err == nil {
+		screen = strings.ReplaceAll(strings.TrimRight(screen, "\n"), "\n", "\r\n")
+		r.writeEvent(a, Event{Time: 0, Type: EventOutput, Data: "\x1b[H\x1b[2J" + screen})
+	}
+
+	if err := r.backend.PipePane(location.PaneID, "cat >> "+paths.Quote(r.rawPath(id))); err != nil {
+		cleanup()
+		return nil, err
+	}
+
+	if err := r.saveMeta(a.meta); err != nil {
+		r.backend.PipePane(location.PaneID, "")
+		cleanup()
+		return nil, err
+	}
+
+	r.active[id] = a
+	go r.run(a)
+
+	log.Printf("Started recording %s of %s:%d.%d", id, location.SessionName, location.WindowIndex, location.PaneIndex)
+	meta := a.meta
+	return &meta, nil
+}
+
+// Stop finishes a recording
+func (r *Recorder) Stop(id string) (*protocol.Recording, error) {
+	r.mu.Lock()
+	a, ok := r.active[id]
+	if ok {
+		delete(r.active, id)
+	}
+	r.mu.Unlock()
+
+	if !ok {
+		if _, err := r.Get(id); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("recording '%s' is not active", id)
+	}
+
+	// Stop piping first so the last output is still read
+	if err := r.backend.PipePane(a.meta.PaneID, ""); err != nil {
+		log.Printf("Failed to stop piping pane %s: %v", a.meta.PaneID, err)
+	}
+	close(a.stop)
+	<-a.done
+
+	a.raw.Close()
+	a.cast.Close()
+	os.Remove(r.rawPath(id))
+
+	a.meta.Active = false
+	a.meta.StoppedAt = time.Now().UnixMilli()
+	if info, err := os.Stat(r.castPath(id)); err == nil {
+		a.meta.Size = info.Size()
+	}
+	if err := r.saveMeta(a.meta); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Stopped recording %s after %.1fs", id, a.meta.Duration)
+	return &a.meta, nil
+}
+
+// StopPane finishes the recording of a pane
+func (r *Recorder) StopPane(paneID string) (*protocol.Recording, error) {
+	r.mu.Lock()
+	id := ""
+	for _, a := range r.active {
+		if a.meta.PaneID == paneID {
+			id = a.meta.ID
+		}
+	}
+	r.mu.Unlock()
+
+	if id == "" {
+		return nil, fmt.Errorf("%w: pane %s is not being recorded", ErrNotFound, paneID)
+	}
+	return r.Stop(id)
+}
+
+// List returns all recordings, newest first
+func (r *Recorder) List() ([]protocol.Recording, error) {
+	paths, err := filepath.Glob(filepath.Join(r.dir, "rec-*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]protocol.Recording, 0, len(paths))
+	for _, path := range paths {
+		id := strings.TrimSuffix(filepath.Base(path), ".json")
+		meta, err := r.Get(id)
+		if err != nil {
+			log.Printf("Skipping recording %s: %v", id, err)
+			continue
+		}
+		result = append(result, *meta)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].StartedAt > result[j].StartedAt
+	})
+	return result, nil
+}
+
+// Get returns the metadata of a recording
+func (r *Recorder) Get(id string) (*protocol.Recording, error) {
+	if !recordingIDRegex.MatchString(id) {
+		return nil, fmt.Errorf("%w: '%s'", ErrNotFound, id)
+	}
+
+	r.mu.Lock()
+	if a, ok := r.active[id]; ok {
+		meta := a.meta
+		r.mu.Unlock()
+		if info, err := os.Stat(r.castPath(id)); err == nil {
+			meta.Size = info.Size()
+		}
+		return &meta, nil
+	}
+	r.mu.Unlock()
+
+	return r.loadMeta(id)
+}
+
+// Delete removes a recording, stopping it first if it is active
+func (r *Recorder) Delete(id string) error {
+	meta, err := r.Get(id)
+	if err != nil {
+		return err
+	}
+	if meta.Active {
+		if _, err := r.Stop(id); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Remove(r.castPath(id)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete recording: %w", err)
+	}
+	if err := os.Remove(r.metaPath(id)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete recording: %w", err)
+	}
+
+	log.Printf("Deleted recording %s", id)
+	return nil
+}
+
+// CastPath returns the path of a recording's asciicast file
+func (r *Recorder) CastPath(id string) (string, error) {
+	if _, err := r.Get(id); err != nil {
+		return "", err
+	}
+	return r.castPath(id), nil
+}
+
+// Load reads the header and events of a recording; active recordings are
+// read as far as they got
+func (r *Recorder) Load(id string) (*Header, []Event, error) {
+	path, err := r.CastPath(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	// A single event holds everything printed within one poll
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	if !scanner.Scan() {
+		return nil, nil, fmt.Errorf("recording '%s' is empty", id)
+	}
+	var header Header
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse recording header: %w", err)
+	}
+	if header.Version != castVersion {
+		return nil, nil, fmt.Errorf("unsupported asciicast version %d", header.Version)
+	}
+
+	events := make([]Event, 0)
+	for scanner.Scan() {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			// An active recording may end in a partly written line
+			break
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+
+	return &header, events, nil
+}
+
+// run turns piped output into events until the recording is stopped
+func (r *Recorder) run(a *activeRecording) {
+	defer close(a.done)
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	lastCheck := a.start
+	stopping := false
+
+	for {
+		select {
+		case <-a.stop:
+			r.readOutput(a)
+			return
+		case now := <-ticker.C:
+			r.readOutput(a)
+			if stopping || now.Sub(lastCheck) < paneCheckInterval {
+				continue
+			}
+			lastCheck = now
+
+			if r.maxDuration > 0 && now.Sub(a.start) >= r.maxDuration {
+				log.Printf("Recording %s reached its maximum duration", a.meta.ID)
+				stopping = true
+				go r.Stop(a.meta.ID)
+				continue
+			}
+
+			width, height, err := r.backend.PaneSize(a.meta.PaneID)
+			if err != nil {
+				log.Printf("Pane of recording %s closed", a.meta.ID)
+				stopping = true
+				go r.Stop(a.meta.ID)
+				continue
+			}
+			if width != a.meta.Width || height != a.meta.Height {
+				r.mu.Lock()
+				a.meta.Width, a.meta.Height = width, height
+				r.mu.Unlock()
+				r.writeEvent(a, Event{Time: now.Sub(a.start).Seconds(), Type: EventResize, Data: fmt.Sprintf("%dx%d", width, height)})
+			}
+		}
+	}
+}
+
+// readOutput appends everything piped since the last call as one event
+func (r *Recorder) readOutput(a *activeRecording) {
+	data := a.pending
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := a.raw.Read(buf)
+		data = append(data, buf[:n]...)
+		if err != nil || n == 0 {
+			if err != nil && err != io.EOF {
+				log.Printf("Failed to read output of recording %s: %v", a.meta.ID, err)
+			}
+			break
+		}
+	}
+
+	// Events must be valid UTF-8, so a character split between two reads
+	// waits for its remaining bytes
+	complete := len(data)
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				complete = i
+			}
+			break
+		}
+	}
+	a.pending = append([]byte{}, data[complete:]...)
+
+	if complete > 0 {
+		event := Event{Time: time.Since(a.start).Seconds(), Type: EventOutput, Data: string(data[:complete])}
+		r.writeEvent(a, event)
+
+		r.mu.Lock()
+		a.meta.Duration = event.Time
+		r.mu.Unlock()
+	}
+}
+
+// writeEvent appends an event to a recording
+func (r *Recorder) writeEvent(a *activeRecording, event Event) {
+	line, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to encode event of recording %s: %v", a.meta.ID, err)
+		return
+	}
+	if _, err := a.cast.Write(append(line, '\n')); err != nil {
+		log.Printf("Failed to write recording %s: %v", a.meta.ID, err)
+	}
+}
+
+// finishAbandoned finishes recordings that were active when the server last
+// stopped; output piped since then is dropped
+func (r *Recorder) finishAbandoned() {
+	paths, err := filepath.Glob(filepath.Join(r.dir, "rec-*.json"))
+	if err != nil {
+		return
+	}
+
+	for _, path := range paths {
+		id := strings.TrimSuffix(filepath.Base(path), ".json")
+		meta, err := r.loadMeta(id)
+		if err != nil || !meta.Active {
+			continue
+		}
+
+		// tmux outlives the server and may still be piping the pane
+		r.backend.PipePane(meta.PaneID, "")
+		os.Remove(r.rawPath(id))
+
+		meta.Active = false
+		meta.StoppedAt = time.Now().UnixMilli()
+		if info, err := os.Stat(r.castPath(id)); err == nil {
+			meta.Size = info.Size()
+			meta.StoppedAt = info.ModTime().UnixMilli()
+		}
+		if err := r.saveMeta(*meta); err != nil {
+			log.Printf("Failed to finish recording %s: %v", id, err)
+			continue
+		}
+		log.Printf("Finished recording %s left active by a previous run", id)
+	}
+}
+
+// loadMeta reads the metadata of a recording from disk
+func (r *Recorder) loadMeta(id string) (*protocol.Recording, error) {
+	data, err := os.ReadFile(r.metaPath(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: '%s'", ErrNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+
+	var meta protocol.Recording
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("failed to parse recording: %w", err)
+	}
+	return &meta, nil
+}
+
+// saveMeta writes the metadata of a recording to disk
+func (r *Recorder) saveMeta(meta protocol.Recording) error {
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode recording: %w", err)
+	}
+
+	// Write to a temp file first so a crash never leaves a truncated file
+	tmp := r.metaPath(meta.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write recording: %w", err)
+	}
+	return os.Rename(tmp, r.metaPath(meta.ID))
+}
+
+// castPath returns the path of a recording's asciicast file
+func (r *Recorder) castPath(id string) string {
+	return filepath.Join(r.dir, id+".cast")
+}
+
+// metaPath returns the path of a recording's metadata file
+func (r *Recorder) metaPath(id string) string {
+	return filepath.Join(r.dir, id+".json")
+}
+
+// rawPath returns the path of the file tmux pipes an active recording to
+func (r *Recorder) rawPath(id string) string {
+	return filepath.Join(r.dir, id+".raw")
+}
+
+// generateRecordingID generates a random recording ID
+func generateRecordingID() (string, error) {
+	bytes := make([]byte, 8)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return "rec-" + hex.EncodeToString(bytes), nil
+}
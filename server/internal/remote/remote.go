@@ -0,0 +1,305 @@
+// Package remote runs commands on hosts over SSH, sharing one connection
+// per host between all commands run on it
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Defaults for Options
+const (
+	defaultConnectTimeout = 10 * time.Second
+	defaultKeepalive      = 30 * time.Second
+	defaultMaxSessions    = 8 // OpenSSH allows 10 sessions per connection
+)
+
+// Keys tried in ~/.ssh when a host has no key file
+var defaultKeyFiles = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// HostConfig configures a host from the ssh.hosts config list
+type HostConfig struct {
+	Name    string `mapstructure:"name"`     // Name reported in sessions, defaults to the address
+	Address string `mapstructure:"address"`  // host or host:port, port 22 by default
+	User    string `mapstructure:"user"`     // Defaults to the local user
+	KeyFile string `mapstructure:"key_file"` // Private key; the SSH agent and ~/.ssh keys are used without one
+}
+
+// Options are the SSH settings shared by all hosts
+type Options struct {
+	KnownHosts     string        // known_hosts file host keys are checked against
+	ConnectTimeout time.Duration // Timeout of dialing and the SSH handshake
+	Keepalive      time.Duration // Interval of keepalive requests, which close dead connections
+	MaxSessions    int           // Commands run at once over a connection
+}
+
+// Host runs commands on a remote host
+// The connection is dialed when first needed, shared by all commands, each
+// run in its own SSH session, and dialed again after it breaks.
+type Host struct {
+	name      string
+	address   string
+	config    *ssh.ClientConfig
+	keyFile   string
+	keepalive time.Duration
+	sessions  chan struct{} // Semaphore of running commands
+
+	mu     sync.Mutex
+	client *ssh.Client
+}
+
+// NewHost creates a host; nothing is dialed until the first command
+func NewHost(cfg HostConfig, opts Options) (*Host, error) {
+	if cfg.Address == "" {
+		return nil, fmt.Errorf("host '%s' has no address", cfg.Name)
+	}
+	address := cfg.Address
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, "22")
+	}
+	if cfg.Name == "" {
+		cfg.Name = cfg.Address
+	}
+
+	if cfg.User == "" {
+		current, err := user.Current()
+		if err != nil {
+			return nil, fmt.Errorf("failed to find the user for host '%s': %w", cfg.Name, err)
+		}
+		cfg.User = current.Username
+	}
+
+	hostKeys, err := knownhosts.New(opts.KnownHosts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read known hosts: %w", err)
+	}
+
+	timeout := opts.ConnectTimeout
+	if timeout <= 0 {
+		timeout = defaultConnectTimeout
+	}
+	keepalive := opts.Keepalive
+	if keepalive <= 0 {
+		keepalive = defaultKeepalive
+	}
+	maxSessions := opts.MaxSessions
+	if maxSessions <= 0 {
+		maxSessions = defaultMaxSessions
+	}
+
+	return &Host{
+		name:    cfg.Name,
+		address: address,
+		config: &ssh.ClientConfig{
+			User:            cfg.User,
+			HostKeyCallback: hostKeys,
+			Timeout:         timeout,
+		},
+		keyFile:   cfg.KeyFile,
+		keepalive: keepalive,
+		sessions:  make(chan struct{}, maxSessions),
+	}, nil
+}
+
+// Name returns the name of the host
+func (h *Host) Name() string {
+	return h.name
+}
+
+// Address returns the host:port of the host
+func (h *Host) Address() string {
+	return h.address
+}
+
+// Run runs a shell command line on the host
+// Nil readers and writers stand for empty input and discarded output. A
+// command exiting with a non-zero status returns an *ssh.ExitError.
+func (h *Host) Run(command string, stdin io.Reader, stdout, stderr io.Writer) error {
+	h.sessions <- struct{}{}
+	defer func() { <-h.sessions }()
+
+	session, err := h.newSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	session.Stdin = stdin
+	session.Stdout = stdout
+	session.Stderr = stderr
+	return session.Run(command)
+}
+
+// Close closes the connection to the host, if any
+func (h *Host) Close() error {
+	h.mu.Lock()
+	client := h.client
+	h.client = nil
+	h.mu.Unlock()
+
+	if client == nil {
+		return nil
+	}
+	return client.Close()
+}
+
+// newSession opens a session on the shared connection, dialing again once
+// if the connection turns out to be broken
+func (h *Host) newSession() (*ssh.Session, error) {
+	client, err := h.connect()
+	if err != nil {
+		return nil, err
+	}
+	session, err := client.NewSession()
+	if err == nil {
+		return session, nil
+	}
+
+	h.drop(client)
+	if client, err = h.connect(); err != nil {
+		return nil, err
+	}
+	session, err = client.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open a session on host '%s': %w", h.name, err)
+	}
+	return session, nil
+}
+
+// connect returns the shared connection, dialing it if there is none
+func (h *Host) connect() (*ssh.Client, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.client != nil {
+		return h.client, nil
+	}
+
+	// Authentication with the agent's keys is over once the connection is
+	// established
+	var agentConn net.Conn
+	if socket := os.Getenv("SSH_AUTH_SOCK"); socket != "" && h.keyFile == "" {
+		if conn, err := net.Dial("unix", socket); err == nil {
+			agentConn = conn
+			defer conn.Close()
+		}
+	}
+
+	config := *h.config
+	signers, err := h.signers(agentConn)
+	if err != nil {
+		return nil, err
+	}
+	config.Auth = []ssh.AuthMethod{ssh.PublicKeys(signers...)}
+
+	client, err := ssh.Dial("tcp", h.address, &config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to host '%s': %w", h.name, err)
+	}
+	h.client = client
+	go h.keepAlive(client)
+	return client, nil
+}
+
+// drop forgets a broken connection and closes it
+func (h *Host) drop(client *ssh.Client) {
+	h.mu.Lock()
+	if h.client == client {
+		h.client = nil
+	}
+	h.mu.Unlock()
+	client.Close()
+}
+
+// keepAlive sends keepalive requests over a connection until one fails or
+// goes unanswered, then drops the connection
+func (h *Host) keepAlive(client *ssh.Client) {
+	ticker := time.NewTicker(h.keepalive)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		result := make(chan error, 1)
+		go func() {
+			_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+			result <- err
+		}()
+
+		select {
+		case err := <-result:
+			if err == nil {
+				continue
+			}
+		case <-time.After(h.keepalive):
+		}
+		h.drop(client)
+		return
+	}
+}
+
+// signers returns the keys to authenticate with: the host's key file, or
+// the keys of the SSH agent, if connected, and the default keys in ~/.ssh
+// Keys are loaded for every dial so that changed keys and agents are used.
+func (h *Host) signers(agentConn net.Conn) ([]ssh.Signer, error) {
+	if h.keyFile != "" {
+		signer, err := loadKey(h.keyFile)
+		if err != nil {
+			return nil, err
+		}
+		return []ssh.Signer{signer}, nil
+	}
+
+	var signers []ssh.Signer
+	if agentConn != nil {
+		if keys, err := agent.NewClient(agentConn).Signers(); err == nil {
+			signers = append(signers, keys...)
+		}
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		for _, name := range defaultKeyFiles {
+			// Missing and passphrase protected keys are skipped
+			if signer, err := loadKey(filepath.Join(home, ".ssh", name)); err == nil {
+				signers = append(signers, signer)
+			}
+		}
+	}
+
+	if len(signers) == 0 {
+		return nil, fmt.Errorf("no SSH keys for host '%s': set key_file or add a key to the SSH agent", h.name)
+	}
+	return signers, nil
+}
+
+// loadKey reads an unencrypted private key
+func loadKey(path string) (ssh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key: %w", err)
+	}
+	signer, err := ssh.ParsePrivateKey(data)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		return nil, fmt.Errorf("key %s is protected by a passphrase, add it to the SSH agent instead", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse key %s: %w", path, err)
+	}
+	return signer, nil
+}
+
+// IsExitError tells whether err is from a command that ran and failed,
+// rather than one that couldn't be run
+func IsExitError(err error) bool {
+	var exitErr *ssh.ExitError
+	return errors.As(err, &exitErr)
+}
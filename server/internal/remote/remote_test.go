@@ -0,0 +1,298 @@
+package remote
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// testServer is an SSH server running in the test process
+// Commands aren't run: each one answers with its command line followed by
+// its input, and "fail" exits with status 3.
+type testServer struct {
+	listener net.Listener
+	config   *ssh.ServerConfig
+
+	mu               sync.Mutex
+	conns            []*ssh.ServerConn
+	dials            int
+	ignoreKeepalives bool // Leave keepalive requests unanswered
+}
+
+// newTestServer starts a server and returns it with a host connecting to it
+func newTestServer(t *testing.T, opts Options) (*testServer, *Host) {
+	t.Helper()
+	dir := t.TempDir()
+
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostSigner, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientPublic, clientKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authorized, err := ssh.NewPublicKey(clientPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &testServer{
+		config: &ssh.ServerConfig{
+			PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+				if !bytes.Equal(key.Marshal(), authorized.Marshal()) {
+					return nil, errors.New("unknown key")
+				}
+				return nil, nil
+			},
+		},
+	}
+	s.config.AddHostKey(hostSigner)
+
+	s.listener, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		s.listener.Close()
+		s.closeConns()
+	})
+	go s.serve()
+
+	// The host trusts the server's key and authenticates with the client key
+	address := s.listener.Addr().String()
+	knownHosts := filepath.Join(dir, "known_hosts")
+	line := knownhosts.Line([]string{address}, hostSigner.PublicKey()) + "\n"
+	if err := os.WriteFile(knownHosts, []byte(line), 0600); err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(clientKey, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "id_ed25519")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	opts.KnownHosts = knownHosts
+	host, err := NewHost(HostConfig{Name: "test", Address: address, User: "handx", KeyFile: keyFile}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { host.Close() })
+	return s, host
+}
+
+func (s *testServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *testServer) handle(conn net.Conn) {
+	serverConn, channels, requests, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	s.mu.Lock()
+	s.conns = append(s.conns, serverConn)
+	s.dials++
+	s.mu.Unlock()
+
+	go func() {
+		for req := range requests {
+			s.mu.Lock()
+			ignore := s.ignoreKeepalives
+			s.mu.Unlock()
+			if req.Type == "keepalive@openssh.com" && ignore {
+				continue
+			}
+			req.Reply(req.Type == "keepalive@openssh.com", nil)
+		}
+	}()
+
+	for newChannel := range channels {
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go answer(channel, channelRequests)
+	}
+}
+
+// answer answers the exec request of a session channel
+func answer(channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+
+	for req := range requests {
+		if req.Type != "exec" || len(req.Payload) < 4 {
+			req.Reply(false, nil)
+			continue
+		}
+		command := string(req.Payload[4 : 4+binary.BigEndian.Uint32(req.Payload)])
+		req.Reply(true, nil)
+
+		status := uint32(0)
+		if command == "fail" {
+			status = 3
+		} else {
+			io.WriteString(channel, command+"\n")
+			io.Copy(channel, channel)
+		}
+		channel.SendRequest("exit-status", false, binary.BigEndian.AppendUint32(nil, status))
+		return
+	}
+}
+
+// closeConns breaks the connections of all clients
+func (s *testServer) closeConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+func (s *testServer) dialCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dials
+}
+
+func TestRun(t *testing.T) {
+	server, host := newTestServer(t, Options{})
+
+	var stdout bytes.Buffer
+	command := "tmux list-sessions 'it'\\''s'"
+	if err := host.Run(command, strings.NewReader("input"), &stdout, nil); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if want := command + "\ninput"; stdout.String() != want {
+		t.Errorf("output = %q, want %q", stdout.String(), want)
+	}
+
+	err := host.Run("fail", nil, nil, nil)
+	if !IsExitError(err) {
+		t.Errorf("Run(fail) = %v, want an exit error", err)
+	}
+
+	// Commands share the connection
+	if dials := server.dialCount(); dials != 1 {
+		t.Errorf("dialed %d times, want 1", dials)
+	}
+}
+
+func TestRunConcurrently(t *testing.T) {
+	server, host := newTestServer(t, Options{MaxSessions: 2})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- host.Run("true", nil, nil, nil)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Run failed: %v", err)
+		}
+	}
+	if dials := server.dialCount(); dials != 1 {
+		t.Errorf("dialed %d times, want 1", dials)
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	server, host := newTestServer(t, Options{})
+
+	if err := host.Run("true", nil, nil, nil); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// A broken connection is dialed again by the next command
+	server.closeConns()
+	var stdout bytes.Buffer
+	if err := host.Run("again", nil, &stdout, nil); err != nil {
+		t.Fatalf("Run after the connection broke failed: %v", err)
+	}
+	if stdout.String() != "again\n" {
+		t.Errorf("output = %q, want %q", stdout.String(), "again\n")
+	}
+	if dials := server.dialCount(); dials != 2 {
+		t.Errorf("dialed %d times, want 2", dials)
+	}
+}
+
+func TestKeepalive(t *testing.T) {
+	server, host := newTestServer(t, Options{Keepalive: 20 * time.Millisecond})
+
+	if err := host.Run("true", nil, nil, nil); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// Answered keepalives keep the connection
+	time.Sleep(100 * time.Millisecond)
+	if !host.connected() {
+		t.Fatal("connection dropped although keepalives were answered")
+	}
+
+	// Unanswered ones drop it
+	server.mu.Lock()
+	server.ignoreKeepalives = true
+	server.mu.Unlock()
+	deadline := time.Now().Add(2 * time.Second)
+	for host.connected() {
+		if time.Now().After(deadline) {
+			t.Fatal("connection kept although keepalives went unanswered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	server.mu.Lock()
+	server.ignoreKeepalives = false
+	server.mu.Unlock()
+	if err := host.Run("true", nil, nil, nil); err != nil {
+		t.Fatalf("Run after the connection was dropped failed: %v", err)
+	}
+	if dials := server.dialCount(); dials != 2 {
+		t.Errorf("dialed %d times, want 2", dials)
+	}
+}
+
+// connected reports whether the host holds a connection
+func (h *Host) connected() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.client != nil
+}
@@ -0,0 +1,222 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Spec is a parsed schedule expression that can compute its next activation
+type Spec interface {
+	Next(after time.Time) time.Time
+}
+
+// cronSpec is a standard 5-field cron expression (minute hour dom month dow)
+type cronSpec struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+}
+
+// everySpec runs at a fixed interval
+type everySpec struct {
+	interval time.Duration
+}
+
+// fieldBounds describes the allowed range of a cron field
+type fieldBounds struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteBounds = fieldBounds{min: 0, max: 59}
+	hourBounds   = fieldBounds{min: 0, max: 23}
+	domBounds    = fieldBounds{min: 1, max: 31}
+	monthBounds  = fieldBounds{min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dowBounds = fieldBounds{min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// descriptors are the predefined @-shorthands
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseSpec parses a cron expression
+// Supports 5-field cron syntax (with lists, ranges, steps and month/day names),
+// the @hourly/@daily/... descriptors and "@every <duration>" (e.g. "@every 15m")
+func ParseSpec(expr string) (Spec, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return nil, fmt.Errorf("empty schedule expression")
+	}
+
+	if strings.HasPrefix(expr, "@every ") {
+		interval, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(expr, "@every ")))
+		if err != nil {
+			return nil, fmt.Errorf("invalid @every duration: %w", err)
+		}
+		if interval < time.Second {
+			return nil, fmt.Errorf("@every interval must be at least 1s")
+		}
+		return everySpec{interval: interval}, nil
+	}
+
+	if d, ok := descriptors[expr]; ok {
+		expr = d
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields in cron expression, got %d", len(fields))
+	}
+
+	var (
+		spec cronSpec
+		err  error
+	)
+	if spec.minute, err = parseField(fields[0], minuteBounds); err != nil {
+		return nil, fmt.Errorf("minute: %w", err)
+	}
+	if spec.hour, err = parseField(fields[1], hourBounds); err != nil {
+		return nil, fmt.Errorf("hour: %w", err)
+	}
+	if spec.dom, err = parseField(fields[2], domBounds); err != nil {
+		return nil, fmt.Errorf("day of month: %w", err)
+	}
+	if spec.month, err = parseField(fields[3], monthBounds); err != nil {
+		return nil, fmt.Errorf("month: %w", err)
+	}
+	if spec.dow, err = parseField(fields[4], dowBounds); err != nil {
+		return nil, fmt.Errorf("day of week: %w", err)
+	}
+
+	// 7 is an alias for Sunday
+	if spec.dow&(1<<7) != 0 {
+		spec.dow |= 1 << 0
+	}
+
+	spec.domStar = fields[2] == "*" || fields[2] == "?"
+	spec.dowStar = fields[4] == "*" || fields[4] == "?"
+
+	return spec, nil
+}
+
+// parseField parses one comma-separated cron field into a bitset
+func parseField(field string, b fieldBounds) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			s, err := strconv.Atoi(part[i+1:])
+			if err != nil || s <= 0 {
+				return 0, fmt.Errorf("invalid step in '%s'", part)
+			}
+			step = s
+			part = part[:i]
+		}
+
+		lo, hi := b.min, b.max
+		switch {
+		case part == "*" || part == "?":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if lo, err = parseValue(bounds[0], b); err != nil {
+				return 0, err
+			}
+			if hi, err = parseValue(bounds[1], b); err != nil {
+				return 0, err
+			}
+		default:
+			v, err := parseValue(part, b)
+			if err != nil {
+				return 0, err
+			}
+			lo = v
+			// "5/10" means starting at 5 every 10
+			if step == 1 {
+				hi = v
+			}
+		}
+
+		if lo > hi {
+			return 0, fmt.Errorf("invalid range '%s'", part)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// parseValue parses a single numeric or named cron value
+func parseValue(s string, b fieldBounds) (int, error) {
+	if v, ok := b.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value '%s'", s)
+	}
+	if v < b.min || v > b.max {
+		return 0, fmt.Errorf("value %d out of range [%d-%d]", v, b.min, b.max)
+	}
+	return v, nil
+}
+
+// Next returns the next activation strictly after the given time
+func (s everySpec) Next(after time.Time) time.Time {
+	return after.Add(s.interval).Truncate(time.Second)
+}
+
+// Next returns the next activation strictly after the given time
+// Returns the zero time if no activation exists within the next five years
+func (s cronSpec) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
+
+// dayMatches applies the cron rule that when both day-of-month and
+// day-of-week are restricted, a day matching either one qualifies
+func (s cronSpec) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
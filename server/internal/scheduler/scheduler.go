@@ -0,0 +1,353 @@
+package scheduler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// Executor runs a command in a tmux session
+type Executor interface {
+	ExecuteCommand(sessionName, command string, windowIndex *int) error
+}
+
+// Scheduler dispatches commands into tmux sessions on cron-style schedules
+type Scheduler struct {
+	executor     Executor
+	path         string // File the schedules are persisted to
+	historyLimit int    // Number of runs kept per schedule
+
+	schedules   map[string]*protocol.Schedule
+	specs       map[string]Spec
+	mu          sync.Mutex
+	subscribers []func(protocol.Schedule, protocol.ScheduleRun)
+}
+
+// NewScheduler creates a scheduler and loads persisted schedules from path
+func NewScheduler(executor Executor, path string, historyLimit int) (*Scheduler, error) {
+	if historyLimit <= 0 {
+		historyLimit = 50 // Default to 50 runs
+	}
+
+	s := &Scheduler{
+		executor:     executor,
+		path:         path,
+		historyLimit: historyLimit,
+		schedules:    make(map[string]*protocol.Schedule),
+		specs:        make(map[string]Spec),
+	}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Subscribe registers fn to be called after every run
+// fn runs on the dispatch goroutine and must not block.
+func (s *Scheduler) Subscribe(fn func(protocol.Schedule, protocol.ScheduleRun)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.subscribers = append(s.subscribers, fn)
+}
+
+// Run starts the dispatch loop, checking for due schedules every second
+func (s *Scheduler) Run() {
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		s.dispatchDue(now)
+	}
+}
+
+// Create validates and adds a new schedule
+func (s *Scheduler) Create(payload protocol.CreateSchedulePayload) (*protocol.Schedule, error) {
+	if payload.SessionName == "" {
+		return nil, fmt.Errorf("session name is required")
+	}
+	if payload.Command == "" {
+		return nil, fmt.Errorf("command is required")
+	}
+	if payload.Spec == "" && payload.RunAt == 0 {
+		return nil, fmt.Errorf("either spec or run_at is required")
+	}
+
+	var spec Spec
+	if payload.Spec != "" {
+		var err error
+		spec, err = ParseSpec(payload.Spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule '%s': %w", payload.Spec, err)
+		}
+	}
+
+	id, err := generateScheduleID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	schedule := &protocol.Schedule{
+		ID:          id,
+		Name:        payload.Name,
+		Spec:        payload.Spec,
+		RunAt:       payload.RunAt,
+		SessionName: payload.SessionName,
+		WindowIndex: payload.WindowIndex,
+		Command:     payload.Command,
+		CreatedAt:   now.UnixMilli(),
+	}
+	if spec != nil {
+		schedule.RunAt = 0
+		schedule.NextRunAt = nextRun(spec, now)
+	} else {
+		schedule.NextRunAt = payload.RunAt
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.schedules[id] = schedule
+	if spec != nil {
+		s.specs[id] = spec
+	}
+	if err := s.save(); err != nil {
+		delete(s.schedules, id)
+		delete(s.specs, id)
+		return nil, err
+	}
+
+	result := *schedule
+	return &result, nil
+}
+
+// List returns all schedules ordered by creation time, without run history
+func (s *Scheduler) List() []protocol.Schedule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]protocol.Schedule, 0, len(s.schedules))
+	for _, schedule := range s.schedules {
+		item := *schedule
+		item.History = nil
+		result = append(result, item)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt < result[j].CreatedAt
+	})
+
+	return result
+}
+
+// SetPaused pauses or resumes a schedule
+func (s *Scheduler) SetPaused(id string, paused bool) (*protocol.Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedule, ok := s.schedules[id]
+	if !ok {
+		return nil, fmt.Errorf("schedule '%s' not found", id)
+	}
+
+	schedule.Paused = paused
+	if !paused {
+		// Skip runs missed while paused
+		if spec, ok := s.specs[id]; ok {
+			schedule.NextRunAt = nextRun(spec, time.Now())
+		}
+	}
+
+	if err := s.save(); err != nil {
+		return nil, err
+	}
+
+	result := *schedule
+	result.History = nil
+	return &result, nil
+}
+
+// Delete removes a schedule
+func (s *Scheduler) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.schedules[id]; !ok {
+		return fmt.Errorf("schedule '%s' not found", id)
+	}
+
+	delete(s.schedules, id)
+	delete(s.specs, id)
+	return s.save()
+}
+
+// History returns the recorded runs of a schedule, most recent last
+func (s *Scheduler) History(id string) ([]protocol.ScheduleRun, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedule, ok := s.schedules[id]
+	if !ok {
+		return nil, fmt.Errorf("schedule '%s' not found", id)
+	}
+
+	runs := make([]protocol.ScheduleRun, len(schedule.History))
+	copy(runs, schedule.History)
+	return runs, nil
+}
+
+// dispatchDue executes every schedule whose next run time has passed
+func (s *Scheduler) dispatchDue(now time.Time) {
+	s.mu.Lock()
+	due := make([]protocol.Schedule, 0)
+	for id, schedule := range s.schedules {
+		if schedule.Paused || schedule.NextRunAt == 0 || schedule.NextRunAt > now.UnixMilli() {
+			continue
+		}
+
+		due = append(due, *schedule)
+		if spec, ok := s.specs[id]; ok {
+			schedule.NextRunAt = nextRun(spec, now)
+		} else {
+			// One-shot schedules only run once
+			schedule.NextRunAt = 0
+		}
+	}
+	s.mu.Unlock()
+
+	for _, schedule := range due {
+		log.Printf("Running schedule %s: session=%s, command=%s", schedule.ID, schedule.SessionName, schedule.Command)
+
+		run := protocol.ScheduleRun{
+			StartedAt: time.Now().UnixMilli(),
+			Success:   true,
+		}
+		if err := s.executor.ExecuteCommand(schedule.SessionName, schedule.Command, schedule.WindowIndex); err != nil {
+			log.Printf("Schedule %s failed: %v", schedule.ID, err)
+			run.Success = false
+			run.Error = err.Error()
+		}
+
+		s.recordRun(schedule.ID, run)
+
+		s.mu.Lock()
+		subscribers := s.subscribers
+		s.mu.Unlock()
+		for _, fn := range subscribers {
+			fn(schedule, run)
+		}
+	}
+}
+
+// recordRun appends a run to a schedule's history and persists it
+func (s *Scheduler) recordRun(id string, run protocol.ScheduleRun) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedule, ok := s.schedules[id]
+	if !ok {
+		// Deleted while running
+		return
+	}
+
+	schedule.LastRunAt = run.StartedAt
+	schedule.History = append(schedule.History, run)
+	if len(schedule.History) > s.historyLimit {
+		schedule.History = schedule.History[len(schedule.History)-s.historyLimit:]
+	}
+
+	if err := s.save(); err != nil {
+		log.Printf("Failed to persist schedules: %v", err)
+	}
+}
+
+// load reads persisted schedules from disk
+func (s *Scheduler) load() error {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read schedules: %w", err)
+	}
+
+	var schedules []*protocol.Schedule
+	if err := json.Unmarshal(data, &schedules); err != nil {
+		return fmt.Errorf("failed to parse schedules: %w", err)
+	}
+
+	now := time.Now()
+	for _, schedule := range schedules {
+		if schedule.Spec != "" {
+			spec, err := ParseSpec(schedule.Spec)
+			if err != nil {
+				log.Printf("Skipping schedule %s with invalid spec '%s': %v", schedule.ID, schedule.Spec, err)
+				continue
+			}
+			s.specs[schedule.ID] = spec
+			// Runs missed while the server was down are skipped
+			schedule.NextRunAt = nextRun(spec, now)
+		}
+		s.schedules[schedule.ID] = schedule
+	}
+
+	log.Printf("Loaded %d schedules from %s", len(s.schedules), s.path)
+	return nil
+}
+
+// save writes all schedules to disk; callers must hold the lock
+func (s *Scheduler) save() error {
+	schedules := make([]*protocol.Schedule, 0, len(s.schedules))
+	for _, schedule := range s.schedules {
+		schedules = append(schedules, schedule)
+	}
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].CreatedAt < schedules[j].CreatedAt
+	})
+
+	data, err := json.MarshalIndent(schedules, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode schedules: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create schedule directory: %w", err)
+	}
+
+	// Write to a temp file first so a crash never leaves a truncated file
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write schedules: %w", err)
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// nextRun returns the next activation of spec after t in unix ms, or 0 if none
+func nextRun(spec Spec, t time.Time) int64 {
+	next := spec.Next(t)
+	if next.IsZero() {
+		return 0
+	}
+	return next.UnixMilli()
+}
+
+// generateScheduleID generates a random schedule ID
+func generateScheduleID() (string, error) {
+	bytes := make([]byte, 8)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return "sched-" + hex.EncodeToString(bytes), nil
+}
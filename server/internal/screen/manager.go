@@ -0,0 +1,859 @@
+// Package screen manages GNU screen sessions
+package screen
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/myan/handx-server/internal/environ"
+	"github.com/myan/handx-server/internal/procinfo"
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// How long CreateSession waits for a new session to show up
+const (
+	startTimeout = 2 * time.Second
+	pollInterval = 50 * time.Millisecond
+)
+
+// inputRegister is the screen register input is pasted through
+const inputRegister = "h"
+
+// Input sent for the special keys ExecuteCommand presses instead of typing
+var specialKeys = map[string]string{
+	"Escape": "\x1b",
+	"Enter":  "\r",
+	"Tab":    "\t",
+}
+
+// sessionLine matches a session in screen -ls output, e.g.
+// "\t4711.dev\t(10/18/2026 08:41:05 PM)\t(Detached)"; the date is missing
+// in some builds
+var sessionLine = regexp.MustCompile(`^\t(\d+)\.(\S+)\t(?:\(([^)]*)\)\t)?\(([^)]*)\)`)
+
+// windowEntry matches a window in the output of the windows query, e.g.
+// "1*$ vim" for window 1, current, titled vim
+var windowEntry = regexp.MustCompile(`^(\d+)(\S*) (.*)$`)
+
+// sizeInfo matches the cursor position and size in the output of the info query
+var sizeInfo = regexp.MustCompile(`\(\d+,\d+\)/\((\d+),(\d+)\)`)
+
+// createdLayouts are the date formats screen -ls prints, depending on the build
+var createdLayouts = []string{
+	"01/02/2006 03:04:05 PM",
+	"01/02/06 15:04:05",
+	"01/02/2006 15:04:05",
+}
+
+// Manager manages GNU screen sessions
+// screen has no panes: each window is reported as a window with a single
+// pane, whose ID is "<session>:<window index>". Requires screen 4.2 or newer
+// for queries.
+type Manager struct {
+	historyLines int // Scrollback of new windows
+
+	mu    sync.Mutex      // Serializes use of inputRegister
+	bells map[string]bool // Panes whose bell flag was last seen set
+}
+
+// session is a running screen session
+type session struct {
+	pid       int
+	name      string
+	createdAt int64
+	attached  bool
+}
+
+// window is a window of a session as listed by the windows query
+type window struct {
+	index  int
+	title  string
+	active bool
+	bell   bool
+}
+
+// NewManager creates a screen manager
+func NewManager(historyLines int) (*Manager, error) {
+	if _, err := exec.LookPath("screen"); err != nil {
+		return nil, fmt.Errorf("screen is not installed on the system")
+	}
+
+	if historyLines <= 0 {
+		historyLines = 10000
+	}
+
+	return &Manager{
+		historyLines: historyLines,
+		bells:        make(map[string]bool),
+	}, nil
+}
+
+// ListSessions returns all screen sessions
+func (m *Manager) ListSessions() ([]protocol.Session, error) {
+	sessions, err := listSessions()
+	if err != nil {
+		return nil, err
+	}
+
+	table, _ := procinfo.Snapshot()
+
+	result := make([]protocol.Session, 0, len(sessions))
+	for _, s := range sessions {
+		windows, err := m.describeWindows(s, table)
+		if err != nil {
+			// The session ended since it was listed
+			continue
+		}
+
+		attachedClients := 0
+		if s.attached {
+			attachedClients = 1
+		}
+		width, height, _ := windowSize(s, nil)
+
+		result = append(result, protocol.Session{
+			ID:              fmt.Sprintf("session-%s", s.name),
+			Name:            s.name,
+			Windows:         windows,
+			CreatedAt:       s.createdAt,
+			Attached:        s.attached,
+			AttachedClients: attachedClients,
+			WindowCount:     len(windows),
+			Width:           width,
+			Height:          height,
+		})
+	}
+
+	return result, nil
+}
+
+// CreateSession starts a detached session
+// opts may be nil to create a session with screen defaults. screen sizes
+// detached windows itself, so Width and Height are ignored.
+func (m *Manager) CreateSession(name string, opts *protocol.SessionOptions) (*protocol.Session, error) {
+	if name == "" || strings.ContainsAny(name, ". \t") {
+		return nil, fmt.Errorf("invalid session name '%s'", name)
+	}
+	if _, err := findSession(name); err == nil {
+		return nil, fmt.Errorf("session '%s' already exists", name)
+	}
+
+	args := []string{"-dmS", name, "-h", strconv.Itoa(m.historyLines)}
+	cmd := exec.Command("screen")
+	cmd.Env = os.Environ()
+	if opts != nil {
+		if opts.WindowName != "" {
+			args = append(args, "-t", opts.WindowName)
+		}
+		cmd.Dir = opts.StartDirectory
+
+		env, err := environ.Pairs(opts.Environment)
+		if err != nil {
+			return nil, err
+		}
+		cmd.Env = append(cmd.Env, env...)
+
+		if opts.Command != "" {
+			args = append(args, shellCommand(opts.Command)...)
+		}
+	}
+	cmd.Args = append(cmd.Args, args...)
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("failed to create session: %s", strings.TrimSpace(string(output)))
+	}
+
+	// screen -dm returns before the session is listening
+	deadline := time.Now().Add(startTimeout)
+	for {
+		sessions, err := m.ListSessions()
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range sessions {
+			if s.Name == name {
+				return &s, nil
+			}
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("session '%s' did not start", name)
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// KillSession ends a session and the programs in its windows
+func (m *Manager) KillSession(name string) error {
+	s, err := findSession(name)
+	if err != nil {
+		return err
+	}
+
+	return s.command(nil, "quit")
+}
+
+// RenameSession renames a session
+func (m *Manager) RenameSession(oldName, newName string) error {
+	if newName == "" || strings.ContainsAny(newName, ". \t") {
+		return fmt.Errorf("invalid session name '%s'", newName)
+	}
+
+	s, err := findSession(oldName)
+	if err != nil {
+		return err
+	}
+	if _, err := findSession(newName); err == nil {
+		return fmt.Errorf("session '%s' already exists", newName)
+	}
+
+	if err := s.command(nil, "sessionname", newName); err != nil {
+		return fmt.Errorf("failed to rename session: %w", err)
+	}
+	return nil
+}
+
+// ExecuteCommand executes a command in a window of a session, the current
+// one if windowIndex is nil
+func (m *Manager) ExecuteCommand(sessionName, command string, windowIndex *int) error {
+	s, w, err := findWindow(sessionName, windowIndex)
+	if err != nil {
+		return err
+	}
+
+	// Press special keys without Enter
+	if key, ok := specialKeys[command]; ok {
+		return m.sendInput(s, w.index, key, false)
+	}
+	return m.sendInput(s, w.index, command, true)
+}
+
+// SendText types text into the current window of a session without Enter
+func (m *Manager) SendText(sessionName, text string) error {
+	s, w, err := findWindow(sessionName, nil)
+	if err != nil {
+		return err
+	}
+
+	return m.sendInput(s, w.index, text, false)
+}
+
+// CaptureOutput captures the scrollback of a window of a session, the
+// current one if windowIndex is nil
+func (m *Manager) CaptureOutput(sessionName string, windowIndex *int) (string, error) {
+	s, w, err := findWindow(sessionName, windowIndex)
+	if err != nil {
+		return "", err
+	}
+
+	return capture(s, w.index, m.historyLines)
+}
+
+// ListWindows lists the windows of a session
+func (m *Manager) ListWindows(sessionName string) ([]protocol.Window, error) {
+	s, err := findSession(sessionName)
+	if err != nil {
+		return nil, err
+	}
+
+	table, _ := procinfo.Snapshot()
+	return m.describeWindows(s, table)
+}
+
+// CreateWindow opens a window in a session, which becomes its current window
+// opts may be nil to run the default shell in the session's directory
+func (m *Manager) CreateWindow(sessionName, windowName string, opts *protocol.WindowOptions) (*protocol.Window, error) {
+	s, err := findSession(sessionName)
+	if err != nil {
+		return nil, err
+	}
+	before, err := s.windows()
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"screen"}
+	if windowName != "" {
+		args = append(args, "-t", windowName)
+	}
+	if opts != nil {
+		env, err := environ.Pairs(opts.Environment)
+		if err != nil {
+			return nil, err
+		}
+		// Windows inherit the session's directory and environment; both
+		// are set for the new window only and reset afterwards
+		for _, kv := range env {
+			k, v, _ := strings.Cut(kv, "=")
+			if err := s.command(nil, "setenv", k, v); err != nil {
+				return nil, fmt.Errorf("failed to set environment: %w", err)
+			}
+			defer s.command(nil, "unsetenv", k)
+		}
+		if opts.StartDirectory != "" {
+			if err := s.command(nil, "chdir", opts.StartDirectory); err != nil {
+				return nil, fmt.Errorf("failed to change directory: %w", err)
+			}
+			defer s.command(nil, "chdir")
+		}
+		if opts.Command != "" {
+			args = append(args, shellCommand(opts.Command)...)
+		}
+	}
+
+	if err := s.command(nil, args...); err != nil {
+		return nil, fmt.Errorf("failed to create window: %w", err)
+	}
+
+	after, err := s.windows()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list windows after creation: %w", err)
+	}
+	existing := make(map[int]bool, len(before))
+	for _, w := range before {
+		existing[w.index] = true
+	}
+	for _, w := range after {
+		if !existing[w.index] {
+			return &protocol.Window{
+				ID:     fmt.Sprintf("window-%s-%d", sessionName, w.index),
+				Name:   w.title,
+				Index:  w.index,
+				Active: w.active,
+				PaneID: fmt.Sprintf("%d", w.index),
+			}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("failed to find newly created window")
+}
+
+// CloseWindow closes a window of a session, killing its program
+func (m *Manager) CloseWindow(sessionName string, windowIndex int) error {
+	s, err := findSession(sessionName)
+	if err != nil {
+		return err
+	}
+	windows, err := s.windows()
+	if err != nil {
+		return err
+	}
+
+	if _, err := pickWindow(s, windows, &windowIndex); err != nil {
+		return err
+	}
+	// Don't allow closing the last window
+	if len(windows) == 1 {
+		return fmt.Errorf("cannot close the last window in session '%s'", sessionName)
+	}
+
+	if err := s.command(&windowIndex, "kill"); err != nil {
+		return fmt.Errorf("failed to close window: %w", err)
+	}
+	return nil
+}
+
+// SwitchWindow makes a window the current window of a session
+func (m *Manager) SwitchWindow(sessionName string, windowIndex int) (string, error) {
+	s, w, err := findWindow(sessionName, &windowIndex)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.command(nil, "select", strconv.Itoa(windowIndex)); err != nil {
+		return "", fmt.Errorf("failed to switch window: %w", err)
+	}
+	return w.title, nil
+}
+
+// ListAllPanes returns the location of every window of every session
+func (m *Manager) ListAllPanes() ([]protocol.PaneLocation, error) {
+	sessions, err := listSessions()
+	if err != nil {
+		// No screen sessions means no panes
+		return []protocol.PaneLocation{}, nil
+	}
+
+	result := make([]protocol.PaneLocation, 0)
+	for _, s := range sessions {
+		windows, err := s.windows()
+		if err != nil {
+			continue
+		}
+		for _, w := range windows {
+			result = append(result, protocol.PaneLocation{
+				SessionName: s.name,
+				WindowIndex: w.index,
+				PaneIndex:   0,
+				PaneID:      paneID(s.name, w.index),
+			})
+		}
+	}
+
+	return result, nil
+}
+
+// PaneInfo describes the pane of a window including its process tree
+// A nil window index selects the current window; windows only have pane 0.
+// Returns the pane and the index of its window.
+func (m *Manager) PaneInfo(sessionName string, windowIndex, paneIndex *int) (*protocol.Pane, int, error) {
+	s, w, err := findWindow(sessionName, windowIndex)
+	if err != nil {
+		return nil, 0, err
+	}
+	if paneIndex != nil && *paneIndex != 0 {
+		return nil, 0, fmt.Errorf("pane %d not found in window %d of session '%s'", *paneIndex, w.index, sessionName)
+	}
+
+	table, _ := procinfo.Snapshot()
+	pane := describePane(s, w, windowPIDs(s, table), table)
+	if width, height, err := windowSize(s, &w.index); err == nil {
+		pane.Width, pane.Height = width, height
+	}
+	return &pane, w.index, nil
+}
+
+// CapturePane captures a window's content including up to lines of history
+func (m *Manager) CapturePane(paneID string, lines int) (string, error) {
+	s, index, err := parsePaneID(paneID)
+	if err != nil {
+		return "", err
+	}
+
+	return capture(s, index, lines)
+}
+
+// SendToPane types text into a window, pressing Enter afterwards if enter is set
+func (m *Manager) SendToPane(paneID, text string, enter bool) error {
+	s, index, err := parsePaneID(paneID)
+	if err != nil {
+		return err
+	}
+
+	return m.sendInput(s, index, text, enter)
+}
+
+// TakeBells returns the windows that rang the bell since the last call
+// screen keeps a window's bell flag until the window is shown, so a bell is
+// reported when the flag becomes set.
+func (m *Manager) TakeBells() ([]protocol.PaneLocation, error) {
+	sessions, err := listSessions()
+	if err != nil {
+		return []protocol.PaneLocation{}, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make([]protocol.PaneLocation, 0)
+	seen := make(map[string]bool)
+	for _, s := range sessions {
+		windows, err := s.windows()
+		if err != nil {
+			continue
+		}
+		for _, w := range windows {
+			id := paneID(s.name, w.index)
+			if !w.bell {
+				continue
+			}
+			seen[id] = true
+			if m.bells[id] {
+				continue
+			}
+			result = append(result, protocol.PaneLocation{
+				SessionName: s.name,
+				WindowIndex: w.index,
+				PaneIndex:   0,
+				PaneID:      id,
+			})
+		}
+	}
+	m.bells = seen
+
+	return result, nil
+}
+
+// sendInput pastes text into a window through a register, so screen doesn't
+// interpret it as it would arguments of stuff
+func (m *Manager) sendInput(s *session, index int, text string, enter bool) error {
+	if enter {
+		text = strings.TrimRight(text, "\r\n")
+	}
+	// Terminals send Enter as a carriage return
+	input := strings.ReplaceAll(text, "\n", "\r")
+	if enter {
+		input += "\r"
+	}
+	if input == "" {
+		return nil
+	}
+
+	file, err := os.CreateTemp("", "handx-screen-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	_, err = file.WriteString(input)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := s.command(&index, "readreg", inputRegister, file.Name()); err != nil {
+		return fmt.Errorf("failed to load input: %w", err)
+	}
+	if err := s.command(&index, "paste", inputRegister); err != nil {
+		return fmt.Errorf("failed to send input to window %d: %w", index, err)
+	}
+	// The file must outlive the commands reading it
+	return s.sync(&index)
+}
+
+// capture returns the last lines lines of a window's scrollback and screen
+func capture(s *session, index, lines int) (string, error) {
+	file, err := os.CreateTemp("", "handx-screen-*")
+	if err != nil {
+		return "", err
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	if err := s.command(&index, "hardcopy", "-h", file.Name()); err != nil {
+		return "", fmt.Errorf("failed to capture window %d: %w", index, err)
+	}
+	if err := s.sync(&index); err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(file.Name())
+	if err != nil {
+		return "", fmt.Errorf("failed to capture window %d: %w", index, err)
+	}
+
+	// Lines are padded to the window width and the screen to its height
+	output := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	for i, line := range output {
+		output[i] = strings.TrimRight(line, " ")
+	}
+	for len(output) > 0 && output[len(output)-1] == "" {
+		output = output[:len(output)-1]
+	}
+	if len(output) > lines {
+		output = output[len(output)-lines:]
+	}
+	if len(output) == 0 {
+		return "", nil
+	}
+	return strings.Join(output, "\n") + "\n", nil
+}
+
+// describeWindows converts the windows of a session to their protocol form
+func (m *Manager) describeWindows(s *session, table *procinfo.Table) ([]protocol.Window, error) {
+	windows, err := s.windows()
+	if err != nil {
+		return nil, err
+	}
+
+	pids := windowPIDs(s, table)
+	width, height, _ := windowSize(s, nil)
+
+	result := make([]protocol.Window, 0, len(windows))
+	for _, w := range windows {
+		pane := describePane(s, w, pids, table)
+		pane.Width, pane.Height = width, height
+		result = append(result, protocol.Window{
+			ID:     fmt.Sprintf("window-%s-%d", s.name, w.index),
+			Name:   w.title,
+			Index:  w.index,
+			Active: w.active,
+			PaneID: fmt.Sprintf("%d", w.index),
+			Panes:  []protocol.Pane{pane},
+		})
+	}
+	return result, nil
+}
+
+// describePane describes the single pane of a window, with its process tree
+// when its process is known
+func describePane(s *session, w window, pids map[int]int, table *procinfo.Table) protocol.Pane {
+	pane := protocol.Pane{
+		ID:     paneID(s.name, w.index),
+		Index:  0,
+		Active: true,
+	}
+
+	pid := pids[w.index]
+	if pid == 0 || table == nil {
+		return pane
+	}
+	pane.PID = pid
+	pane.Processes = table.Tree(pid)
+	if len(pane.Processes) > 0 {
+		pane.CurrentCommand = pane.Processes[0].Command
+	}
+
+	// Like tmux, report the program in front of the shell
+	if fg := table.Foreground(pid); fg != nil {
+		pane.CurrentCommand = fg.Command
+		pid = fg.PID
+	}
+	// Best effort: only readable where /proc exists
+	if dir, err := os.Readlink(fmt.Sprintf("/proc/%d/cwd", pid)); err == nil {
+		pane.CurrentPath = dir
+	}
+	return pane
+}
+
+// windowPIDs maps window indexes of a session to the processes screen started
+// for them, which it tells apart by their WINDOW variable
+// Only works where /proc exists; elsewhere the map is empty.
+func windowPIDs(s *session, table *procinfo.Table) map[int]int {
+	result := make(map[int]int)
+	if table == nil {
+		return result
+	}
+
+	for _, p := range table.Tree(s.pid) {
+		if p.PPID != s.pid {
+			continue
+		}
+		environ, err := os.ReadFile(fmt.Sprintf("/proc/%d/environ", p.PID))
+		if err != nil {
+			continue
+		}
+		for _, kv := range strings.Split(string(environ), "\x00") {
+			if value, ok := strings.CutPrefix(kv, "WINDOW="); ok {
+				if index, err := strconv.Atoi(value); err == nil {
+					result[index] = p.PID
+				}
+				break
+			}
+		}
+	}
+	return result
+}
+
+// windowSize returns the size of a window of a session, the current one if
+// index is nil
+func windowSize(s *session, index *int) (int, int, error) {
+	output, err := s.query(index, "info")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	match := sizeInfo.FindStringSubmatch(output)
+	if match == nil {
+		return 0, 0, fmt.Errorf("failed to parse window size '%s'", output)
+	}
+	width, _ := strconv.Atoi(match[1])
+	height, _ := strconv.Atoi(match[2])
+	return width, height, nil
+}
+
+// listSessions returns the running screen sessions, ordered by name
+func listSessions() ([]*session, error) {
+	// screen -ls exits non-zero whenever it lists no session to attach to
+	output, err := exec.Command("screen", "-ls").Output()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	result := make([]*session, 0)
+	for _, line := range strings.Split(string(output), "\n") {
+		match := sessionLine.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		pid, _ := strconv.Atoi(match[1])
+		// "Attached", "Detached" or "Multi, attached"
+		status := strings.ToLower(match[4])
+		result = append(result, &session{
+			pid:       pid,
+			name:      match[2],
+			createdAt: parseCreated(match[3]),
+			attached:  strings.HasSuffix(status, "attached") && !strings.HasSuffix(status, "detached"),
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].name < result[j].name
+	})
+	return result, nil
+}
+
+// parseCreated converts the creation date screen -ls prints to Unix ms
+// Returns 0 for missing or unknown formats
+func parseCreated(value string) int64 {
+	for _, layout := range createdLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t.UnixMilli()
+		}
+	}
+	return 0
+}
+
+// findSession finds a session by name
+func findSession(name string) (*session, error) {
+	sessions, err := listSessions()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range sessions {
+		if s.name == name {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("session '%s' not found", name)
+}
+
+// findWindow finds a window of a session, the current one if index is nil
+func findWindow(sessionName string, index *int) (*session, window, error) {
+	s, err := findSession(sessionName)
+	if err != nil {
+		return nil, window{}, err
+	}
+	windows, err := s.windows()
+	if err != nil {
+		return nil, window{}, err
+	}
+
+	w, err := pickWindow(s, windows, index)
+	return s, w, err
+}
+
+// pickWindow picks a window by index, or the current one if index is nil
+func pickWindow(s *session, windows []window, index *int) (window, error) {
+	for _, w := range windows {
+		if (index == nil && w.active) || (index != nil && w.index == *index) {
+			return w, nil
+		}
+	}
+
+	if index == nil {
+		// Without a display screen may not mark a current window
+		if len(windows) > 0 {
+			return windows[0], nil
+		}
+		return window{}, fmt.Errorf("no windows in session '%s'", s.name)
+	}
+	return window{}, fmt.Errorf("window index %d not found in session '%s'", *index, s.name)
+}
+
+// paneID returns the ID of the pane of a window
+func paneID(sessionName string, index int) string {
+	return fmt.Sprintf("%s:%d", sessionName, index)
+}
+
+// parsePaneID finds the session and window index of a pane ID
+func parsePaneID(id string) (*session, int, error) {
+	i := strings.LastIndex(id, ":")
+	if i < 0 {
+		return nil, 0, fmt.Errorf("pane %s not found", id)
+	}
+	index, err := strconv.Atoi(id[i+1:])
+	if err != nil {
+		return nil, 0, fmt.Errorf("pane %s not found", id)
+	}
+
+	s, err := findSession(id[:i])
+	if err != nil {
+		return nil, 0, fmt.Errorf("pane %s not found", id)
+	}
+	return s, index, nil
+}
+
+// windows lists the windows of the session
+func (s *session) windows() ([]window, error) {
+	output, err := s.query(nil, "windows")
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]window, 0)
+	// Windows are separated by two spaces
+	for _, entry := range strings.Split(strings.TrimSpace(output), "  ") {
+		match := windowEntry.FindStringSubmatch(strings.TrimSpace(entry))
+		if match == nil {
+			continue
+		}
+		index, _ := strconv.Atoi(match[1])
+		result = append(result, window{
+			index:  index,
+			title:  match[3],
+			active: strings.Contains(match[2], "*"),
+			bell:   strings.Contains(match[2], "!"),
+		})
+	}
+	return result, nil
+}
+
+// target returns the exact session name for -S, which otherwise also
+// matches sessions whose name merely starts with it
+func (s *session) target() string {
+	return fmt.Sprintf("%d.%s", s.pid, s.name)
+}
+
+// command sends a command to the session, for a window if index is set
+// screen runs it asynchronously; see sync.
+func (s *session) command(index *int, args ...string) error {
+	cmdArgs := []string{"-S", s.target()}
+	if index != nil {
+		cmdArgs = append(cmdArgs, "-p", strconv.Itoa(*index))
+	}
+	cmdArgs = append(cmdArgs, "-X")
+	cmdArgs = append(cmdArgs, args...)
+
+	output, err := exec.Command("screen", cmdArgs...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// query asks the session for information, about a window if index is set
+func (s *session) query(index *int, args ...string) (string, error) {
+	cmdArgs := []string{"-S", s.target()}
+	if index != nil {
+		cmdArgs = append(cmdArgs, "-p", strconv.Itoa(*index))
+	}
+	cmdArgs = append(cmdArgs, "-Q")
+	cmdArgs = append(cmdArgs, args...)
+
+	output, err := exec.Command("screen", cmdArgs...).Output()
+	if err != nil {
+		return "", fmt.Errorf("session '%s' not found", s.name)
+	}
+	return string(output), nil
+}
+
+// sync waits until the session has run the commands sent before
+// Queries are answered in order, so the answer to one follows them.
+func (s *session) sync(index *int) error {
+	_, err := s.query(index, "number")
+	return err
+}
+
+// shellCommand returns the arguments running a command through the shell,
+// like tmux runs window commands
+func shellCommand(command string) []string {
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+	return []string{shell, "-c", command}
+}
@@ -0,0 +1,108 @@
+package search
+
+import (
+	"log"
+	"sync"
+
+	"github.com/myan/handx-server/internal/stream"
+)
+
+// indexedPane is the output kept for one pane
+type indexedPane struct {
+	lines []string
+	first int // Line number of lines[0], counted since the pane was indexed
+}
+
+// Index keeps the recent output of every pane that printed something, so
+// searches don't have to capture it again
+// A pane is seeded with its scrollback when it first prints and then grows
+// with the streamed output. Lines redrawn in place, such as progress bars,
+// are kept in every version seen.
+type Index struct {
+	backend  Backend
+	streamer *stream.Streamer
+	maxLines int
+	mu       sync.Mutex
+	panes    map[string]*indexedPane // Keyed by pane ID
+}
+
+// NewIndex creates an index fed by the output of streamer, keeping at most
+// maxLines per pane
+func NewIndex(backend Backend, streamer *stream.Streamer, maxLines int) *Index {
+	if maxLines <= 0 {
+		maxLines = 10000
+	}
+
+	idx := &Index{
+		backend:  backend,
+		streamer: streamer,
+		maxLines: maxLines,
+		panes:    make(map[string]*indexedPane),
+	}
+	streamer.Subscribe(idx.handleOutput)
+
+	return idx
+}
+
+// Lines returns the indexed output of a pane and the line number of its
+// first line; ok is false for panes that aren't indexed
+// A nil index holds no panes.
+func (idx *Index) Lines(paneID string) ([]string, int, bool) {
+	if idx == nil {
+		return nil, 0, false
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	pane, ok := idx.panes[paneID]
+	if !ok {
+		return nil, 0, false
+	}
+	return append([]string{}, pane.lines...), pane.first, true
+}
+
+// handleOutput appends new output to a pane, seeding unknown panes
+func (idx *Index) handleOutput(output stream.Output) {
+	idx.mu.Lock()
+	pane, ok := idx.panes[output.PaneID]
+	idx.mu.Unlock()
+
+	if !ok {
+		// The capture already contains the new output
+		content, err := idx.backend.CapturePane(output.PaneID, idx.maxLines)
+		if err != nil {
+			log.Printf("Failed to seed search index for pane %s: %v", output.PaneID, err)
+			return
+		}
+
+		idx.mu.Lock()
+		idx.panes[output.PaneID] = &indexedPane{lines: stream.PlainLines(content), first: 1}
+		idx.prune()
+		idx.mu.Unlock()
+		return
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	pane.lines = append(pane.lines, output.Lines...)
+	if extra := len(pane.lines) - idx.maxLines; extra > 0 {
+		// Copy so the dropped lines can be collected
+		pane.lines = append([]string{}, pane.lines[extra:]...)
+		pane.first += extra
+	}
+}
+
+// prune drops panes that no longer exist; callers must hold the lock
+func (idx *Index) prune() {
+	live := make(map[string]bool)
+	for _, location := range idx.streamer.Panes() {
+		live[location.PaneID] = true
+	}
+	for id := range idx.panes {
+		if !live[id] {
+			delete(idx.panes, id)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package search
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/myan/handx-server/internal/stream"
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// Limits of a single search
+const (
+	defaultContext    = 2
+	maxContext        = 20
+	defaultMaxResults = 100
+	maxMaxResults     = 1000
+)
+
+// Backend is the subset of the tmux manager used to search pane output
+type Backend interface {
+	ListAllPanes() ([]protocol.PaneLocation, error)
+	CapturePane(paneID string, lines int) (string, error)
+}
+
+// Searcher searches the scrollback of panes, using the index for panes it
+// holds and capturing the others
+type Searcher struct {
+	backend      Backend
+	index        *Index // nil without indexing
+	historyLines int    // Scrollback captured when not limited by the request
+}
+
+// NewSearcher creates a searcher; index may be nil
+func NewSearcher(backend Backend, index *Index, historyLines int) *Searcher {
+	if historyLines <= 0 {
+		historyLines = 10000
+	}
+
+	return &Searcher{
+		backend:      backend,
+		index:        index,
+		historyLines: historyLines,
+	}
+}
+
+// Search finds the lines matching a query in the panes it targets
+func (s *Searcher) Search(query protocol.SearchOutputPayload) (*protocol.SearchOutputResponse, error) {
+	re, err := compile(query)
+	if err != nil {
+		return nil, err
+	}
+
+	context := query.Context
+	if context == 0 {
+		context = defaultContext
+	}
+	if context < 0 {
+		context = 0
+	}
+	if context > maxContext {
+		context = maxContext
+	}
+
+	maxResults := query.MaxResults
+	if maxResults <= 0 {
+		maxResults = defaultMaxResults
+	}
+	if maxResults > maxMaxResults {
+		maxResults = maxMaxResults
+	}
+
+	lines := query.Lines
+	if lines <= 0 || lines > s.historyLines {
+		lines = s.historyLines
+	}
+
+	locations, err := s.backend.ListAllPanes()
+	if err != nil {
+		return nil, err
+	}
+	sortLocations(locations)
+
+	response := &protocol.SearchOutputResponse{
+		Matches: make([]protocol.SearchMatch, 0),
+	}
+
+	for _, location := range locations {
+		if !targets(query, location) {
+			continue
+		}
+
+		output, first, ok := s.index.Lines(location.PaneID)
+		if ok {
+			response.PanesIndexed++
+		} else {
+			content, err := s.backend.CapturePane(location.PaneID, lines)
+			if err != nil {
+				// The pane may have closed since it was listed
+				continue
+			}
+			output, first = stream.PlainLines(content), 1
+		}
+		// Only the requested amount of scrollback is searched
+		if len(output) > lines {
+			first += len(output) - lines
+			output = output[len(output)-lines:]
+		}
+		response.PanesSearched++
+
+		for i, line := range output {
+			loc := re.FindStringIndex(line)
+			if loc == nil {
+				continue
+			}
+			if len(response.Matches) >= maxResults {
+				response.Truncated = true
+				return response, nil
+			}
+
+			response.Matches = append(response.Matches, protocol.SearchMatch{
+				PaneLocation: location,
+				Line:         first + i,
+				Text:         line,
+				MatchStart:   utf8.RuneCountInString(line[:loc[0]]),
+				MatchEnd:     utf8.RuneCountInString(line[:loc[1]]),
+				Before:       append([]string{}, output[max(0, i-context):i]...),
+				After:        append([]string{}, output[i+1:min(len(output), i+1+context)]...),
+			})
+		}
+	}
+
+	return response, nil
+}
+
+// compile turns a query into a regular expression honoring its case option
+func compile(query protocol.SearchOutputPayload) (*regexp.Regexp, error) {
+	if query.Query == "" {
+		return nil, fmt.Errorf("query is required")
+	}
+
+	expr := query.Query
+	if !query.Regex {
+		expr = regexp.QuoteMeta(expr)
+	}
+
+	flags := ""
+	switch query.Case {
+	case protocol.CaseSensitive:
+	case protocol.CaseInsensitive:
+		flags = "(?i)"
+	case protocol.CaseSmart, "":
+		// Case only matters when the query has upper case letters
+		if !hasUpper(query.Query) {
+			flags = "(?i)"
+		}
+	default:
+		return nil, fmt.Errorf("unknown case option '%s'", query.Case)
+	}
+
+	// Compiled without flags first so errors refer to the query as given
+	if _, err := regexp.Compile(expr); err != nil {
+		return nil, fmt.Errorf("invalid pattern '%s': %w", query.Query, err)
+	}
+	return regexp.MustCompile(flags + expr), nil
+}
+
+// hasUpper reports whether s contains an upper case letter
+func hasUpper(s string) bool {
+	for _, r := range s {
+		if unicode.IsUpper(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// targets reports whether a query searches the pane at location
+// Unlike most messages, leaving out the window or pane searches all of them.
+func targets(query protocol.SearchOutputPayload, location protocol.PaneLocation) bool {
+	if query.SessionName != "" && query.SessionName != location.SessionName {
+		return false
+	}
+	if query.WindowIndex != nil && *query.WindowIndex != location.WindowIndex {
+		return false
+	}
+	if query.PaneIndex != nil && *query.PaneIndex != location.PaneIndex {
+		return false
+	}
+	return true
+}
+
+// sortLocations orders panes by session, window and pane
+func sortLocations(locations []protocol.PaneLocation) {
+	sort.Slice(locations, func(i, j int) bool {
+		a, b := locations[i], locations[j]
+		if a.SessionName != b.SessionName {
+			return a.SessionName < b.SessionName
+		}
+		if a.WindowIndex != b.WindowIndex {
+			return a.WindowIndex < b.WindowIndex
+		}
+		return a.PaneIndex < b.PaneIndex
+	})
+}
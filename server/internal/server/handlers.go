@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"sort"
 
 	"github.com/myan/handx-server/pkg/protocol"
 )
@@ -25,7 +26,12 @@ func (c *Client) handleConnect(msg *protocol.Message) {
 	log.Printf("Connect from client: type=%s, version=%s", payload.ClientType, payload.Version)
 
 	// TODO: Validate token
-	// For now, always accept
+	// For now, always accept; the token is only checked for file access
+	if payload.Token != "" {
+		c.mu.Lock()
+		c.token = payload.Token
+		c.mu.Unlock()
+	}
 
 	ackPayload := protocol.ConnectAckPayload{
 		Success:           true,
@@ -38,15 +44,29 @@ func (c *Client) handleConnect(msg *protocol.Message) {
 
 // handleListSessions handles the list_sessions message
 func (c *Client) handleListSessions(msg *protocol.Message) {
-	log.Printf("List sessions requested")
+	var payload protocol.ListSessionsPayload
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse list sessions payload", msg.ID)
+		return
+	}
 
-	sessions, err := c.server.tmuxManager.ListSessions()
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse list sessions payload", msg.ID)
+		return
+	}
+
+	log.Printf("List sessions requested: sort_by=%s", payload.SortBy)
+
+	sessions, err := c.server.backend.ListSessions()
 	if err != nil {
 		log.Printf("Failed to list sessions: %v", err)
 		c.sendError(protocol.ErrorTmuxError, fmt.Sprintf("Failed to list sessions: %v", err), msg.ID)
 		return
 	}
 
+	sortSessions(sessions, payload.SortBy)
+
 	response := protocol.ListSessionsResponse{
 		Sessions: sessions,
 	}
@@ -55,6 +75,24 @@ func (c *Client) handleListSessions(msg *protocol.Message) {
 	c.sendMessage(protocol.TypeListSessionsResponse, response)
 }
 
+// sortSessions orders sessions by the requested key
+// Time based keys put the most recent first; unknown keys sort by name
+func sortSessions(sessions []protocol.Session, sortBy string) {
+	sort.SliceStable(sessions, func(i, j int) bool {
+		a, b := sessions[i], sessions[j]
+		switch sortBy {
+		case "created":
+			return a.CreatedAt > b.CreatedAt
+		case "activity":
+			return a.LastActivity > b.LastActivity
+		case "attached":
+			return a.LastAttached > b.LastAttached
+		default:
+			return a.Name < b.Name
+		}
+	})
+}
+
 // handleCreateSession handles the create_session message
 func (c *Client) handleCreateSession(msg *protocol.Message) {
 	var payload protocol.CreateSessionPayload
@@ -69,9 +107,18 @@ func (c *Client) handleCreateSession(msg *protocol.Message) {
 		return
 	}
 
-	log.Printf("Create session: name=%s", payload.Name)
+	log.Printf("Create session: name=%s, dir=%s, command=%s, socket=%s", payload.Name, payload.StartDirectory, payload.Command, payload.Socket)
+
+	if _, ok := c.server.backend.(SocketManager); payload.Socket != "" && !ok {
+		c.sendError(protocol.ErrorFeatureDisabled, "tmux sockets are not supported by this backend", msg.ID)
+		return
+	}
+	if _, ok := c.server.backend.(HostManager); payload.Host != "" && !ok {
+		c.sendError(protocol.ErrorFeatureDisabled, "Remote hosts are not supported by this backend", msg.ID)
+		return
+	}
 
-	session, err := c.server.tmuxManager.CreateSession(payload.Name)
+	session, err := c.server.backend.CreateSession(payload.Name, &payload.SessionOptions)
 	if err != nil {
 		log.Printf("Failed to create session: %v", err)
 
@@ -93,6 +140,42 @@ func (c *Client) handleCreateSession(msg *protocol.Message) {
 	c.sendMessage(protocol.TypeCreateSessionResponse, response)
 }
 
+// handleListSockets handles the list_sockets message
+func (c *Client) handleListSockets(msg *protocol.Message) {
+	sockets, ok := c.server.backend.(SocketManager)
+	if !ok {
+		c.sendError(protocol.ErrorFeatureDisabled, "tmux sockets are not supported by this backend", msg.ID)
+		return
+	}
+
+	result, err := sockets.ListSockets()
+	if err != nil {
+		log.Printf("Failed to list sockets: %v", err)
+		c.sendError(protocol.ErrorTmuxError, fmt.Sprintf("Failed to list sockets: %v", err), msg.ID)
+		return
+	}
+
+	c.sendMessage(protocol.TypeListSocketsResponse, protocol.ListSocketsResponse{Sockets: result})
+}
+
+// handleListHosts handles the list_hosts message
+func (c *Client) handleListHosts(msg *protocol.Message) {
+	hosts, ok := c.server.backend.(HostManager)
+	if !ok {
+		c.sendError(protocol.ErrorFeatureDisabled, "Remote hosts are not supported by this backend", msg.ID)
+		return
+	}
+
+	result, err := hosts.ListHosts()
+	if err != nil {
+		log.Printf("Failed to list hosts: %v", err)
+		c.sendError(protocol.ErrorTmuxError, fmt.Sprintf("Failed to list hosts: %v", err), msg.ID)
+		return
+	}
+
+	c.sendMessage(protocol.TypeListHostsResponse, protocol.ListHostsResponse{Hosts: result})
+}
+
 // handleExecuteCommand handles the execute_command message
 func (c *Client) handleExecuteCommand(msg *protocol.Message) {
 	var payload protocol.ExecuteCommandPayload
@@ -114,12 +197,15 @@ func (c *Client) handleExecuteCommand(msg *protocol.Message) {
 	}
 
 	// Execute command with Enter key - automatically execute after submission
-	err = c.server.tmuxManager.ExecuteCommand(payload.SessionName, payload.Command, payload.WindowIndex)
+	err = c.server.backend.ExecuteCommand(payload.SessionName, payload.Command, payload.WindowIndex)
 	if err != nil {
 		log.Printf("Failed to execute command: %v", err)
 		c.sendError(protocol.ErrorCommandFailed, fmt.Sprintf("Failed to execute command: %v", err), msg.ID)
 		return
 	}
+	if c.server.history != nil {
+		c.server.history.Record(payload.SessionName, payload.Command)
+	}
 
 	response := protocol.ExecuteCommandResponse{
 		Success:     true,
@@ -150,7 +236,7 @@ func (c *Client) handleCaptureOutput(msg *protocol.Message) {
 		log.Printf("Capture output: session=%s", payload.SessionName)
 	}
 
-	output, err := c.server.tmuxManager.CaptureOutput(payload.SessionName, payload.WindowIndex)
+	output, err := c.server.backend.CaptureOutput(payload.SessionName, payload.WindowIndex)
 	if err != nil {
 		log.Printf("Failed to capture output: %v", err)
 		c.sendError(protocol.ErrorTmuxError, fmt.Sprintf("Failed to capture output: %v", err), msg.ID)
@@ -181,7 +267,7 @@ func (c *Client) handleDeleteSession(msg *protocol.Message) {
 
 	log.Printf("Delete session: name=%s", payload.SessionName)
 
-	err = c.server.tmuxManager.KillSession(payload.SessionName)
+	err = c.server.backend.KillSession(payload.SessionName)
 	if err != nil {
 		log.Printf("Failed to delete session: %v", err)
 		c.sendError(protocol.ErrorSessionNotFound, fmt.Sprintf("Failed to delete session: %v", err), msg.ID)
@@ -193,6 +279,13 @@ func (c *Client) handleDeleteSession(msg *protocol.Message) {
 		"session_name": payload.SessionName,
 	}
 
+	// A deleted session isn't kept in the snapshot for restoring
+	if c.server.snapshotter != nil {
+		if _, err := c.server.snapshotter.Dismiss([]string{payload.SessionName}); err != nil {
+			log.Printf("Failed to drop session %s from snapshot: %v", payload.SessionName, err)
+		}
+	}
+
 	log.Printf("Session deleted: %s", payload.SessionName)
 	c.sendMessage(protocol.TypeDeleteSessionResponse, response)
 }
@@ -213,7 +306,7 @@ func (c *Client) handleRenameSession(msg *protocol.Message) {
 
 	log.Printf("Rename session: %s -> %s", payload.OldName, payload.NewName)
 
-	err = c.server.tmuxManager.RenameSession(payload.OldName, payload.NewName)
+	err = c.server.backend.RenameSession(payload.OldName, payload.NewName)
 	if err != nil {
 		log.Printf("Failed to rename session: %v", err)
 		c.sendError(protocol.ErrorTmuxError, fmt.Sprintf("Failed to rename session: %v", err), msg.ID)
@@ -246,7 +339,7 @@ func (c *Client) handleListWindows(msg *protocol.Message) {
 
 	log.Printf("List windows: session=%s", payload.SessionName)
 
-	windows, err := c.server.tmuxManager.ListWindows(payload.SessionName)
+	windows, err := c.server.backend.ListWindows(payload.SessionName)
 	if err != nil {
 		log.Printf("Failed to list windows: %v", err)
 		c.sendError(protocol.ErrorSessionNotFound, fmt.Sprintf("Failed to list windows: %v", err), msg.ID)
@@ -278,7 +371,7 @@ func (c *Client) handleSwitchWindow(msg *protocol.Message) {
 
 	log.Printf("Switch window: session=%s, window=%d", payload.SessionName, payload.WindowIndex)
 
-	windowName, err := c.server.tmuxManager.SwitchWindow(payload.SessionName, payload.WindowIndex)
+	windowName, err := c.server.backend.SwitchWindow(payload.SessionName, payload.WindowIndex)
 	if err != nil {
 		log.Printf("Failed to switch window: %v", err)
 		c.sendError(protocol.ErrorWindowNotFound, fmt.Sprintf("Failed to switch window: %v", err), msg.ID)
@@ -312,7 +405,7 @@ func (c *Client) handleCreateWindow(msg *protocol.Message) {
 
 	log.Printf("Create window: session=%s, name=%s", payload.SessionName, payload.WindowName)
 
-	window, err := c.server.tmuxManager.CreateWindow(payload.SessionName, payload.WindowName)
+	window, err := c.server.backend.CreateWindow(payload.SessionName, payload.WindowName, &payload.WindowOptions)
 	if err != nil {
 		log.Printf("Failed to create window: %v", err)
 		c.sendError(protocol.ErrorTmuxError, fmt.Sprintf("Failed to create window: %v", err), msg.ID)
@@ -345,7 +438,7 @@ func (c *Client) handleCloseWindow(msg *protocol.Message) {
 
 	log.Printf("Close window: session=%s, index=%d", payload.SessionName, payload.WindowIndex)
 
-	err = c.server.tmuxManager.CloseWindow(payload.SessionName, payload.WindowIndex)
+	err = c.server.backend.CloseWindow(payload.SessionName, payload.WindowIndex)
 	if err != nil {
 		log.Printf("Failed to close window: %v", err)
 		c.sendError(protocol.ErrorWindowNotFound, fmt.Sprintf("Failed to close window: %v", err), msg.ID)
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+
+	"github.com/myan/handx-server/internal/agent"
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// handleListAgentStates handles the list_agent_states message
+func (c *Client) handleListAgentStates(msg *protocol.Message) {
+	if c.server.agents == nil {
+		c.sendError(protocol.ErrorFeatureDisabled, "Agent detection is not enabled", msg.ID)
+		return
+	}
+
+	response := protocol.ListAgentStatesResponse{
+		States: c.server.agents.States(),
+	}
+
+	c.sendMessage(protocol.TypeListAgentStatesResponse, response)
+}
+
+// handleRespondPrompt handles the respond_prompt message
+func (c *Client) handleRespondPrompt(msg *protocol.Message) {
+	if c.server.agents == nil {
+		c.sendError(protocol.ErrorFeatureDisabled, "Agent detection is not enabled", msg.ID)
+		return
+	}
+
+	var payload protocol.RespondPromptPayload
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse respond prompt payload", msg.ID)
+		return
+	}
+
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse respond prompt payload", msg.ID)
+		return
+	}
+
+	pane, windowIndex, err := c.server.backend.PaneInfo(payload.SessionName, payload.WindowIndex, payload.PaneIndex)
+	if err != nil {
+		log.Printf("Failed to find pane to respond in: %v", err)
+		c.sendError(protocol.ErrorPaneNotFound, err.Error(), msg.ID)
+		return
+	}
+
+	location := protocol.PaneLocation{
+		SessionName: payload.SessionName,
+		WindowIndex: windowIndex,
+		PaneIndex:   pane.Index,
+		PaneID:      pane.ID,
+	}
+
+	sent, err := c.server.agents.Respond(location, payload.Option, payload.Text)
+	if err != nil {
+		log.Printf("Failed to respond to prompt: %v", err)
+		switch {
+		case errors.Is(err, agent.ErrNotWaiting):
+			c.sendError(protocol.ErrorNoPrompt, err.Error(), msg.ID)
+		case errors.Is(err, agent.ErrInvalidOption):
+			c.sendError(protocol.ErrorInvalidRequest, err.Error(), msg.ID)
+		default:
+			c.sendError(protocol.ErrorTmuxError, err.Error(), msg.ID)
+		}
+		return
+	}
+
+	response := protocol.RespondPromptResponse{
+		PaneLocation: location,
+		Success:      true,
+		Sent:         sent,
+	}
+
+	log.Printf("Responded to prompt in %s:%d.%d with '%s'", location.SessionName, location.WindowIndex, location.PaneIndex, sent)
+	c.sendMessage(protocol.TypeRespondPromptResponse, response)
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/myan/handx-server/internal/artifact"
+	"github.com/myan/handx-server/internal/stream"
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// Scrollback searched for artifacts
+const (
+	defaultArtifactLines = 1000
+	maxArtifactLines     = 50000
+)
+
+// handleExtractArtifacts handles the extract_artifacts message
+func (c *Client) handleExtractArtifacts(msg *protocol.Message) {
+	var payload protocol.ExtractArtifactsPayload
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse extract artifacts payload", msg.ID)
+		return
+	}
+
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse extract artifacts payload", msg.ID)
+		return
+	}
+
+	lines := payload.Lines
+	if lines <= 0 {
+		lines = defaultArtifactLines
+	}
+	if lines > maxArtifactLines {
+		lines = maxArtifactLines
+	}
+
+	pane, windowIndex, err := c.server.backend.PaneInfo(payload.SessionName, payload.WindowIndex, payload.PaneIndex)
+	if err != nil {
+		log.Printf("Failed to find pane to extract artifacts from: %v", err)
+		c.sendError(protocol.ErrorPaneNotFound, err.Error(), msg.ID)
+		return
+	}
+
+	content, err := c.server.backend.CapturePane(pane.ID, lines)
+	if err != nil {
+		log.Printf("Failed to capture pane: %v", err)
+		c.sendError(protocol.ErrorTmuxError, err.Error(), msg.ID)
+		return
+	}
+
+	output := stream.PlainLines(content)
+	artifacts := filterArtifacts(artifact.Extract(output), payload.Types)
+
+	response := protocol.ExtractArtifactsResponse{
+		PaneLocation: protocol.PaneLocation{
+			SessionName: payload.SessionName,
+			WindowIndex: windowIndex,
+			PaneIndex:   pane.Index,
+			PaneID:      pane.ID,
+		},
+		LineCount: len(output),
+		Artifacts: artifacts,
+	}
+
+	log.Printf("Extracted %d artifacts from %s:%d.%d", len(artifacts), payload.SessionName, windowIndex, pane.Index)
+	c.sendMessage(protocol.TypeExtractArtifactsResponse, response)
+}
+
+// filterArtifacts keeps the artifacts of the given types, or all without types
+func filterArtifacts(artifacts []protocol.Artifact, types []string) []protocol.Artifact {
+	if len(types) == 0 {
+		return artifacts
+	}
+
+	wanted := make(map[string]bool, len(types))
+	for _, t := range types {
+		wanted[t] = true
+	}
+
+	result := make([]protocol.Artifact, 0, len(artifacts))
+	for _, a := range artifacts {
+		if wanted[a.Type] {
+			result = append(result, a)
+		}
+	}
+	return result
+}
@@ -0,0 +1,185 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/myan/handx-server/internal/attach"
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// handleAttach handles the attach message
+func (c *Client) handleAttach(msg *protocol.Message) {
+	if !c.server.attach {
+		c.sendError(protocol.ErrorFeatureDisabled, "Attaching is not enabled", msg.ID)
+		return
+	}
+
+	var payload protocol.AttachPayload
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse attach payload", msg.ID)
+		return
+	}
+
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse attach payload", msg.ID)
+		return
+	}
+
+	if payload.SessionName == "" {
+		c.sendError(protocol.ErrorInvalidRequest, "session_name is required", msg.ID)
+		return
+	}
+
+	// A client has one attachment; attaching again replaces it
+	c.detach()
+
+	var socket string
+	if sockets, ok := c.server.backend.(SocketManager); ok {
+		if socket, err = sockets.SessionSocket(payload.SessionName); err != nil {
+			c.sendError(protocol.ErrorSessionNotFound, err.Error(), msg.ID)
+			return
+		}
+	}
+
+	var session *attach.Session
+	ready := make(chan struct{})
+	session, err = attach.Start(attach.Options{
+		SessionName: payload.SessionName,
+		Cols:        payload.Cols,
+		Rows:        payload.Rows,
+		Grouped:     payload.Grouped,
+		Socket:      socket,
+	}, func(data []byte) bool {
+		// Output follows the response
+		<-ready
+		return c.isAttached(session) && c.sendBinary(data)
+	})
+	if err != nil {
+		log.Printf("Failed to attach: %v", err)
+		c.sendError(protocol.ErrorSessionNotFound, err.Error(), msg.ID)
+		return
+	}
+
+	c.mu.Lock()
+	connected := c.connected
+	if connected {
+		c.attachment = session
+	}
+	c.mu.Unlock()
+	if !connected {
+		close(ready)
+		session.Close()
+		return
+	}
+
+	cols, rows, _ := session.Resize(payload.Cols, payload.Rows)
+	response := protocol.AttachResponse{
+		Success:         true,
+		SessionName:     payload.SessionName,
+		AttachedSession: session.Name(),
+		Cols:            cols,
+		Rows:            rows,
+	}
+	c.sendMessage(protocol.TypeAttachResponse, response)
+	close(ready)
+
+	go func() {
+		<-session.Done()
+		// Only report exits the client didn't ask for
+		c.mu.Lock()
+		current := c.attachment == session
+		if current {
+			c.attachment = nil
+		}
+		c.mu.Unlock()
+
+		if current {
+			c.sendMessage(protocol.TypeDetached, protocol.DetachedPayload{
+				SessionName:     payload.SessionName,
+				AttachedSession: session.Name(),
+			})
+		}
+	}()
+}
+
+// handleAttachResize handles the attach_resize message
+func (c *Client) handleAttachResize(msg *protocol.Message) {
+	var payload protocol.AttachResizePayload
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse attach resize payload", msg.ID)
+		return
+	}
+
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse attach resize payload", msg.ID)
+		return
+	}
+
+	c.mu.Lock()
+	session := c.attachment
+	c.mu.Unlock()
+	if session == nil {
+		c.sendError(protocol.ErrorInvalidRequest, "Not attached", msg.ID)
+		return
+	}
+
+	cols, rows, err := session.Resize(payload.Cols, payload.Rows)
+	if err != nil {
+		c.sendError(protocol.ErrorInternalError, err.Error(), msg.ID)
+		return
+	}
+
+	response := protocol.AttachResizeResponse{
+		Success: true,
+		Cols:    cols,
+		Rows:    rows,
+	}
+	c.sendMessage(protocol.TypeAttachResizeResponse, response)
+}
+
+// handleDetach handles the detach message
+func (c *Client) handleDetach(msg *protocol.Message) {
+	response := protocol.DetachResponse{
+		Success:  true,
+		Detached: c.detach(),
+	}
+	c.sendMessage(protocol.TypeDetachResponse, response)
+}
+
+// handleTerminalInput types a binary frame into the attachment
+func (c *Client) handleTerminalInput(data []byte) {
+	c.mu.Lock()
+	session := c.attachment
+	c.mu.Unlock()
+	if session == nil {
+		return
+	}
+
+	if err := session.Write(data); err != nil {
+		log.Printf("Failed to write terminal input: %v", err)
+	}
+}
+
+// detach closes the client's attachment, reporting whether there was one
+func (c *Client) detach() bool {
+	c.mu.Lock()
+	session := c.attachment
+	c.attachment = nil
+	c.mu.Unlock()
+
+	if session == nil {
+		return false
+	}
+	session.Close()
+	return true
+}
+
+// isAttached reports whether session is the client's current attachment
+func (c *Client) isAttached(session *attach.Session) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.attachment == session
+}
@@ -0,0 +1,249 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/myan/handx-server/internal/stream"
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// Scrollback line ranges are selected from
+const (
+	defaultSelectionLines = 10000
+	maxSelectionLines     = 50000
+)
+
+// handleListBuffers handles the list_buffers message
+func (c *Client) handleListBuffers(msg *protocol.Message) {
+	buffers, ok := c.server.backend.(BufferManager)
+	if !ok {
+		c.sendError(protocol.ErrorFeatureDisabled, "Paste buffers are not supported by this backend", msg.ID)
+		return
+	}
+
+	list, err := buffers.ListBuffers()
+	if err != nil {
+		log.Printf("Failed to list buffers: %v", err)
+		c.sendError(protocol.ErrorTmuxError, err.Error(), msg.ID)
+		return
+	}
+
+	response := protocol.ListBuffersResponse{
+		Buffers: list,
+	}
+	c.sendMessage(protocol.TypeListBuffersResponse, response)
+}
+
+// handleGetBuffer handles the get_buffer message
+func (c *Client) handleGetBuffer(msg *protocol.Message) {
+	buffers, ok := c.server.backend.(BufferManager)
+	if !ok {
+		c.sendError(protocol.ErrorFeatureDisabled, "Paste buffers are not supported by this backend", msg.ID)
+		return
+	}
+
+	var payload protocol.BufferNamePayload
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse get buffer payload", msg.ID)
+		return
+	}
+
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse get buffer payload", msg.ID)
+		return
+	}
+
+	content, err := buffers.ShowBuffer(payload.Name)
+	if err != nil {
+		c.sendError(protocol.ErrorBufferNotFound, err.Error(), msg.ID)
+		return
+	}
+
+	response := protocol.GetBufferResponse{
+		Name:    payload.Name,
+		Content: content,
+	}
+	c.sendMessage(protocol.TypeGetBufferResponse, response)
+}
+
+// handleSetBuffer handles the set_buffer message
+func (c *Client) handleSetBuffer(msg *protocol.Message) {
+	buffers, ok := c.server.backend.(BufferManager)
+	if !ok {
+		c.sendError(protocol.ErrorFeatureDisabled, "Paste buffers are not supported by this backend", msg.ID)
+		return
+	}
+
+	var payload protocol.SetBufferPayload
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse set buffer payload", msg.ID)
+		return
+	}
+
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse set buffer payload", msg.ID)
+		return
+	}
+
+	name, err := buffers.SetBuffer(payload.Name, payload.Content)
+	if err != nil {
+		log.Printf("Failed to set buffer: %v", err)
+		c.sendError(protocol.ErrorTmuxError, err.Error(), msg.ID)
+		return
+	}
+
+	response := protocol.SetBufferResponse{
+		Success: true,
+		Name:    name,
+		Size:    len(payload.Content),
+	}
+	c.sendMessage(protocol.TypeSetBufferResponse, response)
+}
+
+// handlePasteBuffer handles the paste_buffer message
+func (c *Client) handlePasteBuffer(msg *protocol.Message) {
+	buffers, ok := c.server.backend.(BufferManager)
+	if !ok {
+		c.sendError(protocol.ErrorFeatureDisabled, "Paste buffers are not supported by this backend", msg.ID)
+		return
+	}
+
+	var payload protocol.PasteBufferPayload
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse paste buffer payload", msg.ID)
+		return
+	}
+
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse paste buffer payload", msg.ID)
+		return
+	}
+
+	pane, windowIndex, err := c.server.backend.PaneInfo(payload.SessionName, payload.WindowIndex, payload.PaneIndex)
+	if err != nil {
+		c.sendError(protocol.ErrorPaneNotFound, err.Error(), msg.ID)
+		return
+	}
+
+	bracketed := payload.Bracketed == nil || *payload.Bracketed
+	if err := buffers.PasteBuffer(payload.Name, pane.ID, bracketed); err != nil {
+		log.Printf("Failed to paste buffer: %v", err)
+		c.sendError(protocol.ErrorBufferNotFound, err.Error(), msg.ID)
+		return
+	}
+
+	response := protocol.PasteBufferResponse{
+		Success: true,
+		PaneLocation: protocol.PaneLocation{
+			SessionName: payload.SessionName,
+			WindowIndex: windowIndex,
+			PaneIndex:   pane.Index,
+			PaneID:      pane.ID,
+		},
+		Name: payload.Name,
+	}
+	c.sendMessage(protocol.TypePasteBufferResponse, response)
+}
+
+// handleCopySelection handles the copy_selection message
+func (c *Client) handleCopySelection(msg *protocol.Message) {
+	buffers, ok := c.server.backend.(BufferManager)
+	if !ok {
+		c.sendError(protocol.ErrorFeatureDisabled, "Copying is not supported by this backend", msg.ID)
+		return
+	}
+
+	var payload protocol.CopySelectionPayload
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse copy selection payload", msg.ID)
+		return
+	}
+
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse copy selection payload", msg.ID)
+		return
+	}
+
+	lines := payload.Lines
+	if lines <= 0 {
+		lines = defaultSelectionLines
+	}
+	if lines > maxSelectionLines {
+		lines = maxSelectionLines
+	}
+
+	pane, windowIndex, err := c.server.backend.PaneInfo(payload.SessionName, payload.WindowIndex, payload.PaneIndex)
+	if err != nil {
+		c.sendError(protocol.ErrorPaneNotFound, err.Error(), msg.ID)
+		return
+	}
+
+	content, err := c.server.backend.CapturePane(pane.ID, lines)
+	if err != nil {
+		log.Printf("Failed to capture pane: %v", err)
+		c.sendError(protocol.ErrorTmuxError, err.Error(), msg.ID)
+		return
+	}
+
+	output := stream.PlainLines(content)
+	start, end, err := selectLines(len(output), payload.StartLine, payload.EndLine)
+	if err != nil {
+		c.sendError(protocol.ErrorInvalidRequest, err.Error(), msg.ID)
+		return
+	}
+	text := strings.Join(output[start-1:end], "\n")
+
+	name, err := buffers.SetBuffer(payload.BufferName, text)
+	if err != nil {
+		log.Printf("Failed to set buffer: %v", err)
+		c.sendError(protocol.ErrorTmuxError, err.Error(), msg.ID)
+		return
+	}
+
+	response := protocol.CopySelectionResponse{
+		PaneLocation: protocol.PaneLocation{
+			SessionName: payload.SessionName,
+			WindowIndex: windowIndex,
+			PaneIndex:   pane.Index,
+			PaneID:      pane.ID,
+		},
+		BufferName: name,
+		StartLine:  start,
+		EndLine:    end,
+		LineCount:  len(output),
+		Text:       text,
+	}
+
+	log.Printf("Copied lines %d-%d of %s:%d.%d to buffer %s", start, end, payload.SessionName, windowIndex, pane.Index, name)
+	c.sendMessage(protocol.TypeCopySelectionResponse, response)
+}
+
+// selectLines resolves a 1-based inclusive line range of count lines, where
+// negative numbers count from the last line and an end of 0 means the last
+func selectLines(count, start, end int) (int, int, error) {
+	if count == 0 {
+		return 0, 0, fmt.Errorf("the pane has no output")
+	}
+	if start < 0 {
+		start = count + start + 1
+	}
+	if end < 0 {
+		end = count + end + 1
+	} else if end == 0 {
+		end = count
+	}
+	start = max(start, 1)
+	end = min(end, count)
+
+	if start > end {
+		return 0, 0, fmt.Errorf("empty line range %d-%d of %d lines", start, end, count)
+	}
+	return start, end, nil
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// handleComplete handles the complete message
+func (c *Client) handleComplete(msg *protocol.Message) {
+	if c.server.completer == nil {
+		c.sendError(protocol.ErrorFeatureDisabled, "Completion is not enabled", msg.ID)
+		return
+	}
+	var payload protocol.CompletePayload
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse complete payload", msg.ID)
+		return
+	}
+
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse complete payload", msg.ID)
+		return
+	}
+
+	pane, windowIndex, err := c.server.findPane(payload.SessionName, payload.WindowIndex, payload.PaneIndex)
+	if err != nil {
+		log.Printf("Failed to find pane to complete for: %v", err)
+		c.sendError(protocol.ErrorPaneNotFound, err.Error(), msg.ID)
+		return
+	}
+	// Paths, branches and executables are looked up on the server's host
+	if pane.Host != "" {
+		c.sendError(protocol.ErrorInvalidRequest, fmt.Sprintf("Completion is not available for panes on remote host '%s'", pane.Host), msg.ID)
+		return
+	}
+
+	response := protocol.CompleteResponse{
+		PaneLocation: protocol.PaneLocation{
+			SessionName: payload.SessionName,
+			WindowIndex: windowIndex,
+			PaneIndex:   pane.Index,
+			PaneID:      pane.ID,
+		},
+		CurrentPath: pane.CurrentPath,
+		Completions: c.server.completer.Complete(payload.Input, pane.CurrentPath, payload.SessionName, payload.Limit),
+	}
+	c.sendMessage(protocol.TypeCompleteResponse, response)
+}
+
+// findPane describes a pane, skipping its process tree where the backend can
+func (s *Server) findPane(sessionName string, windowIndex, paneIndex *int) (*protocol.Pane, int, error) {
+	if finder, ok := s.backend.(PaneFinder); ok {
+		return finder.FindPane(sessionName, windowIndex, paneIndex)
+	}
+	return s.backend.PaneInfo(sessionName, windowIndex, paneIndex)
+}
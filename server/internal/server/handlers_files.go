@@ -0,0 +1,317 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/myan/handx-server/internal/files"
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// handleListDirectory handles the list_directory message
+func (c *Client) handleListDirectory(msg *protocol.Message) {
+	if c.server.files == nil {
+		c.sendError(protocol.ErrorFeatureDisabled, "File access is not enabled", msg.ID)
+		return
+	}
+	if !c.authorizeFiles(msg.ID) {
+		return
+	}
+
+	var payload protocol.ListDirectoryPayload
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse list directory payload", msg.ID)
+		return
+	}
+
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse list directory payload", msg.ID)
+		return
+	}
+
+	base, err := c.server.paneBase(payload.SessionName, payload.WindowIndex, payload.PaneIndex)
+	if err != nil {
+		c.sendError(protocol.ErrorPaneNotFound, err.Error(), msg.ID)
+		return
+	}
+
+	response, err := c.server.files.List(payload.Path, base, payload.ShowHidden)
+	if err != nil {
+		c.sendError(fileErrorCode(err), fmt.Sprintf("Failed to list directory: %v", err), msg.ID)
+		return
+	}
+	c.sendMessage(protocol.TypeListDirectoryResponse, response)
+}
+
+// handleReadFile handles the read_file message
+func (c *Client) handleReadFile(msg *protocol.Message) {
+	if c.server.files == nil {
+		c.sendError(protocol.ErrorFeatureDisabled, "File access is not enabled", msg.ID)
+		return
+	}
+	if !c.authorizeFiles(msg.ID) {
+		return
+	}
+
+	var payload protocol.ReadFilePayload
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse read file payload", msg.ID)
+		return
+	}
+
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse read file payload", msg.ID)
+		return
+	}
+
+	if payload.Path == "" {
+		c.sendError(protocol.ErrorInvalidRequest, "path is required", msg.ID)
+		return
+	}
+
+	base, err := c.server.paneBase(payload.SessionName, payload.WindowIndex, payload.PaneIndex)
+	if err != nil {
+		c.sendError(protocol.ErrorPaneNotFound, err.Error(), msg.ID)
+		return
+	}
+
+	response, err := c.server.files.Read(payload.Path, base, payload.Offset, payload.Length)
+	if err != nil {
+		c.sendError(fileErrorCode(err), fmt.Sprintf("Failed to read file: %v", err), msg.ID)
+		return
+	}
+	c.sendMessage(protocol.TypeReadFileResponse, response)
+}
+
+// handleWriteFile handles the write_file message
+func (c *Client) handleWriteFile(msg *protocol.Message) {
+	if c.server.files == nil {
+		c.sendError(protocol.ErrorFeatureDisabled, "File access is not enabled", msg.ID)
+		return
+	}
+	if !c.authorizeFiles(msg.ID) {
+		return
+	}
+
+	var payload protocol.WriteFilePayload
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse write file payload", msg.ID)
+		return
+	}
+
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse write file payload", msg.ID)
+		return
+	}
+
+	base, err := c.server.paneBase(payload.SessionName, payload.WindowIndex, payload.PaneIndex)
+	if err != nil {
+		c.sendError(protocol.ErrorPaneNotFound, err.Error(), msg.ID)
+		return
+	}
+
+	entry, err := c.server.files.Write(payload, base)
+	if err != nil {
+		log.Printf("Failed to write file: %v", err)
+		c.sendError(fileErrorCode(err), fmt.Sprintf("Failed to write file: %v", err), msg.ID)
+		return
+	}
+
+	log.Printf("Wrote %s (%d bytes)", entry.Path, entry.Size)
+	response := protocol.WriteFileResponse{
+		Success: true,
+		File:    *entry,
+	}
+	c.sendMessage(protocol.TypeWriteFileResponse, response)
+}
+
+// handleDownloadFile serves a file as an attachment
+// Query parameters: path, and session_name, window_index and pane_index
+// for paths relative to a pane.
+func (s *Server) handleDownloadFile(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeHTTP(w, r) {
+		return
+	}
+
+	base, err := s.paneBaseFromQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	file, info, err := s.files.Open(r.URL.Query().Get("path"), base)
+	if err != nil {
+		http.Error(w, err.Error(), fileHTTPStatus(err))
+		return
+	}
+	defer file.Close()
+
+	log.Printf("Downloading %s (%d bytes)", file.Name(), info.Size())
+	name := filepath.Base(file.Name())
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	http.ServeContent(w, r, name, info.ModTime(), file)
+}
+
+// handleUploadFiles writes the files of a multipart form into a directory
+// Query parameters: path of the directory, overwrite, and session_name,
+// window_index and pane_index for paths relative to a pane.
+func (s *Server) handleUploadFiles(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeHTTP(w, r) {
+		return
+	}
+
+	base, err := s.paneBaseFromQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, "expected a multipart/form-data body", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	overwrite, _ := strconv.ParseBool(query.Get("overwrite"))
+
+	uploaded := make([]protocol.FileEntry, 0)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if part.FileName() == "" {
+			// Not a file field
+			part.Close()
+			continue
+		}
+
+		entry, err := s.files.Upload(query.Get("path"), base, part.FileName(), part, overwrite)
+		part.Close()
+		if err != nil {
+			log.Printf("Failed to upload %s: %v", part.FileName(), err)
+			http.Error(w, err.Error(), fileHTTPStatus(err))
+			return
+		}
+		log.Printf("Uploaded %s (%d bytes)", entry.Path, entry.Size)
+		uploaded = append(uploaded, *entry)
+	}
+
+	if len(uploaded) == 0 {
+		http.Error(w, "no files in the form", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(protocol.UploadFilesResponse{Success: true, Files: uploaded})
+}
+
+// authorizeHTTP checks the connection token, given as a bearer token or a
+// token query parameter, and writes 401 if it isn't valid
+func (s *Server) authorizeHTTP(w http.ResponseWriter, r *http.Request) bool {
+	token := r.URL.Query().Get("token")
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		token = strings.TrimPrefix(header, "Bearer ")
+	}
+
+	if s.tokens == nil || token == "" || !s.tokens.ValidateToken(token) {
+		http.Error(w, "invalid or missing token", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// authorizeFiles checks the connection token the client connected with,
+// like authorizeHTTP does for transfers, and sends an error if it isn't valid
+func (c *Client) authorizeFiles(msgID string) bool {
+	c.mu.Lock()
+	token := c.token
+	c.mu.Unlock()
+
+	if c.server.tokens == nil || token == "" || !c.server.tokens.ValidateToken(token) {
+		c.sendError(protocol.ErrorInvalidToken, "File access requires a valid connection token", msgID)
+		return false
+	}
+	return true
+}
+
+// paneBase returns the current path of a pane to resolve relative paths
+// against, or "" without a session name
+func (s *Server) paneBase(sessionName string, windowIndex, paneIndex *int) (string, error) {
+	if sessionName == "" {
+		return "", nil
+	}
+	pane, _, err := s.findPane(sessionName, windowIndex, paneIndex)
+	if err != nil {
+		return "", err
+	}
+	// Files are those of the server's host
+	if pane.Host != "" {
+		return "", fmt.Errorf("pane is on remote host '%s'", pane.Host)
+	}
+	return pane.CurrentPath, nil
+}
+
+// paneBaseFromQuery is paneBase for the query parameters of a request
+func (s *Server) paneBaseFromQuery(r *http.Request) (string, error) {
+	query := r.URL.Query()
+
+	var indexes [2]*int
+	for i, key := range []string{"window_index", "pane_index"} {
+		if value := query.Get(key); value != "" {
+			index, err := strconv.Atoi(value)
+			if err != nil {
+				return "", fmt.Errorf("invalid %s '%s'", key, value)
+			}
+			indexes[i] = &index
+		}
+	}
+	return s.paneBase(query.Get("session_name"), indexes[0], indexes[1])
+}
+
+// fileErrorCode maps file errors to error codes
+func fileErrorCode(err error) string {
+	switch {
+	case errors.Is(err, files.ErrNotFound):
+		return protocol.ErrorFileNotFound
+	case errors.Is(err, files.ErrOutsideRoots), errors.Is(err, fs.ErrPermission):
+		return protocol.ErrorAccessDenied
+	default:
+		return protocol.ErrorInvalidRequest
+	}
+}
+
+// fileHTTPStatus maps file errors to HTTP status codes
+func fileHTTPStatus(err error) int {
+	switch fileErrorCode(err) {
+	case protocol.ErrorFileNotFound:
+		return http.StatusNotFound
+	case protocol.ErrorAccessDenied:
+		return http.StatusForbidden
+	default:
+		if errors.Is(err, files.ErrTooLarge) {
+			return http.StatusRequestEntityTooLarge
+		}
+		if errors.Is(err, files.ErrExists) {
+			return http.StatusConflict
+		}
+		return http.StatusBadRequest
+	}
+}
@@ -0,0 +1,191 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/myan/handx-server/internal/history"
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// handleGetHistory handles the get_history message
+func (c *Client) handleGetHistory(msg *protocol.Message) {
+	if c.server.history == nil {
+		c.sendError(protocol.ErrorFeatureDisabled, "Command history is not enabled", msg.ID)
+		return
+	}
+
+	var payload protocol.GetHistoryPayload
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse get history payload", msg.ID)
+		return
+	}
+
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse get history payload", msg.ID)
+		return
+	}
+
+	response := protocol.HistoryResponse{
+		Entries: c.server.history.Get(payload.SessionName, payload.Limit),
+	}
+	c.sendMessage(protocol.TypeGetHistoryResponse, response)
+}
+
+// handleSearchHistory handles the search_history message
+func (c *Client) handleSearchHistory(msg *protocol.Message) {
+	if c.server.history == nil {
+		c.sendError(protocol.ErrorFeatureDisabled, "Command history is not enabled", msg.ID)
+		return
+	}
+
+	var payload protocol.SearchHistoryPayload
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse search history payload", msg.ID)
+		return
+	}
+
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse search history payload", msg.ID)
+		return
+	}
+
+	response := protocol.HistoryResponse{
+		Entries: c.server.history.Search(payload.Query, payload.SessionName, payload.Limit),
+	}
+	log.Printf("History search for %q returned %d entries", payload.Query, len(response.Entries))
+	c.sendMessage(protocol.TypeSearchHistoryResponse, response)
+}
+
+// handleSaveSnippet handles the save_snippet message
+func (c *Client) handleSaveSnippet(msg *protocol.Message) {
+	if c.server.snippets == nil {
+		c.sendError(protocol.ErrorFeatureDisabled, "Snippets are not enabled", msg.ID)
+		return
+	}
+
+	var payload protocol.SaveSnippetPayload
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse save snippet payload", msg.ID)
+		return
+	}
+
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse save snippet payload", msg.ID)
+		return
+	}
+
+	snippet, err := c.server.snippets.Save(payload)
+	if err != nil {
+		log.Printf("Failed to save snippet: %v", err)
+		c.sendError(protocol.ErrorInvalidRequest, fmt.Sprintf("Failed to save snippet: %v", err), msg.ID)
+		return
+	}
+
+	log.Printf("Snippet saved: %s (%s)", snippet.Name, snippet.ID)
+	c.sendMessage(protocol.TypeSaveSnippetResponse, protocol.SnippetResponse{
+		Success: true,
+		Snippet: snippet,
+	})
+}
+
+// handleListSnippets handles the list_snippets message
+func (c *Client) handleListSnippets(msg *protocol.Message) {
+	if c.server.snippets == nil {
+		c.sendError(protocol.ErrorFeatureDisabled, "Snippets are not enabled", msg.ID)
+		return
+	}
+
+	snippets := c.server.snippets.List()
+	log.Printf("Returning %d snippets", len(snippets))
+	c.sendMessage(protocol.TypeListSnippetsResponse, protocol.ListSnippetsResponse{Snippets: snippets})
+}
+
+// handleRunSnippet handles the run_snippet message
+func (c *Client) handleRunSnippet(msg *protocol.Message) {
+	if c.server.snippets == nil {
+		c.sendError(protocol.ErrorFeatureDisabled, "Snippets are not enabled", msg.ID)
+		return
+	}
+
+	var payload protocol.RunSnippetPayload
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse run snippet payload", msg.ID)
+		return
+	}
+
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse run snippet payload", msg.ID)
+		return
+	}
+
+	command, err := c.server.snippets.Render(payload.SnippetID, payload.Name, payload.Params)
+	if err != nil {
+		log.Printf("Failed to render snippet: %v", err)
+		code := protocol.ErrorInvalidRequest
+		if errors.Is(err, history.ErrSnippetNotFound) {
+			code = protocol.ErrorSnippetNotFound
+		}
+		c.sendError(code, fmt.Sprintf("Failed to run snippet: %v", err), msg.ID)
+		return
+	}
+
+	log.Printf("Run snippet: session=%s, command=%s", payload.SessionName, command)
+
+	if err := c.server.backend.ExecuteCommand(payload.SessionName, command, payload.WindowIndex); err != nil {
+		log.Printf("Failed to execute snippet: %v", err)
+		c.sendError(protocol.ErrorCommandFailed, fmt.Sprintf("Failed to execute command: %v", err), msg.ID)
+		return
+	}
+	if c.server.history != nil {
+		c.server.history.Record(payload.SessionName, command)
+	}
+
+	c.sendMessage(protocol.TypeRunSnippetResponse, protocol.RunSnippetResponse{
+		Success:     true,
+		SessionName: payload.SessionName,
+		Command:     command,
+	})
+}
+
+// handleDeleteSnippet handles the delete_snippet message
+func (c *Client) handleDeleteSnippet(msg *protocol.Message) {
+	if c.server.snippets == nil {
+		c.sendError(protocol.ErrorFeatureDisabled, "Snippets are not enabled", msg.ID)
+		return
+	}
+
+	var payload protocol.SnippetIDPayload
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse delete snippet payload", msg.ID)
+		return
+	}
+
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse delete snippet payload", msg.ID)
+		return
+	}
+
+	if err := c.server.snippets.Delete(payload.SnippetID); err != nil {
+		log.Printf("Failed to delete snippet: %v", err)
+		code := protocol.ErrorInternalError
+		if errors.Is(err, history.ErrSnippetNotFound) {
+			code = protocol.ErrorSnippetNotFound
+		}
+		c.sendError(code, fmt.Sprintf("Failed to delete snippet: %v", err), msg.ID)
+		return
+	}
+
+	log.Printf("Snippet deleted: %s", payload.SnippetID)
+	c.sendMessage(protocol.TypeDeleteSnippetResponse, protocol.DeleteSnippetResponse{
+		Success:   true,
+		SnippetID: payload.SnippetID,
+	})
+}
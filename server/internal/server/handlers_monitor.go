@@ -0,0 +1,83 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// handleSetPaneMonitor handles the set_pane_monitor message
+func (c *Client) handleSetPaneMonitor(msg *protocol.Message) {
+	if c.server.monitor == nil {
+		c.sendError(protocol.ErrorFeatureDisabled, "Pane monitoring is not enabled", msg.ID)
+		return
+	}
+
+	var payload protocol.SetPaneMonitorPayload
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse set pane monitor payload", msg.ID)
+		return
+	}
+
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse set pane monitor payload", msg.ID)
+		return
+	}
+
+	if (payload.ActivityAfter != nil && *payload.ActivityAfter < 0) || (payload.SilenceAfter != nil && *payload.SilenceAfter < 0) {
+		c.sendError(protocol.ErrorInvalidRequest, "Monitor durations must not be negative", msg.ID)
+		return
+	}
+
+	pane, windowIndex, err := c.server.backend.PaneInfo(payload.SessionName, payload.WindowIndex, payload.PaneIndex)
+	if err != nil {
+		log.Printf("Failed to find pane to monitor: %v", err)
+		c.sendError(protocol.ErrorPaneNotFound, err.Error(), msg.ID)
+		return
+	}
+
+	location := protocol.PaneLocation{
+		SessionName: payload.SessionName,
+		WindowIndex: windowIndex,
+		PaneIndex:   pane.Index,
+		PaneID:      pane.ID,
+	}
+
+	monitor := c.server.monitor.SetPane(location, payload.Bell, secondsPtr(payload.ActivityAfter), secondsPtr(payload.SilenceAfter), payload.Reset)
+
+	response := protocol.SetPaneMonitorResponse{
+		Success: true,
+		Monitor: monitor,
+	}
+
+	log.Printf("Pane monitor set for %s:%d.%d: bell=%v, activity=%ds, silence=%ds",
+		location.SessionName, location.WindowIndex, location.PaneIndex, monitor.Bell, monitor.ActivityAfter, monitor.SilenceAfter)
+	c.sendMessage(protocol.TypeSetPaneMonitorResponse, response)
+}
+
+// handleListPaneMonitors handles the list_pane_monitors message
+func (c *Client) handleListPaneMonitors(msg *protocol.Message) {
+	if c.server.monitor == nil {
+		c.sendError(protocol.ErrorFeatureDisabled, "Pane monitoring is not enabled", msg.ID)
+		return
+	}
+
+	response := protocol.ListPaneMonitorsResponse{
+		Defaults: c.server.monitor.Defaults(),
+		Monitors: c.server.monitor.List(),
+	}
+
+	c.sendMessage(protocol.TypeListPaneMonitorsResponse, response)
+}
+
+// secondsPtr converts an optional number of seconds to a duration
+func secondsPtr(seconds *int) *time.Duration {
+	if seconds == nil {
+		return nil
+	}
+	d := time.Duration(*seconds) * time.Second
+	return &d
+}
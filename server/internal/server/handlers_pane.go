@@ -0,0 +1,85 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// handlePaneInfo handles the pane_info message
+func (c *Client) handlePaneInfo(msg *protocol.Message) {
+	var payload protocol.PaneInfoPayload
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse pane info payload", msg.ID)
+		return
+	}
+
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse pane info payload", msg.ID)
+		return
+	}
+
+	log.Printf("Pane info: session=%s, window=%v, pane=%v", payload.SessionName, payload.WindowIndex, payload.PaneIndex)
+
+	pane, windowIndex, err := c.server.backend.PaneInfo(payload.SessionName, payload.WindowIndex, payload.PaneIndex)
+	if err != nil {
+		log.Printf("Failed to get pane info: %v", err)
+		c.sendError(protocol.ErrorPaneNotFound, err.Error(), msg.ID)
+		return
+	}
+
+	response := protocol.PaneInfoResponse{
+		SessionName: payload.SessionName,
+		WindowIndex: windowIndex,
+		Pane:        pane,
+	}
+
+	c.sendMessage(protocol.TypePaneInfoResponse, response)
+}
+
+// handleSendInput handles the send_input message
+func (c *Client) handleSendInput(msg *protocol.Message) {
+	var payload protocol.SendInputPayload
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse send input payload", msg.ID)
+		return
+	}
+
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse send input payload", msg.ID)
+		return
+	}
+
+	pane, windowIndex, err := c.server.backend.PaneInfo(payload.SessionName, payload.WindowIndex, payload.PaneIndex)
+	if err != nil {
+		c.sendError(protocol.ErrorPaneNotFound, err.Error(), msg.ID)
+		return
+	}
+
+	if err := c.server.backend.SendToPane(pane.ID, payload.Text, payload.Execute); err != nil {
+		log.Printf("Failed to send input: %v", err)
+		c.sendError(protocol.ErrorCommandFailed, fmt.Sprintf("Failed to send input: %v", err), msg.ID)
+		return
+	}
+	if payload.Execute && c.server.history != nil {
+		c.server.history.Record(payload.SessionName, payload.Text)
+	}
+
+	response := protocol.SendInputResponse{
+		Success: true,
+		PaneLocation: protocol.PaneLocation{
+			SessionName: payload.SessionName,
+			WindowIndex: windowIndex,
+			PaneIndex:   pane.Index,
+			PaneID:      pane.ID,
+		},
+		Bytes: len(payload.Text),
+	}
+
+	log.Printf("Sent %d bytes of input to %s:%d.%d", len(payload.Text), payload.SessionName, windowIndex, pane.Index)
+	c.sendMessage(protocol.TypeSendInputResponse, response)
+}
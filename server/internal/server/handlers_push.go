@@ -0,0 +1,89 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// handleGetPushConfig handles the get_push_config message
+func (c *Client) handleGetPushConfig(msg *protocol.Message) {
+	response := protocol.GetPushConfigResponse{
+		Enabled: c.server.webPush != nil,
+	}
+	if c.server.webPush != nil {
+		response.PublicKey = c.server.webPush.PublicKey()
+	}
+
+	c.sendMessage(protocol.TypeGetPushConfigResponse, response)
+}
+
+// handleSubscribePush handles the subscribe_push message
+func (c *Client) handleSubscribePush(msg *protocol.Message) {
+	if c.server.webPush == nil {
+		c.sendError(protocol.ErrorFeatureDisabled, "Web push is not enabled", msg.ID)
+		return
+	}
+
+	var payload protocol.PushSubscription
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse push subscription payload", msg.ID)
+		return
+	}
+
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse push subscription payload", msg.ID)
+		return
+	}
+
+	if err := c.server.webPush.Subscribe(payload); err != nil {
+		log.Printf("Failed to add push subscription: %v", err)
+		c.sendError(protocol.ErrorInvalidRequest, fmt.Sprintf("Failed to subscribe: %v", err), msg.ID)
+		return
+	}
+
+	response := protocol.SubscribePushResponse{
+		Success:  true,
+		Endpoint: payload.Endpoint,
+	}
+
+	log.Printf("Push subscription added for client %s", c.id)
+	c.sendMessage(protocol.TypeSubscribePushResponse, response)
+}
+
+// handleUnsubscribePush handles the unsubscribe_push message
+func (c *Client) handleUnsubscribePush(msg *protocol.Message) {
+	if c.server.webPush == nil {
+		c.sendError(protocol.ErrorFeatureDisabled, "Web push is not enabled", msg.ID)
+		return
+	}
+
+	var payload protocol.PushSubscription
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse push subscription payload", msg.ID)
+		return
+	}
+
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse push subscription payload", msg.ID)
+		return
+	}
+
+	if err := c.server.webPush.Unsubscribe(payload.Endpoint); err != nil {
+		log.Printf("Failed to remove push subscription: %v", err)
+		c.sendError(protocol.ErrorInvalidRequest, fmt.Sprintf("Failed to unsubscribe: %v", err), msg.ID)
+		return
+	}
+
+	response := protocol.SubscribePushResponse{
+		Success:  true,
+		Endpoint: payload.Endpoint,
+	}
+
+	log.Printf("Push subscription removed for client %s", c.id)
+	c.sendMessage(protocol.TypeUnsubscribePushResponse, response)
+}
@@ -0,0 +1,309 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/myan/handx-server/internal/recording"
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// handleStartRecording handles the start_recording message
+func (c *Client) handleStartRecording(msg *protocol.Message) {
+	if c.server.recorder == nil {
+		c.sendError(protocol.ErrorFeatureDisabled, "Recording is not enabled", msg.ID)
+		return
+	}
+	var payload protocol.StartRecordingPayload
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse start recording payload", msg.ID)
+		return
+	}
+
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse start recording payload", msg.ID)
+		return
+	}
+
+	pane, windowIndex, err := c.server.backend.PaneInfo(payload.SessionName, payload.WindowIndex, payload.PaneIndex)
+	if err != nil {
+		log.Printf("Failed to find pane to record: %v", err)
+		c.sendError(protocol.ErrorPaneNotFound, err.Error(), msg.ID)
+		return
+	}
+
+	location := protocol.PaneLocation{
+		SessionName: payload.SessionName,
+		WindowIndex: windowIndex,
+		PaneIndex:   pane.Index,
+		PaneID:      pane.ID,
+	}
+	rec, err := c.server.recorder.Start(location, payload.Title)
+	if err != nil {
+		log.Printf("Failed to start recording: %v", err)
+		code := protocol.ErrorTmuxError
+		if errors.Is(err, recording.ErrAlreadyRecording) {
+			code = protocol.ErrorInvalidRequest
+		}
+		c.sendError(code, fmt.Sprintf("Failed to start recording: %v", err), msg.ID)
+		return
+	}
+
+	response := protocol.RecordingResponse{
+		Success:   true,
+		Recording: rec,
+	}
+	c.sendMessage(protocol.TypeStartRecordingResponse, response)
+}
+
+// handleStopRecording handles the stop_recording message
+func (c *Client) handleStopRecording(msg *protocol.Message) {
+	if c.server.recorder == nil {
+		c.sendError(protocol.ErrorFeatureDisabled, "Recording is not enabled", msg.ID)
+		return
+	}
+
+	var payload protocol.StopRecordingPayload
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse stop recording payload", msg.ID)
+		return
+	}
+
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse stop recording payload", msg.ID)
+		return
+	}
+
+	var rec *protocol.Recording
+	if payload.RecordingID != "" {
+		rec, err = c.server.recorder.Stop(payload.RecordingID)
+	} else {
+		pane, _, paneErr := c.server.backend.PaneInfo(payload.SessionName, payload.WindowIndex, payload.PaneIndex)
+		if paneErr != nil {
+			c.sendError(protocol.ErrorPaneNotFound, paneErr.Error(), msg.ID)
+			return
+		}
+		rec, err = c.server.recorder.StopPane(pane.ID)
+	}
+	if err != nil {
+		log.Printf("Failed to stop recording: %v", err)
+		code := protocol.ErrorInvalidRequest
+		if errors.Is(err, recording.ErrNotFound) {
+			code = protocol.ErrorRecordingNotFound
+		}
+		c.sendError(code, fmt.Sprintf("Failed to stop recording: %v", err), msg.ID)
+		return
+	}
+
+	response := protocol.RecordingResponse{
+		Success:   true,
+		Recording: rec,
+	}
+	c.sendMessage(protocol.TypeStopRecordingResponse, response)
+}
+
+// handleListRecordings handles the list_recordings message
+func (c *Client) handleListRecordings(msg *protocol.Message) {
+	if c.server.recorder == nil {
+		c.sendError(protocol.ErrorFeatureDisabled, "Recording is not enabled", msg.ID)
+		return
+	}
+
+	recordings, err := c.server.recorder.List()
+	if err != nil {
+		log.Printf("Failed to list recordings: %v", err)
+		c.sendError(protocol.ErrorInternalError, fmt.Sprintf("Failed to list recordings: %v", err), msg.ID)
+		return
+	}
+
+	log.Printf("Returning %d recordings", len(recordings))
+	c.sendMessage(protocol.TypeListRecordingsResponse, protocol.ListRecordingsResponse{Recordings: recordings})
+}
+
+// handleDeleteRecording handles the delete_recording message
+func (c *Client) handleDeleteRecording(msg *protocol.Message) {
+	if c.server.recorder == nil {
+		c.sendError(protocol.ErrorFeatureDisabled, "Recording is not enabled", msg.ID)
+		return
+	}
+
+	var payload protocol.RecordingIDPayload
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse delete recording payload", msg.ID)
+		return
+	}
+
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse delete recording payload", msg.ID)
+		return
+	}
+
+	if err := c.server.recorder.Delete(payload.RecordingID); err != nil {
+		log.Printf("Failed to delete recording: %v", err)
+		code := protocol.ErrorInternalError
+		if errors.Is(err, recording.ErrNotFound) {
+			code = protocol.ErrorRecordingNotFound
+		}
+		c.sendError(code, fmt.Sprintf("Failed to delete recording: %v", err), msg.ID)
+		return
+	}
+
+	response := protocol.DeleteRecordingResponse{
+		Success:     true,
+		RecordingID: payload.RecordingID,
+	}
+	c.sendMessage(protocol.TypeDeleteRecordingResponse, response)
+}
+
+// handlePlayRecording handles the play_recording message
+// The recording is replayed in the background, replacing any playback the
+// client is already watching.
+func (c *Client) handlePlayRecording(msg *protocol.Message) {
+	if c.server.recorder == nil {
+		c.sendError(protocol.ErrorFeatureDisabled, "Recording is not enabled", msg.ID)
+		return
+	}
+
+	var payload protocol.PlayRecordingPayload
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse play recording payload", msg.ID)
+		return
+	}
+
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse play recording payload", msg.ID)
+		return
+	}
+
+	speed := payload.Speed
+	if speed <= 0 {
+		speed = 1
+	}
+
+	rec, err := c.server.recorder.Get(payload.RecordingID)
+	if err != nil {
+		c.sendError(protocol.ErrorRecordingNotFound, err.Error(), msg.ID)
+		return
+	}
+	_, events, err := c.server.recorder.Load(payload.RecordingID)
+	if err != nil {
+		log.Printf("Failed to load recording: %v", err)
+		c.sendError(protocol.ErrorInternalError, fmt.Sprintf("Failed to load recording: %v", err), msg.ID)
+		return
+	}
+
+	stop := make(chan struct{})
+	c.mu.Lock()
+	if !c.connected {
+		c.mu.Unlock()
+		return
+	}
+	if c.playback != nil {
+		close(c.playback)
+	}
+	c.playback = stop
+	c.mu.Unlock()
+
+	c.sendMessage(protocol.TypePlayRecordingResponse, protocol.PlayRecordingResponse{
+		Success:   true,
+		Recording: rec,
+		Speed:     speed,
+	})
+
+	log.Printf("Playing recording %s at %gx", rec.ID, speed)
+	go func() {
+		var sequence int64
+		completed := recording.Play(events, speed, payload.MaxIdle, stop, func(event recording.Event) {
+			sequence++
+			c.sendMessage(protocol.TypeTerminalOutput, protocol.TerminalOutputPayload{
+				SessionName: rec.SessionName,
+				Output:      event.Data,
+				Sequence:    sequence,
+				RecordingID: rec.ID,
+			})
+		})
+
+		c.mu.Lock()
+		if c.playback == stop {
+			c.playback = nil
+		}
+		c.mu.Unlock()
+
+		c.sendMessage(protocol.TypePlaybackEnded, protocol.PlaybackEndedPayload{
+			RecordingID: rec.ID,
+			Completed:   completed,
+		})
+	}()
+}
+
+// handleStopPlayback handles the stop_playback message
+func (c *Client) handleStopPlayback(msg *protocol.Message) {
+	c.mu.Lock()
+	stopped := c.playback != nil
+	if stopped {
+		close(c.playback)
+		c.playback = nil
+	}
+	c.mu.Unlock()
+
+	response := protocol.StopPlaybackResponse{
+		Success: true,
+		Stopped: stopped,
+	}
+	c.sendMessage(protocol.TypeStopPlaybackResponse, response)
+}
+
+// handleListRecordingsHTTP serves the recording list as JSON
+func (s *Server) handleListRecordingsHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeHTTP(w, r) {
+		return
+	}
+
+	recordings, err := s.recorder.List()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(protocol.ListRecordingsResponse{Recordings: recordings})
+}
+
+// handleDownloadRecording serves a recording's asciicast file
+func (s *Server) handleDownloadRecording(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeHTTP(w, r) {
+		return
+	}
+
+	id := r.PathValue("id")
+	path, err := s.recorder.CastPath(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		http.Error(w, "recording not found", http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-asciicast")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.cast"`, id))
+	http.ServeContent(w, r, id+".cast", info.ModTime(), file)
+}
@@ -0,0 +1,173 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// handleCreateSchedule handles the create_schedule message
+func (c *Client) handleCreateSchedule(msg *protocol.Message) {
+	if c.server.scheduler == nil {
+		c.sendError(protocol.ErrorFeatureDisabled, "Scheduler is not enabled", msg.ID)
+		return
+	}
+
+	var payload protocol.CreateSchedulePayload
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse create schedule payload", msg.ID)
+		return
+	}
+
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse create schedule payload", msg.ID)
+		return
+	}
+
+	log.Printf("Create schedule: session=%s, spec=%s, run_at=%d, command=%s", payload.SessionName, payload.Spec, payload.RunAt, payload.Command)
+
+	schedule, err := c.server.scheduler.Create(payload)
+	if err != nil {
+		log.Printf("Failed to create schedule: %v", err)
+		c.sendError(protocol.ErrorInvalidRequest, fmt.Sprintf("Failed to create schedule: %v", err), msg.ID)
+		return
+	}
+
+	response := protocol.ScheduleResponse{
+		Success:  true,
+		Schedule: schedule,
+	}
+
+	log.Printf("Schedule created: %s", schedule.ID)
+	c.sendMessage(protocol.TypeCreateScheduleResponse, response)
+}
+
+// handleListSchedules handles the list_schedules message
+func (c *Client) handleListSchedules(msg *protocol.Message) {
+	if c.server.scheduler == nil {
+		c.sendError(protocol.ErrorFeatureDisabled, "Scheduler is not enabled", msg.ID)
+		return
+	}
+
+	schedules := c.server.scheduler.List()
+
+	response := protocol.ListSchedulesResponse{
+		Schedules: schedules,
+	}
+
+	log.Printf("Returning %d schedules", len(schedules))
+	c.sendMessage(protocol.TypeListSchedulesResponse, response)
+}
+
+// handlePauseSchedule handles the pause_schedule and resume_schedule messages
+func (c *Client) handlePauseSchedule(msg *protocol.Message, paused bool) {
+	if c.server.scheduler == nil {
+		c.sendError(protocol.ErrorFeatureDisabled, "Scheduler is not enabled", msg.ID)
+		return
+	}
+
+	var payload protocol.ScheduleIDPayload
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse schedule payload", msg.ID)
+		return
+	}
+
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse schedule payload", msg.ID)
+		return
+	}
+
+	log.Printf("Set schedule paused: id=%s, paused=%t", payload.ScheduleID, paused)
+
+	schedule, err := c.server.scheduler.SetPaused(payload.ScheduleID, paused)
+	if err != nil {
+		log.Printf("Failed to update schedule: %v", err)
+		c.sendError(protocol.ErrorScheduleNotFound, fmt.Sprintf("Failed to update schedule: %v", err), msg.ID)
+		return
+	}
+
+	response := protocol.ScheduleResponse{
+		Success:  true,
+		Schedule: schedule,
+	}
+
+	responseType := protocol.TypeResumeScheduleResponse
+	if paused {
+		responseType = protocol.TypePauseScheduleResponse
+	}
+	c.sendMessage(responseType, response)
+}
+
+// handleDeleteSchedule handles the delete_schedule message
+func (c *Client) handleDeleteSchedule(msg *protocol.Message) {
+	if c.server.scheduler == nil {
+		c.sendError(protocol.ErrorFeatureDisabled, "Scheduler is not enabled", msg.ID)
+		return
+	}
+
+	var payload protocol.ScheduleIDPayload
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse delete schedule payload", msg.ID)
+		return
+	}
+
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse delete schedule payload", msg.ID)
+		return
+	}
+
+	log.Printf("Delete schedule: id=%s", payload.ScheduleID)
+
+	if err := c.server.scheduler.Delete(payload.ScheduleID); err != nil {
+		log.Printf("Failed to delete schedule: %v", err)
+		c.sendError(protocol.ErrorScheduleNotFound, fmt.Sprintf("Failed to delete schedule: %v", err), msg.ID)
+		return
+	}
+
+	response := protocol.DeleteScheduleResponse{
+		Success:    true,
+		ScheduleID: payload.ScheduleID,
+	}
+
+	log.Printf("Schedule deleted: %s", payload.ScheduleID)
+	c.sendMessage(protocol.TypeDeleteScheduleResponse, response)
+}
+
+// handleGetScheduleHistory handles the get_schedule_history message
+func (c *Client) handleGetScheduleHistory(msg *protocol.Message) {
+	if c.server.scheduler == nil {
+		c.sendError(protocol.ErrorFeatureDisabled, "Scheduler is not enabled", msg.ID)
+		return
+	}
+
+	var payload protocol.ScheduleIDPayload
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse schedule history payload", msg.ID)
+		return
+	}
+
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse schedule history payload", msg.ID)
+		return
+	}
+
+	runs, err := c.server.scheduler.History(payload.ScheduleID)
+	if err != nil {
+		log.Printf("Failed to get schedule history: %v", err)
+		c.sendError(protocol.ErrorScheduleNotFound, fmt.Sprintf("Failed to get schedule history: %v", err), msg.ID)
+		return
+	}
+
+	response := protocol.ScheduleHistoryResponse{
+		ScheduleID: payload.ScheduleID,
+		Runs:       runs,
+	}
+
+	c.sendMessage(protocol.TypeGetScheduleHistoryResponse, response)
+}
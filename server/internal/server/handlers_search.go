@@ -0,0 +1,38 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// handleSearchOutput handles the search_output message
+func (c *Client) handleSearchOutput(msg *protocol.Message) {
+	if c.server.searcher == nil {
+		c.sendError(protocol.ErrorFeatureDisabled, "Output search is not enabled", msg.ID)
+		return
+	}
+
+	var payload protocol.SearchOutputPayload
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse search output payload", msg.ID)
+		return
+	}
+
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse search output payload", msg.ID)
+		return
+	}
+
+	response, err := c.server.searcher.Search(payload)
+	if err != nil {
+		log.Printf("Failed to search output: %v", err)
+		c.sendError(protocol.ErrorInvalidRequest, err.Error(), msg.ID)
+		return
+	}
+
+	log.Printf("Output search for %q matched %d lines in %d panes", payload.Query, len(response.Matches), response.PanesSearched)
+	c.sendMessage(protocol.TypeSearchOutputResponse, response)
+}
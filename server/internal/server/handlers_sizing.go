@@ -0,0 +1,41 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// handleResize handles the resize message
+func (c *Client) handleResize(msg *protocol.Message) {
+	if c.server.sizer == nil {
+		c.sendError(protocol.ErrorFeatureDisabled, "Sizing is not enabled", msg.ID)
+		return
+	}
+
+	var payload protocol.ResizePayload
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse resize payload", msg.ID)
+		return
+	}
+
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse resize payload", msg.ID)
+		return
+	}
+
+	if payload.SessionName == "" {
+		c.sendError(protocol.ErrorInvalidRequest, "session_name is required", msg.ID)
+		return
+	}
+
+	response, err := c.server.sizer.Resize(c.id, payload.SessionName, payload.Cols, payload.Rows)
+	if err != nil {
+		log.Printf("Failed to resize session: %v", err)
+		c.sendError(protocol.ErrorInvalidRequest, err.Error(), msg.ID)
+		return
+	}
+	c.sendMessage(protocol.TypeResizeResponse, response)
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// handleSaveSnapshot handles the save_snapshot message
+func (c *Client) handleSaveSnapshot(msg *protocol.Message) {
+	if c.server.snapshotter == nil {
+		c.sendError(protocol.ErrorFeatureDisabled, "Snapshots are not enabled", msg.ID)
+		return
+	}
+
+	log.Printf("Save snapshot requested")
+
+	snap, err := c.server.snapshotter.Save()
+	if err != nil {
+		log.Printf("Failed to save snapshot: %v", err)
+		c.sendError(protocol.ErrorSnapshotFailed, fmt.Sprintf("Failed to save snapshot: %v", err), msg.ID)
+		return
+	}
+
+	response := protocol.SaveSnapshotResponse{
+		Success:   true,
+		CreatedAt: snap.CreatedAt,
+		Sessions:  len(snap.Sessions),
+	}
+
+	log.Printf("Snapshot saved with %d sessions", len(snap.Sessions))
+	c.sendMessage(protocol.TypeSaveSnapshotResponse, response)
+}
+
+// handleRestoreSessions handles the restore_sessions message
+func (c *Client) handleRestoreSessions(msg *protocol.Message) {
+	if c.server.snapshotter == nil {
+		c.sendError(protocol.ErrorFeatureDisabled, "Snapshots are not enabled", msg.ID)
+		return
+	}
+
+	var payload protocol.RestoreSessionsPayload
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse restore sessions payload", msg.ID)
+		return
+	}
+
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse restore sessions payload", msg.ID)
+		return
+	}
+
+	if payload.Dismiss {
+		dismissed, err := c.server.snapshotter.Dismiss(payload.Sessions)
+		if err != nil {
+			log.Printf("Failed to dismiss sessions: %v", err)
+			c.sendError(protocol.ErrorSnapshotFailed, fmt.Sprintf("Failed to dismiss sessions: %v", err), msg.ID)
+			return
+		}
+
+		log.Printf("Dismissed sessions from snapshot: %v", dismissed)
+		c.sendMessage(protocol.TypeRestoreSessionsResponse, protocol.RestoreSessionsResponse{
+			Success:   true,
+			Restored:  []string{},
+			Skipped:   []string{},
+			Failed:    []protocol.RestoreFailure{},
+			Dismissed: dismissed,
+		})
+		return
+	}
+
+	log.Printf("Restore sessions: %v", payload.Sessions)
+
+	result, err := c.server.snapshotter.Restore(payload.Sessions)
+	if err != nil {
+		log.Printf("Failed to restore sessions: %v", err)
+		c.sendError(protocol.ErrorSnapshotFailed, fmt.Sprintf("Failed to restore sessions: %v", err), msg.ID)
+		return
+	}
+
+	log.Printf("Restored %d sessions, skipped %d, failed %d", len(result.Restored), len(result.Skipped), len(result.Failed))
+	c.sendMessage(protocol.TypeRestoreSessionsResponse, result)
+}
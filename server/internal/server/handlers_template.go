@@ -0,0 +1,100 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/myan/handx-server/internal/workspace"
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// handleListTemplates handles the list_templates message
+func (c *Client) handleListTemplates(msg *protocol.Message) {
+	templates, err := workspace.LoadTemplates(c.server.templatesDir)
+	if err != nil {
+		log.Printf("Failed to load templates: %v", err)
+		c.sendError(protocol.ErrorInternalError, fmt.Sprintf("Failed to load templates: %v", err), msg.ID)
+		return
+	}
+
+	infos := make([]protocol.TemplateInfo, 0, len(templates))
+	for _, t := range templates {
+		windows := make([]string, 0, len(t.Windows))
+		for _, w := range t.Windows {
+			windows = append(windows, w.Name)
+		}
+
+		infos = append(infos, protocol.TemplateInfo{
+			Name:        t.Name,
+			Description: t.Description,
+			Session:     t.Session,
+			Variables:   t.Variables,
+			Windows:     windows,
+		})
+	}
+
+	response := protocol.ListTemplatesResponse{
+		Templates: infos,
+	}
+
+	log.Printf("Returning %d templates", len(infos))
+	c.sendMessage(protocol.TypeListTemplatesResponse, response)
+}
+
+// handleCreateSessionFromTemplate handles the create_session_from_template message
+func (c *Client) handleCreateSessionFromTemplate(msg *protocol.Message) {
+	backend, ok := c.server.backend.(workspace.Backend)
+	if !ok {
+		c.sendError(protocol.ErrorFeatureDisabled, "Workspace templates are not supported by this backend", msg.ID)
+		return
+	}
+
+	var payload protocol.CreateSessionFromTemplatePayload
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse create session from template payload", msg.ID)
+		return
+	}
+
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse create session from template payload", msg.ID)
+		return
+	}
+
+	log.Printf("Create session from template: template=%s, name=%s", payload.Template, payload.SessionName)
+
+	tmpl, err := workspace.FindTemplate(c.server.templatesDir, payload.Template)
+	if err != nil {
+		log.Printf("Failed to load template: %v", err)
+		c.sendError(protocol.ErrorTemplateNotFound, err.Error(), msg.ID)
+		return
+	}
+
+	rendered, err := tmpl.Render(payload.Variables)
+	if err != nil {
+		log.Printf("Failed to render template: %v", err)
+		c.sendError(protocol.ErrorInvalidRequest, fmt.Sprintf("Failed to render template: %v", err), msg.ID)
+		return
+	}
+
+	session, err := workspace.Build(backend, rendered, payload.SessionName)
+	if err != nil {
+		log.Printf("Failed to create session from template: %v", err)
+		if strings.HasSuffix(err.Error(), "already exists") {
+			c.sendError(protocol.ErrorSessionAlreadyExists, err.Error(), msg.ID)
+		} else {
+			c.sendError(protocol.ErrorTmuxError, fmt.Sprintf("Failed to create session from template: %v", err), msg.ID)
+		}
+		return
+	}
+
+	response := protocol.CreateSessionResponse{
+		Success: true,
+		Session: session,
+	}
+
+	log.Printf("Session created from template %s: %s", payload.Template, session.Name)
+	c.sendMessage(protocol.TypeCreateSessionFromTemplateResponse, response)
+}
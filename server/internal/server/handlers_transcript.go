@@ -0,0 +1,65 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// handleExportTranscript handles the export_transcript message
+func (c *Client) handleExportTranscript(msg *protocol.Message) {
+	if c.server.exporter == nil {
+		c.sendError(protocol.ErrorFeatureDisabled, "Transcript export is not enabled", msg.ID)
+		return
+	}
+	var payload protocol.ExportTranscriptPayload
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse export transcript payload", msg.ID)
+		return
+	}
+
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse export transcript payload", msg.ID)
+		return
+	}
+
+	pane, windowIndex, err := c.server.backend.PaneInfo(payload.SessionName, payload.WindowIndex, payload.PaneIndex)
+	if err != nil {
+		log.Printf("Failed to find pane to export: %v", err)
+		c.sendError(protocol.ErrorPaneNotFound, err.Error(), msg.ID)
+		return
+	}
+
+	target := protocol.PaneLocation{
+		SessionName: payload.SessionName,
+		WindowIndex: windowIndex,
+		PaneIndex:   pane.Index,
+		PaneID:      pane.ID,
+	}
+	response, err := c.server.exporter.Export(target, payload.Scope, payload.Format, payload.Lines)
+	if err != nil {
+		log.Printf("Failed to export transcript: %v", err)
+		c.sendError(protocol.ErrorInvalidRequest, fmt.Sprintf("Failed to export transcript: %v", err), msg.ID)
+		return
+	}
+
+	c.sendMessage(protocol.TypeExportTranscriptResponse, response)
+}
+
+// handleDownloadTranscript serves an exported transcript until its link expires
+func (s *Server) handleDownloadTranscript(w http.ResponseWriter, r *http.Request) {
+	export, ok := s.exporter.Get(r.PathValue("token"))
+	if !ok {
+		http.Error(w, "transcript not found or link expired", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", export.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, export.FileName))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(export.Data)
+}
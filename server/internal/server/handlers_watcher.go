@@ -0,0 +1,139 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// handleCreateWatcher handles the create_watcher message
+func (c *Client) handleCreateWatcher(msg *protocol.Message) {
+	if c.server.watchers == nil {
+		c.sendError(protocol.ErrorFeatureDisabled, "Output watchers are not enabled", msg.ID)
+		return
+	}
+
+	var payload protocol.CreateWatcherPayload
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse create watcher payload", msg.ID)
+		return
+	}
+
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse create watcher payload", msg.ID)
+		return
+	}
+
+	log.Printf("Create watcher: session=%s, patterns=%v", payload.SessionName, payload.Patterns)
+
+	watcher, err := c.server.watchers.Create(payload)
+	if err != nil {
+		log.Printf("Failed to create watcher: %v", err)
+		c.sendError(protocol.ErrorInvalidRequest, fmt.Sprintf("Failed to create watcher: %v", err), msg.ID)
+		return
+	}
+
+	response := protocol.WatcherResponse{
+		Success: true,
+		Watcher: watcher,
+	}
+
+	log.Printf("Watcher created: %s", watcher.ID)
+	c.sendMessage(protocol.TypeCreateWatcherResponse, response)
+}
+
+// handleListWatchers handles the list_watchers message
+func (c *Client) handleListWatchers(msg *protocol.Message) {
+	if c.server.watchers == nil {
+		c.sendError(protocol.ErrorFeatureDisabled, "Output watchers are not enabled", msg.ID)
+		return
+	}
+
+	watchers := c.server.watchers.List()
+
+	response := protocol.ListWatchersResponse{
+		Watchers: watchers,
+	}
+
+	log.Printf("Returning %d watchers", len(watchers))
+	c.sendMessage(protocol.TypeListWatchersResponse, response)
+}
+
+// handlePauseWatcher handles the pause_watcher and resume_watcher messages
+func (c *Client) handlePauseWatcher(msg *protocol.Message, paused bool) {
+	if c.server.watchers == nil {
+		c.sendError(protocol.ErrorFeatureDisabled, "Output watchers are not enabled", msg.ID)
+		return
+	}
+
+	var payload protocol.WatcherIDPayload
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse watcher payload", msg.ID)
+		return
+	}
+
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse watcher payload", msg.ID)
+		return
+	}
+
+	log.Printf("Set watcher paused: id=%s, paused=%t", payload.WatcherID, paused)
+
+	watcher, err := c.server.watchers.SetPaused(payload.WatcherID, paused)
+	if err != nil {
+		log.Printf("Failed to update watcher: %v", err)
+		c.sendError(protocol.ErrorWatcherNotFound, fmt.Sprintf("Failed to update watcher: %v", err), msg.ID)
+		return
+	}
+
+	response := protocol.WatcherResponse{
+		Success: true,
+		Watcher: watcher,
+	}
+
+	responseType := protocol.TypeResumeWatcherResponse
+	if paused {
+		responseType = protocol.TypePauseWatcherResponse
+	}
+	c.sendMessage(responseType, response)
+}
+
+// handleDeleteWatcher handles the delete_watcher message
+func (c *Client) handleDeleteWatcher(msg *protocol.Message) {
+	if c.server.watchers == nil {
+		c.sendError(protocol.ErrorFeatureDisabled, "Output watchers are not enabled", msg.ID)
+		return
+	}
+
+	var payload protocol.WatcherIDPayload
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse delete watcher payload", msg.ID)
+		return
+	}
+
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		c.sendError(protocol.ErrorInternalError, "Failed to parse delete watcher payload", msg.ID)
+		return
+	}
+
+	log.Printf("Delete watcher: id=%s", payload.WatcherID)
+
+	if err := c.server.watchers.Delete(payload.WatcherID); err != nil {
+		log.Printf("Failed to delete watcher: %v", err)
+		c.sendError(protocol.ErrorWatcherNotFound, fmt.Sprintf("Failed to delete watcher: %v", err), msg.ID)
+		return
+	}
+
+	response := protocol.DeleteWatcherResponse{
+		Success:   true,
+		WatcherID: payload.WatcherID,
+	}
+
+	log.Printf("Watcher deleted: %s", payload.WatcherID)
+	c.sendMessage(protocol.TypeDeleteWatcherResponse, response)
+}
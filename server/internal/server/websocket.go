@@ -8,6 +8,20 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/myan/handx-server/internal/agent"
+	"github.com/myan/handx-server/internal/attach"
+	"github.com/myan/handx-server/internal/complete"
+	"github.com/myan/handx-server/internal/files"
+	"github.com/myan/handx-server/internal/history"
+	"github.com/myan/handx-server/internal/monitor"
+	"github.com/myan/handx-server/internal/notify"
+	"github.com/myan/handx-server/internal/recording"
+	"github.com/myan/handx-server/internal/scheduler"
+	"github.com/myan/handx-server/internal/search"
+	"github.com/myan/handx-server/internal/sizing"
+	"github.com/myan/handx-server/internal/snapshot"
+	"github.com/myan/handx-server/internal/transcript"
+	"github.com/myan/handx-server/internal/watcher"
 	"github.com/myan/handx-server/pkg/protocol"
 	"github.com/rs/cors"
 )
@@ -23,12 +37,16 @@ var upgrader = websocket.Upgrader{
 
 // Client represents a connected WebSocket client
 type Client struct {
-	conn      *websocket.Conn
-	send      chan []byte
-	server    *Server
-	id        string
-	connected bool
-	mu        sync.Mutex
+	conn       *websocket.Conn
+	send       chan []byte
+	binary     chan []byte // Terminal output of the attachment
+	server     *Server
+	id         string
+	connected  bool
+	token      string          // Connection token from the URL or connect
+	playback   chan struct{}   // Closed to stop the recording being replayed
+	attachment *attach.Session // tmux client relayed over binary frames
+	mu         sync.Mutex
 }
 
 // Server handles WebSocket connections
@@ -38,33 +56,199 @@ type Server struct {
 	register     chan *Client
 	unregister   chan *Client
 	mu           sync.Mutex
-	tmuxManager  TmuxManager
+	backend      Backend
+	scheduler    *scheduler.Scheduler
+	templatesDir string
+	snapshotter  *snapshot.Snapshotter
+	monitor      *monitor.Monitor
+	watchers     *watcher.Registry
+	webPush      *notify.WebPush
+	agents       *agent.Detector
+	searcher     *search.Searcher
+	recorder     *recording.Recorder
+	exporter     *transcript.Exporter
+	history      *history.Store
+	snippets     *history.Snippets
+	completer    *complete.Completer
+	files        *files.Browser
+	tokens       *TokenManager // Authenticates file access
+	sizer        *sizing.Sizer
+	attach       bool // Whether clients may attach through a pseudo terminal
 }
 
-// TmuxManager interface for tmux operations
-type TmuxManager interface {
+// Backend manages the sessions clients work with, e.g. through tmux
+// Every session has windows and every window at least one pane; panes are
+// addressed by an ID unique within the backend.
+type Backend interface {
+	// Sessions
 	ListSessions() ([]protocol.Session, error)
-	CreateSession(name string) (*protocol.Session, error)
+	CreateSession(name string, opts *protocol.SessionOptions) (*protocol.Session, error)
 	KillSession(name string) error
 	RenameSession(oldName, newName string) error
-	ExecuteCommand(sessionName, command string, windowIndex *int) error
-	SendText(sessionName, text string) error
-	CaptureOutput(sessionName string, windowIndex *int) (string, error)
+
+	// Windows
 	ListWindows(sessionName string) ([]protocol.Window, error)
-	CreateWindow(sessionName, windowName string) (*protocol.Window, error)
+	CreateWindow(sessionName, windowName string, opts *protocol.WindowOptions) (*protocol.Window, error)
 	CloseWindow(sessionName string, windowIndex int) error
 	SwitchWindow(sessionName string, windowIndex int) (string, error)
+
+	// Panes
+	ListAllPanes() ([]protocol.PaneLocation, error)
+	// PaneInfo describes a pane, the active one of the active window for nil
+	// indexes, and returns the index of its window
+	PaneInfo(sessionName string, windowIndex, paneIndex *int) (*protocol.Pane, int, error)
+
+	// Capture
+	CaptureOutput(sessionName string, windowIndex *int) (string, error)
+	CapturePane(paneID string, lines int) (string, error)
+
+	// Input
+	ExecuteCommand(sessionName, command string, windowIndex *int) error
+	SendText(sessionName, text string) error
+	SendToPane(paneID, text string, enter bool) error
+}
+
+// PaneFinder is implemented by backends that can describe a pane without
+// collecting its processes, which is cheap enough to do on every keystroke
+type PaneFinder interface {
+	// FindPane describes a pane like PaneInfo, without its process tree
+	FindPane(sessionName string, windowIndex, paneIndex *int) (*protocol.Pane, int, error)
+}
+
+// BufferManager is implemented by backends with paste buffers
+type BufferManager interface {
+	ListBuffers() ([]protocol.Buffer, error)
+	ShowBuffer(name string) (string, error)
+	SetBuffer(name, content string) (string, error)
+	PasteBuffer(name, paneID string, bracketed bool) error
+}
+
+// SocketManager is implemented by backends running sessions on several
+// tmux servers
+type SocketManager interface {
+	ListSockets() ([]protocol.TmuxSocket, error)
+	// SessionSocket returns the socket path of the server running a
+	// session, "" for the default server
+	SessionSocket(sessionName string) (string, error)
+}
+
+// HostManager is implemented by backends running sessions on remote hosts
+type HostManager interface {
+	ListHosts() ([]protocol.RemoteHost, error)
 }
 
 // NewServer creates a new WebSocket server
-func NewServer(tmuxManager TmuxManager) *Server {
+func NewServer(backend Backend) *Server {
 	return &Server{
-		clients:     make(map[*Client]bool),
-		broadcast:   make(chan []byte),
-		register:    make(chan *Client),
-		unregister:  make(chan *Client),
-		tmuxManager: tmuxManager,
+		clients:    make(map[*Client]bool),
+		broadcast:  make(chan []byte),
+		register:   make(chan *Client),
+		unregister: make(chan *Client),
+		backend:    backend,
+	}
+}
+
+// SetScheduler enables the schedule management messages
+func (s *Server) SetScheduler(sched *scheduler.Scheduler) {
+	s.scheduler = sched
+}
+
+// SetTemplatesDir sets the directory workspace templates are loaded from
+func (s *Server) SetTemplatesDir(dir string) {
+	s.templatesDir = dir
+}
+
+// SetSnapshotter enables the snapshot and restore messages
+func (s *Server) SetSnapshotter(snapshotter *snapshot.Snapshotter) {
+	s.snapshotter = snapshotter
+}
+
+// SetMonitor enables pane monitoring; its alerts are pushed to all clients
+func (s *Server) SetMonitor(m *monitor.Monitor) {
+	s.monitor = m
+	m.Subscribe(func(alert protocol.PaneAlertPayload) {
+		s.Broadcast(protocol.TypePaneAlert, alert)
+	})
+}
+
+// SetWatchers enables the output watcher messages; matches are pushed to all clients
+func (s *Server) SetWatchers(registry *watcher.Registry) {
+	s.watchers = registry
+	registry.Subscribe(func(match protocol.WatcherMatchPayload) {
+		s.Broadcast(protocol.TypeWatcherMatch, match)
+	})
+}
+
+// SetWebPush enables the web push subscription messages
+func (s *Server) SetWebPush(webPush *notify.WebPush) {
+	s.webPush = webPush
+}
+
+// SetAgentDetector enables the agent prompt messages; state changes are pushed to all clients
+func (s *Server) SetAgentDetector(detector *agent.Detector) {
+	s.agents = detector
+	detector.Subscribe(func(state protocol.AgentStatePayload) {
+		s.Broadcast(protocol.TypeAgentState, state)
+	})
+}
+
+// SetSearcher enables the output search message
+func (s *Server) SetSearcher(searcher *search.Searcher) {
+	s.searcher = searcher
+}
+
+// SetRecorder enables the recording messages and HTTP endpoints
+func (s *Server) SetRecorder(recorder *recording.Recorder) {
+	s.recorder = recorder
+}
+
+// SetExporter enables the transcript export message and download endpoint
+func (s *Server) SetExporter(exporter *transcript.Exporter) {
+	s.exporter = exporter
+}
+
+// SetHistory enables the command history and snippet messages; executed
+// commands are recorded in store
+func (s *Server) SetHistory(store *history.Store, snippets *history.Snippets) {
+	s.history = store
+	s.snippets = snippets
+}
+
+// SetCompleter enables the complete message
+func (s *Server) SetCompleter(completer *complete.Completer) {
+	s.completer = completer
+}
+
+// SetFileBrowser enables file access within the browser's roots
+func (s *Server) SetFileBrowser(browser *files.Browser) {
+	s.files = browser
+}
+
+// SetTokenManager sets the tokens file access is authenticated with
+func (s *Server) SetTokenManager(tokens *TokenManager) {
+	s.tokens = tokens
+}
+
+// SetSizer enables sizing sessions to the viewports of clients
+func (s *Server) SetSizer(sizer *sizing.Sizer) {
+	s.sizer = sizer
+}
+
+// SetAttachEnabled allows clients to attach to sessions as tmux clients
+func (s *Server) SetAttachEnabled(enabled bool) {
+	s.attach = enabled
+}
+
+// Broadcast sends an event message to all connected clients
+func (s *Server) Broadcast(msgType protocol.MessageType, payload interface{}) {
+	msg := protocol.NewMessage(generateMessageID(), msgType, payload)
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("Failed to encode %s broadcast: %v", msgType, err)
+		return
 	}
+
+	s.broadcast <- data
 }
 
 // Run starts the WebSocket server hub
@@ -81,10 +265,13 @@ func (s *Server) Run() {
 			s.mu.Lock()
 			if _, ok := s.clients[client]; ok {
 				delete(s.clients, client)
-				close(client.send)
+				client.disconnect()
 				log.Printf("Client unregistered: %s", client.id)
 			}
 			s.mu.Unlock()
+			if s.sizer != nil {
+				go s.sizer.RemoveClient(client.id)
+			}
 
 		case message := <-s.broadcast:
 			s.mu.Lock()
@@ -92,7 +279,7 @@ func (s *Server) Run() {
 				select {
 				case client.send <- message:
 				default:
-					close(client.send)
+					client.disconnect()
 					delete(s.clients, client)
 				}
 			}
@@ -112,9 +299,11 @@ func (s *Server) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 	client := &Client{
 		conn:      conn,
 		send:      make(chan []byte, 256),
+		binary:    make(chan []byte, 64),
 		server:    s,
 		id:        generateClientID(),
 		connected: true,
+		token:     r.URL.Query().Get("token"), // From the printed connection URL
 	}
 
 	s.register <- client
@@ -128,6 +317,17 @@ func (s *Server) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 func (s *Server) SetupRoutes(port string, allowedOrigins []string) *http.Server {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/ws", s.HandleWebSocket)
+	if s.recorder != nil {
+		mux.HandleFunc("GET /recordings", s.handleListRecordingsHTTP)
+		mux.HandleFunc("GET "+recording.DownloadPath+"{id}", s.handleDownloadRecording)
+	}
+	if s.exporter != nil {
+		mux.HandleFunc("GET "+transcript.DownloadPath+"{token}", s.handleDownloadTranscript)
+	}
+	if s.files != nil {
+		mux.HandleFunc("GET "+files.DownloadPath, s.handleDownloadFile)
+		mux.HandleFunc("POST "+files.UploadPath, s.handleUploadFiles)
+	}
 
 	// Setup CORS
 	c := cors.New(cors.Options{
@@ -158,7 +358,7 @@ func (c *Client) readPump() {
 	})
 
 	for {
-		_, message, err := c.conn.ReadMessage()
+		messageType, message, err := c.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("error: %v", err)
@@ -166,6 +366,12 @@ func (c *Client) readPump() {
 			break
 		}
 
+		// Binary frames are keyboard input for the attachment
+		if messageType == websocket.BinaryMessage {
+			c.handleTerminalInput(message)
+			continue
+		}
+
 		// Handle the message
 		c.handleMessage(message)
 	}
@@ -206,6 +412,12 @@ func (c *Client) writePump() {
 				return
 			}
 
+		case data := <-c.binary:
+			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if err := c.conn.WriteMessage(websocket.BinaryMessage, data); err != nil {
+				return
+			}
+
 		case <-ticker.C:
 			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
@@ -250,6 +462,114 @@ func (c *Client) handleMessage(data []byte) {
 		c.handleExecuteCommand(&msg)
 	case protocol.TypeCaptureOutput:
 		c.handleCaptureOutput(&msg)
+	case protocol.TypeCreateSchedule:
+		c.handleCreateSchedule(&msg)
+	case protocol.TypeListSchedules:
+		c.handleListSchedules(&msg)
+	case protocol.TypePauseSchedule:
+		c.handlePauseSchedule(&msg, true)
+	case protocol.TypeResumeSchedule:
+		c.handlePauseSchedule(&msg, false)
+	case protocol.TypeDeleteSchedule:
+		c.handleDeleteSchedule(&msg)
+	case protocol.TypeGetScheduleHistory:
+		c.handleGetScheduleHistory(&msg)
+	case protocol.TypeListTemplates:
+		c.handleListTemplates(&msg)
+	case protocol.TypeCreateSessionFromTemplate:
+		c.handleCreateSessionFromTemplate(&msg)
+	case protocol.TypeSaveSnapshot:
+		c.handleSaveSnapshot(&msg)
+	case protocol.TypeRestoreSessions:
+		c.handleRestoreSessions(&msg)
+	case protocol.TypePaneInfo:
+		c.handlePaneInfo(&msg)
+	case protocol.TypeSetPaneMonitor:
+		c.handleSetPaneMonitor(&msg)
+	case protocol.TypeListPaneMonitors:
+		c.handleListPaneMonitors(&msg)
+	case protocol.TypeCreateWatcher:
+		c.handleCreateWatcher(&msg)
+	case protocol.TypeListWatchers:
+		c.handleListWatchers(&msg)
+	case protocol.TypePauseWatcher:
+		c.handlePauseWatcher(&msg, true)
+	case protocol.TypeResumeWatcher:
+		c.handlePauseWatcher(&msg, false)
+	case protocol.TypeDeleteWatcher:
+		c.handleDeleteWatcher(&msg)
+	case protocol.TypeGetPushConfig:
+		c.handleGetPushConfig(&msg)
+	case protocol.TypeSubscribePush:
+		c.handleSubscribePush(&msg)
+	case protocol.TypeUnsubscribePush:
+		c.handleUnsubscribePush(&msg)
+	case protocol.TypeListAgentStates:
+		c.handleListAgentStates(&msg)
+	case protocol.TypeRespondPrompt:
+		c.handleRespondPrompt(&msg)
+	case protocol.TypeExtractArtifacts:
+		c.handleExtractArtifacts(&msg)
+	case protocol.TypeSearchOutput:
+		c.handleSearchOutput(&msg)
+	case protocol.TypeStartRecording:
+		c.handleStartRecording(&msg)
+	case protocol.TypeStopRecording:
+		c.handleStopRecording(&msg)
+	case protocol.TypeListRecordings:
+		c.handleListRecordings(&msg)
+	case protocol.TypeDeleteRecording:
+		c.handleDeleteRecording(&msg)
+	case protocol.TypePlayRecording:
+		c.handlePlayRecording(&msg)
+	case protocol.TypeStopPlayback:
+		c.handleStopPlayback(&msg)
+	case protocol.TypeExportTranscript:
+		c.handleExportTranscript(&msg)
+	case protocol.TypeGetHistory:
+		c.handleGetHistory(&msg)
+	case protocol.TypeSearchHistory:
+		c.handleSearchHistory(&msg)
+	case protocol.TypeSaveSnippet:
+		c.handleSaveSnippet(&msg)
+	case protocol.TypeListSnippets:
+		c.handleListSnippets(&msg)
+	case protocol.TypeRunSnippet:
+		c.handleRunSnippet(&msg)
+	case protocol.TypeDeleteSnippet:
+		c.handleDeleteSnippet(&msg)
+	case protocol.TypeComplete:
+		c.handleComplete(&msg)
+	case protocol.TypeListDirectory:
+		c.handleListDirectory(&msg)
+	case protocol.TypeReadFile:
+		c.handleReadFile(&msg)
+	case protocol.TypeWriteFile:
+		c.handleWriteFile(&msg)
+	case protocol.TypeListBuffers:
+		c.handleListBuffers(&msg)
+	case protocol.TypeGetBuffer:
+		c.handleGetBuffer(&msg)
+	case protocol.TypeSetBuffer:
+		c.handleSetBuffer(&msg)
+	case protocol.TypePasteBuffer:
+		c.handlePasteBuffer(&msg)
+	case protocol.TypeCopySelection:
+		c.handleCopySelection(&msg)
+	case protocol.TypeSendInput:
+		c.handleSendInput(&msg)
+	case protocol.TypeResize:
+		c.handleResize(&msg)
+	case protocol.TypeAttach:
+		c.handleAttach(&msg)
+	case protocol.TypeAttachResize:
+		c.handleAttachResize(&msg)
+	case protocol.TypeDetach:
+		c.handleDetach(&msg)
+	case protocol.TypeListSockets:
+		c.handleListSockets(&msg)
+	case protocol.TypeListHosts:
+		c.handleListHosts(&msg)
 	default:
 		c.sendError("UNKNOWN_MESSAGE_TYPE", "Unknown message type: "+string(msg.Type), msg.ID)
 	}
@@ -277,6 +597,44 @@ func (c *Client) sendMessage(msgType protocol.MessageType, payload interface{})
 	return nil
 }
 
+// disconnect closes the send channel once the hub dropped the client, so
+// handlers still running in the background stop sending
+func (c *Client) disconnect() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.connected = false
+	close(c.send)
+	if c.playback != nil {
+		close(c.playback)
+		c.playback = nil
+	}
+	if c.attachment != nil {
+		go c.attachment.Close()
+		c.attachment = nil
+	}
+}
+
+// sendBinary sends terminal output to the client, waiting while its queue is
+// full rather than dropping output; it returns false once disconnected
+func (c *Client) sendBinary(data []byte) bool {
+	for {
+		c.mu.Lock()
+		if !c.connected {
+			c.mu.Unlock()
+			return false
+		}
+		select {
+		case c.binary <- data:
+			c.mu.Unlock()
+			return true
+		default:
+		}
+		c.mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
 // sendError sends an error message to the client
 func (c *Client) sendError(code, message, originalMsgID string) {
 	payload := protocol.ErrorPayload{
@@ -289,7 +647,8 @@ func (c *Client) sendError(code, message, originalMsgID string) {
 
 // Helper function to generate client ID
 func generateClientID() string {
-	return "client-" + time.Now().Format("20060102150405")
+	// Microseconds keep clients connecting in the same second apart
+	return "client-" + time.Now().Format("20060102150405.000000")
 }
 
 // Helper function to generate message ID
@@ -0,0 +1,781 @@
+// Package shell runs sessions of plain shells in pseudo terminals, for hosts
+// without tmux
+package shell
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/myan/handx-server/internal/environ"
+	"github.com/myan/handx-server/internal/procinfo"
+	"github.com/myan/handx-server/internal/pty"
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// Size of windows created without one
+const (
+	defaultCols = 80
+	defaultRows = 24
+)
+
+// Bracketed paste markers around multi-line input
+const (
+	pasteStart = "\x1b[200~"
+	pasteEnd   = "\x1b[201~"
+)
+
+// Input sent for the special keys ExecuteCommand presses instead of typing
+var specialKeys = map[string]string{
+	"Escape": "\x1b",
+	"Enter":  "\r",
+	"Tab":    "\t",
+}
+
+// Options configures the shell manager
+type Options struct {
+	Shell        string // Shell run in new windows; $SHELL or /bin/sh when empty
+	HistoryLines int    // Lines of scrollback kept per pane
+}
+
+// Manager manages sessions of plain shells
+// Like tmux, a session has windows and lives until its last window's shell
+// exits, but every window has a single pane and nothing survives a restart
+// of the server. Output is kept as plain text scrollback in memory.
+type Manager struct {
+	shell        string
+	historyLines int
+
+	mu       sync.Mutex
+	sessions map[string]*session
+	nextPane int
+}
+
+// session is a named group of windows
+type session struct {
+	name       string
+	createdAt  time.Time
+	windows    []*window // Ordered by index
+	active     int       // Index of the active window
+	cols, rows int       // Size of new windows
+}
+
+// window is a window of a session, running one pane
+type window struct {
+	index int
+	name  string // Empty to name the window after its current command
+	pane  *pane
+}
+
+// pane is a shell running in a pseudo terminal
+type pane struct {
+	id           string
+	cmd          *exec.Cmd
+	pty          *os.File
+	startDir     string
+	cols, rows   int
+	scrollback   *scrollback
+	lastActivity time.Time
+}
+
+// NewManager creates a shell manager
+func NewManager(opts Options) (*Manager, error) {
+	shell := opts.Shell
+	if shell == "" {
+		shell = os.Getenv("SHELL")
+	}
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+	path, err := exec.LookPath(shell)
+	if err != nil {
+		return nil, fmt.Errorf("shell '%s' not found: %w", shell, err)
+	}
+
+	historyLines := opts.HistoryLines
+	if historyLines <= 0 {
+		historyLines = 10000
+	}
+
+	return &Manager{
+		shell:        path,
+		historyLines: historyLines,
+		sessions:     make(map[string]*session),
+	}, nil
+}
+
+// ListSessions returns all sessions, oldest first
+func (m *Manager) ListSessions() ([]protocol.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	table, _ := procinfo.Snapshot()
+
+	result := make([]protocol.Session, 0, len(m.sessions))
+	for _, s := range m.sessions {
+		result = append(result, m.describeSession(s, table))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].CreatedAt != result[j].CreatedAt {
+			return result[i].CreatedAt < result[j].CreatedAt
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+// CreateSession starts a session with one window
+// opts may be nil to run the default shell in the home directory
+func (m *Manager) CreateSession(name string, opts *protocol.SessionOptions) (*protocol.Session, error) {
+	if name == "" || strings.ContainsAny(name, ":.") {
+		return nil, fmt.Errorf("invalid session name '%s'", name)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.sessions[name]; ok {
+		return nil, fmt.Errorf("session '%s' already exists", name)
+	}
+
+	s := &session{name: name, createdAt: time.Now(), cols: defaultCols, rows: defaultRows}
+	windowOpts := &protocol.WindowOptions{}
+	windowName := ""
+	if opts != nil {
+		if opts.Width > 0 {
+			s.cols = opts.Width
+		}
+		if opts.Height > 0 {
+			s.rows = opts.Height
+		}
+		windowOpts = &protocol.WindowOptions{
+			StartDirectory: opts.StartDirectory,
+			Command:        opts.Command,
+			Environment:    opts.Environment,
+		}
+		windowName = opts.WindowName
+	}
+
+	if _, err := m.startWindow(s, windowName, windowOpts); err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	m.sessions[name] = s
+
+	log.Printf("Created shell session %s", name)
+	session := m.describeSession(s, nil)
+	return &session, nil
+}
+
+// KillSession ends a session and the shells of its windows
+func (m *Manager) KillSession(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.sessions[name]
+	if !ok {
+		return fmt.Errorf("session '%s' not found", name)
+	}
+
+	delete(m.sessions, name)
+	for _, w := range s.windows {
+		w.pane.kill()
+	}
+	return nil
+}
+
+// RenameSession renames a session
+func (m *Manager) RenameSession(oldName, newName string) error {
+	if newName == "" || strings.ContainsAny(newName, ":.") {
+		return fmt.Errorf("invalid session name '%s'", newName)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.sessions[oldName]
+	if !ok {
+		return fmt.Errorf("session '%s' not found", oldName)
+	}
+	if _, ok := m.sessions[newName]; ok {
+		return fmt.Errorf("session '%s' already exists", newName)
+	}
+
+	delete(m.sessions, oldName)
+	s.name = newName
+	m.sessions[newName] = s
+	return nil
+}
+
+// ExecuteCommand runs a command in a window of a session, the active one if
+// windowIndex is nil
+func (m *Manager) ExecuteCommand(sessionName, command string, windowIndex *int) error {
+	m.mu.Lock()
+	w, err := m.findWindow(sessionName, windowIndex)
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// Press special keys without Enter
+	if key, ok := specialKeys[command]; ok {
+		return m.SendToPane(w.pane.id, key, false)
+	}
+	return m.SendToPane(w.pane.id, command, true)
+}
+
+// SendText types text into the active window of a session without Enter
+func (m *Manager) SendText(sessionName, text string) error {
+	m.mu.Lock()
+	w, err := m.findWindow(sessionName, nil)
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return m.SendToPane(w.pane.id, text, false)
+}
+
+// CaptureOutput returns the scrollback of a window of a session, the active
+// one if windowIndex is nil
+func (m *Manager) CaptureOutput(sessionName string, windowIndex *int) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w, err := m.findWindow(sessionName, windowIndex)
+	if err != nil {
+		return "", err
+	}
+	return w.pane.scrollback.Capture(m.historyLines + w.pane.rows), nil
+}
+
+// ListWindows lists the windows of a session
+func (m *Manager) ListWindows(sessionName string) ([]protocol.Window, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.sessions[sessionName]
+	if !ok {
+		return nil, fmt.Errorf("session '%s' not found", sessionName)
+	}
+
+	table, _ := procinfo.Snapshot()
+	return m.describeWindows(s, table), nil
+}
+
+// CreateWindow starts a window after the last one of a session and makes it active
+// opts may be nil to run the default shell in the home directory
+func (m *Manager) CreateWindow(sessionName, windowName string, opts *protocol.WindowOptions) (*protocol.Window, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.sessions[sessionName]
+	if !ok {
+		return nil, fmt.Errorf("session '%s' not found", sessionName)
+	}
+	if opts == nil {
+		opts = &protocol.WindowOptions{}
+	}
+
+	w, err := m.startWindow(s, windowName, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create window: %w", err)
+	}
+
+	return &protocol.Window{
+		ID:     fmt.Sprintf("window-%s-%d", sessionName, w.index),
+		Name:   m.windowName(w, nil),
+		Index:  w.index,
+		Active: true,
+		PaneID: fmt.Sprintf("%d", w.index),
+	}, nil
+}
+
+// CloseWindow ends a window of a session
+func (m *Manager) CloseWindow(sessionName string, windowIndex int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w, err := m.findWindow(sessionName, &windowIndex)
+	if err != nil {
+		return err
+	}
+
+	s := m.sessions[sessionName]
+	if len(s.windows) == 1 {
+		return fmt.Errorf("cannot close the last window in session '%s'", sessionName)
+	}
+
+	m.removeWindow(s, w)
+	w.pane.kill()
+	return nil
+}
+
+// SwitchWindow makes a window of a session active and returns its name
+func (m *Manager) SwitchWindow(sessionName string, windowIndex int) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w, err := m.findWindow(sessionName, &windowIndex)
+	if err != nil {
+		return "", err
+	}
+
+	m.sessions[sessionName].active = w.index
+	return m.windowName(w, nil), nil
+}
+
+// ListAllPanes returns the location of every pane in every session
+func (m *Manager) ListAllPanes() ([]protocol.PaneLocation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make([]protocol.PaneLocation, 0)
+	for _, s := range m.sessions {
+		for _, w := range s.windows {
+			result = append(result, protocol.PaneLocation{
+				SessionName: s.name,
+				WindowIndex: w.index,
+				PaneIndex:   0,
+				PaneID:      w.pane.id,
+			})
+		}
+	}
+	return result, nil
+}
+
+// ListPanes lists the pane of a window with its process tree
+func (m *Manager) ListPanes(sessionName string, windowIndex int) ([]protocol.Pane, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w, err := m.findWindow(sessionName, &windowIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	table, _ := procinfo.Snapshot()
+	return []protocol.Pane{m.describePane(w.pane, table)}, nil
+}
+
+// PaneInfo describes a single pane including its process tree
+// A nil window index selects the active window; windows only have pane 0.
+// Returns the pane and the index of the window it belongs to.
+func (m *Manager) PaneInfo(sessionName string, windowIndex, paneIndex *int) (*protocol.Pane, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w, err := m.findWindow(sessionName, windowIndex)
+	if err != nil {
+		return nil, 0, err
+	}
+	if paneIndex != nil && *paneIndex != 0 {
+		return nil, 0, fmt.Errorf("pane %d not found in window %d of session '%s'", *paneIndex, w.index, sessionName)
+	}
+
+	table, _ := procinfo.Snapshot()
+	pane := m.describePane(w.pane, table)
+	return &pane, w.index, nil
+}
+
+// FindPane describes a single pane like PaneInfo, without its process tree
+// The current path is the shell's, not that of a program it runs.
+func (m *Manager) FindPane(sessionName string, windowIndex, paneIndex *int) (*protocol.Pane, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w, err := m.findWindow(sessionName, windowIndex)
+	if err != nil {
+		return nil, 0, err
+	}
+	if paneIndex != nil && *paneIndex != 0 {
+		return nil, 0, fmt.Errorf("pane %d not found in window %d of session '%s'", *paneIndex, w.index, sessionName)
+	}
+
+	pane := m.describePane(w.pane, nil)
+	return &pane, w.index, nil
+}
+
+// CapturePane returns a pane's content including up to lines of history
+func (m *Manager) CapturePane(paneID string, lines int) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p := m.findPane(paneID)
+	if p == nil {
+		return "", fmt.Errorf("pane %s not found", paneID)
+	}
+	return p.scrollback.Capture(lines + p.rows), nil
+}
+
+// SendToPane types text into a pane, pressing Enter afterwards if enter is set
+// Multi-line text is sent as a bracketed paste when the program in the pane
+// asked for it, like tmux pastes it.
+func (m *Manager) SendToPane(paneID, text string, enter bool) error {
+	m.mu.Lock()
+	p := m.findPane(paneID)
+	bracketed := p != nil && p.scrollback.bracketedPaste
+	m.mu.Unlock()
+	if p == nil {
+		return fmt.Errorf("pane %s not found", paneID)
+	}
+
+	if enter {
+		text = strings.TrimRight(text, "\r\n")
+	}
+	// Terminals send Enter as a carriage return
+	input := strings.ReplaceAll(text, "\n", "\r")
+	if bracketed && strings.Contains(text, "\n") {
+		input = pasteStart + input + pasteEnd
+	}
+	if enter {
+		input += "\r"
+	}
+
+	if _, err := p.pty.Write([]byte(input)); err != nil {
+		return fmt.Errorf("failed to send input to pane %s: %w", paneID, err)
+	}
+	return nil
+}
+
+// PaneSize returns the width and height of a pane
+func (m *Manager) PaneSize(paneID string) (int, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p := m.findPane(paneID)
+	if p == nil {
+		return 0, 0, fmt.Errorf("pane %s not found", paneID)
+	}
+	return p.cols, p.rows, nil
+}
+
+// TakeBells returns the panes that rang the bell since the last call
+func (m *Manager) TakeBells() ([]protocol.PaneLocation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make([]protocol.PaneLocation, 0)
+	for _, s := range m.sessions {
+		for _, w := range s.windows {
+			if !w.pane.scrollback.bell {
+				continue
+			}
+			w.pane.scrollback.bell = false
+			result = append(result, protocol.PaneLocation{
+				SessionName: s.name,
+				WindowIndex: w.index,
+				PaneIndex:   0,
+				PaneID:      w.pane.id,
+			})
+		}
+	}
+	return result, nil
+}
+
+// ResizeSession resizes every window of a session
+func (m *Manager) ResizeSession(sessionName string, cols, rows int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.sessions[sessionName]
+	if !ok {
+		return fmt.Errorf("session '%s' not found", sessionName)
+	}
+
+	for _, w := range s.windows {
+		if err := w.pane.resize(cols, rows); err != nil {
+			return fmt.Errorf("failed to resize pane %s: %w", w.pane.id, err)
+		}
+	}
+	return nil
+}
+
+// ResetSessionSize returns the windows of a session to the size it was created with
+func (m *Manager) ResetSessionSize(sessionName string) error {
+	m.mu.Lock()
+	s, ok := m.sessions[sessionName]
+	m.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("session '%s' not found", sessionName)
+	}
+
+	return m.ResizeSession(sessionName, s.cols, s.rows)
+}
+
+// startWindow starts a shell in a new window of a session and makes it
+// active; callers must hold the lock
+func (m *Manager) startWindow(s *session, name string, opts *protocol.WindowOptions) (*window, error) {
+	env, err := environment(opts.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := opts.StartDirectory
+	if dir == "" {
+		dir, _ = os.UserHomeDir()
+	}
+
+	cmd := exec.Command(m.shell)
+	if opts.Command != "" {
+		cmd = exec.Command(m.shell, "-c", opts.Command)
+	}
+	cmd.Dir = dir
+	cmd.Env = env
+
+	master, slave, err := pty.Open()
+	if err != nil {
+		return nil, err
+	}
+	if err := pty.SetSize(master, s.cols, s.rows); err != nil {
+		master.Close()
+		slave.Close()
+		return nil, fmt.Errorf("failed to size pseudo terminal: %w", err)
+	}
+
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = slave, slave, slave
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+	if err := cmd.Start(); err != nil {
+		master.Close()
+		slave.Close()
+		return nil, fmt.Errorf("failed to start shell: %w", err)
+	}
+	// The shell holds the slave end now
+	slave.Close()
+
+	m.nextPane++
+	p := &pane{
+		id:           fmt.Sprintf("%%%d", m.nextPane),
+		cmd:          cmd,
+		pty:          master,
+		startDir:     dir,
+		cols:         s.cols,
+		rows:         s.rows,
+		scrollback:   newScrollback(m.historyLines),
+		lastActivity: time.Now(),
+	}
+
+	index := 0
+	if len(s.windows) > 0 {
+		index = s.windows[len(s.windows)-1].index + 1
+	}
+	w := &window{index: index, name: name, pane: p}
+	s.windows = append(s.windows, w)
+	s.active = index
+
+	go m.relay(p)
+	return w, nil
+}
+
+// relay reads a pane's output into its scrollback until the shell exits,
+// then closes its window
+func (m *Manager) relay(p *pane) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := p.pty.Read(buf)
+		if n > 0 {
+			m.mu.Lock()
+			p.scrollback.Write(buf[:n])
+			p.lastActivity = time.Now()
+			m.mu.Unlock()
+		}
+		if err != nil {
+			// EIO once the shell exited and closed the slave end
+			break
+		}
+	}
+
+	p.cmd.Wait()
+	p.pty.Close()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, s := range m.sessions {
+		for _, w := range s.windows {
+			if w.pane == p {
+				m.removeWindow(s, w)
+				return
+			}
+		}
+	}
+}
+
+// removeWindow removes a window from its session, and the session when it
+// was the last one; callers must hold the lock
+func (m *Manager) removeWindow(s *session, w *window) {
+	for i, other := range s.windows {
+		if other != w {
+			continue
+		}
+		s.windows = append(s.windows[:i], s.windows[i+1:]...)
+		if len(s.windows) == 0 {
+			delete(m.sessions, s.name)
+			log.Printf("Shell session %s ended", s.name)
+			return
+		}
+		if s.active == w.index {
+			s.active = s.windows[min(i, len(s.windows)-1)].index
+		}
+		return
+	}
+}
+
+// findWindow returns a window of a session, the active one if windowIndex is
+// nil; callers must hold the lock
+func (m *Manager) findWindow(sessionName string, windowIndex *int) (*window, error) {
+	s, ok := m.sessions[sessionName]
+	if !ok {
+		return nil, fmt.Errorf("session '%s' not found", sessionName)
+	}
+
+	index := s.active
+	if windowIndex != nil {
+		index = *windowIndex
+	}
+	for _, w := range s.windows {
+		if w.index == index {
+			return w, nil
+		}
+	}
+	return nil, fmt.Errorf("window index %d not found in session '%s'", index, sessionName)
+}
+
+// findPane returns a pane by ID, or nil; callers must hold the lock
+func (m *Manager) findPane(paneID string) *pane {
+	for _, s := range m.sessions {
+		for _, w := range s.windows {
+			if w.pane.id == paneID {
+				return w.pane
+			}
+		}
+	}
+	return nil
+}
+
+// describeSession converts a session to its protocol form; callers must hold the lock
+func (m *Manager) describeSession(s *session, table *procinfo.Table) protocol.Session {
+	windows := m.describeWindows(s, table)
+
+	var lastActivity time.Time
+	var width, height int
+	for _, w := range s.windows {
+		if w.pane.lastActivity.After(lastActivity) {
+			lastActivity = w.pane.lastActivity
+		}
+		if w.index == s.active {
+			width, height = w.pane.cols, w.pane.rows
+		}
+	}
+
+	return protocol.Session{
+		ID:           fmt.Sprintf("session-%s", s.name),
+		Name:         s.name,
+		Windows:      windows,
+		CreatedAt:    s.createdAt.UnixMilli(),
+		LastActivity: lastActivity.UnixMilli(),
+		WindowCount:  len(s.windows),
+		Width:        width,
+		Height:       height,
+	}
+}
+
+// describeWindows converts the windows of a session to their protocol form;
+// callers must hold the lock
+func (m *Manager) describeWindows(s *session, table *procinfo.Table) []protocol.Window {
+	result := make([]protocol.Window, 0, len(s.windows))
+	for _, w := range s.windows {
+		result = append(result, protocol.Window{
+			ID:     fmt.Sprintf("window-%s-%d", s.name, w.index),
+			Name:   m.windowName(w, table),
+			Index:  w.index,
+			Active: w.index == s.active,
+			PaneID: fmt.Sprintf("%d", w.index),
+			Panes:  []protocol.Pane{m.describePane(w.pane, table)},
+		})
+	}
+	return result
+}
+
+// describePane converts a pane to its protocol form, with its process tree
+// when a process table is given
+func (m *Manager) describePane(p *pane, table *procinfo.Table) protocol.Pane {
+	pid := p.cmd.Process.Pid
+	result := protocol.Pane{
+		ID:             p.id,
+		Index:          0,
+		Active:         true,
+		Width:          p.cols,
+		Height:         p.rows,
+		CurrentCommand: filepath.Base(m.shell),
+		CurrentPath:    p.startDir,
+		PID:            pid,
+	}
+
+	if table != nil {
+		result.Processes = table.Tree(pid)
+		// Like tmux, report the program in front of the shell
+		if fg := table.Foreground(pid); fg != nil {
+			result.CurrentCommand = fg.Command
+			pid = fg.PID
+		}
+	}
+	// Best effort: only readable where /proc exists
+	if dir, err := os.Readlink(fmt.Sprintf("/proc/%d/cwd", pid)); err == nil {
+		result.CurrentPath = dir
+	}
+	return result
+}
+
+// windowName returns a window's name, which like tmux's automatic rename
+// follows the current command unless a name was given
+func (m *Manager) windowName(w *window, table *procinfo.Table) string {
+	if w.name != "" {
+		return w.name
+	}
+	if table != nil {
+		if fg := table.Foreground(w.pane.cmd.Process.Pid); fg != nil {
+			return fg.Command
+		}
+	}
+	return filepath.Base(m.shell)
+}
+
+// resize changes the size of a pane's pseudo terminal
+func (p *pane) resize(cols, rows int) error {
+	if err := pty.SetSize(p.pty, cols, rows); err != nil {
+		return err
+	}
+	p.cols, p.rows = cols, rows
+	return nil
+}
+
+// kill hangs up on the pane's processes, like closing a terminal does
+func (p *pane) kill() {
+	// The shell leads its own process group
+	syscall.Kill(-p.cmd.Process.Pid, syscall.SIGHUP)
+	p.pty.Close()
+}
+
+// environment returns the environment of a new shell: the server's, with
+// extra variables and a dumb terminal, so programs print plain lines
+func environment(extra map[string]string) ([]string, error) {
+	pairs, err := environ.Pairs(extra)
+	if err != nil {
+		return nil, err
+	}
+
+	env := make([]string, 0, len(os.Environ())+len(pairs)+1)
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, "TERM=") && !strings.HasPrefix(kv, "TMUX=") {
+			env = append(env, kv)
+		}
+	}
+	env = append(env, "TERM=dumb")
+	return append(env, pairs...), nil
+}
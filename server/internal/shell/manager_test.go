@@ -0,0 +1,227 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+
+	m, err := NewManager(Options{Shell: "/bin/sh", HistoryLines: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		sessions, _ := m.ListSessions()
+		for _, s := range sessions {
+			m.KillSession(s.Name)
+		}
+	})
+	return m
+}
+
+// waitForOutput waits until the active window of a session printed want
+func waitForOutput(t *testing.T, m *Manager, sessionName, want string) string {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		output, err := m.CaptureOutput(sessionName, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(output, want) {
+			return output
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("output of %s doesn't contain %q:\n%s", sessionName, want, output)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// waitForSessions waits until count sessions are left
+func waitForSessions(t *testing.T, m *Manager, count int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		sessions, _ := m.ListSessions()
+		if len(sessions) == count {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d sessions left, want %d", len(sessions), count)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestSession(t *testing.T) {
+	m := newTestManager(t)
+
+	dir := t.TempDir()
+	opts := &protocol.SessionOptions{StartDirectory: dir, Environment: map[string]string{"GREETING": "hello"}, Width: 100, Height: 30}
+	session, err := m.CreateSession("dev", opts)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	if session.Name != "dev" || len(session.Windows) != 1 {
+		t.Errorf("session = %+v, want dev with one window", session)
+	}
+	if _, err := m.CreateSession("dev", nil); err == nil {
+		t.Error("duplicate session was created")
+	}
+	if _, err := m.CreateSession("a:b", nil); err == nil {
+		t.Error("session with an invalid name was created")
+	}
+
+	if err := m.ExecuteCommand("dev", `echo "$GREETING from $(pwd) in $TERM"`, nil); err != nil {
+		t.Fatalf("ExecuteCommand failed: %v", err)
+	}
+	waitForOutput(t, m, "dev", "hello from "+dir+" in dumb")
+
+	pane, _, err := m.FindPane("dev", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cols, rows, err := m.PaneSize(pane.ID)
+	if err != nil || cols != 100 || rows != 30 {
+		t.Errorf("PaneSize = %d, %d, %v, want 100, 30", cols, rows, err)
+	}
+}
+
+func TestWindows(t *testing.T) {
+	m := newTestManager(t)
+
+	if _, err := m.CreateSession("dev", nil); err != nil {
+		t.Fatal(err)
+	}
+	window, err := m.CreateWindow("dev", "logs", nil)
+	if err != nil {
+		t.Fatalf("CreateWindow failed: %v", err)
+	}
+	if window.Index != 1 || window.Name != "logs" {
+		t.Errorf("window = %+v, want logs at index 1", window)
+	}
+
+	// Commands go to the active window unless one is given
+	if err := m.ExecuteCommand("dev", "echo in-logs", nil); err != nil {
+		t.Fatal(err)
+	}
+	first := 0
+	if err := m.ExecuteCommand("dev", "echo in-first", &first); err != nil {
+		t.Fatal(err)
+	}
+	waitForOutput(t, m, "dev", "in-logs")
+	if _, err := m.SwitchWindow("dev", 0); err != nil {
+		t.Fatal(err)
+	}
+	if output := waitForOutput(t, m, "dev", "in-first"); strings.Contains(output, "in-logs") {
+		t.Errorf("first window printed the command sent to the second:\n%s", output)
+	}
+
+	if err := m.CloseWindow("dev", 1); err != nil {
+		t.Fatalf("CloseWindow failed: %v", err)
+	}
+	if err := m.CloseWindow("dev", 0); err == nil {
+		t.Error("closed the last window")
+	}
+	windows, err := m.ListWindows("dev")
+	if err != nil || len(windows) != 1 || windows[0].Index != 0 {
+		t.Errorf("ListWindows = %+v, %v, want window 0", windows, err)
+	}
+}
+
+func TestSessionEnds(t *testing.T) {
+	m := newTestManager(t)
+
+	for _, name := range []string{"exits", "killed"} {
+		if _, err := m.CreateSession(name, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A session ends with the shell of its last window
+	if err := m.ExecuteCommand("exits", "exit", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.KillSession("killed"); err != nil {
+		t.Fatalf("KillSession failed: %v", err)
+	}
+	waitForSessions(t, m, 0)
+
+	if err := m.KillSession("killed"); err == nil {
+		t.Error("killed a session that doesn't exist")
+	}
+}
+
+func TestSpecialKeys(t *testing.T) {
+	m := newTestManager(t)
+
+	if _, err := m.CreateSession("dev", &protocol.SessionOptions{Command: "cat -vT"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Pressed rather than typed, and without Enter
+	if err := m.SendText("dev", "a"); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"Tab", "Escape", "Enter"} {
+		if err := m.ExecuteCommand("dev", key, nil); err != nil {
+			t.Fatalf("ExecuteCommand(%s) failed: %v", key, err)
+		}
+	}
+	output := waitForOutput(t, m, "dev", "a^I^[")
+	if strings.Contains(output, "Tab") || strings.Contains(output, "Escape") {
+		t.Errorf("key names were typed:\n%s", output)
+	}
+}
+
+func TestBracketedPaste(t *testing.T) {
+	m := newTestManager(t)
+
+	// cat -v shows the paste markers it receives
+	command := `printf '\033[?2004h'; exec cat -v`
+	if _, err := m.CreateSession("dev", &protocol.SessionOptions{Command: command}); err != nil {
+		t.Fatal(err)
+	}
+	pane, _, err := m.FindPane("dev", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paneID := pane.ID
+
+	// Wait for the program to ask for bracketed paste
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		m.mu.Lock()
+		bracketed := m.findPane(paneID).scrollback.bracketedPaste
+		m.mu.Unlock()
+		if bracketed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("bracketed paste wasn't enabled")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if err := m.SendToPane(paneID, "one\ntwo\n", true); err != nil {
+		t.Fatalf("SendToPane failed: %v", err)
+	}
+	waitForOutput(t, m, "dev", "^[[200~one\ntwo^[[201~\n")
+
+	// A single line isn't pasted
+	if err := m.SendToPane(paneID, "three", true); err != nil {
+		t.Fatal(err)
+	}
+	output := waitForOutput(t, m, "dev", "three\n")
+	if strings.Contains(output, "^[[200~three") {
+		t.Errorf("single line was pasted:\n%s", output)
+	}
+}
@@ -0,0 +1,164 @@
+package shell
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Parser states of the output stream
+const (
+	stateGround = iota
+	stateEscape
+	stateCSI
+	stateString // OSC, DCS and similar strings, ended by BEL or ST
+	stateStringEscape
+)
+
+// scrollback keeps the plain text lines a pane printed
+// Escape sequences are dropped rather than rendered, except for erasing to
+// the end of the line, which shells use when redrawing the prompt. That is
+// enough for line-oriented programs; full-screen programs don't render.
+type scrollback struct {
+	limit int // Number of complete lines kept
+	lines []string
+	line  []rune // Line being printed
+	col   int    // Cursor position in line
+
+	state   int
+	params  []byte // Parameters of the CSI sequence being parsed
+	partial []byte // Incomplete UTF-8 character
+
+	bell           bool // Rang since the flag was last taken
+	bracketedPaste bool // The program asked for bracketed paste
+}
+
+// newScrollback creates a scrollback keeping limit lines
+func newScrollback(limit int) *scrollback {
+	return &scrollback{limit: limit}
+}
+
+// Write parses output of the pane
+func (s *scrollback) Write(data []byte) {
+	for _, b := range data {
+		switch s.state {
+		case stateGround:
+			s.ground(b)
+		case stateEscape:
+			switch b {
+			case '[':
+				s.state = stateCSI
+				s.params = s.params[:0]
+			case ']', 'P', 'X', '^', '_':
+				s.state = stateString
+			default:
+				s.state = stateGround
+			}
+		case stateCSI:
+			if b >= 0x40 && b <= 0x7e {
+				s.csi(string(s.params), b)
+				s.state = stateGround
+			} else {
+				s.params = append(s.params, b)
+			}
+		case stateString:
+			// BEL ends the string here rather than ringing
+			if b == '\a' {
+				s.state = stateGround
+			} else if b == 0x1b {
+				s.state = stateStringEscape
+			}
+		case stateStringEscape:
+			s.state = stateGround
+		}
+	}
+}
+
+// ground handles a byte outside escape sequences
+func (s *scrollback) ground(b byte) {
+	if len(s.partial) > 0 || b >= utf8.RuneSelf {
+		s.partial = append(s.partial, b)
+		if !utf8.FullRune(s.partial) {
+			return
+		}
+		r, _ := utf8.DecodeRune(s.partial)
+		s.partial = s.partial[:0]
+		s.put(r)
+		return
+	}
+
+	switch b {
+	case 0x1b:
+		s.state = stateEscape
+	case '\n':
+		s.newline()
+	case '\r':
+		s.col = 0
+	case '\b':
+		if s.col > 0 {
+			s.col--
+		}
+	case '\t':
+		for {
+			s.put(' ')
+			if s.col%8 == 0 {
+				break
+			}
+		}
+	case '\a':
+		s.bell = true
+	default:
+		if b >= 0x20 && b != 0x7f {
+			s.put(rune(b))
+		}
+	}
+}
+
+// csi handles the CSI sequences that matter for plain text
+func (s *scrollback) csi(params string, final byte) {
+	switch {
+	case final == 'K' && (params == "" || params == "0"):
+		s.line = s.line[:min(s.col, len(s.line))]
+	case final == 'h' && params == "?2004":
+		s.bracketedPaste = true
+	case final == 'l' && params == "?2004":
+		s.bracketedPaste = false
+	}
+}
+
+// put prints a character at the cursor
+func (s *scrollback) put(r rune) {
+	if s.col < len(s.line) {
+		s.line[s.col] = r
+	} else {
+		s.line = append(s.line, r)
+	}
+	s.col++
+}
+
+// newline completes the current line
+func (s *scrollback) newline() {
+	s.lines = append(s.lines, string(s.line))
+	s.line = s.line[:0]
+	s.col = 0
+
+	// Trim in batches rather than on every line
+	if len(s.lines) > 2*s.limit {
+		s.lines = append([]string(nil), s.lines[len(s.lines)-s.limit:]...)
+	}
+}
+
+// Capture returns the last lines lines, including the one being printed,
+// each ending with a newline
+func (s *scrollback) Capture(lines int) string {
+	all := s.lines
+	if len(s.line) > 0 {
+		all = append(all[:len(all):len(all)], string(s.line))
+	}
+	if lines < len(all) {
+		all = all[len(all)-lines:]
+	}
+	if len(all) == 0 {
+		return ""
+	}
+	return strings.Join(all, "\n") + "\n"
+}
@@ -0,0 +1,66 @@
+package shell
+
+import "testing"
+
+func TestScrollbackCapture(t *testing.T) {
+	tests := []struct {
+		name   string
+		output []string
+		want   string
+	}{
+		{"lines", []string{"one\r\ntwo\r\n"}, "one\ntwo\n"},
+		{"line being printed", []string{"one\r\n$ "}, "one\n$ \n"},
+		{"colors", []string{"\x1b[1;31merror\x1b[0m done\r\n"}, "error done\n"},
+		{"title", []string{"\x1b]0;vim\aa\x1b]2;b\x1b\\b\r\n"}, "ab\n"},
+		{"carriage return", []string{"12345\rab\r\n"}, "ab345\n"},
+		{"erase to end of line", []string{"$ long command\r$ \x1b[K\r\n"}, "$ \n"},
+		{"backspace", []string{"abc\b\bX\r\n"}, "aXc\n"},
+		{"tab", []string{"a\tb\r\n"}, "a       b\n"},
+		{"split sequence", []string{"\x1b[3", "1mred\x1b", "[0m\r\n"}, "red\n"},
+		{"split character", []string{"caf\xc3", "\xa9\r\n"}, "café\n"},
+	}
+
+	for _, tt := range tests {
+		s := newScrollback(100)
+		for _, data := range tt.output {
+			s.Write([]byte(data))
+		}
+		if got := s.Capture(100); got != tt.want {
+			t.Errorf("%s: Capture = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestScrollbackLimit(t *testing.T) {
+	s := newScrollback(2)
+	for _, line := range []string{"1", "2", "3", "4", "5", "6"} {
+		s.Write([]byte(line + "\r\n"))
+	}
+
+	if got := s.Capture(2); got != "5\n6\n" {
+		t.Errorf("Capture(2) = %q, want the last two lines", got)
+	}
+	if len(s.lines) > 4 {
+		t.Errorf("%d lines kept, want at most twice the limit", len(s.lines))
+	}
+}
+
+func TestScrollbackModes(t *testing.T) {
+	s := newScrollback(100)
+
+	s.Write([]byte("\x1b[?2004h$ "))
+	if !s.bracketedPaste {
+		t.Error("bracketed paste wasn't enabled")
+	}
+	s.Write([]byte("\a"))
+	if !s.bell {
+		t.Error("bell didn't ring")
+	}
+	s.Write([]byte("\x1b[?2004l"))
+	if s.bracketedPaste {
+		t.Error("bracketed paste wasn't disabled")
+	}
+	if got := s.Capture(100); got != "$ \n" {
+		t.Errorf("Capture = %q, want the prompt only", got)
+	}
+}
@@ -0,0 +1,163 @@
+package sizing
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// Smallest size a viewport may report
+const (
+	minCols = 10
+	minRows = 2
+)
+
+// Backend is the subset of the tmux manager used to size windows
+type Backend interface {
+	ResizeSession(sessionName string, cols, rows int) error
+	ResetSessionSize(sessionName string) error
+}
+
+// viewport is the size a client reported for a session
+type viewport struct {
+	cols, rows int
+	reportedAt time.Time
+}
+
+// Sizer sizes the windows of each session to the viewports of the clients
+// showing it, combined by a policy
+// When the last client leaves a session its windows go back to following
+// the attached tmux clients.
+type Sizer struct {
+	backend Backend
+	policy  string
+	mu      sync.Mutex
+	// Viewports by session, then by client
+	viewports map[string]map[string]viewport
+}
+
+// NewSizer creates a sizer with a policy of largest, smallest or latest
+func NewSizer(backend Backend, policy string) (*Sizer, error) {
+	switch policy {
+	case protocol.SizeLargest, protocol.SizeSmallest, protocol.SizeLatest:
+	case "":
+		policy = protocol.SizeLatest
+	default:
+		return nil, fmt.Errorf("unknown sizing policy '%s'", policy)
+	}
+
+	return &Sizer{
+		backend:   backend,
+		policy:    policy,
+		viewports: make(map[string]map[string]viewport),
+	}, nil
+}
+
+// Resize records the viewport of a client showing a session, forgetting the
+// one it reported for any other session, and resizes the session's windows
+func (s *Sizer) Resize(clientID, sessionName string, cols, rows int) (*protocol.ResizeResponse, error) {
+	if cols < minCols || rows < minRows {
+		return nil, fmt.Errorf("viewport %dx%d is too small", cols, rows)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// A client shows one session at a time
+	for name := range s.viewports {
+		if name != sessionName {
+			s.forget(clientID, name)
+		}
+	}
+
+	clients, ok := s.viewports[sessionName]
+	if !ok {
+		clients = make(map[string]viewport)
+		s.viewports[sessionName] = clients
+	}
+	clients[clientID] = viewport{cols: cols, rows: rows, reportedAt: time.Now()}
+
+	size, err := s.apply(sessionName)
+	if err != nil {
+		// Most likely the session doesn't exist
+		delete(clients, clientID)
+		if len(clients) == 0 {
+			delete(s.viewports, sessionName)
+		}
+		return nil, err
+	}
+	return &protocol.ResizeResponse{
+		Success:     true,
+		SessionName: sessionName,
+		Cols:        size[0],
+		Rows:        size[1],
+		Policy:      s.policy,
+		Clients:     len(clients),
+	}, nil
+}
+
+// RemoveClient forgets the viewports of a disconnected client
+func (s *Sizer) RemoveClient(clientID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for name := range s.viewports {
+		s.forget(clientID, name)
+	}
+}
+
+// forget drops a client's viewport of a session and resizes the session for
+// the remaining clients; callers must hold the lock
+func (s *Sizer) forget(clientID, sessionName string) {
+	clients := s.viewports[sessionName]
+	if _, ok := clients[clientID]; !ok {
+		return
+	}
+	delete(clients, clientID)
+
+	if len(clients) > 0 {
+		if _, err := s.apply(sessionName); err != nil {
+			log.Printf("Failed to resize session %s: %v", sessionName, err)
+		}
+		return
+	}
+
+	delete(s.viewports, sessionName)
+	if err := s.backend.ResetSessionSize(sessionName); err != nil {
+		log.Printf("Failed to reset size of session %s: %v", sessionName, err)
+	}
+}
+
+// apply resizes a session to the size the policy picks from its viewports;
+// callers must hold the lock
+func (s *Sizer) apply(sessionName string) ([2]int, error) {
+	var size [2]int
+	var latest time.Time
+	first := true
+	for _, v := range s.viewports[sessionName] {
+		switch s.policy {
+		case protocol.SizeLargest:
+			size = [2]int{max(size[0], v.cols), max(size[1], v.rows)}
+		case protocol.SizeSmallest:
+			if first {
+				size = [2]int{v.cols, v.rows}
+			}
+			size = [2]int{min(size[0], v.cols), min(size[1], v.rows)}
+		case protocol.SizeLatest:
+			if v.reportedAt.After(latest) {
+				size, latest = [2]int{v.cols, v.rows}, v.reportedAt
+			}
+		}
+		first = false
+	}
+
+	// Windows created since the last resize are sized too
+	if err := s.backend.ResizeSession(sessionName, size[0], size[1]); err != nil {
+		return size, err
+	}
+	log.Printf("Resized session %s to %dx%d (%s of %d clients)", sessionName, size[0], size[1], s.policy, len(s.viewports[sessionName]))
+	return size, nil
+}
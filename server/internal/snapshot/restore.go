@@ -0,0 +1,214 @@
+package snapshot
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/myan/handx-server/internal/paths"
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// Restore recreates the sessions of the latest snapshot that don't exist yet
+// If names is non-empty only those sessions are restored.
+func (s *Snapshotter) Restore(names []string) (*protocol.RestoreSessionsResponse, error) {
+	snap, err := s.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing := make(map[string]bool)
+	if sessions, err := s.backend.ListSessions(); err == nil {
+		for _, session := range sessions {
+			existing[session.Name] = true
+		}
+	}
+
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[name] = true
+	}
+
+	result := &protocol.RestoreSessionsResponse{
+		Success:           true,
+		SnapshotCreatedAt: snap.CreatedAt,
+		Restored:          []string{},
+		Skipped:           []string{},
+		Failed:            []protocol.RestoreFailure{},
+	}
+
+	for _, ss := range snap.Sessions {
+		if len(wanted) > 0 && !wanted[ss.Name] {
+			continue
+		}
+		if existing[ss.Name] {
+			result.Skipped = append(result.Skipped, ss.Name)
+			continue
+		}
+
+		if err := s.restoreSession(ss); err != nil {
+			log.Printf("Failed to restore session %s: %v", ss.Name, err)
+			// Don't leave a half-restored session behind
+			s.backend.KillSession(ss.Name)
+			result.Failed = append(result.Failed, protocol.RestoreFailure{
+				SessionName: ss.Name,
+				Error:       err.Error(),
+			})
+			continue
+		}
+
+		log.Printf("Restored session %s", ss.Name)
+		result.Restored = append(result.Restored, ss.Name)
+	}
+
+	return result, nil
+}
+
+// restoreSession recreates one session with its windows and panes
+func (s *Snapshotter) restoreSession(ss SessionSnapshot) error {
+	if len(ss.Windows) == 0 {
+		return fmt.Errorf("session has no windows")
+	}
+
+	// Paths of sessions on remote hosts can't be checked or read here
+	remote := ss.Host != ""
+
+	first := ss.Windows[0]
+	firstPane := firstPaneOf(first)
+	session, err := s.backend.CreateSession(ss.Name, &protocol.SessionOptions{
+		StartDirectory: startDir(firstPane.CurrentPath, remote),
+		Command:        s.paneStartCommand(firstPane, remote),
+		WindowName:     first.Name,
+		Socket:         ss.Socket,
+		Host:           ss.Host,
+	})
+	if err != nil {
+		return err
+	}
+	if len(session.Windows) == 0 {
+		return fmt.Errorf("session has no windows after creation")
+	}
+
+	// Window indexes may differ from the snapshot (e.g. gaps, base-index)
+	activeIndex := session.Windows[0].Index
+	if err := s.restoreWindow(ss.Name, session.Windows[0].Index, first, remote); err != nil {
+		return err
+	}
+
+	for _, ws := range ss.Windows[1:] {
+		pane := firstPaneOf(ws)
+		window, err := s.backend.CreateWindow(ss.Name, ws.Name, &protocol.WindowOptions{
+			StartDirectory: startDir(pane.CurrentPath, remote),
+			Command:        s.paneStartCommand(pane, remote),
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := s.restoreWindow(ss.Name, window.Index, ws, remote); err != nil {
+			return err
+		}
+		if ws.Active {
+			activeIndex = window.Index
+		}
+	}
+
+	if _, err := s.backend.SwitchWindow(ss.Name, activeIndex); err != nil {
+		log.Printf("Failed to select active window of %s: %v", ss.Name, err)
+	}
+
+	return nil
+}
+
+// restoreWindow recreates the panes of a window whose first pane already
+// exists; remote is set for windows on remote hosts
+func (s *Snapshotter) restoreWindow(sessionName string, windowIndex int, ws WindowSnapshot, remote bool) error {
+	for i, pane := range ws.Panes {
+		if i > 0 {
+			err := s.backend.SplitWindow(sessionName, windowIndex, false, &protocol.WindowOptions{
+				StartDirectory: startDir(pane.CurrentPath, remote),
+				Command:        s.paneStartCommand(pane, remote),
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		// The new pane is active, so the program lands in the right place
+		if s.shouldRestoreCommand(pane) {
+			idx := windowIndex
+			if err := s.backend.ExecuteCommand(sessionName, pane.CommandLine, &idx); err != nil {
+				log.Printf("Failed to restart '%s' in %s:%d: %v", pane.CommandLine, sessionName, windowIndex, err)
+			}
+		}
+	}
+
+	// A custom layout string restores the exact pane geometry
+	if ws.Layout != "" && len(ws.Panes) > 1 {
+		if err := s.backend.SelectLayout(sessionName, windowIndex, ws.Layout); err != nil {
+			log.Printf("Failed to restore layout of %s:%d: %v", sessionName, windowIndex, err)
+		}
+	}
+
+	return nil
+}
+
+// paneStartCommand returns the command a restored pane starts with
+// With saved scrollback the pane prints it before handing over to the shell.
+// Panes on remote hosts can't read the local file and start the shell.
+func (s *Snapshotter) paneStartCommand(pane PaneSnapshot, remote bool) string {
+	if pane.ScrollbackFile == "" || remote {
+		return ""
+	}
+
+	path := filepath.Join(s.dir, pane.ScrollbackFile)
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+
+	return fmt.Sprintf(`cat %s; exec "${SHELL:-/bin/sh}" -l`, paths.Quote(path))
+}
+
+// shouldRestoreCommand reports whether a pane's foreground program is restarted
+func (s *Snapshotter) shouldRestoreCommand(pane PaneSnapshot) bool {
+	if pane.CommandLine == "" {
+		return false
+	}
+
+	for _, name := range s.opts.RestoreCommands {
+		if name == pane.CurrentCommand || (name == "*" && !shells[pane.CurrentCommand]) {
+			return true
+		}
+	}
+	return false
+}
+
+// shells are never restarted by "*", the pane already runs a shell
+var shells = map[string]bool{
+	"sh": true, "bash": true, "zsh": true, "fish": true, "dash": true, "ksh": true, "tcsh": true, "csh": true,
+}
+
+// firstPaneOf returns a window's first pane, or an empty pane if it has none
+func firstPaneOf(ws WindowSnapshot) PaneSnapshot {
+	if len(ws.Panes) == 0 {
+		return PaneSnapshot{}
+	}
+	return ws.Panes[0]
+}
+
+// startDir returns dir if it still exists, otherwise "" so tmux uses its
+// default; directories on remote hosts are passed on unchecked, where tmux
+// falls back to a default itself when they are gone
+func startDir(dir string, remote bool) string {
+	if dir == "" || remote {
+		return dir
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return ""
+	}
+	return dir
+}
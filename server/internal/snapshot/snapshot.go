@@ -0,0 +1,365 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/myan/handx-server/internal/paths"
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// snapshotVersion is bumped when the on-disk format changes incompatibly
+const snapshotVersion = 1
+
+var (
+	// ErrNoSessions is returned by Save when there are no sessions to snapshot
+	ErrNoSessions = errors.New("no sessions to snapshot")
+	// ErrNoSnapshot is returned when no snapshot has been saved yet
+	ErrNoSnapshot = errors.New("no snapshot found")
+)
+
+// Backend is the subset of the tmux manager used to snapshot and restore sessions
+type Backend interface {
+	ListSessions() ([]protocol.Session, error)
+	ListPanes(sessionName string, windowIndex int) ([]protocol.Pane, error)
+	CapturePane(paneID string, lines int) (string, error)
+	CreateSession(name string, opts *protocol.SessionOptions) (*protocol.Session, error)
+	KillSession(name string) error
+	CreateWindow(sessionName, windowName string, opts *protocol.WindowOptions) (*protocol.Window, error)
+	SwitchWindow(sessionName string, windowIndex int) (string, error)
+	SplitWindow(sessionName string, windowIndex int, horizontal bool, opts *protocol.WindowOptions) error
+	SelectLayout(sessionName string, windowIndex int, layout string) error
+	ExecuteCommand(sessionName, command string, windowIndex *int) error
+}
+
+// Snapshot is the saved state of all tmux sessions
+type Snapshot struct {
+	Version   int               `json:"version"`
+	CreatedAt int64             `json:"created_at"`
+	Sessions  []SessionSnapshot `json:"sessions"`
+}
+
+// SessionSnapshot is the saved state of one session
+type SessionSnapshot struct {
+	Name    string           `json:"name"`
+	Socket  string           `json:"socket,omitempty"` // tmux socket, "" for the default server
+	Host    string           `json:"host,omitempty"`   // Remote host, "" for local sessions
+	Windows []WindowSnapshot `json:"windows"`
+}
+
+// WindowSnapshot is the saved state of one window
+type WindowSnapshot struct {
+	Index  int            `json:"index"`
+	Name   string         `json:"name"`
+	Layout string         `json:"layout"`
+	Active bool           `json:"active"`
+	Panes  []PaneSnapshot `json:"panes"`
+}
+
+// PaneSnapshot is the saved state of one pane
+type PaneSnapshot struct {
+	Index          int    `json:"index"`
+	Active         bool   `json:"active"`
+	CurrentPath    string `json:"current_path"`
+	CurrentCommand string `json:"current_command"`
+	CommandLine    string `json:"command_line,omitempty"`    // Full command line of the foreground process
+	ScrollbackFile string `json:"scrollback_file,omitempty"` // Relative to the snapshot directory
+}
+
+// Options configures a Snapshotter
+type Options struct {
+	Interval        time.Duration // How often to snapshot, 0 disables periodic snapshots
+	Scrollback      bool          // Save pane contents
+	ScrollbackLines int           // History lines saved per pane
+	RestoreCommands []string      // Foreground programs that are restarted on restore
+}
+
+// Snapshotter periodically saves tmux session state and restores it
+type Snapshotter struct {
+	backend Backend
+	dir     string
+	opts    Options
+	mu      sync.Mutex // Serializes saves and restores
+}
+
+// NewSnapshotter creates a snapshotter storing snapshots in dir
+func NewSnapshotter(backend Backend, dir string, opts Options) *Snapshotter {
+	if opts.ScrollbackLines <= 0 {
+		opts.ScrollbackLines = 2000
+	}
+
+	return &Snapshotter{
+		backend: backend,
+		dir:     dir,
+		opts:    opts,
+	}
+}
+
+// Run saves a snapshot every interval until the process exits
+func (s *Snapshotter) Run() {
+	if s.opts.Interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		// Having no sessions is the normal idle state, not a failure
+		if _, err := s.Save(); err != nil && !errors.Is(err, ErrNoSessions) {
+			log.Printf("Failed to save session snapshot: %v", err)
+		}
+	}
+}
+
+// Save snapshots all sessions to disk
+// Sessions of the previous snapshot that aren't running are kept until they
+// are restored or dismissed, so a freshly restarted tmux server never
+// overwrites the state it should be restored from. Without any sessions the
+// previous snapshot is kept as it is.
+func (s *Snapshotter) Save() (*Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sessions, err := s.backend.ListSessions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	if len(sessions) == 0 {
+		return nil, ErrNoSessions
+	}
+
+	running := make(map[string]bool, len(sessions))
+	for _, session := range sessions {
+		running[session.Name] = true
+	}
+	var missing []SessionSnapshot
+	if previous, err := s.Load(); err == nil {
+		for _, ss := range previous.Sessions {
+			if !running[ss.Name] {
+				missing = append(missing, ss)
+			}
+		}
+	}
+
+	// Scrollback is written next to the current one and swapped in once the
+	// snapshot is complete; only the latest snapshot's scrollback is kept
+	scrollbackDir := filepath.Join(s.dir, "scrollback")
+	scrollbackTmp := scrollbackDir + ".tmp"
+	if s.opts.Scrollback {
+		os.RemoveAll(scrollbackTmp)
+		if err := os.MkdirAll(scrollbackTmp, 0700); err != nil {
+			return nil, fmt.Errorf("failed to create snapshot directory: %w", err)
+		}
+	}
+
+	snap := &Snapshot{
+		Version:   snapshotVersion,
+		CreatedAt: time.Now().UnixMilli(),
+		Sessions:  make([]SessionSnapshot, 0, len(sessions)),
+	}
+
+	for _, session := range sessions {
+		ss := SessionSnapshot{
+			Name:    session.Name,
+			Socket:  session.Socket,
+			Host:    session.Host,
+			Windows: make([]WindowSnapshot, 0, len(session.Windows)),
+		}
+
+		for _, window := range session.Windows {
+			panes, err := s.backend.ListPanes(session.Name, window.Index)
+			if err != nil {
+				log.Printf("Skipping window %s:%d in snapshot: %v", session.Name, window.Index, err)
+				continue
+			}
+
+			ws := WindowSnapshot{
+				Index:  window.Index,
+				Name:   window.Name,
+				Layout: window.Layout,
+				Active: window.Active,
+				Panes:  make([]PaneSnapshot, 0, len(panes)),
+			}
+
+			for _, pane := range panes {
+				ps := PaneSnapshot{
+					Index:          pane.Index,
+					Active:         pane.Active,
+					CurrentPath:    pane.CurrentPath,
+					CurrentCommand: pane.CurrentCommand,
+					CommandLine:    foregroundCommandLine(pane),
+				}
+
+				// Scrollback is replayed from a local file, which panes on
+				// remote hosts can't read
+				if s.opts.Scrollback && session.Host == "" {
+					content, err := s.backend.CapturePane(pane.ID, s.opts.ScrollbackLines)
+					if err == nil {
+						name := fmt.Sprintf("%s-%d-%d.txt", paths.SanitizeFileName(session.Name), window.Index, pane.Index)
+						if err := os.WriteFile(filepath.Join(scrollbackTmp, name), []byte(content), 0600); err == nil {
+							ps.ScrollbackFile = filepath.Join("scrollback", name)
+						}
+					}
+				}
+
+				ws.Panes = append(ws.Panes, ps)
+			}
+
+			ss.Windows = append(ss.Windows, ws)
+		}
+
+		snap.Sessions = append(snap.Sessions, ss)
+	}
+
+	// Missing sessions take their scrollback along into the new directory
+	for _, ss := range missing {
+		if s.opts.Scrollback {
+			for i := range ss.Windows {
+				for j := range ss.Windows[i].Panes {
+					pane := &ss.Windows[i].Panes[j]
+					if pane.ScrollbackFile == "" {
+						continue
+					}
+					name := filepath.Base(pane.ScrollbackFile)
+					if err := os.Rename(filepath.Join(s.dir, pane.ScrollbackFile), filepath.Join(scrollbackTmp, name)); err != nil {
+						pane.ScrollbackFile = ""
+					}
+				}
+			}
+		}
+		snap.Sessions = append(snap.Sessions, ss)
+	}
+
+	if err := s.write(snap); err != nil {
+		return nil, err
+	}
+
+	if s.opts.Scrollback {
+		os.RemoveAll(scrollbackDir)
+		if err := os.Rename(scrollbackTmp, scrollbackDir); err != nil {
+			return nil, fmt.Errorf("failed to write scrollback: %w", err)
+		}
+	}
+
+	return snap, nil
+}
+
+// Load reads the latest snapshot from disk
+func (s *Snapshotter) Load() (*Snapshot, error) {
+	data, err := os.ReadFile(s.snapshotPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNoSnapshot
+		}
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot: %w", err)
+	}
+	if snap.Version != snapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d", snap.Version)
+	}
+
+	return &snap, nil
+}
+
+// Dismiss drops sessions that aren't running from the latest snapshot, so
+// they are no longer kept for restoring; all of them if names is empty
+// Returns the names of the dropped sessions.
+func (s *Snapshotter) Dismiss(names []string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snap, err := s.Load()
+	if errors.Is(err, ErrNoSnapshot) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	running := make(map[string]bool)
+	if sessions, err := s.backend.ListSessions(); err == nil {
+		for _, session := range sessions {
+			running[session.Name] = true
+		}
+	}
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[name] = true
+	}
+
+	dismissed := make([]string, 0)
+	kept := make([]SessionSnapshot, 0, len(snap.Sessions))
+	for _, ss := range snap.Sessions {
+		if running[ss.Name] || (len(wanted) > 0 && !wanted[ss.Name]) {
+			kept = append(kept, ss)
+			continue
+		}
+		dismissed = append(dismissed, ss.Name)
+		for _, ws := range ss.Windows {
+			for _, pane := range ws.Panes {
+				if pane.ScrollbackFile != "" {
+					os.Remove(filepath.Join(s.dir, pane.ScrollbackFile))
+				}
+			}
+		}
+	}
+	if len(dismissed) == 0 {
+		return dismissed, nil
+	}
+
+	snap.Sessions = kept
+	if err := s.write(snap); err != nil {
+		return nil, err
+	}
+	return dismissed, nil
+}
+
+// write atomically replaces the latest snapshot file
+func (s *Snapshotter) write(snap *Snapshot) error {
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	tmp := s.snapshotPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := os.Rename(tmp, s.snapshotPath()); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return nil
+}
+
+// snapshotPath returns the path of the latest snapshot file
+func (s *Snapshotter) snapshotPath() string {
+	return filepath.Join(s.dir, "sessions.json")
+}
+
+// foregroundCommandLine returns the full command line of the most recently
+// started child of a pane's shell, or "" when the shell itself is in front
+func foregroundCommandLine(pane protocol.Pane) string {
+	commandLine := ""
+	for _, p := range pane.Processes {
+		// Children are listed in pid order, so the last one is the newest
+		if p.PPID == pane.PID {
+			commandLine = p.Args
+		}
+	}
+	return commandLine
+}
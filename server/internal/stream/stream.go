@@ -0,0 +1,221 @@
+package stream
+
+import (
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// captureLines is how much of each pane is captured per poll; output
+// exceeding it between two polls is only partially streamed
+const captureLines = 200
+
+// Terminal escape sequences: CSI, OSC and two-character escapes
+var escapeRegex = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(\x07|\x1b\\)|\x1b[@-Z\\-_]`)
+
+// Backend is the subset of the tmux manager used to stream pane output
+type Backend interface {
+	ListAllPanes() ([]protocol.PaneLocation, error)
+	CapturePane(paneID string, lines int) (string, error)
+}
+
+// Output is new output printed by a pane
+type Output struct {
+	protocol.PaneLocation
+	Lines []string // Plain text lines; a line that changed in place is repeated in full
+	Time  time.Time
+}
+
+// paneState is the last capture of a pane
+type paneState struct {
+	location protocol.PaneLocation
+	lines    []string
+}
+
+// Streamer polls every pane and turns screen changes into a stream of new
+// output lines, shared by the features that react to pane output
+type Streamer struct {
+	backend     Backend
+	interval    time.Duration
+	mu          sync.Mutex
+	panes       map[string]*paneState
+	subscribers []func(Output)
+}
+
+// NewStreamer creates a streamer polling panes every interval
+func NewStreamer(backend Backend, interval time.Duration) *Streamer {
+	if interval <= 0 {
+		interval = 500 * time.Millisecond
+	}
+
+	return &Streamer{
+		backend:  backend,
+		interval: interval,
+		panes:    make(map[string]*paneState),
+	}
+}
+
+// Subscribe registers fn to be called with new output
+// fn runs on the streamer goroutine and must not block.
+func (s *Streamer) Subscribe(fn func(Output)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.subscribers = append(s.subscribers, fn)
+}
+
+// Panes returns the panes currently being streamed
+func (s *Streamer) Panes() []protocol.PaneLocation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]protocol.PaneLocation, 0, len(s.panes))
+	for _, state := range s.panes {
+		result = append(result, state.location)
+	}
+	return result
+}
+
+// Screen returns at most n trailing lines of the last capture of a pane,
+// i.e. what it currently shows rather than what it printed
+func (s *Streamer) Screen(paneID string, n int) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.panes[paneID]
+	if !ok {
+		return nil
+	}
+	lines := state.lines
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return append([]string{}, lines...)
+}
+
+// Run polls panes every interval until the process exits
+func (s *Streamer) Run() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.poll()
+	}
+}
+
+// poll captures every pane once and publishes what is new
+func (s *Streamer) poll() {
+	locations, err := s.backend.ListAllPanes()
+	if err != nil {
+		log.Printf("Failed to list panes for output streaming: %v", err)
+		return
+	}
+
+	now := time.Now()
+	seen := make(map[string]bool, len(locations))
+	outputs := make([]Output, 0)
+
+	for _, location := range locations {
+		seen[location.PaneID] = true
+
+		content, err := s.backend.CapturePane(location.PaneID, captureLines)
+		if err != nil {
+			// The pane may have closed since it was listed
+			continue
+		}
+		lines := PlainLines(content)
+
+		s.mu.Lock()
+		state, ok := s.panes[location.PaneID]
+		if !ok {
+			// The first capture is the baseline, not new output
+			s.panes[location.PaneID] = &paneState{location: location, lines: lines}
+			s.mu.Unlock()
+			continue
+		}
+		added := newLines(state.lines, lines)
+		state.location = location
+		state.lines = lines
+		s.mu.Unlock()
+
+		if len(added) > 0 {
+			outputs = append(outputs, Output{PaneLocation: location, Lines: added, Time: now})
+		}
+	}
+
+	s.mu.Lock()
+	for id := range s.panes {
+		if !seen[id] {
+			delete(s.panes, id)
+		}
+	}
+	subscribers := s.subscribers
+	s.mu.Unlock()
+
+	for _, output := range outputs {
+		for _, fn := range subscribers {
+			fn(output)
+		}
+	}
+}
+
+// PlainLines converts a capture to plain text lines without trailing blank lines
+func PlainLines(content string) []string {
+	lines := strings.Split(escapeRegex.ReplaceAllString(content, ""), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \r")
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// newLines returns the lines of cur that were not in prev
+// cur is expected to be prev scrolled up by some lines with new lines
+// appended; the last line of prev may have changed in place, e.g. a prompt
+// being typed on or a progress bar. Without any overlap the screen was
+// cleared or redrawn and all of cur is new.
+func newLines(prev, cur []string) []string {
+	if equalLines(prev, cur) {
+		return nil
+	}
+	if len(prev) == 0 {
+		return cur
+	}
+
+	// Find the smallest scroll distance at which prev, except its last line,
+	// lines up with the start of cur
+	for d := 0; d < len(prev); d++ {
+		overlap := prev[d : len(prev)-1]
+		if len(overlap) == 0 || len(overlap) > len(cur) {
+			continue
+		}
+		if equalLines(overlap, cur[:len(overlap)]) {
+			added := cur[len(overlap):]
+			if len(added) > 1 && added[0] == prev[len(prev)-1] {
+				added = added[1:]
+			}
+			return added
+		}
+	}
+
+	return cur
+}
+
+// equalLines reports whether two line slices are identical
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -1,383 +1,637 @@
 package tmux
 
 import (
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
 	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/GianlucaP106/gotmux/gotmux"
+	"github.com/myan/handx-server/internal/environ"
+	"github.com/myan/handx-server/internal/procinfo"
 	"github.com/myan/handx-server/pkg/protocol"
 )
 
+// Text longer than pasteThreshold bytes, or with newlines, is pasted
+// through a buffer rather than typed with send-keys
+const (
+	pasteThreshold  = 1024
+	pasteChunkSize  = 16 << 10
+	pasteChunkDelay = 20 * time.Millisecond
+)
+
+// ErrNotInstalled is returned by NewManager when tmux is needed locally but
+// not installed
+var ErrNotInstalled = errors.New("tmux is not installed on the system")
+
 // ANSI escape code regex
 var ansiEscapeRegex = regexp.MustCompile(`\x1b\[[0-9;]*[a-zA-Z]`)
 
+// Options configures the tmux manager
+type Options struct {
+	HistoryLines int      // Number of history lines to capture
+	Sockets      []string // Servers besides the default one, as -L names or -S paths
+	Discover     bool     // Also manage the servers of other sockets in the socket directory
+	// Hosts whose default servers are managed over SSH
+	Hosts []Runner
+	// RemoteOnly manages the servers of Hosts only, so tmux need not be
+	// installed locally; sessions are created on the first host by default
+	RemoteOnly bool
+}
+
 // Manager manages tmux sessions
+// Sessions may live on several tmux servers: the default one, configured
+// sockets, remote hosts and, with discovery, any other socket of the user.
+// Session names are unique across servers.
 type Manager struct {
-	tmux         *gotmux.Tmux
-	historyLines int // Number of history lines to capture
+	defaultServer *server
+	configured    []*server
+	discover      bool
+	historyLines  int // Number of history lines to capture
+
+	mu    sync.Mutex
+	bells map[string]map[string]bool // Windows whose bell flag was last seen set, per server
 }
 
 // NewManager creates a new tmux manager
-func NewManager(historyLines int) (*Manager, error) {
-	tmux, err := gotmux.DefaultTmux()
-	if err != nil {
-		return nil, fmt.Errorf("failed to initialize tmux: %w", err)
+func NewManager(opts Options) (*Manager, error) {
+	if opts.RemoteOnly {
+		if len(opts.Hosts) == 0 {
+			return nil, fmt.Errorf("failed to initialize tmux: no remote hosts configured")
+		}
+	} else if !gotmux.IsInstalled() {
+		return nil, fmt.Errorf("failed to initialize tmux: %w", ErrNotInstalled)
 	}
 
+	historyLines := opts.HistoryLines
 	if historyLines <= 0 {
 		historyLines = 10000 // Default to 10000 lines
 	}
 
-	return &Manager{
-		tmux:         tmux,
-		historyLines: historyLines,
-	}, nil
+	m := &Manager{historyLines: historyLines, bells: make(map[string]map[string]bool)}
+	if !opts.RemoteOnly {
+		m.defaultServer = newServer("", "")
+		m.discover = opts.Discover
+		for _, socket := range opts.Sockets {
+			m.configured = append(m.configured, newServer(socket, socketPath(socket)))
+		}
+	}
+	for _, host := range opts.Hosts {
+		m.configured = append(m.configured, newHostServer(host))
+	}
+
+	// Socket and host names qualify pane IDs and pick servers
+	names := make(map[string]bool)
+	for _, srv := range m.configured {
+		if srv.name == "" || strings.Contains(srv.name, "@") || names[srv.name] {
+			return nil, fmt.Errorf("invalid or duplicate tmux socket or host '%s'", srv.name)
+		}
+		names[srv.name] = true
+	}
+
+	if opts.RemoteOnly {
+		m.defaultServer, m.configured = m.configured[0], m.configured[1:]
+	}
+	return m, nil
 }
 
-// ListSessions returns all tmux sessions
+// sessionFormat is the list-sessions format, one tab separated field per value
+// In a session context window_width/window_height refer to its current window
+var sessionFormat = strings.Join([]string{
+	"#{session_name}",
+	"#{session_created}",
+	"#{session_activity}",
+	"#{session_attached}",
+	"#{session_last_attached}",
+	"#{session_windows}",
+	"#{window_width}",
+	"#{window_height}",
+	"#{session_group}",
+	"#{session_group_size}",
+}, "\t")
+
+// windowFormat is the list-windows format used to list windows of all sessions
+var windowFormat = strings.Join([]string{
+	"#{session_name}",
+	"#{window_index}",
+	"#{window_name}",
+	"#{window_active}",
+	"#{window_layout}",
+}, "\t")
+
+// paneFormat is the list-panes format, also used to describe a single pane
+var paneFormat = strings.Join([]string{
+	"#{session_name}",
+	"#{window_index}",
+	"#{pane_id}",
+	"#{pane_index}",
+	"#{pane_active}",
+	"#{pane_width}",
+	"#{pane_height}",
+	"#{pane_current_command}",
+	"#{pane_current_path}",
+	"#{pane_pid}",
+}, "\t")
+
+// ListSessions returns the sessions of all tmux servers
 func (m *Manager) ListSessions() ([]protocol.Session, error) {
-	sessions, err := m.tmux.ListSessions()
+	result := make([]protocol.Session, 0)
+	for _, srv := range m.servers() {
+		result = append(result, m.listSessions(srv)...)
+	}
+	return result, nil
+}
+
+// listSessions returns the sessions of one tmux server
+func (m *Manager) listSessions(srv *server) []protocol.Session {
+	output, err := srv.command("list-sessions", "-F", sessionFormat).Output()
 	if err != nil {
-		// If no sessions exist, return empty list instead of error
-		return []protocol.Session{}, nil
+		// No server or no sessions
+		return nil
 	}
 
-	result := make([]protocol.Session, 0, len(sessions))
-	for _, s := range sessions {
-		windows, err := m.getSessionWindows(s)
-		if err != nil {
+	windows := m.listAllWindows(srv)
+
+	result := make([]protocol.Session, 0)
+	for _, line := range strings.Split(strings.TrimRight(string(output), "\n"), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 10 {
 			continue
 		}
 
+		attachedClients, _ := strconv.Atoi(fields[3])
+		windowCount, _ := strconv.Atoi(fields[5])
+		width, _ := strconv.Atoi(fields[6])
+		height, _ := strconv.Atoi(fields[7])
+		groupSize, _ := strconv.Atoi(fields[9])
+
+		sessionWindows := windows[fields[0]]
+		if sessionWindows == nil {
+			sessionWindows = []protocol.Window{}
+		}
+
 		result = append(result, protocol.Session{
-			ID:        fmt.Sprintf("session-%s", s.Name),
-			Name:      s.Name,
-			Windows:   windows,
-			CreatedAt: time.Now().UnixMilli(), // gotmux doesn't provide creation time
-			Attached:  s.Attached > 0,
+			ID:              fmt.Sprintf("session-%s", fields[0]),
+			Name:            fields[0],
+			Windows:         sessionWindows,
+			CreatedAt:       unixToMilli(fields[1]),
+			Attached:        attachedClients > 0,
+			AttachedClients: attachedClients,
+			LastActivity:    unixToMilli(fields[2]),
+			LastAttached:    unixToMilli(fields[4]),
+			WindowCount:     windowCount,
+			Width:           width,
+			Height:          height,
+			Group:           fields[8],
+			GroupSize:       groupSize,
+			Socket:          srv.socket(),
+			Host:            srv.hostName(),
 		})
 	}
 
-	return result, nil
+	return result
 }
 
-// getSessionWindows returns windows for a session
-func (m *Manager) getSessionWindows(session *gotmux.Session) ([]protocol.Window, error) {
-	windows, err := session.ListWindows()
+// listAllWindows returns the windows of every session of a server keyed by session name
+func (m *Manager) listAllWindows(srv *server) map[string][]protocol.Window {
+	result := make(map[string][]protocol.Window)
+
+	cmd := srv.command("list-windows", "-a", "-F", windowFormat)
+	output, err := cmd.Output()
 	if err != nil {
-		return []protocol.Window{}, nil
+		return result
 	}
 
-	result := make([]protocol.Window, 0, len(windows))
-	for _, w := range windows {
-		result = append(result, protocol.Window{
-			ID:     fmt.Sprintf("window-%s-%d", session.Name, w.Index),
-			Name:   w.Name,
-			Index:  w.Index,
-			Active: w.Active,
-			PaneID: fmt.Sprintf("%d", w.Index),
+	panes := m.listAllPanes(srv)
+
+	for _, line := range strings.Split(strings.TrimRight(string(output), "\n"), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 5 {
+			continue
+		}
+
+		index, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+
+		result[fields[0]] = append(result[fields[0]], protocol.Window{
+			ID:     fmt.Sprintf("window-%s-%d", fields[0], index),
+			Name:   fields[2],
+			Index:  index,
+			Active: fields[3] == "1",
+			PaneID: fmt.Sprintf("%d", index),
+			Layout: fields[4],
+			Panes:  panes[paneWindowKey(fields[0], index)],
 		})
 	}
 
-	return result, nil
+	return result
 }
 
-// CreateSession creates a new tmux session
-func (m *Manager) CreateSession(name string) (*protocol.Session, error) {
-	// Check if session already exists
-	existingSessions, _ := m.tmux.ListSessions()
-	for _, s := range existingSessions {
-		if s.Name == name {
-			return nil, fmt.Errorf("session '%s' already exists", name)
-		}
-	}
+// listAllPanes returns the panes of every window of a server keyed by paneWindowKey
+func (m *Manager) listAllPanes(srv *server) map[string][]protocol.Pane {
+	result := make(map[string][]protocol.Pane)
 
-	// Create new session (detached by default)
-	session, err := m.tmux.NewSession(&gotmux.SessionOptions{
-		Name: name,
-	})
+	output, err := srv.command("list-panes", "-a", "-F", paneFormat).Output()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create session: %w", err)
+		return result
 	}
 
-	windows, _ := m.getSessionWindows(session)
+	// One process table snapshot serves all panes
+	table := srv.processes()
 
-	return &protocol.Session{
-		ID:        fmt.Sprintf("session-%s", name),
-		Name:      name,
-		Windows:   windows,
-		CreatedAt: time.Now().UnixMilli(),
-		Attached:  false,
-	}, nil
+	for _, line := range strings.Split(strings.TrimRight(string(output), "\n"), "\n") {
+		sessionName, windowIndex, pane, ok := parsePane(line, table)
+		if !ok {
+			continue
+		}
+		pane.ID = srv.paneID(pane.ID)
+		pane.Host = srv.hostName()
+		key := paneWindowKey(sessionName, windowIndex)
+		result[key] = append(result[key], pane)
+	}
+
+	return result
 }
 
-// AttachSession attaches to a session
-func (m *Manager) AttachSession(name string) error {
-	session, err := m.getSessionByName(name)
-	if err != nil {
-		return err
+// ListAllPanes returns the location of every pane in every session
+func (m *Manager) ListAllPanes() ([]protocol.PaneLocation, error) {
+	result := make([]protocol.PaneLocation, 0)
+	for _, srv := range m.servers() {
+		output, err := srv.command("list-panes", "-a", "-F", paneFormat).Output()
+		if err != nil {
+			// No tmux server means no panes
+			continue
+		}
+
+		for _, line := range strings.Split(strings.TrimRight(string(output), "\n"), "\n") {
+			sessionName, windowIndex, pane, ok := parsePane(line, nil)
+			if !ok {
+				continue
+			}
+			result = append(result, protocol.PaneLocation{
+				SessionName: sessionName,
+				WindowIndex: windowIndex,
+				PaneIndex:   pane.Index,
+				PaneID:      srv.paneID(pane.ID),
+			})
+		}
 	}
 
-	return session.Attach()
+	return result, nil
 }
 
-// KillSession kills a tmux session
-func (m *Manager) KillSession(name string) error {
-	session, err := m.getSessionByName(name)
-	if err != nil {
-		return err
+// bellFormat lists windows with their bell flag and active pane
+var bellFormat = strings.Join([]string{
+	"#{window_bell_flag}",
+	"#{session_name}",
+	"#{window_index}",
+	"#{pane_index}",
+	"#{pane_id}",
+}, "\t")
+
+// TakeBells returns the active pane of every window that rang the bell since
+// the last call
+// tmux only records which window rang, not which pane, and keeps the flag
+// until the window is shown, so a bell is reported when the flag becomes set.
+// The flags are left alone since clients show them in their status lines.
+func (m *Manager) TakeBells() ([]protocol.PaneLocation, error) {
+	result := make([]protocol.PaneLocation, 0)
+	for _, srv := range m.servers() {
+		bells, err := m.takeBells(srv)
+		result = append(result, bells...)
+		if err != nil {
+			return result, err
+		}
 	}
-
-	return session.Kill()
+	return result, nil
 }
 
-// RenameSession renames a tmux session
-func (m *Manager) RenameSession(oldName, newName string) error {
-	// Check if old session exists
-	_, err := m.getSessionByName(oldName)
+// takeBells takes the bells of one server, see TakeBells
+func (m *Manager) takeBells(srv *server) ([]protocol.PaneLocation, error) {
+	output, err := srv.command("list-windows", "-a", "-F", bellFormat).Output()
 	if err != nil {
-		return fmt.Errorf("session '%s' not found", oldName)
+		return nil, nil
 	}
 
-	// Check if new name already exists
-	sessions, _ := m.tmux.ListSessions()
-	for _, s := range sessions {
-		if s.Name == newName {
-			return fmt.Errorf("session '%s' already exists", newName)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make([]protocol.PaneLocation, 0)
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(strings.TrimRight(string(output), "\n"), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 5 || fields[0] != "1" {
+			continue
 		}
+
+		windowIndex, _ := strconv.Atoi(fields[2])
+		key := paneWindowKey(fields[1], windowIndex)
+		seen[key] = true
+		if m.bells[srv.name][key] {
+			continue
+		}
+
+		paneIndex, _ := strconv.Atoi(fields[3])
+		result = append(result, protocol.PaneLocation{
+			SessionName: fields[1],
+			WindowIndex: windowIndex,
+			PaneIndex:   paneIndex,
+			PaneID:      srv.paneID(fields[4]),
+		})
 	}
+	m.bells[srv.name] = seen
 
-	// Use tmux rename-session command
-	cmd := exec.Command("tmux", "rename-session", "-t", oldName, newName)
-	output, err := cmd.CombinedOutput()
+	return result, nil
+}
+
+// paneWindowKey identifies a window when grouping panes
+func paneWindowKey(sessionName string, windowIndex int) string {
+	return fmt.Sprintf("%s\t%d", sessionName, windowIndex)
+}
+
+// parsePane parses one line of paneFormat output
+// The pane's process tree is attached when a process table is given.
+func parsePane(line string, table *procinfo.Table) (string, int, protocol.Pane, bool) {
+	fields := strings.Split(line, "\t")
+	if len(fields) != 10 {
+		return "", 0, protocol.Pane{}, false
+	}
+
+	windowIndex, err := strconv.Atoi(fields[1])
 	if err != nil {
-		return fmt.Errorf("failed to rename session: %s", string(output))
+		return "", 0, protocol.Pane{}, false
 	}
-	return nil
+	index, _ := strconv.Atoi(fields[3])
+	width, _ := strconv.Atoi(fields[5])
+	height, _ := strconv.Atoi(fields[6])
+	pid, _ := strconv.Atoi(fields[9])
+
+	pane := protocol.Pane{
+		ID:             fields[2],
+		Index:          index,
+		Active:         fields[4] == "1",
+		Width:          width,
+		Height:         height,
+		CurrentCommand: fields[7],
+		CurrentPath:    fields[8],
+		PID:            pid,
+	}
+	if table != nil && pid > 0 {
+		pane.Processes = table.Tree(pid)
+	}
+
+	return fields[0], windowIndex, pane, true
 }
 
-// getSessionByName finds a session by name
-func (m *Manager) getSessionByName(name string) (*gotmux.Session, error) {
-	sessions, err := m.tmux.ListSessions()
+// getSessionInfo returns the metadata of a single session
+func (m *Manager) getSessionInfo(name string) (*protocol.Session, error) {
+	sessions, err := m.ListSessions()
 	if err != nil {
-		return nil, fmt.Errorf("failed to list sessions: %w", err)
+		return nil, err
 	}
 
 	for _, s := range sessions {
 		if s.Name == name {
-			return s, nil
+			return &s, nil
 		}
 	}
 
 	return nil, fmt.Errorf("session '%s' not found", name)
 }
 
-// ExecuteCommand executes a command in a session
-// If windowIndex is provided, executes in that window; otherwise executes in active window
-func (m *Manager) ExecuteCommand(sessionName, command string, windowIndex *int) error {
-	session, err := m.getSessionByName(sessionName)
+// unixToMilli converts a tmux unix timestamp in seconds to milliseconds
+// Returns 0 for empty or invalid values
+func unixToMilli(value string) int64 {
+	seconds, err := strconv.ParseInt(value, 10, 64)
 	if err != nil {
-		return err
+		return 0
 	}
+	return seconds * 1000
+}
 
-	var activePane *gotmux.Pane
-
-	// If window index is specified, get pane from that window
-	if windowIndex != nil {
-		windows, err := session.ListWindows()
-		if err != nil {
-			return fmt.Errorf("failed to list windows: %w", err)
-		}
+// CreateSession creates a new tmux session
+// opts may be nil to create a session with tmux defaults
+func (m *Manager) CreateSession(name string, opts *protocol.SessionOptions) (*protocol.Session, error) {
+	// Check if session already exists, on any server
+	if _, err := m.getSessionByName(name); err == nil {
+		return nil, fmt.Errorf("session '%s' already exists", name)
+	}
 
-		var targetWindow *gotmux.Window
-		for _, w := range windows {
-			if w.Index == *windowIndex {
-				targetWindow = w
-				break
-			}
-		}
+	srv, err := m.createServer(opts)
+	if err != nil {
+		return nil, err
+	}
 
-		if targetWindow == nil {
-			return fmt.Errorf("window index %d not found in session '%s'", *windowIndex, sessionName)
+	// Create new session (detached by default)
+	args := []string{"new-session", "-d", "-s", name}
+	if opts != nil {
+		if opts.StartDirectory != "" {
+			args = append(args, "-c", opts.StartDirectory)
 		}
-
-		// Get active pane from target window
-		panes, err := targetWindow.ListPanes()
-		if err != nil {
-			return fmt.Errorf("failed to list panes: %w", err)
+		if opts.WindowName != "" {
+			args = append(args, "-n", opts.WindowName)
 		}
-
-		if len(panes) == 0 {
-			return fmt.Errorf("no panes found in window %d", *windowIndex)
+		if opts.Width > 0 {
+			args = append(args, "-x", strconv.Itoa(opts.Width))
 		}
-
-		// Find active pane in window
-		for _, p := range panes {
-			if p.Active {
-				activePane = p
-				break
-			}
+		if opts.Height > 0 {
+			args = append(args, "-y", strconv.Itoa(opts.Height))
 		}
 
-		if activePane == nil {
-			activePane = panes[0]
-		}
-	} else {
-		// Get the active pane from session (original behavior)
-		panes, err := session.ListPanes()
+		envArgs, err := environmentArgs(opts.Environment)
 		if err != nil {
-			return fmt.Errorf("failed to list panes: %w", err)
+			return nil, err
 		}
+		args = append(args, envArgs...)
 
-		if len(panes) == 0 {
-			return fmt.Errorf("no panes found in session")
+		// The command must come last, tmux runs it through the default shell
+		if opts.Command != "" {
+			args = append(args, opts.Command)
 		}
+	}
 
-		// Find active pane
-		for _, p := range panes {
-			if p.Active {
-				activePane = p
-				break
-			}
-		}
+	cmd := srv.command(args...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %s", strings.TrimSpace(string(output)))
+	}
 
-		if activePane == nil {
-			// If no active pane, use the first one
-			activePane = panes[0]
-		}
+	return m.getSessionInfo(name)
+}
+
+// environmentArgs converts an environment map into tmux -e flags
+func environmentArgs(env map[string]string) ([]string, error) {
+	pairs, err := environ.Pairs(env)
+	if err != nil {
+		return nil, err
 	}
 
-	// Handle special keys (send directly without -l flag and without Enter)
-	if command == "Escape" || command == "Enter" || command == "Tab" {
-		cmd := exec.Command("tmux", "send-keys", "-t", activePane.Id, command)
-		return cmd.Run()
+	args := make([]string, 0, len(pairs)*2)
+	for _, kv := range pairs {
+		args = append(args, "-e", kv)
 	}
+	return args, nil
+}
 
-	// Use tmux send-keys directly with C-m (Ctrl+M = Enter) to ensure command execution
-	// First send the command text in literal mode, then send C-m (Enter)
-	cmd1 := exec.Command("tmux", "send-keys", "-t", activePane.Id, "-l", command)
-	if err := cmd1.Run(); err != nil {
+// AttachSession attaches the terminal handx runs in to a local session
+func (m *Manager) AttachSession(name string) error {
+	srv, err := m.getSessionByName(name)
+	if err != nil {
 		return err
 	}
-	// Send C-m (Ctrl+M = Enter key)
-	cmd2 := exec.Command("tmux", "send-keys", "-t", activePane.Id, "C-m")
-	return cmd2.Run()
+	if srv.host != nil {
+		return fmt.Errorf("session '%s' is on remote host '%s'", name, srv.name)
+	}
+
+	args := []string{"attach-session", "-t", name}
+	if srv.path != "" {
+		args = append([]string{"-S", srv.path}, args...)
+	}
+	cmd := exec.Command("tmux", args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd.Run()
 }
 
-// SendText sends text to a session without executing (no Enter key)
-func (m *Manager) SendText(sessionName, text string) error {
-	session, err := m.getSessionByName(sessionName)
+// KillSession kills a tmux session
+func (m *Manager) KillSession(name string) error {
+	srv, err := m.getSessionByName(name)
 	if err != nil {
 		return err
 	}
 
-	// Get the active pane
-	panes, err := session.ListPanes()
+	output, err := srv.command("kill-session", "-t", name).CombinedOutput()
 	if err != nil {
-		return fmt.Errorf("failed to list panes: %w", err)
+		return fmt.Errorf("failed to kill session: %s", strings.TrimSpace(string(output)))
 	}
+	return nil
+}
 
-	if len(panes) == 0 {
-		return fmt.Errorf("no panes found in session")
+// RenameSession renames a tmux session
+func (m *Manager) RenameSession(oldName, newName string) error {
+	// Check if old session exists
+	srv, err := m.getSessionByName(oldName)
+	if err != nil {
+		return fmt.Errorf("session '%s' not found", oldName)
 	}
 
-	// Find active pane
-	var activePane *gotmux.Pane
-	for _, p := range panes {
-		if p.Active {
-			activePane = p
-			break
-		}
+	// Check if new name already exists, on any server
+	if _, err := m.getSessionByName(newName); err == nil {
+		return fmt.Errorf("session '%s' already exists", newName)
 	}
 
-	if activePane == nil {
-		activePane = panes[0]
+	// Use tmux rename-session command
+	cmd := srv.command("rename-session", "-t", oldName, newName)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to rename session: %s", string(output))
 	}
-
-	// Send text without Enter using tmux send-keys -l (literal mode)
-	// This will type the text but not execute it
-	cmd := exec.Command("tmux", "send-keys", "-t", activePane.Id, "-l", text)
-	return cmd.Run()
+	return nil
 }
 
-// stripANSI removes ANSI escape codes from string
-func stripANSI(str string) string {
-	return ansiEscapeRegex.ReplaceAllString(str, "")
+// getSessionByName finds the server running a session
+func (m *Manager) getSessionByName(name string) (*server, error) {
+	for _, srv := range m.servers() {
+		// Servers that aren't running or reachable have no sessions
+		if srv.command("has-session", "-t", "="+name).Run() == nil {
+			return srv, nil
+		}
+	}
+
+	return nil, fmt.Errorf("session '%s' not found", name)
 }
 
-// CaptureOutput captures the output of a session's pane
-// If windowIndex is provided, captures from that window; otherwise captures from active window
-func (m *Manager) CaptureOutput(sessionName string, windowIndex *int) (string, error) {
-	session, err := m.getSessionByName(sessionName)
+// activePane returns the server of a session and the tmux ID of the active
+// pane of a window, or of the active window for a nil index
+func (m *Manager) activePane(sessionName string, windowIndex *int) (*server, string, error) {
+	srv, err := m.getSessionByName(sessionName)
 	if err != nil {
-		return "", err
+		return nil, "", err
 	}
 
-	// Get windows
-	windows, err := session.ListWindows()
+	// display-message falls back to the current pane for a missing window,
+	// so look the window up among the session's; = matches the name exactly
+	output, err := srv.command("list-windows", "-t", "="+sessionName, "-F", "#{window_index}\t#{window_active}\t#{pane_id}").Output()
 	if err != nil {
-		return "", err
+		return nil, "", fmt.Errorf("session '%s' not found", sessionName)
 	}
-
-	var targetWindow *gotmux.Window
-
-	// If window index is specified, find that window
-	if windowIndex != nil {
-		for _, w := range windows {
-			if w.Index == *windowIndex {
-				targetWindow = w
-				break
-			}
-		}
-		if targetWindow == nil {
-			return "", fmt.Errorf("window index %d not found in session '%s'", *windowIndex, sessionName)
+	id := ""
+	for _, line := range strings.Split(strings.TrimRight(string(output), "\n"), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 {
+			continue
 		}
-	} else {
-		// Otherwise, use active window
-		for _, w := range windows {
-			if w.Active {
-				targetWindow = w
-				break
-			}
+		if windowIndex == nil && fields[1] == "1" || windowIndex != nil && fields[0] == strconv.Itoa(*windowIndex) {
+			id = fields[2]
+			break
 		}
-		if targetWindow == nil {
-			return "", fmt.Errorf("no active window found")
+	}
+	if id == "" {
+		if windowIndex != nil {
+			return nil, "", fmt.Errorf("window index %d not found in session '%s'", *windowIndex, sessionName)
 		}
+		return nil, "", fmt.Errorf("no panes found in session")
 	}
+	return srv, id, nil
+}
 
-	// Get active pane from target window
-	panes, err := targetWindow.ListPanes()
+// ExecuteCommand executes a command in a session
+// If windowIndex is provided, executes in that window; otherwise executes in active window
+func (m *Manager) ExecuteCommand(sessionName, command string, windowIndex *int) error {
+	srv, paneID, err := m.activePane(sessionName, windowIndex)
 	if err != nil {
-		return "", err
+		return err
 	}
 
-	var activePane *gotmux.Pane
-	for _, p := range panes {
-		if p.Active {
-			activePane = p
-			break
-		}
+	// Handle special keys (send directly without -l flag and without Enter)
+	if command == "Escape" || command == "Enter" || command == "Tab" {
+		cmd := srv.command("send-keys", "-t", paneID, command)
+		return cmd.Run()
 	}
 
-	if activePane == nil {
-		return "", fmt.Errorf("no active pane found in target window")
+	// Type the command, pasting multi-line and long ones, then press Enter
+	return m.SendToPane(srv.paneID(paneID), command, true)
+}
+
+// SendText sends text to a session without executing (no Enter key)
+func (m *Manager) SendText(sessionName, text string) error {
+	srv, paneID, err := m.activePane(sessionName, nil)
+	if err != nil {
+		return err
+	}
+
+	// Type the text without Enter, so it is not executed
+	return m.SendToPane(srv.paneID(paneID), text, false)
+}
+
+// stripANSI removes ANSI escape codes from string
+func stripANSI(str string) string {
+	return ansiEscapeRegex.ReplaceAllString(str, "")
+}
+
+// CaptureOutput captures the output of a session's pane
+// If windowIndex is provided, captures from that window; otherwise captures from active window
+func (m *Manager) CaptureOutput(sessionName string, windowIndex *int) (string, error) {
+	srv, paneID, err := m.activePane(sessionName, windowIndex)
+	if err != nil {
+		return "", err
 	}
 
 	// Capture pane content with full history using direct tmux command
 	// -p: print to stdout
 	// -e: include escape sequences (ANSI colors)
 	// -S -N: start from N lines back in history
-	cmd := exec.Command("tmux", "capture-pane", "-t", activePane.Id, "-p", "-e", "-S", fmt.Sprintf("-%d", m.historyLines))
+	cmd := srv.command("capture-pane", "-t", paneID, "-p", "-e", "-S", fmt.Sprintf("-%d", m.historyLines))
 	output, err := cmd.Output()
 	if err != nil {
-		// Fallback to gotmux's Capture if direct command fails
-		content, err := activePane.Capture()
-		if err != nil {
-			return "", err
-		}
-		return content, nil
+		return "", fmt.Errorf("failed to capture pane %s: %w", paneID, err)
 	}
 
 	return string(output), nil
@@ -385,116 +639,119 @@ func (m *Manager) CaptureOutput(sessionName string, windowIndex *int) (string, e
 
 // ListWindows lists windows in a session
 func (m *Manager) ListWindows(sessionName string) ([]protocol.Window, error) {
-	session, err := m.getSessionByName(sessionName)
+	srv, err := m.getSessionByName(sessionName)
 	if err != nil {
 		return nil, err
 	}
 
-	return m.getSessionWindows(session)
+	windows := m.listAllWindows(srv)[sessionName]
+	if windows == nil {
+		windows = []protocol.Window{}
+	}
+	return windows, nil
 }
 
 // SwitchWindow switches to a specific window in a session
 func (m *Manager) SwitchWindow(sessionName string, windowIndex int) (string, error) {
-	session, err := m.getSessionByName(sessionName)
+	srv, err := m.getSessionByName(sessionName)
 	if err != nil {
 		return "", err
 	}
 
-	windows, err := session.ListWindows()
-	if err != nil {
-		return "", fmt.Errorf("failed to list windows: %w", err)
-	}
-
 	// Find window by index
-	var targetWindow *gotmux.Window
-	for _, w := range windows {
-		if w.Index == windowIndex {
-			targetWindow = w
-			break
-		}
-	}
-
-	if targetWindow == nil {
+	target := fmt.Sprintf("%s:%d", sessionName, windowIndex)
+	name, err := srv.command("display-message", "-p", "-t", target, "#{window_name}").Output()
+	if err != nil {
 		return "", fmt.Errorf("window index %d not found in session '%s'", windowIndex, sessionName)
 	}
 
 	// Use tmux select-window command to switch
-	cmd := exec.Command("tmux", "select-window", "-t", fmt.Sprintf("%s:%d", sessionName, windowIndex))
+	cmd := srv.command("select-window", "-t", target)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return "", fmt.Errorf("failed to switch window: %s", string(output))
 	}
 
-	return targetWindow.Name, nil
+	return strings.TrimSuffix(string(name), "\n"), nil
 }
 
 // CreateWindow creates a new window in a session
-func (m *Manager) CreateWindow(sessionName, windowName string) (*protocol.Window, error) {
-	session, err := m.getSessionByName(sessionName)
+// opts may be nil to create a window with tmux defaults
+func (m *Manager) CreateWindow(sessionName, windowName string, opts *protocol.WindowOptions) (*protocol.Window, error) {
+	srv, err := m.getSessionByName(sessionName)
 	if err != nil {
 		return nil, err
 	}
 
 	// If windowName is empty, tmux will auto-generate a name
-	args := []string{"new-window", "-t", sessionName, "-P", "-F", "#{window_index}"}
+	args := []string{"new-window", "-t", sessionName, "-P", "-F", "#{window_index}\t#{window_active}\t#{window_name}"}
 	if windowName != "" {
 		args = append(args, "-n", windowName)
 	}
+	if opts != nil {
+		if opts.StartDirectory != "" {
+			args = append(args, "-c", opts.StartDirectory)
+		}
 
-	cmd := exec.Command("tmux", args...)
+		envArgs, err := environmentArgs(opts.Environment)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, envArgs...)
+
+		if opts.Command != "" {
+			args = append(args, opts.Command)
+		}
+	}
+
+	cmd := srv.command(args...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create window: %s", string(output))
 	}
 
-	// Get the newly created window index
-	windowIndex := 0
-	fmt.Sscanf(string(output), "%d", &windowIndex)
-
-	// Refresh windows list to get the new window
-	windows, err := session.ListWindows()
-	if err != nil {
-		return nil, fmt.Errorf("failed to list windows after creation: %w", err)
+	// The new window is described by -P
+	fields := strings.SplitN(strings.TrimSuffix(string(output), "\n"), "\t", 3)
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("failed to find newly created window")
 	}
-
-	// Find the newly created window
-	for _, w := range windows {
-		if w.Index == windowIndex {
-			return &protocol.Window{
-				ID:      fmt.Sprintf("window-%s-%d", sessionName, w.Index),
-				Name:    w.Name,
-				Index:   w.Index,
-				Active:  w.Active,
-				PaneID:  fmt.Sprintf("%%pane-%d", w.Index),
-			}, nil
-		}
+	windowIndex, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to find newly created window")
 	}
 
-	return nil, fmt.Errorf("failed to find newly created window")
+	return &protocol.Window{
+		ID:     fmt.Sprintf("window-%s-%d", sessionName, windowIndex),
+		Name:   fields[2],
+		Index:  windowIndex,
+		Active: fields[1] == "1",
+		PaneID: fmt.Sprintf("%%pane-%d", windowIndex),
+	}, nil
 }
 
 // CloseWindow closes a window in a session
 func (m *Manager) CloseWindow(sessionName string, windowIndex int) error {
-	session, err := m.getSessionByName(sessionName)
+	srv, err := m.getSessionByName(sessionName)
 	if err != nil {
 		return err
 	}
 
-	windows, err := session.ListWindows()
+	output, err := srv.command("list-windows", "-t", sessionName, "-F", "#{window_index}").Output()
 	if err != nil {
 		return fmt.Errorf("failed to list windows: %w", err)
 	}
+	windows := strings.Fields(string(output))
 
 	// Check if window exists
-	var targetWindow *gotmux.Window
-	for _, w := range windows {
-		if w.Index == windowIndex {
-			targetWindow = w
+	found := false
+	for _, index := range windows {
+		if index == strconv.Itoa(windowIndex) {
+			found = true
 			break
 		}
 	}
 
-	if targetWindow == nil {
+	if !found {
 		return fmt.Errorf("window index %d not found in session '%s'", windowIndex, sessionName)
 	}
 
@@ -504,11 +761,429 @@ func (m *Manager) CloseWindow(sessionName string, windowIndex int) error {
 	}
 
 	// Use tmux kill-window command
-	cmd := exec.Command("tmux", "kill-window", "-t", fmt.Sprintf("%s:%d", sessionName, windowIndex))
-	output, err := cmd.CombinedOutput()
+	cmd := srv.command("kill-window", "-t", fmt.Sprintf("%s:%d", sessionName, windowIndex))
+	output, err = cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("failed to close window: %s", string(output))
 	}
 
 	return nil
 }
+
+// SplitWindow splits the active pane of a window, the new pane becomes active
+// horizontal splits side by side (left/right); otherwise panes are stacked (top/bottom)
+func (m *Manager) SplitWindow(sessionName string, windowIndex int, horizontal bool, opts *protocol.WindowOptions) error {
+	srv, err := m.getSessionByName(sessionName)
+	if err != nil {
+		return err
+	}
+
+	args := []string{"split-window", "-t", fmt.Sprintf("%s:%d", sessionName, windowIndex)}
+	if horizontal {
+		args = append(args, "-h")
+	} else {
+		args = append(args, "-v")
+	}
+	if opts != nil {
+		if opts.StartDirectory != "" {
+			args = append(args, "-c", opts.StartDirectory)
+		}
+
+		envArgs, err := environmentArgs(opts.Environment)
+		if err != nil {
+			return err
+		}
+		args = append(args, envArgs...)
+
+		if opts.Command != "" {
+			args = append(args, opts.Command)
+		}
+	}
+
+	cmd := srv.command(args...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to split window: %s", strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// SelectLayout applies a layout to a window
+// layout is a preset (even-horizontal, even-vertical, main-horizontal, main-vertical, tiled)
+// or a custom layout string as printed by #{window_layout}
+func (m *Manager) SelectLayout(sessionName string, windowIndex int, layout string) error {
+	srv, err := m.getSessionByName(sessionName)
+	if err != nil {
+		return err
+	}
+
+	cmd := srv.command("select-layout", "-t", fmt.Sprintf("%s:%d", sessionName, windowIndex), layout)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to select layout: %s", strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// ListPanes lists the panes of a window with their process trees
+func (m *Manager) ListPanes(sessionName string, windowIndex int) ([]protocol.Pane, error) {
+	srv, err := m.getSessionByName(sessionName)
+	if err != nil {
+		return nil, err
+	}
+
+	target := fmt.Sprintf("%s:%d", sessionName, windowIndex)
+	output, err := srv.command("list-panes", "-t", target, "-F", paneFormat).Output()
+	if err != nil {
+		return nil, fmt.Errorf("window index %d not found in session '%s'", windowIndex, sessionName)
+	}
+
+	table := srv.processes()
+
+	result := make([]protocol.Pane, 0)
+	for _, line := range strings.Split(strings.TrimRight(string(output), "\n"), "\n") {
+		if _, _, pane, ok := parsePane(line, table); ok {
+			pane.ID = srv.paneID(pane.ID)
+			pane.Host = srv.hostName()
+			result = append(result, pane)
+		}
+	}
+
+	return result, nil
+}
+
+// PaneInfo describes a single pane including its process tree
+// A nil window or pane index selects the active one. Returns the pane and
+// the index of the window it belongs to.
+func (m *Manager) PaneInfo(sessionName string, windowIndex, paneIndex *int) (*protocol.Pane, int, error) {
+	return m.findPane(sessionName, windowIndex, paneIndex, true)
+}
+
+// FindPane describes a single pane like PaneInfo, without its process tree
+func (m *Manager) FindPane(sessionName string, windowIndex, paneIndex *int) (*protocol.Pane, int, error) {
+	return m.findPane(sessionName, windowIndex, paneIndex, false)
+}
+
+// findPane describes a single pane, with its process tree if processes is set
+func (m *Manager) findPane(sessionName string, windowIndex, paneIndex *int, processes bool) (*protocol.Pane, int, error) {
+	srv, err := m.getSessionByName(sessionName)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// display-message silently falls back to the current pane for unknown
+	// targets, so list the window's panes and pick one
+	target := sessionName + ":"
+	if windowIndex != nil {
+		target += strconv.Itoa(*windowIndex)
+	}
+
+	output, err := srv.command("list-panes", "-t", target, "-F", paneFormat).Output()
+	if err != nil {
+		return nil, 0, fmt.Errorf("window '%s' not found", target)
+	}
+
+	var table *procinfo.Table
+	if processes {
+		table = srv.processes()
+	}
+
+	for _, line := range strings.Split(strings.TrimRight(string(output), "\n"), "\n") {
+		_, index, pane, ok := parsePane(line, table)
+		if !ok {
+			continue
+		}
+		if (paneIndex == nil && pane.Active) || (paneIndex != nil && pane.Index == *paneIndex) {
+			pane.ID = srv.paneID(pane.ID)
+			pane.Host = srv.hostName()
+			return &pane, index, nil
+		}
+	}
+
+	return nil, 0, fmt.Errorf("pane not found in window '%s'", target)
+}
+
+// CapturePane captures a pane's content including up to lines of history
+// Escape sequences are kept and wrapped lines are joined
+func (m *Manager) CapturePane(paneID string, lines int) (string, error) {
+	srv, id, err := m.paneServer(paneID)
+	if err != nil {
+		return "", err
+	}
+
+	cmd := srv.command("capture-pane", "-t", id, "-p", "-e", "-J", "-S", fmt.Sprintf("-%d", lines))
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to capture pane %s: %w", paneID, err)
+	}
+
+	return string(output), nil
+}
+
+// SendToPane types text into a pane, pressing Enter afterwards if enter is set
+// Multi-line and long text is pasted instead, see pasteText.
+func (m *Manager) SendToPane(paneID, text string, enter bool) error {
+	srv, id, err := m.paneServer(paneID)
+	if err != nil {
+		return err
+	}
+
+	if strings.Contains(text, "\n") || len(text) > pasteThreshold {
+		if enter {
+			// Enter is pressed once after the paste
+			text = strings.TrimRight(text, "\r\n")
+		}
+		if err := pasteText(srv, id, text); err != nil {
+			return err
+		}
+	} else if text != "" {
+		if err := srv.command("send-keys", "-t", id, "-l", text).Run(); err != nil {
+			return fmt.Errorf("failed to send keys to pane %s: %w", paneID, err)
+		}
+	}
+	if enter {
+		if err := srv.command("send-keys", "-t", id, "C-m").Run(); err != nil {
+			return fmt.Errorf("failed to send Enter to pane %s: %w", paneID, err)
+		}
+	}
+	return nil
+}
+
+// pasteText pastes text into a pane through a paste buffer, in chunks of at
+// most pasteChunkSize bytes
+// Each chunk is a bracketed paste when the application in the pane asked for
+// it, so shells insert multi-line text instead of running it line by line;
+// the chunks keep long input from flooding the application at once.
+func pasteText(srv *server, paneID, text string) error {
+	buffer := "handx-paste-" + strings.TrimPrefix(paneID, "%")
+	for len(text) > 0 {
+		chunk := text[:chunkEnd(text, pasteChunkSize)]
+		text = text[len(chunk):]
+
+		load := srv.command("load-buffer", "-b", buffer, "-")
+		load.Stdin = strings.NewReader(chunk)
+		if output, err := load.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to load paste buffer: %s", strings.TrimSpace(string(output)))
+		}
+		// -d deletes the buffer again, -p brackets the paste
+		if output, err := srv.command("paste-buffer", "-d", "-p", "-b", buffer, "-t", paneID).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to paste into pane %s: %s", paneID, strings.TrimSpace(string(output)))
+		}
+		if len(text) > 0 {
+			time.Sleep(pasteChunkDelay)
+		}
+	}
+	return nil
+}
+
+// chunkEnd returns where the first chunk of at most size bytes of text
+// ends, after a newline if there is one and never inside a character
+func chunkEnd(text string, size int) int {
+	if len(text) <= size {
+		return len(text)
+	}
+	if i := strings.LastIndexByte(text[:size], '\n'); i >= 0 {
+		return i + 1
+	}
+	end := size
+	for end > 0 && !utf8.RuneStart(text[end]) {
+		end--
+	}
+	return end
+}
+
+// PipePane pipes everything a pane prints to a shell command, replacing any
+// previous pipe; an empty command stops piping
+func (m *Manager) PipePane(paneID, command string) error {
+	srv, id, err := m.paneServer(paneID)
+	if err != nil {
+		return err
+	}
+	// The command would run on the remote host
+	if srv.host != nil {
+		return fmt.Errorf("cannot pipe pane %s on remote host '%s'", paneID, srv.name)
+	}
+
+	args := []string{"pipe-pane", "-t", id}
+	if command != "" {
+		args = append(args, command)
+	}
+	if err := srv.command(args...).Run(); err != nil {
+		return fmt.Errorf("failed to pipe pane %s: %w", paneID, err)
+	}
+	return nil
+}
+
+// PaneSize returns the width and height of a pane
+func (m *Manager) PaneSize(paneID string) (int, int, error) {
+	srv, id, err := m.paneServer(paneID)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	output, err := srv.command("display-message", "-p", "-t", id, "#{pane_width} #{pane_height}").Output()
+	// Unknown panes print nothing rather than failing
+	if err != nil || strings.TrimSpace(string(output)) == "" {
+		return 0, 0, fmt.Errorf("pane %s not found", paneID)
+	}
+
+	var width, height int
+	if _, err := fmt.Sscanf(strings.TrimSpace(string(output)), "%d %d", &width, &height); err != nil {
+		return 0, 0, fmt.Errorf("failed to parse size of pane %s: %w", paneID, err)
+	}
+	return width, height, nil
+}
+
+// bufferFormat lists a buffer as name, size, creation time and a sample of
+// its content with tabs and newlines escaped
+const bufferFormat = "#{buffer_name}\t#{buffer_size}\t#{buffer_created}\t#{buffer_sample}"
+
+// ListBuffers returns the paste buffers of the default server, most recent first
+func (m *Manager) ListBuffers() ([]protocol.Buffer, error) {
+	output, err := m.defaultServer.command("list-buffers", "-F", bufferFormat).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list buffers: %w", err)
+	}
+
+	buffers := make([]protocol.Buffer, 0)
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		parts := strings.SplitN(line, "\t", 4)
+		if len(parts) < 4 {
+			continue
+		}
+		size, _ := strconv.Atoi(parts[1])
+		created, _ := strconv.ParseInt(parts[2], 10, 64)
+		buffers = append(buffers, protocol.Buffer{
+			Name:      parts[0],
+			Size:      size,
+			CreatedAt: created * 1000,
+			Sample:    parts[3],
+		})
+	}
+	return buffers, nil
+}
+
+// ShowBuffer returns the content of a paste buffer, or of the most recent
+// one without a name
+func (m *Manager) ShowBuffer(name string) (string, error) {
+	args := []string{"show-buffer"}
+	if name != "" {
+		args = append(args, "-b", name)
+	}
+	output, err := m.defaultServer.command(args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("buffer '%s' not found", name)
+	}
+	return string(output), nil
+}
+
+// SetBuffer stores content in a paste buffer and returns its name
+// Without a name tmux picks one, like for text copied in copy mode.
+func (m *Manager) SetBuffer(name, content string) (string, error) {
+	args := []string{"load-buffer"}
+	if name != "" {
+		args = append(args, "-b", name)
+	}
+	// Content goes through stdin, as it may be too long for an argument
+	cmd := m.defaultServer.command(append(args, "-")...)
+	cmd.Stdin = strings.NewReader(content)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("failed to set buffer: %s", strings.TrimSpace(string(output)))
+	}
+
+	if name == "" {
+		buffers, err := m.ListBuffers()
+		if err != nil || len(buffers) == 0 {
+			return "", fmt.Errorf("failed to find the new buffer")
+		}
+		name = buffers[0].Name
+	}
+	return name, nil
+}
+
+// PasteBuffer pastes a paste buffer, or the most recent one without a name,
+// into a pane
+// With bracketed set the text is wrapped in bracketed paste sequences when
+// the application in the pane asked for them, so shells and editors don't
+// run or indent it line by line.
+// Buffers live on the default server and are copied to the pane's server
+// for panes of other servers.
+func (m *Manager) PasteBuffer(name, paneID string, bracketed bool) error {
+	srv, id, err := m.paneServer(paneID)
+	if err != nil {
+		return err
+	}
+
+	args := []string{"paste-buffer", "-t", id}
+	if srv != m.defaultServer {
+		content, err := m.ShowBuffer(name)
+		if err != nil {
+			return err
+		}
+		buffer := "handx-paste-" + strings.TrimPrefix(id, "%")
+		load := srv.command("load-buffer", "-b", buffer, "-")
+		load.Stdin = strings.NewReader(content)
+		if output, err := load.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to copy buffer '%s': %s", name, strings.TrimSpace(string(output)))
+		}
+		args = append(args, "-d", "-b", buffer)
+	} else if name != "" {
+		args = append(args, "-b", name)
+	}
+	if bracketed {
+		args = append(args, "-p")
+	}
+	if output, err := srv.command(args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to paste buffer '%s': %s", name, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// sessionWindowIDs returns the server of a session and the IDs of its windows
+func (m *Manager) sessionWindowIDs(sessionName string) (*server, []string, error) {
+	srv, err := m.getSessionByName(sessionName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	output, err := srv.command("list-windows", "-t", sessionName, "-F", "#{window_id}").Output()
+	if err != nil {
+		return nil, nil, fmt.Errorf("session '%s' not found", sessionName)
+	}
+	return srv, strings.Fields(string(output)), nil
+}
+
+// ResizeSession sets every window of a session to a fixed size
+// This sets the window-size option of the windows to manual, so attached
+// tmux clients no longer resize them.
+func (m *Manager) ResizeSession(sessionName string, cols, rows int) error {
+	srv, windowIDs, err := m.sessionWindowIDs(sessionName)
+	if err != nil {
+		return err
+	}
+	for _, id := range windowIDs {
+		output, err := srv.command("resize-window", "-t", id, "-x", strconv.Itoa(cols), "-y", strconv.Itoa(rows)).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("failed to resize window %s: %s", id, strings.TrimSpace(string(output)))
+		}
+	}
+	return nil
+}
+
+// ResetSessionSize undoes ResizeSession, letting the windows of a session
+// follow the attached tmux clients again; tmux resizes them when the option
+// changes, and windows without clients keep their size
+func (m *Manager) ResetSessionSize(sessionName string) error {
+	srv, windowIDs, err := m.sessionWindowIDs(sessionName)
+	if err != nil {
+		return err
+	}
+	for _, id := range windowIDs {
+		if output, err := srv.command("set-option", "-w", "-u", "-t", id, "window-size").CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to reset size of window %s: %s", id, strings.TrimSpace(string(output)))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,288 @@
+package tmux
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/myan/handx-server/internal/paths"
+	"github.com/myan/handx-server/internal/procinfo"
+	"github.com/myan/handx-server/internal/remote"
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// Runner runs shell command lines on a remote host, like *remote.Host does
+// over SSH
+type Runner interface {
+	Name() string
+	Address() string
+	// Run runs a command line; nil readers and writers stand for empty
+	// input and discarded output. A command exiting with a non-zero status
+	// returns an error remote.IsExitError recognizes.
+	Run(command string, stdin io.Reader, stdout, stderr io.Writer) error
+}
+
+// server is a tmux server the manager talks to, identified by its socket,
+// or the default server of a remote host
+type server struct {
+	name string // Socket or host name, "" for the local default server
+	path string // Socket path passed with -S, "" for the default socket
+	host Runner // Host running the server, nil for local servers
+}
+
+// newServer creates a local server for a socket path, or the default server
+func newServer(name, path string) *server {
+	return &server{name: name, path: path}
+}
+
+// newHostServer creates the server of a remote host
+func newHostServer(host Runner) *server {
+	return &server{name: host.Name(), host: host}
+}
+
+// command returns a tmux command run against the server
+func (s *server) command(args ...string) *command {
+	if s.path != "" {
+		args = append([]string{"-S", s.path}, args...)
+	}
+	return &command{host: s.host, args: args}
+}
+
+// socket returns the server's socket as reported in protocol.Session
+func (s *server) socket() string {
+	if s.host != nil {
+		return ""
+	}
+	return s.name
+}
+
+// hostName returns the server's host as reported in protocol.Session
+func (s *server) hostName() string {
+	if s.host == nil {
+		return ""
+	}
+	return s.name
+}
+
+// processes returns a process table to describe the server's panes with,
+// nil for remote servers whose processes aren't visible
+func (s *server) processes() *procinfo.Table {
+	if s.host != nil {
+		return nil
+	}
+	// Process details are best effort
+	table, _ := procinfo.Snapshot()
+	return table
+}
+
+// paneID returns the ID of a pane of the server as handx reports it
+// tmux numbers panes per server, so panes of servers other than the default
+// one are qualified as "<tmux ID>@<socket or host>".
+func (s *server) paneID(id string) string {
+	if s.name == "" {
+		return id
+	}
+	return id + "@" + s.name
+}
+
+// command is a tmux command, run locally or over SSH, with the parts of
+// exec.Cmd the manager uses
+type command struct {
+	Stdin io.Reader
+	host  Runner
+	args  []string
+}
+
+// Run runs the command
+func (c *command) Run() error {
+	return c.run(nil, nil)
+}
+
+// Output runs the command and returns its standard output
+func (c *command) Output() ([]byte, error) {
+	var stdout bytes.Buffer
+	err := c.run(&stdout, nil)
+	return stdout.Bytes(), err
+}
+
+// CombinedOutput runs the command and returns its standard output and error
+func (c *command) CombinedOutput() ([]byte, error) {
+	var output bytes.Buffer
+	err := c.run(&output, &output)
+	return output.Bytes(), err
+}
+
+func (c *command) run(stdout, stderr io.Writer) error {
+	if c.host != nil {
+		// Commands over SSH rarely get a UTF-8 locale, without which tmux
+		// replaces the tabs of formats and non-ASCII text with '_'
+		return c.host.Run(paths.Quote(append([]string{"tmux", "-u"}, c.args...)...), c.Stdin, stdout, stderr)
+	}
+
+	cmd := exec.Command("tmux", c.args...)
+	cmd.Stdin = c.Stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return cmd.Run()
+}
+
+// socketDir returns the directory tmux keeps the sockets of -L names in
+func socketDir() string {
+	dir := os.Getenv("TMUX_TMPDIR")
+	if dir == "" {
+		dir = "/tmp"
+	}
+	return filepath.Join(dir, fmt.Sprintf("tmux-%d", os.Getuid()))
+}
+
+// socketPath returns the path of a socket given as a -L name or -S path
+func socketPath(socket string) string {
+	if strings.Contains(socket, "/") {
+		return socket
+	}
+	return filepath.Join(socketDir(), socket)
+}
+
+// defaultSocketPath returns the socket plain tmux commands use, which is
+// the one in TMUX when handx itself runs in tmux
+func defaultSocketPath() string {
+	if tmux := os.Getenv("TMUX"); tmux != "" {
+		path, _, _ := strings.Cut(tmux, ",")
+		return path
+	}
+	return filepath.Join(socketDir(), "default")
+}
+
+// servers returns the default server, the configured ones and, with
+// discovery, the servers of the other sockets in socketDir
+func (m *Manager) servers() []*server {
+	result := append([]*server{m.defaultServer}, m.configured...)
+	if !m.discover {
+		return result
+	}
+
+	known := map[string]bool{defaultSocketPath(): true}
+	for _, s := range m.configured {
+		if s.host == nil {
+			known[s.path] = true
+		}
+	}
+
+	entries, err := os.ReadDir(socketDir())
+	if err != nil {
+		return result
+	}
+	for _, entry := range entries {
+		path := filepath.Join(socketDir(), entry.Name())
+		if entry.Type()&fs.ModeSocket == 0 || known[path] {
+			continue
+		}
+		result = append(result, newServer(entry.Name(), path))
+	}
+	return result
+}
+
+// server returns the server of a socket or host name
+func (m *Manager) server(name string) (*server, error) {
+	for _, s := range m.servers() {
+		if s.name == name {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown tmux socket or host '%s'", name)
+}
+
+// createServer returns the server to create a session on, per the socket
+// and host of its options
+func (m *Manager) createServer(opts *protocol.SessionOptions) (*server, error) {
+	if opts == nil || (opts.Socket == "" && opts.Host == "") {
+		return m.defaultServer, nil
+	}
+	if opts.Socket != "" && opts.Host != "" {
+		return nil, fmt.Errorf("sessions on remote hosts use the default socket")
+	}
+
+	name := opts.Socket + opts.Host
+	srv, err := m.server(name)
+	if err == nil && (srv.host != nil) != (opts.Host != "") {
+		err = fmt.Errorf("unknown tmux socket or host '%s'", name)
+	}
+	return srv, err
+}
+
+// paneServer returns the server of a pane and the pane's ID within it
+func (m *Manager) paneServer(paneID string) (*server, string, error) {
+	id, name, _ := strings.Cut(paneID, "@")
+	s, err := m.server(name)
+	if err != nil {
+		return nil, "", fmt.Errorf("pane %s not found", paneID)
+	}
+	return s, id, nil
+}
+
+// ListSockets returns the local tmux servers sessions can be created on
+func (m *Manager) ListSockets() ([]protocol.TmuxSocket, error) {
+	result := make([]protocol.TmuxSocket, 0)
+	for _, s := range m.servers() {
+		if s.host != nil {
+			continue
+		}
+
+		path := s.path
+		if path == "" {
+			path = defaultSocketPath()
+		}
+
+		socket := protocol.TmuxSocket{Name: s.name, Path: path}
+		if output, err := s.command("list-sessions", "-F", "#{session_name}").Output(); err == nil {
+			socket.Running = true
+			socket.Sessions = strings.Count(string(output), "\n")
+		}
+		result = append(result, socket)
+	}
+	return result, nil
+}
+
+// SessionSocket returns the socket path of the server running a session,
+// or "" for the default server
+// Sessions on remote hosts have no local socket.
+func (m *Manager) SessionSocket(sessionName string) (string, error) {
+	s, err := m.getSessionByName(sessionName)
+	if err != nil {
+		return "", err
+	}
+	if s.host != nil {
+		return "", fmt.Errorf("session '%s' is on remote host '%s'", sessionName, s.name)
+	}
+	return s.path, nil
+}
+
+// ListHosts returns the remote hosts sessions can be created on
+func (m *Manager) ListHosts() ([]protocol.RemoteHost, error) {
+	result := make([]protocol.RemoteHost, 0)
+	for _, s := range m.servers() {
+		if s.host == nil {
+			continue
+		}
+
+		host := protocol.RemoteHost{Name: s.name, Address: s.host.Address()}
+		output, err := s.command("list-sessions", "-F", "#{session_name}").CombinedOutput()
+		switch {
+		case err == nil:
+			host.Connected = true
+			host.Sessions = strings.Count(string(output), "\n")
+		case remote.IsExitError(err):
+			// Connected, but no tmux server running
+			host.Connected = true
+		default:
+			host.Error = err.Error()
+		}
+		result = append(result, host)
+	}
+	return result, nil
+}
@@ -0,0 +1,316 @@
+package transcript
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/myan/handx-server/internal/stream"
+)
+
+// Terminal escape sequences: CSI, OSC and two-character escapes
+var escapeRegex = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(\x07|\x1b\\)|\x1b[@-Z\\-_]`)
+
+// Pane is the captured output of one pane
+type Pane struct {
+	Title   string // e.g. "work:0.1"
+	Content string // Capture with escape sequences
+}
+
+// renderText renders panes as plain text, separated by title lines when
+// there is more than one
+func renderText(title string, panes []Pane) []byte {
+	var b strings.Builder
+	for i, pane := range panes {
+		if len(panes) > 1 {
+			if i > 0 {
+				b.WriteString("\n")
+			}
+			fmt.Fprintf(&b, "==> %s <==\n", pane.Title)
+		}
+		for _, line := range stream.PlainLines(pane.Content) {
+			b.WriteString(line)
+			b.WriteString("\n")
+		}
+	}
+	return []byte(b.String())
+}
+
+// renderMarkdown renders panes as a markdown document with a fenced block per pane
+func renderMarkdown(title string, panes []Pane) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n", title)
+	for _, pane := range panes {
+		lines := stream.PlainLines(pane.Content)
+		fence := fenceFor(lines)
+
+		fmt.Fprintf(&b, "\n## %s\n\n", pane.Title)
+		b.WriteString(fence + "console\n")
+		for _, line := range lines {
+			b.WriteString(line)
+			b.WriteString("\n")
+		}
+		b.WriteString(fence + "\n")
+	}
+	return []byte(b.String())
+}
+
+// fenceFor returns a code fence longer than any backtick run starting a line
+func fenceFor(lines []string) string {
+	longest := 0
+	for _, line := range lines {
+		trimmed := strings.TrimLeft(line, " ")
+		n := len(trimmed) - len(strings.TrimLeft(trimmed, "`"))
+		if n > longest {
+			longest = n
+		}
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
+
+// htmlTemplate is the standalone page transcripts are rendered into
+const htmlTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>%s</title>
+<style>
+body { margin: 0; padding: 24px; background: #0d1117; color: #c9d1d9; font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", sans-serif; }
+h1 { font-size: 20px; margin: 0 0 16px; }
+h2 { font-size: 14px; margin: 24px 0 8px; color: #8b949e; }
+pre { margin: 0; padding: 12px; overflow-x: auto; background: #010409; border: 1px solid #30363d; border-radius: 6px; font: 13px/1.4 ui-monospace, SFMono-Regular, Menlo, Consolas, monospace; }
+.b { font-weight: bold; } .d { opacity: 0.6; } .i { font-style: italic; } .u { text-decoration: underline; } .s { text-decoration: line-through; }
+</style>
+</head>
+<body>
+<h1>%s</h1>
+%s</body>
+</html>
+`
+
+// renderHTML renders panes as a standalone HTML page keeping their colors
+func renderHTML(title string, panes []Pane) []byte {
+	var body strings.Builder
+	for _, pane := range panes {
+		fmt.Fprintf(&body, "<h2>%s</h2>\n<pre>", html.EscapeString(pane.Title))
+		body.WriteString(ansiToHTML(pane.Content))
+		body.WriteString("</pre>\n")
+	}
+	escaped := html.EscapeString(title)
+	return []byte(fmt.Sprintf(htmlTemplate, escaped, escaped, body.String()))
+}
+
+// style is the SGR state of the terminal
+type style struct {
+	fg, bg                                        string // CSS colors, "" for the default
+	bold, dim, italic, underline, inverse, strike bool
+}
+
+// span returns the opening tag for text in this style, or "" for the default
+func (s style) span() string {
+	fg, bg := s.fg, s.bg
+	if s.inverse {
+		fg, bg = bg, fg
+		if fg == "" {
+			fg = "#0d1117"
+		}
+		if bg == "" {
+			bg = "#c9d1d9"
+		}
+	}
+
+	classes := make([]string, 0)
+	for _, c := range []struct {
+		on    bool
+		class string
+	}{{s.bold, "b"}, {s.dim, "d"}, {s.italic, "i"}, {s.underline, "u"}, {s.strike, "s"}} {
+		if c.on {
+			classes = append(classes, c.class)
+		}
+	}
+	css := make([]string, 0, 2)
+	if fg != "" {
+		css = append(css, "color:"+fg)
+	}
+	if bg != "" {
+		css = append(css, "background:"+bg)
+	}
+
+	if len(classes) == 0 && len(css) == 0 {
+		return ""
+	}
+	tag := "<span"
+	if len(classes) > 0 {
+		tag += ` class="` + strings.Join(classes, " ") + `"`
+	}
+	if len(css) > 0 {
+		tag += ` style="` + strings.Join(css, ";") + `"`
+	}
+	return tag + ">"
+}
+
+// ansiToHTML converts a capture to escaped HTML, turning SGR sequences into
+// spans and dropping other escape sequences and trailing blank lines
+func ansiToHTML(content string) string {
+	lines := strings.Split(content, "\n")
+	lines = lines[:min(len(lines), len(stream.PlainLines(content)))]
+
+	var b strings.Builder
+	current := style{}
+	open := ""
+	// Spans are opened with the text they style, so runs of sequences
+	// don't leave empty ones
+	write := func(text string) {
+		if text == "" {
+			return
+		}
+		if tag := current.span(); tag != open {
+			if open != "" {
+				b.WriteString("</span>")
+			}
+			b.WriteString(tag)
+			open = tag
+		}
+		b.WriteString(html.EscapeString(text))
+	}
+
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		line = strings.TrimRight(line, "\r")
+
+		last := 0
+		for _, loc := range escapeRegex.FindAllStringIndex(line, -1) {
+			write(line[last:loc[0]])
+			last = loc[1]
+
+			seq := line[loc[0]:loc[1]]
+			if strings.HasPrefix(seq, "\x1b[") && strings.HasSuffix(seq, "m") {
+				current = applySGR(current, seq[2:len(seq)-1])
+			}
+		}
+		write(line[last:])
+	}
+	if open != "" {
+		b.WriteString("</span>")
+	}
+	return b.String()
+}
+
+// applySGR returns s changed by the parameters of an SGR sequence
+func applySGR(s style, params string) style {
+	if params == "" {
+		return style{}
+	}
+
+	codes := strings.FieldsFunc(params, func(r rune) bool { return r == ';' || r == ':' })
+	for i := 0; i < len(codes); i++ {
+		code, err := strconv.Atoi(codes[i])
+		if err != nil {
+			continue
+		}
+
+		switch {
+		case code == 0:
+			s = style{}
+		case code == 1:
+			s.bold = true
+		case code == 2:
+			s.dim = true
+		case code == 3:
+			s.italic = true
+		case code == 4:
+			s.underline = true
+		case code == 7:
+			s.inverse = true
+		case code == 9:
+			s.strike = true
+		case code == 22:
+			s.bold, s.dim = false, false
+		case code == 23:
+			s.italic = false
+		case code == 24:
+			s.underline = false
+		case code == 27:
+			s.inverse = false
+		case code == 29:
+			s.strike = false
+		case code >= 30 && code <= 37:
+			s.fg = palette[code-30]
+		case code >= 90 && code <= 97:
+			s.fg = palette[code-90+8]
+		case code == 39:
+			s.fg = ""
+		case code >= 40 && code <= 47:
+			s.bg = palette[code-40]
+		case code >= 100 && code <= 107:
+			s.bg = palette[code-100+8]
+		case code == 49:
+			s.bg = ""
+		case code == 38 || code == 48:
+			color, used := extendedColor(codes[i+1:])
+			i += used
+			if code == 38 {
+				s.fg = color
+			} else {
+				s.bg = color
+			}
+		}
+	}
+	return s
+}
+
+// extendedColor parses the arguments of a 38 or 48 code, either 5;n or
+// 2;r;g;b, and returns the color and how many arguments it used
+func extendedColor(args []string) (string, int) {
+	if len(args) == 0 {
+		return "", 0
+	}
+	switch args[0] {
+	case "5":
+		if len(args) < 2 {
+			return "", len(args)
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 0 || n > 255 {
+			return "", 2
+		}
+		return color256(n), 2
+	case "2":
+		if len(args) < 4 {
+			return "", len(args)
+		}
+		rgb := make([]int, 3)
+		for j := range rgb {
+			rgb[j], _ = strconv.Atoi(args[j+1])
+		}
+		return fmt.Sprintf("#%02x%02x%02x", rgb[0]&0xff, rgb[1]&0xff, rgb[2]&0xff), 4
+	}
+	return "", 1
+}
+
+// palette holds the 16 basic colors, matching the dark page background
+var palette = []string{
+	"#484f58", "#ff7b72", "#3fb950", "#d29922", "#58a6ff", "#bc8cff", "#39c5cf", "#b1bac4",
+	"#6e7681", "#ffa198", "#56d364", "#e3b341", "#79c0ff", "#d2a8ff", "#56d4dd", "#ffffff",
+}
+
+// color256 returns the CSS color of an entry of the 256 color palette
+func color256(n int) string {
+	switch {
+	case n < 16:
+		return palette[n]
+	case n < 232:
+		// 6x6x6 color cube
+		n -= 16
+		levels := []int{0, 95, 135, 175, 215, 255}
+		return fmt.Sprintf("#%02x%02x%02x", levels[n/36], levels[n/6%6], levels[n%6])
+	default:
+		gray := 8 + (n-232)*10
+		return fmt.Sprintf("#%02x%02x%02x", gray, gray, gray)
+	}
+}
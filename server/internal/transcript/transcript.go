@@ -0,0 +1,200 @@
+package transcript
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/myan/handx-server/internal/paths"
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// DownloadPath is where the HTTP server serves transcripts, followed by the token
+const DownloadPath = "/transcripts/"
+
+// Backend is the subset of the tmux manager used to export transcripts
+type Backend interface {
+	ListAllPanes() ([]protocol.PaneLocation, error)
+	CapturePane(paneID string, lines int) (string, error)
+}
+
+// format describes how transcripts of one format are rendered and served
+type format struct {
+	render      func(title string, panes []Pane) []byte
+	extension   string
+	contentType string
+}
+
+var formats = map[string]format{
+	protocol.TranscriptText:     {renderText, "txt", "text/plain; charset=utf-8"},
+	protocol.TranscriptMarkdown: {renderMarkdown, "md", "text/markdown; charset=utf-8"},
+	protocol.TranscriptHTML:     {renderHTML, "html", "text/html; charset=utf-8"},
+}
+
+// Export is a rendered transcript waiting to be downloaded
+type Export struct {
+	Data        []byte
+	FileName    string
+	ContentType string
+	expiresAt   time.Time
+}
+
+// Exporter renders transcripts of pane output and keeps them downloadable
+// through a random link until it expires
+type Exporter struct {
+	backend      Backend
+	linkTTL      time.Duration
+	historyLines int
+	mu           sync.Mutex
+	exports      map[string]*Export // Keyed by link token
+}
+
+// NewExporter creates an exporter whose links expire after linkTTL;
+// transcripts include at most historyLines of scrollback per pane
+func NewExporter(backend Backend, linkTTL time.Duration, historyLines int) *Exporter {
+	if linkTTL <= 0 {
+		linkTTL = 5 * time.Minute
+	}
+	if historyLines <= 0 {
+		historyLines = 10000
+	}
+
+	return &Exporter{
+		backend:      backend,
+		linkTTL:      linkTTL,
+		historyLines: historyLines,
+		exports:      make(map[string]*Export),
+	}
+}
+
+// Export renders the pane at target, its window or its whole session
+func (e *Exporter) Export(target protocol.PaneLocation, scope, formatName string, lines int) (*protocol.ExportTranscriptResponse, error) {
+	if formatName == "" {
+		formatName = protocol.TranscriptText
+	}
+	f, ok := formats[formatName]
+	if !ok {
+		return nil, fmt.Errorf("unknown format '%s'", formatName)
+	}
+	if lines <= 0 || lines > e.historyLines {
+		lines = e.historyLines
+	}
+
+	title := ""
+	switch scope {
+	case protocol.ScopePane, "":
+		scope = protocol.ScopePane
+		title = fmt.Sprintf("%s:%d.%d", target.SessionName, target.WindowIndex, target.PaneIndex)
+	case protocol.ScopeWindow:
+		title = fmt.Sprintf("%s:%d", target.SessionName, target.WindowIndex)
+	case protocol.ScopeSession:
+		title = target.SessionName
+	default:
+		return nil, fmt.Errorf("unknown scope '%s'", scope)
+	}
+
+	locations, err := e.backend.ListAllPanes()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(locations, func(i, j int) bool {
+		a, b := locations[i], locations[j]
+		if a.WindowIndex != b.WindowIndex {
+			return a.WindowIndex < b.WindowIndex
+		}
+		return a.PaneIndex < b.PaneIndex
+	})
+
+	panes := make([]Pane, 0)
+	for _, location := range locations {
+		if !inScope(location, target, scope) {
+			continue
+		}
+		content, err := e.backend.CapturePane(location.PaneID, lines)
+		if err != nil {
+			// The pane may have closed since it was listed
+			continue
+		}
+		panes = append(panes, Pane{
+			Title:   fmt.Sprintf("%s:%d.%d", location.SessionName, location.WindowIndex, location.PaneIndex),
+			Content: content,
+		})
+	}
+	if len(panes) == 0 {
+		return nil, fmt.Errorf("no panes to export in %s", title)
+	}
+
+	now := time.Now()
+	export := &Export{
+		Data:        f.render(title+" — "+now.Format("2006-01-02 15:04"), panes),
+		FileName:    fmt.Sprintf("%s-%s.%s", paths.SanitizeFileName(title), now.Format("20060102-150405"), f.extension),
+		ContentType: f.contentType,
+		expiresAt:   now.Add(e.linkTTL),
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate link: %w", err)
+	}
+
+	e.mu.Lock()
+	e.prune(now)
+	e.exports[token] = export
+	e.mu.Unlock()
+
+	log.Printf("Exported %s transcript of %s (%d panes, %d bytes)", formatName, title, len(panes), len(export.Data))
+	return &protocol.ExportTranscriptResponse{
+		Success:   true,
+		URL:       DownloadPath + token,
+		ExpiresAt: export.expiresAt.UnixMilli(),
+		Format:    formatName,
+		Scope:     scope,
+		FileName:  export.FileName,
+		Size:      len(export.Data),
+		Panes:     len(panes),
+	}, nil
+}
+
+// Get returns the transcript behind a link token unless it expired
+func (e *Exporter) Get(token string) (*Export, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.prune(time.Now())
+	export, ok := e.exports[token]
+	return export, ok
+}
+
+// prune drops expired transcripts; callers must hold the lock
+func (e *Exporter) prune(now time.Time) {
+	for token, export := range e.exports {
+		if now.After(export.expiresAt) {
+			delete(e.exports, token)
+		}
+	}
+}
+
+// inScope reports whether the pane at location is exported for target
+func inScope(location, target protocol.PaneLocation, scope string) bool {
+	switch scope {
+	case protocol.ScopeSession:
+		return location.SessionName == target.SessionName
+	case protocol.ScopeWindow:
+		return location.SessionName == target.SessionName && location.WindowIndex == target.WindowIndex
+	default:
+		return location.PaneID == target.PaneID
+	}
+}
+
+// generateToken generates a random, unguessable link token
+func generateToken() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
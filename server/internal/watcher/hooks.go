@@ -0,0 +1,86 @@
+package watcher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// Hooks are given this long before they are abandoned
+const (
+	webhookTimeout = 10 * time.Second
+	commandTimeout = 60 * time.Second
+)
+
+var webhookClient = &http.Client{Timeout: webhookTimeout}
+
+// postWebhook posts a match as JSON to url
+func postWebhook(url string, match protocol.WatcherMatchPayload) {
+	body, err := json.Marshal(match)
+	if err != nil {
+		log.Printf("Failed to encode webhook for watcher %s: %v", match.WatcherID, err)
+		return
+	}
+
+	resp, err := webhookClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Webhook for watcher %s failed: %v", match.WatcherID, err)
+		return
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		log.Printf("Webhook for watcher %s returned %s", match.WatcherID, resp.Status)
+	}
+}
+
+// runCommand runs a watcher's local command hook through the shell
+// The match is described by HANDX_* environment variables.
+func runCommand(command string, match protocol.WatcherMatchPayload) {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = append(os.Environ(),
+		"HANDX_WATCHER_ID="+match.WatcherID,
+		"HANDX_WATCHER_NAME="+match.WatcherName,
+		"HANDX_SESSION="+match.SessionName,
+		"HANDX_WINDOW="+strconv.Itoa(match.WindowIndex),
+		"HANDX_PANE="+strconv.Itoa(match.PaneIndex),
+		"HANDX_PANE_ID="+match.PaneID,
+		"HANDX_PATTERN="+match.Pattern,
+		"HANDX_LINE="+firstLine(match.Lines),
+		"HANDX_LINES="+strings.Join(match.Lines, "\n"),
+	)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		log.Printf("Command hook for watcher %s failed: %v: %s", match.WatcherID, err, truncate(string(output), 500))
+	}
+}
+
+// firstLine returns the first of lines or ""
+func firstLine(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return lines[0]
+}
+
+// truncate shortens s to at most n bytes for logging
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return fmt.Sprintf("%s... (%d bytes)", s[:n], len(s))
+}
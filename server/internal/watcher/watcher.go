@@ -0,0 +1,335 @@
+package watcher
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/myan/handx-server/internal/stream"
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// maxMatchLines caps the lines reported for one batch of output
+const maxMatchLines = 20
+
+// Registry holds the output watchers and matches them against pane output
+type Registry struct {
+	path        string // File the watchers are persisted to
+	watchers    map[string]*protocol.Watcher
+	patterns    map[string][]*regexp.Regexp
+	mu          sync.Mutex
+	subscribers []func(protocol.WatcherMatchPayload)
+}
+
+// NewRegistry creates a registry fed by the output of streamer and loads
+// persisted watchers from path
+func NewRegistry(streamer *stream.Streamer, path string) (*Registry, error) {
+	r := &Registry{
+		path:     path,
+		watchers: make(map[string]*protocol.Watcher),
+		patterns: make(map[string][]*regexp.Regexp),
+	}
+
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+
+	streamer.Subscribe(r.handleOutput)
+	return r, nil
+}
+
+// Subscribe registers fn to be called for every match
+// fn runs on the streamer goroutine and must not block.
+func (r *Registry) Subscribe(fn func(protocol.WatcherMatchPayload)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.subscribers = append(r.subscribers, fn)
+}
+
+// Create validates and adds a new watcher
+func (r *Registry) Create(payload protocol.CreateWatcherPayload) (*protocol.Watcher, error) {
+	if len(payload.Patterns) == 0 {
+		return nil, fmt.Errorf("at least one pattern is required")
+	}
+	if payload.Cooldown < 0 {
+		return nil, fmt.Errorf("cooldown must not be negative")
+	}
+
+	patterns, err := compilePatterns(payload.Patterns, payload.IgnoreCase)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := generateWatcherID()
+	if err != nil {
+		return nil, err
+	}
+
+	watcher := &protocol.Watcher{
+		ID:          id,
+		Name:        payload.Name,
+		SessionName: payload.SessionName,
+		WindowIndex: payload.WindowIndex,
+		PaneIndex:   payload.PaneIndex,
+		Patterns:    payload.Patterns,
+		IgnoreCase:  payload.IgnoreCase,
+		Webhook:     payload.Webhook,
+		Command:     payload.Command,
+		Once:        payload.Once,
+		Cooldown:    payload.Cooldown,
+		CreatedAt:   time.Now().UnixMilli(),
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.watchers[id] = watcher
+	r.patterns[id] = patterns
+	if err := r.save(); err != nil {
+		delete(r.watchers, id)
+		delete(r.patterns, id)
+		return nil, err
+	}
+
+	result := *watcher
+	return &result, nil
+}
+
+// List returns all watchers ordered by creation time
+func (r *Registry) List() []protocol.Watcher {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make([]protocol.Watcher, 0, len(r.watchers))
+	for _, watcher := range r.watchers {
+		result = append(result, *watcher)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt < result[j].CreatedAt
+	})
+
+	return result
+}
+
+// SetPaused pauses or resumes a watcher
+func (r *Registry) SetPaused(id string, paused bool) (*protocol.Watcher, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	watcher, ok := r.watchers[id]
+	if !ok {
+		return nil, fmt.Errorf("watcher '%s' not found", id)
+	}
+
+	watcher.Paused = paused
+	if err := r.save(); err != nil {
+		return nil, err
+	}
+
+	result := *watcher
+	return &result, nil
+}
+
+// Delete removes a watcher
+func (r *Registry) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.watchers[id]; !ok {
+		return fmt.Errorf("watcher '%s' not found", id)
+	}
+
+	delete(r.watchers, id)
+	delete(r.patterns, id)
+	return r.save()
+}
+
+// handleOutput matches new pane output against every active watcher
+func (r *Registry) handleOutput(output stream.Output) {
+	matches := make([]protocol.WatcherMatchPayload, 0)
+	hooks := make([]protocol.Watcher, 0)
+
+	r.mu.Lock()
+	now := output.Time.UnixMilli()
+	for id, watcher := range r.watchers {
+		if watcher.Paused || !targets(watcher, output.PaneLocation) {
+			continue
+		}
+		if watcher.Cooldown > 0 && now-watcher.LastMatchAt < int64(watcher.Cooldown)*1000 {
+			continue
+		}
+
+		pattern, lines := matchLines(watcher, r.patterns[id], output.Lines)
+		if len(lines) == 0 {
+			continue
+		}
+
+		watcher.LastMatchAt = now
+		watcher.MatchCount++
+		if watcher.Once {
+			watcher.Paused = true
+		}
+
+		matches = append(matches, protocol.WatcherMatchPayload{
+			PaneLocation: output.PaneLocation,
+			WatcherID:    watcher.ID,
+			WatcherName:  watcher.Name,
+			Pattern:      pattern,
+			Lines:        lines,
+			MatchedAt:    now,
+		})
+		hooks = append(hooks, *watcher)
+	}
+
+	if len(matches) > 0 {
+		if err := r.save(); err != nil {
+			log.Printf("Failed to persist watchers: %v", err)
+		}
+	}
+	subscribers := r.subscribers
+	r.mu.Unlock()
+
+	for i, match := range matches {
+		log.Printf("Watcher %s matched '%s' in %s:%d.%d", match.WatcherID, match.Pattern, match.SessionName, match.WindowIndex, match.PaneIndex)
+
+		for _, fn := range subscribers {
+			fn(match)
+		}
+
+		// Hooks may be slow and must not hold up the output stream
+		watcher := hooks[i]
+		if watcher.Webhook != "" {
+			go postWebhook(watcher.Webhook, match)
+		}
+		if watcher.Command != "" {
+			go runCommand(watcher.Command, match)
+		}
+	}
+}
+
+// targets reports whether a watcher watches the pane at location
+func targets(watcher *protocol.Watcher, location protocol.PaneLocation) bool {
+	if watcher.SessionName != "" && watcher.SessionName != location.SessionName {
+		return false
+	}
+	if watcher.WindowIndex != nil && *watcher.WindowIndex != location.WindowIndex {
+		return false
+	}
+	if watcher.PaneIndex != nil && *watcher.PaneIndex != location.PaneIndex {
+		return false
+	}
+	return true
+}
+
+// matchLines returns the first pattern that matched and the matching lines
+func matchLines(watcher *protocol.Watcher, patterns []*regexp.Regexp, lines []string) (string, []string) {
+	first := ""
+	matched := make([]string, 0)
+	for _, line := range lines {
+		for i, re := range patterns {
+			if !re.MatchString(line) {
+				continue
+			}
+			if first == "" {
+				first = watcher.Patterns[i]
+			}
+			if len(matched) < maxMatchLines {
+				matched = append(matched, line)
+			}
+			break
+		}
+	}
+	return first, matched
+}
+
+// compilePatterns compiles a watcher's regular expressions
+func compilePatterns(patterns []string, ignoreCase bool) ([]*regexp.Regexp, error) {
+	result := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		expr := pattern
+		if ignoreCase {
+			expr = "(?i)" + expr
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern '%s': %w", pattern, err)
+		}
+		result = append(result, re)
+	}
+	return result, nil
+}
+
+// load reads persisted watchers from disk
+func (r *Registry) load() error {
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read watchers: %w", err)
+	}
+
+	var watchers []*protocol.Watcher
+	if err := json.Unmarshal(data, &watchers); err != nil {
+		return fmt.Errorf("failed to parse watchers: %w", err)
+	}
+
+	for _, watcher := range watchers {
+		patterns, err := compilePatterns(watcher.Patterns, watcher.IgnoreCase)
+		if err != nil {
+			log.Printf("Skipping watcher %s: %v", watcher.ID, err)
+			continue
+		}
+		r.watchers[watcher.ID] = watcher
+		r.patterns[watcher.ID] = patterns
+	}
+
+	log.Printf("Loaded %d watchers from %s", len(r.watchers), r.path)
+	return nil
+}
+
+// save writes all watchers to disk; callers must hold the lock
+func (r *Registry) save() error {
+	watchers := make([]*protocol.Watcher, 0, len(r.watchers))
+	for _, watcher := range r.watchers {
+		watchers = append(watchers, watcher)
+	}
+	sort.Slice(watchers, func(i, j int) bool {
+		return watchers[i].CreatedAt < watchers[j].CreatedAt
+	})
+
+	data, err := json.MarshalIndent(watchers, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode watchers: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.path), 0700); err != nil {
+		return fmt.Errorf("failed to create watcher directory: %w", err)
+	}
+
+	// Write to a temp file first so a crash never leaves a truncated file
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write watchers: %w", err)
+	}
+	return os.Rename(tmp, r.path)
+}
+
+// generateWatcherID generates a random watcher ID
+func generateWatcherID() (string, error) {
+	bytes := make([]byte, 8)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return "watch-" + hex.EncodeToString(bytes), nil
+}
@@ -0,0 +1,152 @@
+package workspace
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"github.com/myan/handx-server/internal/paths"
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// Backend is the subset of the tmux manager used to build workspaces
+type Backend interface {
+	CreateSession(name string, opts *protocol.SessionOptions) (*protocol.Session, error)
+	KillSession(name string) error
+	CreateWindow(sessionName, windowName string, opts *protocol.WindowOptions) (*protocol.Window, error)
+	ListWindows(sessionName string) ([]protocol.Window, error)
+	SwitchWindow(sessionName string, windowIndex int) (string, error)
+	SplitWindow(sessionName string, windowIndex int, horizontal bool, opts *protocol.WindowOptions) error
+	SelectLayout(sessionName string, windowIndex int, layout string) error
+	ExecuteCommand(sessionName, command string, windowIndex *int) error
+}
+
+// Build creates a session from a rendered template
+// sessionName overrides the template's session name when non-empty.
+// If any step fails the partially built session is killed.
+func Build(b Backend, t *Template, sessionName string) (*protocol.Session, error) {
+	name := sessionName
+	if name == "" {
+		name = t.Session
+	}
+	if name == "" {
+		name = t.Name
+	}
+
+	first := t.Windows[0]
+	firstPane := windowPanes(first)[0]
+	session, err := b.CreateSession(name, &protocol.SessionOptions{
+		StartDirectory: resolveRoot(firstPane.Root, first.Root, t.Root),
+		Environment:    mergeEnv(t.Environment, first.Environment, firstPane.Environment),
+		WindowName:     first.Name,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(session.Windows) == 0 {
+		b.KillSession(name)
+		return nil, fmt.Errorf("session '%s' has no windows", name)
+	}
+
+	firstIndex := session.Windows[0].Index
+	if err := buildWindow(b, t, name, firstIndex, first); err != nil {
+		b.KillSession(name)
+		return nil, err
+	}
+
+	for _, w := range t.Windows[1:] {
+		pane := windowPanes(w)[0]
+		window, err := b.CreateWindow(name, w.Name, &protocol.WindowOptions{
+			StartDirectory: resolveRoot(pane.Root, w.Root, t.Root),
+			Environment:    mergeEnv(t.Environment, w.Environment, pane.Environment),
+		})
+		if err != nil {
+			b.KillSession(name)
+			return nil, err
+		}
+
+		if err := buildWindow(b, t, name, window.Index, w); err != nil {
+			b.KillSession(name)
+			return nil, err
+		}
+	}
+
+	// Leave the first window selected, like tmuxinator
+	if _, err := b.SwitchWindow(name, firstIndex); err != nil {
+		log.Printf("Failed to select first window of workspace %s: %v", name, err)
+	}
+
+	if windows, err := b.ListWindows(name); err == nil {
+		session.Windows = windows
+	}
+
+	return session, nil
+}
+
+// buildWindow starts the commands of a window's panes, splitting as needed
+// The window's first pane must already exist.
+func buildWindow(b Backend, t *Template, sessionName string, windowIndex int, w WindowTemplate) error {
+	for i, pane := range windowPanes(w) {
+		if i > 0 {
+			err := b.SplitWindow(sessionName, windowIndex, pane.Horizontal, &protocol.WindowOptions{
+				StartDirectory: resolveRoot(pane.Root, w.Root, t.Root),
+				Environment:    mergeEnv(t.Environment, w.Environment, pane.Environment),
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		// Commands are typed into the pane's shell so the pane survives when they exit
+		if pane.Command != "" {
+			idx := windowIndex
+			if err := b.ExecuteCommand(sessionName, pane.Command, &idx); err != nil {
+				return fmt.Errorf("failed to start '%s': %w", pane.Command, err)
+			}
+		}
+	}
+
+	if w.Layout != "" {
+		if err := b.SelectLayout(sessionName, windowIndex, w.Layout); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// windowPanes returns the panes of a window, treating a pane-less window as one pane
+func windowPanes(w WindowTemplate) []PaneTemplate {
+	if len(w.Panes) == 0 {
+		return []PaneTemplate{{Command: w.Command}}
+	}
+	return w.Panes
+}
+
+// resolveRoot returns the most specific non-empty directory with ~ expanded
+// Relative directories are resolved against the template root.
+func resolveRoot(paneRoot, windowRoot, templateRoot string) string {
+	templateRoot = paths.ExpandHome(templateRoot)
+	for _, dir := range []string{paneRoot, windowRoot} {
+		if dir == "" {
+			continue
+		}
+		dir = paths.ExpandHome(dir)
+		if !filepath.IsAbs(dir) && templateRoot != "" {
+			dir = filepath.Join(templateRoot, dir)
+		}
+		return dir
+	}
+	return templateRoot
+}
+
+// mergeEnv merges environment maps, later maps taking precedence
+func mergeEnv(envs ...map[string]string) map[string]string {
+	merged := make(map[string]string)
+	for _, env := range envs {
+		for k, v := range env {
+			merged[k] = v
+		}
+	}
+	return merged
+}
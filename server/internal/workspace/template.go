@@ -0,0 +1,199 @@
+package workspace
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// Template is a declarative workspace: a session with windows and panes
+type Template struct {
+	Name        string            `yaml:"name"`
+	Description string            `yaml:"description"`
+	Session     string            `yaml:"session"`     // Session name, defaults to the template name
+	Root        string            `yaml:"root"`        // Default working directory for every window
+	Variables   map[string]string `yaml:"variables"`   // Default values for {{variables}}
+	Environment map[string]string `yaml:"environment"` // Environment for every window
+	Windows     []WindowTemplate  `yaml:"windows"`
+}
+
+// WindowTemplate describes one window of a workspace
+type WindowTemplate struct {
+	Name        string            `yaml:"name"`
+	Root        string            `yaml:"root"`
+	Layout      string            `yaml:"layout"` // tmux layout preset or custom layout string
+	Command     string            `yaml:"command"`
+	Environment map[string]string `yaml:"environment"`
+	Panes       []PaneTemplate    `yaml:"panes"` // When empty the window has a single pane running Command
+}
+
+// PaneTemplate describes one pane of a window
+type PaneTemplate struct {
+	Root        string            `yaml:"root"`
+	Command     string            `yaml:"command"`
+	Environment map[string]string `yaml:"environment"`
+	Horizontal  bool              `yaml:"horizontal"` // Split side by side instead of stacked
+}
+
+// variableRegex matches {{name}} placeholders
+var variableRegex = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_.-]*)\s*\}\}`)
+
+// LoadTemplates reads every *.yaml / *.yml template in dir
+// A missing directory is not an error and yields no templates
+func LoadTemplates(dir string) ([]*Template, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*Template{}, nil
+		}
+		return nil, fmt.Errorf("failed to read template directory: %w", err)
+	}
+
+	templates := make([]*Template, 0, len(entries))
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+
+		t, err := LoadTemplate(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].Name < templates[j].Name
+	})
+
+	return templates, nil
+}
+
+// LoadTemplate reads a single template file
+// The template name defaults to the file name without extension
+func LoadTemplate(path string) (*Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template: %w", err)
+	}
+
+	var t Template
+	if err := yaml.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("failed to parse template %s: %w", filepath.Base(path), err)
+	}
+
+	if t.Name == "" {
+		t.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if len(t.Windows) == 0 {
+		return nil, fmt.Errorf("template '%s' has no windows", t.Name)
+	}
+
+	return &t, nil
+}
+
+// FindTemplate returns the template with the given name from dir
+func FindTemplate(dir, name string) (*Template, error) {
+	templates, err := LoadTemplates(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range templates {
+		if t.Name == name {
+			return t, nil
+		}
+	}
+
+	return nil, fmt.Errorf("template '%s' not found", name)
+}
+
+// Render returns a copy of the template with {{variables}} substituted
+// Values in vars override the template's defaults; unknown variables are an error
+func (t *Template) Render(vars map[string]string) (*Template, error) {
+	values := make(map[string]string, len(t.Variables)+len(vars))
+	for k, v := range t.Variables {
+		values[k] = v
+	}
+	for k, v := range vars {
+		values[k] = v
+	}
+
+	r := &renderer{values: values}
+	out := &Template{
+		Name:        t.Name,
+		Description: t.Description,
+		Session:     r.str(t.Session),
+		Root:        r.str(t.Root),
+		Variables:   t.Variables,
+		Environment: r.env(t.Environment),
+		Windows:     make([]WindowTemplate, 0, len(t.Windows)),
+	}
+
+	for _, w := range t.Windows {
+		rw := WindowTemplate{
+			Name:        r.str(w.Name),
+			Root:        r.str(w.Root),
+			Layout:      r.str(w.Layout),
+			Command:     r.str(w.Command),
+			Environment: r.env(w.Environment),
+			Panes:       make([]PaneTemplate, 0, len(w.Panes)),
+		}
+		for _, p := range w.Panes {
+			rw.Panes = append(rw.Panes, PaneTemplate{
+				Root:        r.str(p.Root),
+				Command:     r.str(p.Command),
+				Environment: r.env(p.Environment),
+				Horizontal:  p.Horizontal,
+			})
+		}
+		out.Windows = append(out.Windows, rw)
+	}
+
+	if len(r.missing) > 0 {
+		sort.Strings(r.missing)
+		return nil, fmt.Errorf("undefined template variables: %s", strings.Join(r.missing, ", "))
+	}
+
+	return out, nil
+}
+
+// renderer substitutes variables and records any that are undefined
+type renderer struct {
+	values  map[string]string
+	missing []string
+}
+
+func (r *renderer) str(s string) string {
+	return variableRegex.ReplaceAllStringFunc(s, func(match string) string {
+		name := variableRegex.FindStringSubmatch(match)[1]
+		v, ok := r.values[name]
+		if !ok {
+			for _, m := range r.missing {
+				if m == name {
+					return match
+				}
+			}
+			r.missing = append(r.missing, name)
+			return match
+		}
+		return v
+	})
+}
+
+func (r *renderer) env(env map[string]string) map[string]string {
+	if env == nil {
+		return nil
+	}
+	out := make(map[string]string, len(env))
+	for k, v := range env {
+		out[k] = r.str(v)
+	}
+	return out
+}
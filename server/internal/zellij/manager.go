@@ -0,0 +1,824 @@
+// Package zellij manages zellij sessions
+package zellij
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/myan/handx-server/internal/environ"
+	"github.com/myan/handx-server/pkg/protocol"
+)
+
+// How long CreateSession waits for a new session to show up
+const (
+	startTimeout = 5 * time.Second
+	pollInterval = 50 * time.Millisecond
+)
+
+// Input sent for the special keys ExecuteCommand presses instead of typing
+var specialKeys = map[string]string{
+	"Escape": "\x1b",
+	"Enter":  "\r",
+	"Tab":    "\t",
+}
+
+// ansiEscape matches the color codes list-sessions prints despite
+// --no-formatting in some versions
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*[a-zA-Z]`)
+
+// sessionLine matches a session in list-sessions output, e.g.
+// "dev [Created 2h 5m 3s ago] (current)"
+var sessionLine = regexp.MustCompile(`^(\S+)(?: \[Created ([^\]]*) ago\])?(.*)$`)
+
+// durationPart matches one part of a creation age, e.g. "5m" or "3days"
+var durationPart = regexp.MustCompile(`(\d+)\s*([a-z]+)`)
+
+// tabLine matches a tab in dump-layout output, e.g.
+// `tab name="Tab #1" focus=true hide_floating_panes=true {`
+var tabLine = regexp.MustCompile(`^\s*tab name="((?:[^"\\]|\\.)*)"(.*)$`)
+
+// Manager manages zellij sessions
+// zellij actions apply to the focused tab and pane of a session, so each tab
+// is reported as a window with a single pane, its focused one, whose ID is
+// "<session>:<tab position>". Tabs are numbered from 1, like go-to-tab does.
+// Acting on another tab focuses it for the duration of the action. Requires
+// zellij 0.40 or newer.
+type Manager struct {
+	historyLines int // Lines of scrollback captured
+
+	mu sync.Mutex // Serializes actions that move the focus
+}
+
+// session is a running zellij session
+type session struct {
+	name      string
+	createdAt int64
+}
+
+// tab is a tab of a session as listed by dump-layout
+type tab struct {
+	index  int
+	name   string
+	active bool
+}
+
+// NewManager creates a zellij manager
+func NewManager(historyLines int) (*Manager, error) {
+	if _, err := exec.LookPath("zellij"); err != nil {
+		return nil, fmt.Errorf("zellij is not installed on the system")
+	}
+
+	if historyLines <= 0 {
+		historyLines = 10000
+	}
+
+	return &Manager{historyLines: historyLines}, nil
+}
+
+// ListSessions returns all running zellij sessions
+func (m *Manager) ListSessions() ([]protocol.Session, error) {
+	sessions, err := listSessions()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]protocol.Session, 0, len(sessions))
+	for _, s := range sessions {
+		windows, err := m.describeWindows(s)
+		if err != nil {
+			// The session ended since it was listed
+			continue
+		}
+
+		result = append(result, protocol.Session{
+			ID:          fmt.Sprintf("session-%s", s.name),
+			Name:        s.name,
+			Windows:     windows,
+			CreatedAt:   s.createdAt,
+			WindowCount: len(windows),
+		})
+	}
+
+	return result, nil
+}
+
+// CreateSession starts a session in the background
+// opts may be nil to create a session with zellij defaults. zellij sizes
+// sessions to the clients attached to them, so Width and Height are ignored.
+func (m *Manager) CreateSession(name string, opts *protocol.SessionOptions) (*protocol.Session, error) {
+	if name == "" || strings.ContainsAny(name, ": \t/") {
+		return nil, fmt.Errorf("invalid session name '%s'", name)
+	}
+	if _, err := findSession(name); err == nil {
+		return nil, fmt.Errorf("session '%s' already exists", name)
+	}
+
+	// The session's shells inherit the directory and environment of its
+	// server, which is started by this command
+	cmd := exec.Command("zellij", "attach", "--create-background", name)
+	cmd.Env = os.Environ()
+	if opts != nil {
+		cmd.Dir = opts.StartDirectory
+
+		env, err := environ.Pairs(opts.Environment)
+		if err != nil {
+			return nil, err
+		}
+		cmd.Env = append(cmd.Env, env...)
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("failed to create session: %s", strings.TrimSpace(string(output)))
+	}
+
+	// The server may not be listening when the command returns
+	s := &session{name: name}
+	deadline := time.Now().Add(startTimeout)
+	for {
+		if _, err := s.tabs(); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("session '%s' did not start", name)
+		}
+		time.Sleep(pollInterval)
+	}
+
+	if opts != nil && opts.Command != "" {
+		// Replace the first tab's shell with a tab running the command
+		windowOpts := &protocol.WindowOptions{StartDirectory: opts.StartDirectory, Command: opts.Command}
+		if err := m.newTab(s, opts.WindowName, windowOpts); err != nil {
+			m.KillSession(name)
+			return nil, fmt.Errorf("failed to create session: %w", err)
+		}
+		if err := m.closeTab(s, 1); err != nil {
+			m.KillSession(name)
+			return nil, fmt.Errorf("failed to create session: %w", err)
+		}
+	} else if opts != nil && opts.WindowName != "" {
+		if err := s.action("rename-tab", opts.WindowName); err != nil {
+			m.KillSession(name)
+			return nil, fmt.Errorf("failed to create session: %w", err)
+		}
+	}
+
+	windows, err := m.describeWindows(s)
+	if err != nil {
+		return nil, err
+	}
+	return &protocol.Session{
+		ID:          fmt.Sprintf("session-%s", name),
+		Name:        name,
+		Windows:     windows,
+		CreatedAt:   time.Now().UnixMilli(),
+		WindowCount: len(windows),
+	}, nil
+}
+
+// KillSession ends a session and the programs in its tabs
+// The session is deleted too, so zellij doesn't offer to resurrect it.
+func (m *Manager) KillSession(name string) error {
+	if _, err := findSession(name); err != nil {
+		return err
+	}
+
+	output, err := exec.Command("zellij", "delete-session", "--force", name).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to kill session: %s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// RenameSession renames a session
+func (m *Manager) RenameSession(oldName, newName string) error {
+	if newName == "" || strings.ContainsAny(newName, ": \t/") {
+		return fmt.Errorf("invalid session name '%s'", newName)
+	}
+
+	s, err := findSession(oldName)
+	if err != nil {
+		return err
+	}
+	if _, err := findSession(newName); err == nil {
+		return fmt.Errorf("session '%s' already exists", newName)
+	}
+
+	if err := s.action("rename-session", newName); err != nil {
+		return fmt.Errorf("failed to rename session: %w", err)
+	}
+	return nil
+}
+
+// ExecuteCommand executes a command in a tab of a session, the focused one
+// if windowIndex is nil
+func (m *Manager) ExecuteCommand(sessionName, command string, windowIndex *int) error {
+	s, t, err := findTab(sessionName, windowIndex)
+	if err != nil {
+		return err
+	}
+
+	// Press special keys without Enter
+	if key, ok := specialKeys[command]; ok {
+		return m.sendInput(s, t.index, key, false)
+	}
+	return m.sendInput(s, t.index, command, true)
+}
+
+// SendText types text into the focused tab of a session without Enter
+func (m *Manager) SendText(sessionName, text string) error {
+	s, t, err := findTab(sessionName, nil)
+	if err != nil {
+		return err
+	}
+
+	return m.sendInput(s, t.index, text, false)
+}
+
+// CaptureOutput captures the scrollback of a tab of a session, the focused
+// one if windowIndex is nil
+func (m *Manager) CaptureOutput(sessionName string, windowIndex *int) (string, error) {
+	s, t, err := findTab(sessionName, windowIndex)
+	if err != nil {
+		return "", err
+	}
+
+	return m.capture(s, t.index, m.historyLines)
+}
+
+// ListWindows lists the tabs of a session
+func (m *Manager) ListWindows(sessionName string) ([]protocol.Window, error) {
+	s, err := findSession(sessionName)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.describeWindows(s)
+}
+
+// CreateWindow opens a tab after the last one of a session and focuses it
+// opts may be nil to run the default shell in the session's directory
+func (m *Manager) CreateWindow(sessionName, windowName string, opts *protocol.WindowOptions) (*protocol.Window, error) {
+	s, err := findSession(sessionName)
+	if err != nil {
+		return nil, err
+	}
+	if opts == nil {
+		opts = &protocol.WindowOptions{}
+	}
+
+	if err := m.newTab(s, windowName, opts); err != nil {
+		return nil, fmt.Errorf("failed to create window: %w", err)
+	}
+
+	tabs, err := s.tabs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list windows after creation: %w", err)
+	}
+	for _, t := range tabs {
+		if t.active {
+			return &protocol.Window{
+				ID:     fmt.Sprintf("window-%s-%d", sessionName, t.index),
+				Name:   t.name,
+				Index:  t.index,
+				Active: true,
+				PaneID: fmt.Sprintf("%d", t.index),
+			}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("failed to find newly created window")
+}
+
+// CloseWindow closes a tab of a session, killing its programs
+// Tabs after it move up one position.
+func (m *Manager) CloseWindow(sessionName string, windowIndex int) error {
+	s, err := findSession(sessionName)
+	if err != nil {
+		return err
+	}
+	tabs, err := s.tabs()
+	if err != nil {
+		return err
+	}
+
+	if _, err := pickTab(s, tabs, &windowIndex); err != nil {
+		return err
+	}
+	// Don't allow closing the last window
+	if len(tabs) == 1 {
+		return fmt.Errorf("cannot close the last window in session '%s'", sessionName)
+	}
+
+	if err := m.closeTab(s, windowIndex); err != nil {
+		return fmt.Errorf("failed to close window: %w", err)
+	}
+	return nil
+}
+
+// SwitchWindow focuses a tab of a session
+func (m *Manager) SwitchWindow(sessionName string, windowIndex int) (string, error) {
+	s, t, err := findTab(sessionName, &windowIndex)
+	if err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := s.action("go-to-tab", strconv.Itoa(windowIndex)); err != nil {
+		return "", fmt.Errorf("failed to switch window: %w", err)
+	}
+	return t.name, nil
+}
+
+// ListAllPanes returns the location of every tab of every session
+func (m *Manager) ListAllPanes() ([]protocol.PaneLocation, error) {
+	sessions, err := listSessions()
+	if err != nil {
+		// No zellij sessions means no panes
+		return []protocol.PaneLocation{}, nil
+	}
+
+	result := make([]protocol.PaneLocation, 0)
+	for _, s := range sessions {
+		tabs, err := s.tabs()
+		if err != nil {
+			continue
+		}
+		for _, t := range tabs {
+			result = append(result, protocol.PaneLocation{
+				SessionName: s.name,
+				WindowIndex: t.index,
+				PaneIndex:   0,
+				PaneID:      paneID(s.name, t.index),
+			})
+		}
+	}
+
+	return result, nil
+}
+
+// PaneInfo describes the pane of a tab
+// A nil window index selects the focused tab; tabs only have pane 0. zellij
+// doesn't report the processes of panes, so none are included.
+// Returns the pane and the index of its tab.
+func (m *Manager) PaneInfo(sessionName string, windowIndex, paneIndex *int) (*protocol.Pane, int, error) {
+	s, t, err := findTab(sessionName, windowIndex)
+	if err != nil {
+		return nil, 0, err
+	}
+	if paneIndex != nil && *paneIndex != 0 {
+		return nil, 0, fmt.Errorf("pane %d not found in window %d of session '%s'", *paneIndex, t.index, sessionName)
+	}
+
+	pane := describePane(s, t)
+	return &pane, t.index, nil
+}
+
+// CapturePane captures a tab's content including up to lines of history
+func (m *Manager) CapturePane(paneID string, lines int) (string, error) {
+	s, index, err := parsePaneID(paneID)
+	if err != nil {
+		return "", err
+	}
+
+	return m.capture(s, index, lines)
+}
+
+// SendToPane types text into a tab, pressing Enter afterwards if enter is set
+func (m *Manager) SendToPane(paneID, text string, enter bool) error {
+	s, index, err := parsePaneID(paneID)
+	if err != nil {
+		return err
+	}
+
+	return m.sendInput(s, index, text, enter)
+}
+
+// sendInput types text into the focused pane of a tab
+func (m *Manager) sendInput(s *session, index int, text string, enter bool) error {
+	if enter {
+		text = strings.TrimRight(text, "\r\n")
+	}
+	// Terminals send Enter as a carriage return
+	input := strings.ReplaceAll(text, "\n", "\r")
+	if enter {
+		input += "\r"
+	}
+	if input == "" {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return s.inTab(index, func() error {
+		if err := s.action("write-chars", "--", input); err != nil {
+			return fmt.Errorf("failed to send input to window %d: %w", index, err)
+		}
+		return nil
+	})
+}
+
+// capture returns the last lines lines of the scrollback and screen of the
+// focused pane of a tab
+func (m *Manager) capture(s *session, index, lines int) (string, error) {
+	file, err := os.CreateTemp("", "handx-zellij-*")
+	if err != nil {
+		return "", err
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	m.mu.Lock()
+	err = s.inTab(index, func() error {
+		return s.action("dump-screen", "--full", file.Name())
+	})
+	m.mu.Unlock()
+	if err != nil {
+		return "", fmt.Errorf("failed to capture window %d: %w", index, err)
+	}
+
+	data, err := os.ReadFile(file.Name())
+	if err != nil {
+		return "", fmt.Errorf("failed to capture window %d: %w", index, err)
+	}
+
+	// The dump ends with the empty lines below the cursor
+	output := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	for i, line := range output {
+		output[i] = strings.TrimRight(line, " ")
+	}
+	for len(output) > 0 && output[len(output)-1] == "" {
+		output = output[:len(output)-1]
+	}
+	if len(output) > lines {
+		output = output[len(output)-lines:]
+	}
+	if len(output) == 0 {
+		return "", nil
+	}
+	return strings.Join(output, "\n") + "\n", nil
+}
+
+// newTab opens a tab after the last one of a session, focused, running
+// opts.Command or the default shell
+// Extra environment variables and commands are passed through a layout
+// whose pane runs env.
+func (m *Manager) newTab(s *session, name string, opts *protocol.WindowOptions) error {
+	env, err := environ.Pairs(opts.Environment)
+	if err != nil {
+		return err
+	}
+
+	args := []string{"new-tab"}
+	if name != "" {
+		args = append(args, "--name", name)
+	}
+	if opts.StartDirectory != "" {
+		args = append(args, "--cwd", opts.StartDirectory)
+	}
+	if len(env) > 0 || opts.Command != "" {
+		file, err := os.CreateTemp("", "handx-zellij-*.kdl")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(file.Name())
+		_, err = file.WriteString(tabLayout(env, opts.StartDirectory, opts.Command))
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+		args = append(args, "--layout", file.Name())
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return s.action(args...)
+}
+
+// closeTab closes a tab of a session, keeping the focus on the tab focused
+// before unless it was the one closed
+func (m *Manager) closeTab(s *session, index int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tabs, err := s.tabs()
+	if err != nil {
+		return err
+	}
+	active := focusedIndex(tabs)
+
+	if index != active {
+		if err := s.action("go-to-tab", strconv.Itoa(index)); err != nil {
+			return err
+		}
+	}
+	if err := s.action("close-tab"); err != nil {
+		return err
+	}
+	if index == active {
+		return nil
+	}
+	if index < active {
+		active--
+	}
+	return s.action("go-to-tab", strconv.Itoa(active))
+}
+
+// describeWindows converts the tabs of a session to their protocol form
+func (m *Manager) describeWindows(s *session) ([]protocol.Window, error) {
+	tabs, err := s.tabs()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]protocol.Window, 0, len(tabs))
+	for _, t := range tabs {
+		result = append(result, protocol.Window{
+			ID:     fmt.Sprintf("window-%s-%d", s.name, t.index),
+			Name:   t.name,
+			Index:  t.index,
+			Active: t.active,
+			PaneID: fmt.Sprintf("%d", t.index),
+			Panes:  []protocol.Pane{describePane(s, t)},
+		})
+	}
+	return result, nil
+}
+
+// describePane describes the focused pane of a tab
+func describePane(s *session, t tab) protocol.Pane {
+	return protocol.Pane{
+		ID:     paneID(s.name, t.index),
+		Index:  0,
+		Active: true,
+	}
+}
+
+// listSessions returns the running zellij sessions, in the order zellij
+// lists them; exited sessions kept for resurrection are left out
+func listSessions() ([]*session, error) {
+	// list-sessions exits non-zero when there are no sessions
+	output, err := exec.Command("zellij", "list-sessions", "--no-formatting").Output()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	now := time.Now()
+	result := make([]*session, 0)
+	for _, line := range strings.Split(ansiEscape.ReplaceAllString(string(output), ""), "\n") {
+		match := sessionLine.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil || strings.Contains(match[3], "EXITED") {
+			continue
+		}
+		s := &session{name: match[1]}
+		if age, ok := parseAge(match[2]); ok {
+			s.createdAt = now.Add(-age).UnixMilli()
+		}
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+// parseAge converts the age list-sessions prints, e.g. "2h 5m 3s", to a
+// duration
+func parseAge(value string) (time.Duration, bool) {
+	units := map[string]time.Duration{
+		"s":    time.Second,
+		"m":    time.Minute,
+		"h":    time.Hour,
+		"d":    24 * time.Hour,
+		"day":  24 * time.Hour,
+		"days": 24 * time.Hour,
+	}
+
+	var age time.Duration
+	parts := durationPart.FindAllStringSubmatch(value, -1)
+	for _, part := range parts {
+		unit, ok := units[part[2]]
+		if !ok {
+			return 0, false
+		}
+		n, _ := strconv.Atoi(part[1])
+		age += time.Duration(n) * unit
+	}
+	return age, len(parts) > 0
+}
+
+// findSession finds a running session by name
+func findSession(name string) (*session, error) {
+	sessions, err := listSessions()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range sessions {
+		if s.name == name {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("session '%s' not found", name)
+}
+
+// findTab finds a tab of a session, the focused one if index is nil
+func findTab(sessionName string, index *int) (*session, tab, error) {
+	s, err := findSession(sessionName)
+	if err != nil {
+		return nil, tab{}, err
+	}
+	tabs, err := s.tabs()
+	if err != nil {
+		return nil, tab{}, err
+	}
+
+	t, err := pickTab(s, tabs, index)
+	return s, t, err
+}
+
+// pickTab picks a tab by index, or the focused one if index is nil
+func pickTab(s *session, tabs []tab, index *int) (tab, error) {
+	for _, t := range tabs {
+		if (index == nil && t.active) || (index != nil && t.index == *index) {
+			return t, nil
+		}
+	}
+
+	if index == nil {
+		if len(tabs) > 0 {
+			return tabs[0], nil
+		}
+		return tab{}, fmt.Errorf("no windows in session '%s'", s.name)
+	}
+	return tab{}, fmt.Errorf("window index %d not found in session '%s'", *index, s.name)
+}
+
+// focusedIndex returns the index of the focused tab, or 1 if none is marked
+func focusedIndex(tabs []tab) int {
+	for _, t := range tabs {
+		if t.active {
+			return t.index
+		}
+	}
+	return 1
+}
+
+// paneID returns the ID of the pane of a tab
+func paneID(sessionName string, index int) string {
+	return fmt.Sprintf("%s:%d", sessionName, index)
+}
+
+// parsePaneID finds the session and tab index of a pane ID
+func parsePaneID(id string) (*session, int, error) {
+	i := strings.LastIndex(id, ":")
+	if i < 0 {
+		return nil, 0, fmt.Errorf("pane %s not found", id)
+	}
+	index, err := strconv.Atoi(id[i+1:])
+	if err != nil {
+		return nil, 0, fmt.Errorf("pane %s not found", id)
+	}
+
+	s, err := findSession(id[:i])
+	if err != nil {
+		return nil, 0, fmt.Errorf("pane %s not found", id)
+	}
+	return s, index, nil
+}
+
+// tabs lists the tabs of the session from its current layout
+func (s *session) tabs() ([]tab, error) {
+	output, err := s.query("dump-layout")
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]tab, 0)
+	for _, line := range strings.Split(output, "\n") {
+		match := tabLine.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		name, err := strconv.Unquote(`"` + match[1] + `"`)
+		if err != nil {
+			name = match[1]
+		}
+		result = append(result, tab{
+			index:  len(result) + 1,
+			name:   name,
+			active: strings.Contains(match[2], "focus=true"),
+		})
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no windows in session '%s'", s.name)
+	}
+	return result, nil
+}
+
+// inTab runs fn with a tab focused, focusing the tab focused before
+// afterwards; callers must hold the manager's lock
+func (s *session) inTab(index int, fn func() error) error {
+	tabs, err := s.tabs()
+	if err != nil {
+		return err
+	}
+	active := focusedIndex(tabs)
+	if index == active {
+		return fn()
+	}
+
+	if _, err := pickTab(s, tabs, &index); err != nil {
+		return err
+	}
+	if err := s.action("go-to-tab", strconv.Itoa(index)); err != nil {
+		return err
+	}
+	err = fn()
+	if restoreErr := s.action("go-to-tab", strconv.Itoa(active)); err == nil {
+		err = restoreErr
+	}
+	return err
+}
+
+// action runs a zellij action in the session
+func (s *session) action(args ...string) error {
+	cmdArgs := append([]string{"--session", s.name, "action"}, args...)
+	output, err := exec.Command("zellij", cmdArgs...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// query runs a zellij action in the session and returns what it printed
+func (s *session) query(args ...string) (string, error) {
+	cmdArgs := append([]string{"--session", s.name, "action"}, args...)
+	output, err := exec.Command("zellij", cmdArgs...).Output()
+	if err != nil {
+		return "", fmt.Errorf("session '%s' not found", s.name)
+	}
+	return string(output), nil
+}
+
+// tabLayout returns a layout with one pane running command, or the default
+// shell, with extra environment variables, like tmux runs window commands
+func tabLayout(env []string, dir, command string) string {
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+	args := append([]string(nil), env...)
+	args = append(args, shell)
+	if command != "" {
+		args = append(args, "-c", command)
+	}
+
+	var b strings.Builder
+	b.WriteString("layout {\n    pane command=\"env\"")
+	if dir != "" {
+		fmt.Fprintf(&b, " cwd=%s", kdlString(dir))
+	}
+	b.WriteString(" {\n        args")
+	for _, arg := range args {
+		b.WriteString(" " + kdlString(arg))
+	}
+	b.WriteString("\n    }\n}\n")
+	return b.String()
+}
+
+// kdlString quotes s as a KDL string
+func kdlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u{%x}`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,76 @@
+package zellij
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"6s", 6 * time.Second, true},
+		{"2h 5m 3s", 2*time.Hour + 5*time.Minute + 3*time.Second, true},
+		{"3days 1h", 73 * time.Hour, true},
+		{"", 0, false},
+		{"5 fortnights", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseAge(tt.value)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseAge(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestTabLayout(t *testing.T) {
+	t.Setenv("SHELL", "/bin/bash")
+
+	got := tabLayout([]string{"GREETING=hi \"there\""}, "/srv/app", "make test\nmake lint")
+	want := `layout {
+    pane command="env" cwd="/srv/app" {
+        args "GREETING=hi \"there\"" "/bin/bash" "-c" "make test\nmake lint"
+    }
+}
+`
+	if got != want {
+		t.Errorf("tabLayout =\n%s\nwant\n%s", got, want)
+	}
+
+	// The default shell without a command or directory
+	got = tabLayout(nil, "", "")
+	want = `layout {
+    pane command="env" {
+        args "/bin/bash"
+    }
+}
+`
+	if got != want {
+		t.Errorf("tabLayout =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestTabLine(t *testing.T) {
+	lines := map[string][]string{
+		`    tab name="Tab #1" focus=true hide_floating_panes=true {`: {"Tab #1", " focus=true hide_floating_panes=true {"},
+		`    tab name="say \"hi\"" {`:                                 {`say \"hi\"`, " {"},
+		`        swap_tiled_layout name="vertical" {`:                 nil,
+		`        tab max_panes=5 {`:                                   nil,
+	}
+
+	for line, want := range lines {
+		match := tabLine.FindStringSubmatch(line)
+		if want == nil {
+			if match != nil {
+				t.Errorf("%q matched as a tab", line)
+			}
+			continue
+		}
+		if match == nil || match[1] != want[0] || match[2] != want[1] {
+			t.Errorf("%q matched %q, want %q", line, match, want)
+		}
+	}
+}
@@ -42,6 +42,161 @@ const (
 	TypeCaptureOutput          MessageType = "capture_output"
 	TypeCaptureOutputResponse  MessageType = "capture_output_response"
 
+	// Scheduling
+	TypeCreateSchedule             MessageType = "create_schedule"
+	TypeCreateScheduleResponse     MessageType = "create_schedule_response"
+	TypeListSchedules              MessageType = "list_schedules"
+	TypeListSchedulesResponse      MessageType = "list_schedules_response"
+	TypePauseSchedule              MessageType = "pause_schedule"
+	TypePauseScheduleResponse      MessageType = "pause_schedule_response"
+	TypeResumeSchedule             MessageType = "resume_schedule"
+	TypeResumeScheduleResponse     MessageType = "resume_schedule_response"
+	TypeDeleteSchedule             MessageType = "delete_schedule"
+	TypeDeleteScheduleResponse     MessageType = "delete_schedule_response"
+	TypeGetScheduleHistory         MessageType = "get_schedule_history"
+	TypeGetScheduleHistoryResponse MessageType = "get_schedule_history_response"
+
+	// Workspace Templates
+	TypeListTemplates                     MessageType = "list_templates"
+	TypeListTemplatesResponse             MessageType = "list_templates_response"
+	TypeCreateSessionFromTemplate         MessageType = "create_session_from_template"
+	TypeCreateSessionFromTemplateResponse MessageType = "create_session_from_template_response"
+
+	// Snapshots
+	TypeSaveSnapshot            MessageType = "save_snapshot"
+	TypeSaveSnapshotResponse    MessageType = "save_snapshot_response"
+	TypeRestoreSessions         MessageType = "restore_sessions"
+	TypeRestoreSessionsResponse MessageType = "restore_sessions_response"
+
+	// Pane Introspection
+	TypePaneInfo         MessageType = "pane_info"
+	TypePaneInfoResponse MessageType = "pane_info_response"
+
+	// Monitoring
+	TypePaneAlert                MessageType = "pane_alert"
+	TypeSetPaneMonitor           MessageType = "set_pane_monitor"
+	TypeSetPaneMonitorResponse   MessageType = "set_pane_monitor_response"
+	TypeListPaneMonitors         MessageType = "list_pane_monitors"
+	TypeListPaneMonitorsResponse MessageType = "list_pane_monitors_response"
+
+	// Output Watchers
+	TypeCreateWatcher         MessageType = "create_watcher"
+	TypeCreateWatcherResponse MessageType = "create_watcher_response"
+	TypeListWatchers          MessageType = "list_watchers"
+	TypeListWatchersResponse  MessageType = "list_watchers_response"
+	TypePauseWatcher          MessageType = "pause_watcher"
+	TypePauseWatcherResponse  MessageType = "pause_watcher_response"
+	TypeResumeWatcher         MessageType = "resume_watcher"
+	TypeResumeWatcherResponse MessageType = "resume_watcher_response"
+	TypeDeleteWatcher         MessageType = "delete_watcher"
+	TypeDeleteWatcherResponse MessageType = "delete_watcher_response"
+	TypeWatcherMatch          MessageType = "watcher_match"
+
+	// Push Notifications
+	TypeGetPushConfig           MessageType = "get_push_config"
+	TypeGetPushConfigResponse   MessageType = "get_push_config_response"
+	TypeSubscribePush           MessageType = "subscribe_push"
+	TypeSubscribePushResponse   MessageType = "subscribe_push_response"
+	TypeUnsubscribePush         MessageType = "unsubscribe_push"
+	TypeUnsubscribePushResponse MessageType = "unsubscribe_push_response"
+
+	// Agent Prompts
+	TypeAgentState              MessageType = "agent_state"
+	TypeListAgentStates         MessageType = "list_agent_states"
+	TypeListAgentStatesResponse MessageType = "list_agent_states_response"
+	TypeRespondPrompt           MessageType = "respond_prompt"
+	TypeRespondPromptResponse   MessageType = "respond_prompt_response"
+
+	// Artifacts
+	TypeExtractArtifacts         MessageType = "extract_artifacts"
+	TypeExtractArtifactsResponse MessageType = "extract_artifacts_response"
+
+	// Output Search
+	TypeSearchOutput         MessageType = "search_output"
+	TypeSearchOutputResponse MessageType = "search_output_response"
+
+	// Recording
+	TypeStartRecording          MessageType = "start_recording"
+	TypeStartRecordingResponse  MessageType = "start_recording_response"
+	TypeStopRecording           MessageType = "stop_recording"
+	TypeStopRecordingResponse   MessageType = "stop_recording_response"
+	TypeListRecordings          MessageType = "list_recordings"
+	TypeListRecordingsResponse  MessageType = "list_recordings_response"
+	TypeDeleteRecording         MessageType = "delete_recording"
+	TypeDeleteRecordingResponse MessageType = "delete_recording_response"
+	TypePlayRecording           MessageType = "play_recording"
+	TypePlayRecordingResponse   MessageType = "play_recording_response"
+	TypeStopPlayback            MessageType = "stop_playback"
+	TypeStopPlaybackResponse    MessageType = "stop_playback_response"
+	TypePlaybackEnded           MessageType = "playback_ended"
+
+	// Transcripts
+	TypeExportTranscript         MessageType = "export_transcript"
+	TypeExportTranscriptResponse MessageType = "export_transcript_response"
+
+	// Command History
+	TypeGetHistory            MessageType = "get_history"
+	TypeGetHistoryResponse    MessageType = "get_history_response"
+	TypeSearchHistory         MessageType = "search_history"
+	TypeSearchHistoryResponse MessageType = "search_history_response"
+	TypeSaveSnippet           MessageType = "save_snippet"
+	TypeSaveSnippetResponse   MessageType = "save_snippet_response"
+	TypeListSnippets          MessageType = "list_snippets"
+	TypeListSnippetsResponse  MessageType = "list_snippets_response"
+	TypeRunSnippet            MessageType = "run_snippet"
+	TypeRunSnippetResponse    MessageType = "run_snippet_response"
+	TypeDeleteSnippet         MessageType = "delete_snippet"
+	TypeDeleteSnippetResponse MessageType = "delete_snippet_response"
+
+	// Completion
+	TypeComplete         MessageType = "complete"
+	TypeCompleteResponse MessageType = "complete_response"
+
+	// Files
+	TypeListDirectory         MessageType = "list_directory"
+	TypeListDirectoryResponse MessageType = "list_directory_response"
+	TypeReadFile              MessageType = "read_file"
+	TypeReadFileResponse      MessageType = "read_file_response"
+	TypeWriteFile             MessageType = "write_file"
+	TypeWriteFileResponse     MessageType = "write_file_response"
+
+	// Paste buffers
+	TypeListBuffers           MessageType = "list_buffers"
+	TypeListBuffersResponse   MessageType = "list_buffers_response"
+	TypeGetBuffer             MessageType = "get_buffer"
+	TypeGetBufferResponse     MessageType = "get_buffer_response"
+	TypeSetBuffer             MessageType = "set_buffer"
+	TypeSetBufferResponse     MessageType = "set_buffer_response"
+	TypePasteBuffer           MessageType = "paste_buffer"
+	TypePasteBufferResponse   MessageType = "paste_buffer_response"
+	TypeCopySelection         MessageType = "copy_selection"
+	TypeCopySelectionResponse MessageType = "copy_selection_response"
+
+	// Input
+	TypeSendInput         MessageType = "send_input"
+	TypeSendInputResponse MessageType = "send_input_response"
+
+	// Sizing
+	TypeResize         MessageType = "resize"
+	TypeResizeResponse MessageType = "resize_response"
+
+	// Terminal attachment
+	TypeAttach               MessageType = "attach"
+	TypeAttachResponse       MessageType = "attach_response"
+	TypeAttachResize         MessageType = "attach_resize"
+	TypeAttachResizeResponse MessageType = "attach_resize_response"
+	TypeDetach               MessageType = "detach"
+	TypeDetachResponse       MessageType = "detach_response"
+	TypeDetached             MessageType = "detached" // Event: the tmux client exited
+
+	// tmux sockets
+	TypeListSockets         MessageType = "list_sockets"
+	TypeListSocketsResponse MessageType = "list_sockets_response"
+
+	// Remote hosts
+	TypeListHosts         MessageType = "list_hosts"
+	TypeListHostsResponse MessageType = "list_hosts_response"
+
 	// Error
 	TypeError MessageType = "error"
 )
@@ -68,11 +223,21 @@ func NewMessage(id string, msgType MessageType, payload interface{}) *Message {
 
 // Session represents a tmux session
 type Session struct {
-	ID        string    `json:"id"`
-	Name      string    `json:"name"`
-	Windows   []Window  `json:"windows"`
-	CreatedAt int64     `json:"created_at"`
-	Attached  bool      `json:"attached"`
+	ID              string   `json:"id"`
+	Name            string   `json:"name"`
+	Windows         []Window `json:"windows"`
+	CreatedAt       int64    `json:"created_at"`              // Unix ms
+	Attached        bool     `json:"attached"`                // At least one client attached
+	AttachedClients int      `json:"attached_clients"`        // Number of attached clients
+	LastActivity    int64    `json:"last_activity"`           // Unix ms of the last activity in the session
+	LastAttached    int64    `json:"last_attached,omitempty"` // Unix ms a client last attached, 0 if never
+	WindowCount     int      `json:"window_count"`
+	Width           int      `json:"width"`  // Size of the current window
+	Height          int      `json:"height"` // Size of the current window
+	Group           string   `json:"group,omitempty"`
+	GroupSize       int      `json:"group_size,omitempty"`
+	Socket          string   `json:"socket,omitempty"` // tmux socket of the session's server, "" for the default server
+	Host            string   `json:"host,omitempty"`   // Remote host of the session's server, "" for local sessions
 }
 
 // Window represents a tmux window
@@ -82,6 +247,55 @@ type Window struct {
 	Index  int    `json:"index"`
 	Active bool   `json:"active"`
 	PaneID string `json:"pane_id"`
+	Layout string `json:"layout,omitempty"` // tmux layout string, e.g. "b262,80x24,0,0,5"
+	Panes  []Pane `json:"panes,omitempty"`
+}
+
+// Pane represents a tmux pane
+type Pane struct {
+	ID             string    `json:"id"` // tmux pane ID, e.g. "%3"
+	Index          int       `json:"index"`
+	Active         bool      `json:"active"`
+	Width          int       `json:"width"`
+	Height         int       `json:"height"`
+	CurrentCommand string    `json:"current_command"`
+	CurrentPath    string    `json:"current_path"`
+	PID            int       `json:"pid"`
+	Processes      []Process `json:"processes,omitempty"` // Pane process and its descendants, parents first
+	Host           string    `json:"host,omitempty"`      // Remote host of the pane, "" for local panes
+}
+
+// Process represents a process running in a pane
+type Process struct {
+	PID        int     `json:"pid"`
+	PPID       int     `json:"ppid"`
+	Command    string  `json:"command"`
+	Args       string  `json:"args"`
+	CPUPercent float64 `json:"cpu_percent"`
+	MemoryRSS  int64   `json:"memory_rss"` // Resident set size in bytes
+}
+
+// Schedule represents a command scheduled to run in a tmux session
+type Schedule struct {
+	ID          string        `json:"id"`
+	Name        string        `json:"name,omitempty"`
+	Spec        string        `json:"spec,omitempty"`   // Cron expression, e.g. "0 2 * * *" or "@every 15m"
+	RunAt       int64         `json:"run_at,omitempty"` // One-shot run time (unix ms), used when Spec is empty
+	SessionName string        `json:"session_name"`
+	WindowIndex *int          `json:"window_index,omitempty"`
+	Command     string        `json:"command"`
+	Paused      bool          `json:"paused"`
+	CreatedAt   int64         `json:"created_at"`
+	LastRunAt   int64         `json:"last_run_at,omitempty"`
+	NextRunAt   int64         `json:"next_run_at,omitempty"`
+	History     []ScheduleRun `json:"history,omitempty"`
+}
+
+// ScheduleRun records a single execution of a schedule
+type ScheduleRun struct {
+	StartedAt int64  `json:"started_at"`
+	Success   bool   `json:"success"`
+	Error     string `json:"error,omitempty"`
 }
 
 // Payloads
@@ -100,14 +314,39 @@ type ConnectAckPayload struct {
 	EncryptionEnabled bool   `json:"encryption_enabled"`
 }
 
+// ListSessionsPayload is the payload for list_sessions message
+type ListSessionsPayload struct {
+	SortBy string `json:"sort_by,omitempty"` // Optional: name (default), created, activity or attached
+}
+
 // ListSessionsResponse is the payload for list_sessions_response
 type ListSessionsResponse struct {
 	Sessions []Session `json:"sessions"`
 }
 
+// SessionOptions are optional settings applied when creating a session
+type SessionOptions struct {
+	StartDirectory string            `json:"start_directory,omitempty"` // Working directory of the first window
+	Command        string            `json:"command,omitempty"`         // Shell command run in the first window instead of the default shell
+	Environment    map[string]string `json:"environment,omitempty"`     // Extra environment variables for the session
+	Width          int               `json:"width,omitempty"`           // Initial width in columns
+	Height         int               `json:"height,omitempty"`          // Initial height in rows
+	WindowName     string            `json:"window_name,omitempty"`     // Name of the first window
+	Socket         string            `json:"socket,omitempty"`          // tmux socket to create the session on, "" for the default server
+	Host           string            `json:"host,omitempty"`            // Remote host to create the session on
+}
+
+// WindowOptions are optional settings applied when creating a window
+type WindowOptions struct {
+	StartDirectory string            `json:"start_directory,omitempty"`
+	Command        string            `json:"command,omitempty"`
+	Environment    map[string]string `json:"environment,omitempty"`
+}
+
 // CreateSessionPayload is the payload for create_session message
 type CreateSessionPayload struct {
 	Name string `json:"name"`
+	SessionOptions
 }
 
 // CreateSessionResponse is the payload for create_session_response
@@ -168,6 +407,7 @@ type SwitchWindowResponse struct {
 type CreateWindowPayload struct {
 	SessionName string `json:"session_name"`
 	WindowName  string `json:"window_name,omitempty"`
+	WindowOptions
 }
 
 // CreateWindowResponse is the payload for create_window_response
@@ -208,6 +448,7 @@ type TerminalOutputPayload struct {
 	SessionName string `json:"session_name"`
 	Output      string `json:"output"`
 	Sequence    int64  `json:"sequence"`
+	RecordingID string `json:"recording_id,omitempty"` // Set when replaying a recording
 }
 
 // CaptureOutputPayload is the payload for capture_output message
@@ -222,6 +463,926 @@ type CaptureOutputResponse struct {
 	Output      string `json:"output"`
 }
 
+// CreateSchedulePayload is the payload for create_schedule message
+type CreateSchedulePayload struct {
+	Name        string `json:"name,omitempty"`
+	Spec        string `json:"spec,omitempty"`
+	RunAt       int64  `json:"run_at,omitempty"`
+	SessionName string `json:"session_name"`
+	WindowIndex *int   `json:"window_index,omitempty"`
+	Command     string `json:"command"`
+}
+
+// ScheduleResponse is the payload for create/pause/resume schedule responses
+type ScheduleResponse struct {
+	Success  bool      `json:"success"`
+	Schedule *Schedule `json:"schedule,omitempty"`
+}
+
+// ListSchedulesResponse is the payload for list_schedules_response
+type ListSchedulesResponse struct {
+	Schedules []Schedule `json:"schedules"`
+}
+
+// ScheduleIDPayload is the payload for messages addressing a single schedule
+type ScheduleIDPayload struct {
+	ScheduleID string `json:"schedule_id"`
+}
+
+// DeleteScheduleResponse is the payload for delete_schedule_response
+type DeleteScheduleResponse struct {
+	Success    bool   `json:"success"`
+	ScheduleID string `json:"schedule_id"`
+}
+
+// ScheduleHistoryResponse is the payload for get_schedule_history_response
+type ScheduleHistoryResponse struct {
+	ScheduleID string        `json:"schedule_id"`
+	Runs       []ScheduleRun `json:"runs"`
+}
+
+// TemplateInfo describes a workspace template available on the server
+type TemplateInfo struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Session     string            `json:"session,omitempty"`
+	Variables   map[string]string `json:"variables,omitempty"` // Variables and their default values
+	Windows     []string          `json:"windows"`             // Window names
+}
+
+// ListTemplatesResponse is the payload for list_templates_response
+type ListTemplatesResponse struct {
+	Templates []TemplateInfo `json:"templates"`
+}
+
+// CreateSessionFromTemplatePayload is the payload for create_session_from_template message
+type CreateSessionFromTemplatePayload struct {
+	Template    string            `json:"template"`
+	SessionName string            `json:"session_name,omitempty"` // Optional: overrides the template's session name
+	Variables   map[string]string `json:"variables,omitempty"`
+}
+
+// SaveSnapshotResponse is the payload for save_snapshot_response
+type SaveSnapshotResponse struct {
+	Success   bool  `json:"success"`
+	CreatedAt int64 `json:"created_at"`
+	Sessions  int   `json:"sessions"`
+}
+
+// RestoreSessionsPayload is the payload for restore_sessions message
+type RestoreSessionsPayload struct {
+	Sessions []string `json:"sessions,omitempty"` // Optional: only restore these sessions
+	Dismiss  bool     `json:"dismiss,omitempty"`  // Drop the sessions from the snapshot instead of restoring them
+}
+
+// RestoreSessionsResponse is the payload for restore_sessions_response
+type RestoreSessionsResponse struct {
+	Success           bool             `json:"success"`
+	SnapshotCreatedAt int64            `json:"snapshot_created_at"`
+	Restored          []string         `json:"restored"`
+	Skipped           []string         `json:"skipped"` // Sessions that already exist
+	Failed            []RestoreFailure `json:"failed"`
+	Dismissed         []string         `json:"dismissed,omitempty"` // Sessions dropped with dismiss
+}
+
+// RestoreFailure describes a session that could not be restored
+type RestoreFailure struct {
+	SessionName string `json:"session_name"`
+	Error       string `json:"error"`
+}
+
+// PaneInfoPayload is the payload for pane_info message
+// Without a window or pane index the active one is used.
+type PaneInfoPayload struct {
+	SessionName string `json:"session_name"`
+	WindowIndex *int   `json:"window_index,omitempty"`
+	PaneIndex   *int   `json:"pane_index,omitempty"`
+}
+
+// PaneInfoResponse is the response for pane_info
+type PaneInfoResponse struct {
+	SessionName string `json:"session_name"`
+	WindowIndex int    `json:"window_index"`
+	Pane        *Pane  `json:"pane"`
+}
+
+// PaneLocation identifies a pane and where it currently lives
+type PaneLocation struct {
+	SessionName string `json:"session_name"`
+	WindowIndex int    `json:"window_index"`
+	PaneIndex   int    `json:"pane_index"`
+	PaneID      string `json:"pane_id"` // tmux pane ID, stable for the pane's lifetime
+}
+
+// Alert types
+const (
+	AlertBell     = "bell"
+	AlertActivity = "activity"
+	AlertSilence  = "silence"
+)
+
+// PaneAlertPayload is the payload for pane_alert events pushed to clients
+type PaneAlertPayload struct {
+	PaneLocation
+	AlertType string   `json:"alert_type"`
+	Lines     []string `json:"lines"` // Output that triggered the alert, or the last output before silence
+}
+
+// PaneMonitor holds the monitoring settings of a pane
+type PaneMonitor struct {
+	PaneLocation
+	Bell          bool `json:"bell"`
+	ActivityAfter int  `json:"activity_after"` // Seconds of idleness after which new output alerts, 0 disables
+	SilenceAfter  int  `json:"silence_after"`  // Seconds without output after which to alert, 0 disables
+}
+
+// SetPaneMonitorPayload is the payload for set_pane_monitor message
+// Without a window or pane index the active one is used. Fields that are
+// not set keep the server defaults; reset removes the pane's settings.
+type SetPaneMonitorPayload struct {
+	SessionName   string `json:"session_name"`
+	WindowIndex   *int   `json:"window_index,omitempty"`
+	PaneIndex     *int   `json:"pane_index,omitempty"`
+	Bell          *bool  `json:"bell,omitempty"`
+	ActivityAfter *int   `json:"activity_after,omitempty"`
+	SilenceAfter  *int   `json:"silence_after,omitempty"`
+	Reset         bool   `json:"reset,omitempty"`
+}
+
+// SetPaneMonitorResponse is the response for set_pane_monitor
+type SetPaneMonitorResponse struct {
+	Success bool        `json:"success"`
+	Monitor PaneMonitor `json:"monitor"`
+}
+
+// ListPaneMonitorsResponse is the response for list_pane_monitors
+type ListPaneMonitorsResponse struct {
+	Defaults PaneMonitor   `json:"defaults"` // Settings of panes without their own
+	Monitors []PaneMonitor `json:"monitors"`
+}
+
+// Watcher fires when pane output matches one of its regular expressions
+type Watcher struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name,omitempty"`
+	SessionName string   `json:"session_name,omitempty"` // Empty watches every session
+	WindowIndex *int     `json:"window_index,omitempty"`
+	PaneIndex   *int     `json:"pane_index,omitempty"`
+	Patterns    []string `json:"patterns"` // Go regular expressions, any of which matches
+	IgnoreCase  bool     `json:"ignore_case,omitempty"`
+	Webhook     string   `json:"webhook,omitempty"`  // URL that receives each match as a JSON POST
+	Command     string   `json:"command,omitempty"`  // Local command run on each match with HANDX_* variables set
+	Once        bool     `json:"once,omitempty"`     // Pause after the first match
+	Cooldown    int      `json:"cooldown,omitempty"` // Seconds after a match during which further matches are ignored
+	Paused      bool     `json:"paused"`
+	CreatedAt   int64    `json:"created_at"`
+	LastMatchAt int64    `json:"last_match_at,omitempty"`
+	MatchCount  int      `json:"match_count"`
+}
+
+// CreateWatcherPayload is the payload for create_watcher message
+type CreateWatcherPayload struct {
+	Name        string   `json:"name,omitempty"`
+	SessionName string   `json:"session_name,omitempty"`
+	WindowIndex *int     `json:"window_index,omitempty"`
+	PaneIndex   *int     `json:"pane_index,omitempty"`
+	Patterns    []string `json:"patterns"`
+	IgnoreCase  bool     `json:"ignore_case,omitempty"`
+	Webhook     string   `json:"webhook,omitempty"`
+	Command     string   `json:"command,omitempty"`
+	Once        bool     `json:"once,omitempty"`
+	Cooldown    int      `json:"cooldown,omitempty"`
+}
+
+// WatcherResponse is the payload for create/pause/resume watcher responses
+type WatcherResponse struct {
+	Success bool     `json:"success"`
+	Watcher *Watcher `json:"watcher,omitempty"`
+}
+
+// ListWatchersResponse is the payload for list_watchers_response
+type ListWatchersResponse struct {
+	Watchers []Watcher `json:"watchers"`
+}
+
+// WatcherIDPayload is the payload for messages addressing a single watcher
+type WatcherIDPayload struct {
+	WatcherID string `json:"watcher_id"`
+}
+
+// DeleteWatcherResponse is the payload for delete_watcher_response
+type DeleteWatcherResponse struct {
+	Success   bool   `json:"success"`
+	WatcherID string `json:"watcher_id"`
+}
+
+// WatcherMatchPayload is the payload for watcher_match events, also posted to webhooks
+type WatcherMatchPayload struct {
+	PaneLocation
+	WatcherID   string   `json:"watcher_id"`
+	WatcherName string   `json:"watcher_name,omitempty"`
+	Pattern     string   `json:"pattern"` // First pattern that matched
+	Lines       []string `json:"lines"`   // Matching lines
+	MatchedAt   int64    `json:"matched_at"`
+}
+
+// GetPushConfigResponse is the response for get_push_config
+type GetPushConfigResponse struct {
+	Enabled   bool   `json:"enabled"`
+	PublicKey string `json:"public_key,omitempty"` // VAPID application server key, base64url encoded
+}
+
+// PushSubscription is a browser Web Push subscription, as returned by PushSubscription.toJSON()
+type PushSubscription struct {
+	Endpoint string               `json:"endpoint"`
+	Keys     PushSubscriptionKeys `json:"keys"`
+}
+
+// PushSubscriptionKeys are the client keys used to encrypt push messages
+type PushSubscriptionKeys struct {
+	P256dh string `json:"p256dh"`
+	Auth   string `json:"auth"`
+}
+
+// SubscribePushResponse is the response for subscribe_push and unsubscribe_push
+type SubscribePushResponse struct {
+	Success  bool   `json:"success"`
+	Endpoint string `json:"endpoint"`
+}
+
+// Agent states
+const (
+	AgentIdle            = "idle"
+	AgentRunning         = "running"
+	AgentWaitingForInput = "waiting_for_input"
+	AgentErrored         = "errored"
+)
+
+// Prompt types
+const (
+	PromptYesNo  = "yes_no"
+	PromptChoice = "choice"
+)
+
+// PromptOption is one answer to a prompt
+type PromptOption struct {
+	Key   string `json:"key"` // Input sent to choose the option
+	Label string `json:"label"`
+}
+
+// AgentStatePayload is the payload for agent_state events, sent when a
+// pane running an AI agent changes state
+type AgentStatePayload struct {
+	PaneLocation
+	State      string         `json:"state"`
+	PromptType string         `json:"prompt_type,omitempty"` // Set while waiting for input
+	Prompt     string         `json:"prompt,omitempty"`      // The question asked
+	Options    []PromptOption `json:"options,omitempty"`
+	Tool       string         `json:"tool,omitempty"`  // Tool being run, if shown
+	Error      string         `json:"error,omitempty"` // Error message when errored
+	ChangedAt  int64          `json:"changed_at"`      // Unix ms
+}
+
+// ListAgentStatesResponse is the response for list_agent_states
+type ListAgentStatesResponse struct {
+	States []AgentStatePayload `json:"states"`
+}
+
+// RespondPromptPayload is the payload for respond_prompt message
+// Either the index of one of the prompt's options or free text is sent.
+// Without a window or pane index the active one is used.
+type RespondPromptPayload struct {
+	SessionName string `json:"session_name"`
+	WindowIndex *int   `json:"window_index,omitempty"`
+	PaneIndex   *int   `json:"pane_index,omitempty"`
+	Option      *int   `json:"option,omitempty"`
+	Text        string `json:"text,omitempty"`
+}
+
+// RespondPromptResponse is the response for respond_prompt
+type RespondPromptResponse struct {
+	PaneLocation
+	Success bool   `json:"success"`
+	Sent    string `json:"sent"` // Input typed into the pane
+}
+
+// Artifact types
+const (
+	ArtifactCode     = "code"
+	ArtifactDiff     = "diff"
+	ArtifactJSON     = "json"
+	ArtifactMarkdown = "markdown"
+)
+
+// DiffStats summarizes a diff artifact
+type DiffStats struct {
+	Files     int `json:"files"`
+	Additions int `json:"additions"`
+	Deletions int `json:"deletions"`
+}
+
+// Artifact is a code block, diff, JSON document or markdown section found in pane output
+type Artifact struct {
+	Type      string     `json:"type"`
+	Language  string     `json:"language,omitempty"` // From the code fence, e.g. "go"
+	StartLine int        `json:"start_line"`         // 1-based line in the captured output, inclusive, excluding code fences
+	EndLine   int        `json:"end_line"`
+	Content   string     `json:"content"` // Without the fence lines
+	Diff      *DiffStats `json:"diff,omitempty"`
+}
+
+// ExtractArtifactsPayload is the payload for extract_artifacts message
+// Without a window or pane index the active one is used.
+type ExtractArtifactsPayload struct {
+	SessionName string   `json:"session_name"`
+	WindowIndex *int     `json:"window_index,omitempty"`
+	PaneIndex   *int     `json:"pane_index,omitempty"`
+	Lines       int      `json:"lines,omitempty"` // Scrollback lines to search besides the screen, default 1000
+	Types       []string `json:"types,omitempty"` // Artifact types to return, default all
+}
+
+// ExtractArtifactsResponse is the response for extract_artifacts
+type ExtractArtifactsResponse struct {
+	PaneLocation
+	LineCount int        `json:"line_count"` // Lines of output searched
+	Artifacts []Artifact `json:"artifacts"`
+}
+
+// Case options of output searches
+const (
+	CaseSmart       = "smart" // Case sensitive only if the query has upper case letters
+	CaseSensitive   = "sensitive"
+	CaseInsensitive = "insensitive"
+)
+
+// SearchOutputPayload is the payload for search_output message
+// Without a session name all sessions are searched, without a window or pane
+// index all windows or panes of the session.
+type SearchOutputPayload struct {
+	Query       string `json:"query"`
+	Regex       bool   `json:"regex,omitempty"` // Query is a regular expression rather than literal text
+	Case        string `json:"case,omitempty"`  // smart (default), sensitive or insensitive
+	SessionName string `json:"session_name,omitempty"`
+	WindowIndex *int   `json:"window_index,omitempty"`
+	PaneIndex   *int   `json:"pane_index,omitempty"`
+	Context     int    `json:"context,omitempty"`     // Lines before and after each match, default 2, -1 for none
+	MaxResults  int    `json:"max_results,omitempty"` // Default 100
+	Lines       int    `json:"lines,omitempty"`       // Trailing lines of output searched per pane, default all
+}
+
+// SearchMatch is a line of pane output matching a search
+type SearchMatch struct {
+	PaneLocation
+	Line       int      `json:"line"` // 1-based line in the pane's output
+	Text       string   `json:"text"`
+	MatchStart int      `json:"match_start"` // Character offsets of the first match in text
+	MatchEnd   int      `json:"match_end"`
+	Before     []string `json:"before"`
+	After      []string `json:"after"`
+}
+
+// SearchOutputResponse is the response for search_output
+type SearchOutputResponse struct {
+	Matches       []SearchMatch `json:"matches"`
+	PanesSearched int           `json:"panes_searched"`
+	PanesIndexed  int           `json:"panes_indexed"` // Searched panes served from the output index
+	Truncated     bool          `json:"truncated"`     // More matches than max_results
+}
+
+// Recording is an asciicast v2 recording of a pane's output
+type Recording struct {
+	ID string `json:"id"`
+	PaneLocation
+	Title     string  `json:"title,omitempty"`
+	Width     int     `json:"width"`
+	Height    int     `json:"height"`
+	StartedAt int64   `json:"started_at"`
+	StoppedAt int64   `json:"stopped_at,omitempty"`
+	Duration  float64 `json:"duration"` // Seconds from the start to the last output
+	Size      int64   `json:"size"`     // Bytes of the asciicast file
+	Active    bool    `json:"active"`
+	URL       string  `json:"url"` // Download path of the asciicast file
+}
+
+// StartRecordingPayload is the payload for start_recording message
+// Without a window or pane index the active one is recorded.
+type StartRecordingPayload struct {
+	SessionName string `json:"session_name"`
+	WindowIndex *int   `json:"window_index,omitempty"`
+	PaneIndex   *int   `json:"pane_index,omitempty"`
+	Title       string `json:"title,omitempty"`
+}
+
+// StopRecordingPayload is the payload for stop_recording message
+// Either the recording ID or the recorded pane is given.
+type StopRecordingPayload struct {
+	RecordingID string `json:"recording_id,omitempty"`
+	SessionName string `json:"session_name,omitempty"`
+	WindowIndex *int   `json:"window_index,omitempty"`
+	PaneIndex   *int   `json:"pane_index,omitempty"`
+}
+
+// RecordingResponse is the response for start_recording and stop_recording
+type RecordingResponse struct {
+	Success   bool       `json:"success"`
+	Recording *Recording `json:"recording,omitempty"`
+}
+
+// ListRecordingsResponse is the response for list_recordings, newest first
+type ListRecordingsResponse struct {
+	Recordings []Recording `json:"recordings"`
+}
+
+// RecordingIDPayload is the payload for messages addressing a single recording
+type RecordingIDPayload struct {
+	RecordingID string `json:"recording_id"`
+}
+
+// DeleteRecordingResponse is the response for delete_recording
+type DeleteRecordingResponse struct {
+	Success     bool   `json:"success"`
+	RecordingID string `json:"recording_id"`
+}
+
+// PlayRecordingPayload is the payload for play_recording message
+// The recording is replayed as terminal_output messages followed by
+// playback_ended; a client plays one recording at a time.
+type PlayRecordingPayload struct {
+	RecordingID string  `json:"recording_id"`
+	Speed       float64 `json:"speed,omitempty"`    // Playback speed factor, default 1
+	MaxIdle     float64 `json:"max_idle,omitempty"` // Longest pause in seconds, default unlimited
+}
+
+// PlayRecordingResponse is the response for play_recording
+type PlayRecordingResponse struct {
+	Success   bool       `json:"success"`
+	Recording *Recording `json:"recording"`
+	Speed     float64    `json:"speed"`
+}
+
+// StopPlaybackResponse is the response for stop_playback
+type StopPlaybackResponse struct {
+	Success bool `json:"success"`
+	Stopped bool `json:"stopped"` // A playback was running
+}
+
+// PlaybackEndedPayload is the payload for playback_ended events
+type PlaybackEndedPayload struct {
+	RecordingID string `json:"recording_id"`
+	Completed   bool   `json:"completed"` // false if stopped early
+}
+
+// Transcript formats
+const (
+	TranscriptText     = "text"
+	TranscriptHTML     = "html" // Standalone page keeping colors
+	TranscriptMarkdown = "markdown"
+)
+
+// Transcript scopes
+const (
+	ScopePane    = "pane"
+	ScopeWindow  = "window"
+	ScopeSession = "session"
+)
+
+// ExportTranscriptPayload is the payload for export_transcript message
+// Without a window or pane index the active one is used.
+type ExportTranscriptPayload struct {
+	SessionName string `json:"session_name"`
+	WindowIndex *int   `json:"window_index,omitempty"`
+	PaneIndex   *int   `json:"pane_index,omitempty"`
+	Scope       string `json:"scope,omitempty"`  // pane (default), window or session
+	Format      string `json:"format,omitempty"` // text (default), html or markdown
+	Lines       int    `json:"lines,omitempty"`  // Scrollback lines per pane, default all
+}
+
+// ExportTranscriptResponse is the response for export_transcript
+type ExportTranscriptResponse struct {
+	Success   bool   `json:"success"`
+	URL       string `json:"url"`        // Download path, valid until expires_at
+	ExpiresAt int64  `json:"expires_at"` // Unix milliseconds
+	Format    string `json:"format"`
+	Scope     string `json:"scope"`
+	FileName  string `json:"file_name"`
+	Size      int    `json:"size"`
+	Panes     int    `json:"panes"`
+}
+
+// HistoryEntry is a distinct command executed through execute_command
+type HistoryEntry struct {
+	Command     string   `json:"command"`
+	SessionName string   `json:"session_name,omitempty"` // Empty in the global history
+	Sessions    []string `json:"sessions,omitempty"`     // Sessions it ran in, global history only
+	Count       int      `json:"count"`
+	FirstUsedAt int64    `json:"first_used_at"`
+	LastUsedAt  int64    `json:"last_used_at"`
+}
+
+// GetHistoryPayload is the payload for get_history message
+// Without a session name the global history is returned.
+type GetHistoryPayload struct {
+	SessionName string `json:"session_name,omitempty"`
+	Limit       int    `json:"limit,omitempty"` // Default 100
+}
+
+// SearchHistoryPayload is the payload for search_history message
+type SearchHistoryPayload struct {
+	Query       string `json:"query"` // Words that must all appear, ignoring case
+	SessionName string `json:"session_name,omitempty"`
+	Limit       int    `json:"limit,omitempty"`
+}
+
+// HistoryResponse is the response for get_history and search_history,
+// most recently used first
+type HistoryResponse struct {
+	Entries []HistoryEntry `json:"entries"`
+}
+
+// SnippetParam is a {{name}} or {{name|default}} placeholder in a snippet
+type SnippetParam struct {
+	Name       string `json:"name"`
+	Default    string `json:"default,omitempty"`
+	HasDefault bool   `json:"has_default"`
+}
+
+// Snippet is a named command template
+type Snippet struct {
+	ID          string         `json:"id"`
+	Name        string         `json:"name"`
+	Command     string         `json:"command"`
+	Description string         `json:"description,omitempty"`
+	Params      []SnippetParam `json:"params"`
+	CreatedAt   int64          `json:"created_at"`
+	UpdatedAt   int64          `json:"updated_at"`
+}
+
+// SaveSnippetPayload is the payload for save_snippet message
+// A snippet with the same name is replaced.
+type SaveSnippetPayload struct {
+	Name        string `json:"name"`
+	Command     string `json:"command"` // Parameters are written {{name}} or {{name|default}}
+	Description string `json:"description,omitempty"`
+}
+
+// SnippetResponse is the response for save_snippet
+type SnippetResponse struct {
+	Success bool     `json:"success"`
+	Snippet *Snippet `json:"snippet,omitempty"`
+}
+
+// ListSnippetsResponse is the response for list_snippets
+type ListSnippetsResponse struct {
+	Snippets []Snippet `json:"snippets"`
+}
+
+// RunSnippetPayload is the payload for run_snippet message
+// The snippet is chosen by ID, or else by name.
+type RunSnippetPayload struct {
+	SnippetID   string            `json:"snippet_id,omitempty"`
+	Name        string            `json:"name,omitempty"`
+	SessionName string            `json:"session_name"`
+	WindowIndex *int              `json:"window_index,omitempty"`
+	Params      map[string]string `json:"params,omitempty"`
+}
+
+// RunSnippetResponse is the response for run_snippet
+type RunSnippetResponse struct {
+	Success     bool   `json:"success"`
+	SessionName string `json:"session_name"`
+	Command     string `json:"command"` // The command executed
+}
+
+// SnippetIDPayload is the payload for messages addressing a single snippet
+type SnippetIDPayload struct {
+	SnippetID string `json:"snippet_id"`
+}
+
+// DeleteSnippetResponse is the response for delete_snippet
+type DeleteSnippetResponse struct {
+	Success   bool   `json:"success"`
+	SnippetID string `json:"snippet_id"`
+}
+
+// Completion kinds
+const (
+	CompletionCommand   = "command" // Executable on PATH
+	CompletionPath      = "path"
+	CompletionDirectory = "directory"
+	CompletionBranch    = "branch"
+	CompletionHistory   = "history" // Whole command line from the history
+)
+
+// CompletePayload is the payload for complete message
+// Input is the command line up to the cursor. Without a window or pane index
+// the active one is used.
+type CompletePayload struct {
+	SessionName string `json:"session_name"`
+	WindowIndex *int   `json:"window_index,omitempty"`
+	PaneIndex   *int   `json:"pane_index,omitempty"`
+	Input       string `json:"input"`
+	Limit       int    `json:"limit,omitempty"` // Default 50
+}
+
+// Completion replaces input from start, a character offset, to its end with text
+type Completion struct {
+	Text    string `json:"text"`
+	Display string `json:"display,omitempty"` // Shorter label, e.g. the file name of a path
+	Kind    string `json:"kind"`
+	Start   int    `json:"start"`
+}
+
+// CompleteResponse is the response for complete
+type CompleteResponse struct {
+	PaneLocation
+	CurrentPath string       `json:"current_path"` // Directory relative paths were completed in
+	Completions []Completion `json:"completions"`
+}
+
+// File entry types
+const (
+	FileTypeFile      = "file"
+	FileTypeDirectory = "directory"
+	FileTypeOther     = "other" // Devices, sockets, pipes and broken symlinks
+)
+
+// File content encodings
+const (
+	EncodingUTF8   = "utf8"
+	EncodingBase64 = "base64"
+)
+
+// FileEntry describes a file or directory
+type FileEntry struct {
+	Name       string `json:"name"`
+	Path       string `json:"path"` // Absolute path on the server
+	Type       string `json:"type"`
+	Symlink    bool   `json:"symlink,omitempty"` // Type is that of the link target
+	Size       int64  `json:"size"`
+	Mode       string `json:"mode"` // e.g. "-rw-r--r--"
+	ModifiedAt int64  `json:"modified_at"`
+}
+
+// ListDirectoryPayload is the payload for list_directory message
+// Relative paths, and an empty one, are resolved against the current path
+// of the pane, or the first root without a session name.
+type ListDirectoryPayload struct {
+	SessionName string `json:"session_name,omitempty"`
+	WindowIndex *int   `json:"window_index,omitempty"`
+	PaneIndex   *int   `json:"pane_index,omitempty"`
+	Path        string `json:"path,omitempty"`
+	ShowHidden  bool   `json:"show_hidden,omitempty"`
+}
+
+// ListDirectoryResponse is the response for list_directory
+type ListDirectoryResponse struct {
+	Path    string      `json:"path"`
+	Parent  string      `json:"parent,omitempty"` // Empty at a root
+	Roots   []string    `json:"roots"`
+	Entries []FileEntry `json:"entries"` // Directories first, then by name
+}
+
+// ReadFilePayload is the payload for read_file message
+type ReadFilePayload struct {
+	SessionName string `json:"session_name,omitempty"`
+	WindowIndex *int   `json:"window_index,omitempty"`
+	PaneIndex   *int   `json:"pane_index,omitempty"`
+	Path        string `json:"path"`
+	Offset      int64  `json:"offset,omitempty"` // Byte offset, negative counts from the end
+	Length      int64  `json:"length,omitempty"` // Bytes to read, capped by files.max_read_bytes
+}
+
+// ReadFileResponse is the response for read_file
+type ReadFileResponse struct {
+	Path       string `json:"path"`
+	Size       int64  `json:"size"`
+	Offset     int64  `json:"offset"`
+	Length     int64  `json:"length"`    // Bytes in content
+	Truncated  bool   `json:"truncated"` // More of the file follows offset+length
+	Encoding   string `json:"encoding"`  // base64 for binary content
+	Content    string `json:"content"`
+	ModifiedAt int64  `json:"modified_at"`
+}
+
+// WriteFilePayload is the payload for write_file message
+type WriteFilePayload struct {
+	SessionName string `json:"session_name,omitempty"`
+	WindowIndex *int   `json:"window_index,omitempty"`
+	PaneIndex   *int   `json:"pane_index,omitempty"`
+	Path        string `json:"path"`
+	Content     string `json:"content"`
+	Encoding    string `json:"encoding,omitempty"`    // Default utf8
+	Append      bool   `json:"append,omitempty"`      // Append instead of replacing
+	Overwrite   bool   `json:"overwrite,omitempty"`   // Replace an existing file
+	CreateDirs  bool   `json:"create_dirs,omitempty"` // Create missing parent directories
+}
+
+// WriteFileResponse is the response for write_file
+type WriteFileResponse struct {
+	Success bool      `json:"success"`
+	File    FileEntry `json:"file"`
+}
+
+// UploadFilesResponse is the body returned by the upload HTTP endpoint
+type UploadFilesResponse struct {
+	Success bool        `json:"success"`
+	Files   []FileEntry `json:"files"`
+}
+
+// Buffer describes a tmux paste buffer
+type Buffer struct {
+	Name      string `json:"name"`
+	Size      int    `json:"size"` // Bytes
+	CreatedAt int64  `json:"created_at"`
+	Sample    string `json:"sample"` // Start of the content, tabs and newlines escaped
+}
+
+// ListBuffersResponse is the response for list_buffers
+type ListBuffersResponse struct {
+	Buffers []Buffer `json:"buffers"` // Most recent first
+}
+
+// BufferNamePayload is the payload for get_buffer message
+type BufferNamePayload struct {
+	Name string `json:"name,omitempty"` // Most recent buffer when empty
+}
+
+// GetBufferResponse is the response for get_buffer
+type GetBufferResponse struct {
+	Name    string `json:"name,omitempty"`
+	Content string `json:"content"`
+}
+
+// SetBufferPayload is the payload for set_buffer message
+type SetBufferPayload struct {
+	Name    string `json:"name,omitempty"` // tmux picks a name when empty
+	Content string `json:"content"`
+}
+
+// SetBufferResponse is the response for set_buffer
+type SetBufferResponse struct {
+	Success bool   `json:"success"`
+	Name    string `json:"name"`
+	Size    int    `json:"size"`
+}
+
+// PasteBufferPayload is the payload for paste_buffer message
+type PasteBufferPayload struct {
+	Name        string `json:"name,omitempty"` // Most recent buffer when empty
+	SessionName string `json:"session_name"`
+	WindowIndex *int   `json:"window_index,omitempty"`
+	PaneIndex   *int   `json:"pane_index,omitempty"`
+	Bracketed   *bool  `json:"bracketed,omitempty"` // Default true
+}
+
+// PasteBufferResponse is the response for paste_buffer
+type PasteBufferResponse struct {
+	Success bool `json:"success"`
+	PaneLocation
+	Name string `json:"name,omitempty"`
+}
+
+// CopySelectionPayload is the payload for copy_selection message
+// Lines are numbered from 1 in the pane's output as captured with Lines of
+// scrollback, like search_output numbers them; negative numbers count from
+// the last line.
+type CopySelectionPayload struct {
+	SessionName string `json:"session_name"`
+	WindowIndex *int   `json:"window_index,omitempty"`
+	PaneIndex   *int   `json:"pane_index,omitempty"`
+	StartLine   int    `json:"start_line"`
+	EndLine     int    `json:"end_line"`              // Inclusive, default the last line
+	Lines       int    `json:"lines,omitempty"`       // Scrollback captured, default 10000
+	BufferName  string `json:"buffer_name,omitempty"` // tmux picks a name when empty
+}
+
+// CopySelectionResponse is the response for copy_selection
+// Text is meant for the client's clipboard.
+type CopySelectionResponse struct {
+	PaneLocation
+	BufferName string `json:"buffer_name"`
+	StartLine  int    `json:"start_line"`
+	EndLine    int    `json:"end_line"`
+	LineCount  int    `json:"line_count"` // Lines in the captured output
+	Text       string `json:"text"`
+}
+
+// SendInputPayload is the payload for send_input message
+// Multi-line and long text is pasted through a paste buffer, as a bracketed
+// paste when the application in the pane supports it.
+type SendInputPayload struct {
+	SessionName string `json:"session_name"`
+	WindowIndex *int   `json:"window_index,omitempty"`
+	PaneIndex   *int   `json:"pane_index,omitempty"`
+	Text        string `json:"text"`
+	Execute     bool   `json:"execute,omitempty"` // Press Enter after the text; otherwise it is only inserted
+}
+
+// SendInputResponse is the response for send_input
+type SendInputResponse struct {
+	Success bool `json:"success"`
+	PaneLocation
+	Bytes int `json:"bytes"`
+}
+
+// Sizing policies, combining the viewports of the clients showing a session
+const (
+	SizeLargest  = "largest"
+	SizeSmallest = "smallest"
+	SizeLatest   = "latest" // The viewport reported last
+)
+
+// ResizePayload is the payload for resize message
+// Clients send it when they show a session and whenever their viewport
+// changes.
+type ResizePayload struct {
+	SessionName string `json:"session_name"`
+	Cols        int    `json:"cols"`
+	Rows        int    `json:"rows"`
+}
+
+// ResizeResponse is the response for resize
+type ResizeResponse struct {
+	Success     bool   `json:"success"`
+	SessionName string `json:"session_name"`
+	Cols        int    `json:"cols"` // Size the session's windows were set to
+	Rows        int    `json:"rows"`
+	Policy      string `json:"policy"`
+	Clients     int    `json:"clients"` // Clients showing the session
+}
+
+// AttachPayload is the payload for attach message
+// After the response the client receives the raw output of a tmux client as
+// binary frames and sends keyboard input as binary frames.
+type AttachPayload struct {
+	SessionName string `json:"session_name"`
+	Cols        int    `json:"cols"`
+	Rows        int    `json:"rows"`
+	Grouped     bool   `json:"grouped,omitempty"` // Attach to a new session grouped with session_name
+}
+
+// AttachResponse is the response for attach
+type AttachResponse struct {
+	Success         bool   `json:"success"`
+	SessionName     string `json:"session_name"`
+	AttachedSession string `json:"attached_session"` // The grouped session when grouped
+	Cols            int    `json:"cols"`
+	Rows            int    `json:"rows"`
+}
+
+// AttachResizePayload is the payload for attach_resize message
+type AttachResizePayload struct {
+	Cols int `json:"cols"`
+	Rows int `json:"rows"`
+}
+
+// AttachResizeResponse is the response for attach_resize
+type AttachResizeResponse struct {
+	Success bool `json:"success"`
+	Cols    int  `json:"cols"`
+	Rows    int  `json:"rows"`
+}
+
+// DetachResponse is the response for detach
+type DetachResponse struct {
+	Success  bool `json:"success"`
+	Detached bool `json:"detached"` // False when the client wasn't attached
+}
+
+// DetachedPayload is the payload for detached event, sent when the tmux
+// client exits on its own, e.g. because the session was killed
+type DetachedPayload struct {
+	SessionName     string `json:"session_name"`
+	AttachedSession string `json:"attached_session"`
+}
+
+// TmuxSocket is a tmux server sessions can be created on
+type TmuxSocket struct {
+	Name     string `json:"name"` // As used in Session.Socket, "" for the default server
+	Path     string `json:"path"`
+	Running  bool   `json:"running"` // A server is listening on the socket
+	Sessions int    `json:"sessions"`
+}
+
+// ListSocketsResponse is the response for list_sockets
+type ListSocketsResponse struct {
+	Sockets []TmuxSocket `json:"sockets"`
+}
+
+// RemoteHost is a host whose tmux server is managed over SSH
+type RemoteHost struct {
+	Name      string `json:"name"` // As used in Session.Host
+	Address   string `json:"address"`
+	Connected bool   `json:"connected"`
+	Sessions  int    `json:"sessions"`
+	Error     string `json:"error,omitempty"` // Why the host couldn't be reached
+}
+
+// ListHostsResponse is the response for list_hosts
+type ListHostsResponse struct {
+	Hosts []RemoteHost `json:"hosts"`
+}
+
 // ErrorPayload is the payload for error message
 type ErrorPayload struct {
 	Code              string `json:"code"`
@@ -238,4 +1399,17 @@ const (
 	ErrorCommandFailed        = "COMMAND_FAILED"
 	ErrorTmuxError            = "TMUX_ERROR"
 	ErrorInternalError        = "INTERNAL_ERROR"
+	ErrorInvalidRequest       = "INVALID_REQUEST"
+	ErrorScheduleNotFound     = "SCHEDULE_NOT_FOUND"
+	ErrorFeatureDisabled      = "FEATURE_DISABLED"
+	ErrorTemplateNotFound     = "TEMPLATE_NOT_FOUND"
+	ErrorSnapshotFailed       = "SNAPSHOT_FAILED"
+	ErrorPaneNotFound         = "PANE_NOT_FOUND"
+	ErrorWatcherNotFound      = "WATCHER_NOT_FOUND"
+	ErrorNoPrompt             = "NO_PROMPT"
+	ErrorRecordingNotFound    = "RECORDING_NOT_FOUND"
+	ErrorSnippetNotFound      = "SNIPPET_NOT_FOUND"
+	ErrorFileNotFound         = "FILE_NOT_FOUND"
+	ErrorAccessDenied         = "ACCESS_DENIED"
+	ErrorBufferNotFound       = "BUFFER_NOT_FOUND"
 )
@@ -0,0 +1,34 @@
+// Service worker showing HandX server notifications delivered with Web Push
+self.addEventListener('push', (event) => {
+  let data = {};
+  try {
+    data = event.data ? event.data.json() : {};
+  } catch {
+    data = { title: 'HandX', body: event.data ? event.data.text() : '' };
+  }
+
+  event.waitUntil(
+    self.registration.showNotification(data.title || 'HandX', {
+      body: data.body || '',
+      tag: data.session_name ? `${data.event}-${data.session_name}` : data.event,
+      data,
+    })
+  );
+});
+
+// Focus an open HandX window, or open the session in a new one
+self.addEventListener('notificationclick', (event) => {
+  event.notification.close();
+  const sessionName = event.notification.data && event.notification.data.session_name;
+
+  event.waitUntil(
+    self.clients.matchAll({ type: 'window', includeUncontrolled: true }).then((windows) => {
+      for (const client of windows) {
+        if ('focus' in client) {
+          return client.focus();
+        }
+      }
+      return self.clients.openWindow(sessionName ? `/terminal?session=${encodeURIComponent(sessionName)}` : '/');
+    })
+  );
+});
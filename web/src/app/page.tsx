@@ -3,7 +3,8 @@
 import { useEffect, useState, useRef } from 'react';
 import { useRouter } from 'next/navigation';
 import { WebSocketClient } from '@/lib/websocket';
-import { MessageType, Session, ListSessionsResponse, ConnectAckPayload, CreateSessionResponse, DeleteSessionPayload, RenameSessionPayload, RenameSessionResponse } from '@/types/message';
+import { enablePush, isPushSupported } from '@/lib/push';
+import { MessageType, Session, ListSessionsResponse, ConnectAckPayload, CreateSessionResponse, DeleteSessionPayload, RenameSessionPayload, RenameSessionResponse, GetPushConfigResponse } from '@/types/message';
 
 export default function Home() {
   const router = useRouter();
@@ -19,6 +20,8 @@ export default function Home() {
   const [longPressSessionId, setLongPressSessionId] = useState<string | null>(null);
   const longPressTimerRef = useRef<NodeJS.Timeout | null>(null);
   const [isMobile, setIsMobile] = useState(false);
+  const [pushKey, setPushKey] = useState('');
+  const [pushEnabled, setPushEnabled] = useState(false);
 
   // Client-side initialization
   useEffect(() => {
@@ -87,6 +90,7 @@ export default function Home() {
         setError('');
         // Request session list
         client.send(MessageType.LIST_SESSIONS, {});
+        client.send(MessageType.GET_PUSH_CONFIG, {});
       } else {
         setConnectionStatus('error');
         setError('Connection rejected by server');
@@ -131,6 +135,20 @@ export default function Home() {
       }
     });
 
+    // Handle push config; re-register silently when permission was granted before
+    client.on(MessageType.GET_PUSH_CONFIG_RESPONSE, (message) => {
+      const payload = message.payload as GetPushConfigResponse;
+      if (!payload.enabled || !payload.public_key || !isPushSupported()) {
+        return;
+      }
+      setPushKey(payload.public_key);
+      if (Notification.permission === 'granted') {
+        enablePush(client, payload.public_key).then(setPushEnabled).catch(() => {
+          // Push stays off if the browser refuses the subscription
+        });
+      }
+    });
+
     // Handle errors
     client.on(MessageType.ERROR, (message) => {
       setError((message.payload as { message?: string }).message || 'An error occurred');
@@ -224,6 +242,19 @@ export default function Home() {
     }
   };
 
+  const handleEnablePush = () => {
+    if (ws && pushKey) {
+      enablePush(ws, pushKey)
+        .then((enabled) => {
+          setPushEnabled(enabled);
+          if (!enabled) {
+            setError('Notifications were not allowed');
+          }
+        })
+        .catch(() => setError('Failed to enable notifications'));
+    }
+  };
+
   const handleRetry = () => {
     window.location.reload();
   };
@@ -331,6 +362,17 @@ export default function Home() {
                       <path strokeLinecap="round" strokeLinejoin="round" d="M4 4v5h.582m15.356 2A8.001 8.001 0 004.582 9m0 0H9m11 11v-5h-.581m0 0a8.003 8.003 0 01-15.357-2m15.357 2H15" />
                     </svg>
                   </button>
+                  {pushKey && (
+                    <button
+                      onClick={handleEnablePush}
+                      className={`w-8 h-8 rounded-lg flex items-center justify-center ${themes[theme].button} transition-all active:scale-95`}
+                      title={pushEnabled ? 'Notifications on' : 'Enable notifications'}
+                    >
+                      <svg className={`w-4 h-4 ${pushEnabled ? themes[theme].accent : themes[theme].textDim}`} fill="none" stroke="currentColor" viewBox="0 0 24 24" strokeWidth={2}>
+                        <path strokeLinecap="round" strokeLinejoin="round" d="M15 17h5l-1.405-1.405A2.032 2.032 0 0118 14.158V11a6.002 6.002 0 00-4-5.659V5a2 2 0 10-4 0v.341C7.67 6.165 6 8.388 6 11v3.159c0 .538-.214 1.055-.595 1.436L4 17h5m6 0v1a3 3 0 11-6 0v-1m6 0H9" />
+                      </svg>
+                    </button>
+                  )}
                 </div>
                 <button
                   onClick={handleCreateSession}
@@ -0,0 +1,46 @@
+import { WebSocketClient } from '@/lib/websocket';
+import { MessageType, PushSubscriptionPayload } from '@/types/message';
+
+// Whether this browser can receive Web Push notifications
+export function isPushSupported(): boolean {
+  return typeof window !== 'undefined' && 'serviceWorker' in navigator && 'PushManager' in window && 'Notification' in window;
+}
+
+// Convert the server's base64url VAPID key to the bytes PushManager expects
+function urlBase64ToUint8Array(base64String: string): Uint8Array {
+  const padding = '='.repeat((4 - (base64String.length % 4)) % 4);
+  const base64 = (base64String + padding).replace(/-/g, '+').replace(/_/g, '/');
+  const raw = window.atob(base64);
+  const output = new Uint8Array(raw.length);
+  for (let i = 0; i < raw.length; i++) {
+    output[i] = raw.charCodeAt(i);
+  }
+  return output;
+}
+
+// Subscribe this browser to server notifications
+// Asks for permission unless already granted, so call it from a user gesture.
+export async function enablePush(client: WebSocketClient, publicKey: string): Promise<boolean> {
+  if (!isPushSupported()) {
+    return false;
+  }
+
+  const permission = Notification.permission === 'granted' ? 'granted' : await Notification.requestPermission();
+  if (permission !== 'granted') {
+    return false;
+  }
+
+  const registration = await navigator.serviceWorker.register('/sw.js');
+  await navigator.serviceWorker.ready;
+
+  let subscription = await registration.pushManager.getSubscription();
+  if (!subscription) {
+    subscription = await registration.pushManager.subscribe({
+      userVisibleOnly: true,
+      applicationServerKey: urlBase64ToUint8Array(publicKey) as BufferSource,
+    });
+  }
+
+  client.send<PushSubscriptionPayload>(MessageType.SUBSCRIBE_PUSH, subscription.toJSON() as PushSubscriptionPayload);
+  return true;
+}
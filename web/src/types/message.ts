@@ -36,6 +36,14 @@ export enum MessageType {
   CAPTURE_OUTPUT = 'capture_output',
   CAPTURE_OUTPUT_RESPONSE = 'capture_output_response',
 
+  // Push Notifications
+  GET_PUSH_CONFIG = 'get_push_config',
+  GET_PUSH_CONFIG_RESPONSE = 'get_push_config_response',
+  SUBSCRIBE_PUSH = 'subscribe_push',
+  SUBSCRIBE_PUSH_RESPONSE = 'subscribe_push_response',
+  UNSUBSCRIBE_PUSH = 'unsubscribe_push',
+  UNSUBSCRIBE_PUSH_RESPONSE = 'unsubscribe_push_response',
+
   // Error
   ERROR = 'error',
 }
@@ -165,6 +173,24 @@ export interface CaptureOutputResponse {
   output: string;
 }
 
+export interface GetPushConfigResponse {
+  enabled: boolean;
+  public_key?: string;
+}
+
+export interface PushSubscriptionPayload {
+  endpoint: string;
+  keys: {
+    p256dh: string;
+    auth: string;
+  };
+}
+
+export interface SubscribePushResponse {
+  success: boolean;
+  endpoint: string;
+}
+
 export interface ErrorPayload {
   code: string;
   message: string;